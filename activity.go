@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Activity types: publishing a post, reacting to one and following a user.
+const (
+	ActivityPosted   = "posted"
+	ActivityReacted  = "reacted"
+	ActivityFollowed = "followed"
+)
+
+// Activity is one public action by a user, shown on their activity page.
+// PostID is set for posted and reacted, TargetUserID for followed, and
+// Reaction for reacted.
+type Activity struct {
+	ID           uint      `json:"-" gorm:"primary_key" bson:"_id"`
+	UserID       uint      `json:"-" gorm:"not null;index" bson:"user_id"`
+	Type         string    `json:"type" gorm:"size:16;not null" bson:"type"`
+	PostID       uint      `json:"-" gorm:"not null;default:0" bson:"post_id"`
+	TargetUserID uint      `json:"-" gorm:"not null;default:0" bson:"target_user_id"`
+	Reaction     string    `json:"reaction,omitempty" gorm:"size:16;not null;default:''" bson:"reaction"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	// Post and User, the followed user, are loaded for responses.
+	Post *Post `json:"post,omitempty" gorm:"-" bson:"-"`
+	User *User `json:"user,omitempty" gorm:"-" bson:"-"`
+}
+
+// ActivityRepository stores users' activity.
+type ActivityRepository interface {
+	Record(ctx context.Context, activity *Activity) error
+	// Remove deletes the activity matching activity's user, type, post,
+	// target user and reaction, for an action that was undone.
+	Remove(ctx context.Context, activity Activity) error
+	// List returns a user's activity, newest first. It honours opts.Offset
+	// and opts.Limit only.
+	List(ctx context.Context, userID uint, opts ListOptions) ([]Activity, error)
+	Count(ctx context.Context, userID uint) (int64, error)
+}
+
+// recordActivity records activity, or with undo set removes it, logging
+// rather than failing the request if it can't: the action itself has
+// already succeeded. Any earlier matching activity is replaced, so repeating
+// an action doesn't list it twice.
+func (a *API) recordActivity(c *gin.Context, activity Activity, undo bool) {
+	ctx := c.Request.Context()
+	err := a.activity.Remove(ctx, activity)
+	if err == nil && !undo {
+		err = a.activity.Record(ctx, &activity)
+	}
+	if err != nil {
+		logf(c.Request.Context(), "activity: failed to update %s activity of user %d: %v", activity.Type, activity.UserID, err)
+	}
+}
+
+// getFeed serves GET /feed, the listed posts of the users the caller
+// follows, newest first. It pages by cursor only: ?per_page= sets the
+// page size and ?cursor=, the next_cursor of the previous page, continues.
+// It takes ?fields= and ?expand= like GET /posts.
+func (a *API) getFeed(c *gin.Context) {
+	if _, ok := c.GetQuery("page"); ok {
+		respondError(c, http.StatusBadRequest, "page cannot be used with the feed; use cursor")
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(c, postFields)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c, postExpansions)
+	if !ok {
+		return
+	}
+	opts := ListOptions{Reverse: true, Limit: page.PerPage + 1}
+	if token, ok := c.GetQuery("cursor"); ok {
+		cursor, err := decodeCursor(token)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		opts.After = &cursor
+	}
+
+	ctx := c.Request.Context()
+	user, _ := currentUser(c)
+	follows, err := a.follows.Following(ctx, user.ID, ListOptions{})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch feed")
+		return
+	}
+	var posts []Post
+	if len(follows) > 0 {
+		authors := make([]uint, len(follows))
+		for i, follow := range follows {
+			authors[i] = follow.FolloweeID
+		}
+		opts.Posts = PostFilter{AuthorIDs: authors, ListedOnly: true}
+		if posts, err = a.posts.List(ctx, opts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch feed")
+			return
+		}
+	}
+
+	// One extra post was fetched to learn whether there is another page.
+	var next interface{}
+	if len(posts) > page.PerPage {
+		posts = posts[:page.PerPage]
+		next = encodeCursor(postCursor(posts[page.PerPage-1]))
+	}
+	if expand["author"] {
+		if err := a.expandAuthors(ctx, posts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+			return
+		}
+	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+
+	respond(c, http.StatusOK, "posts", projectAll(fields, posts), gin.H{
+		"count":       len(posts),
+		"next_cursor": next,
+	})
+}
+
+// getUserActivity serves GET /users/:id/activity, the user's public actions
+// a page at a time, newest first: posts they published, reactions they left
+// and users they followed. Actions on posts or users since deleted, or on
+// drafts the caller can't see, are left out of the page.
+func (a *API) getUserActivity(c *gin.Context) {
+	user, ok := a.pathUser(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	total, err := a.activity.Count(ctx, user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch activity")
+		return
+	}
+	activity, err := a.activity.List(ctx, user.ID, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch activity")
+		return
+	}
+
+	postIDs, userIDs := []uint{}, []uint{}
+	for _, item := range activity {
+		if item.PostID != 0 {
+			postIDs = append(postIDs, item.PostID)
+		}
+		if item.TargetUserID != 0 {
+			userIDs = append(userIDs, item.TargetUserID)
+		}
+	}
+	posts, err := a.posts.GetMany(ctx, postIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	users, err := a.users.GetMany(ctx, userIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	postsByID := make(map[uint]Post, len(posts))
+	for _, post := range posts {
+		if a.canViewPost(c, post) {
+			postsByID[post.ID] = post
+		}
+	}
+	usersByID := make(map[uint]User, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
+	}
+
+	items := []Activity{}
+	for _, item := range activity {
+		if item.PostID != 0 {
+			post, ok := postsByID[item.PostID]
+			if !ok {
+				continue
+			}
+			item.Post = &post
+		}
+		if item.TargetUserID != 0 {
+			target, ok := usersByID[item.TargetUserID]
+			if !ok {
+				continue
+			}
+			item.User = &target
+		}
+		items = append(items, item)
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "activity", items, gin.H{
+		"count":      len(items),
+		"pagination": page.meta(total),
+	})
+}
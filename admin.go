@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startedAt is when the process started, for the uptime in admin stats.
+var startedAt = time.Now()
+
+// isAdmin reports whether the request was authenticated as an admin.
+func isAdmin(c *gin.Context) bool {
+	user, ok := currentUser(c)
+	return ok && hasRole(user, RoleAdmin)
+}
+
+// includeDeleted reads the ?include_deleted flag. Only admins may set it;
+// for anyone else it responds with 403 and returns ok == false.
+func (a *API) includeDeleted(c *gin.Context) (include bool, ok bool) {
+	if c.Query("include_deleted") != "true" {
+		return false, true
+	}
+	if !isAdmin(c) {
+		a.deny(c, "include_deleted requires admin privileges")
+		return false, false
+	}
+	return true, true
+}
+
+// adminListUsers lists every user, soft-deleted ones included, a page at a
+// time.
+func (a *API) adminListUsers(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	sort, ok := parseSort(c, userSortFields)
+	if !ok {
+		return
+	}
+	filter, ok := parseUserFilter(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	opts := ListOptions{IncludeDeleted: true, Sort: sort, Users: filter}
+	total, err := a.users.Count(ctx, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	users, err := a.users.List(ctx, page.apply(opts))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+
+	respond(c, http.StatusOK, "users", users, gin.H{
+		"count":      len(users),
+		"pagination": page.meta(total),
+	})
+}
+
+// adminForceDeletePost permanently deletes a post, whether or not it has
+// been soft-deleted.
+func (a *API) adminForceDeletePost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.posts.GetIncludingDeleted(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	auditBefore(c, post)
+
+	if err := a.posts.ForceDelete(ctx, id); err != nil {
+		respondStoreError(c, err, "post", "delete")
+		return
+	}
+
+	respond(c, http.StatusOK, "", gin.H{"message": "Post permanently deleted"}, nil)
+}
+
+// adminImpersonate issues an access token for another user so admins can
+// see the API as they do. No refresh token is issued, so the session ends
+// when the access token expires.
+func (a *API) adminImpersonate(c *gin.Context) {
+	id, ok := a.userID(c)
+	if !ok {
+		return
+	}
+
+	user, err := a.users.Get(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+
+	token, expiresAt, err := a.tokens.Issue(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	admin, _ := currentUser(c)
+	a.audit(c, AuditImpersonation, admin, "impersonating "+user.Username+" ("+strconv.FormatUint(uint64(user.ID), 10)+")")
+
+	respond(c, http.StatusOK, "", gin.H{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": expiresAt,
+		"user":       user,
+	}, nil)
+}
+
+// adminStats reports record counts and process statistics.
+func (a *API) adminStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	var counts [4]int64
+	for i, count := range []func(context.Context, ListOptions) (int64, error){
+		a.users.Count, a.users.Count, a.posts.Count, a.posts.Count,
+	} {
+		n, err := count(ctx, ListOptions{IncludeDeleted: i%2 == 0})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to count records")
+			return
+		}
+		counts[i] = n
+	}
+	users, liveUsers, posts, livePosts := counts[0], counts[1], counts[2], counts[3]
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	respond(c, http.StatusOK, "", gin.H{
+		"users": gin.H{"total": users, "deleted": users - liveUsers},
+		"posts": gin.H{"total": posts, "deleted": posts - livePosts},
+		"runtime": gin.H{
+			"uptime_seconds": int(time.Since(startedAt).Seconds()),
+			"goroutines":     runtime.NumGoroutine(),
+			"heap_alloc":     mem.HeapAlloc,
+			"go_version":     runtime.Version(),
+		},
+	}, nil)
+}
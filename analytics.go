@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Engagement counters kept per post per day, named as their columns.
+const (
+	EngagementLikes     = "likes"
+	EngagementBookmarks = "bookmarks"
+)
+
+// DailyEngagement counts the likes and bookmarks a post gained on one day,
+// less those taken back that day, so either can be negative. Days are in
+// UTC, laid out as viewDay.
+type DailyEngagement struct {
+	ID        uint   `gorm:"primary_key" bson:"-"`
+	PostID    uint   `gorm:"not null;uniqueIndex:idx_daily_engagements_post_day,priority:1" bson:"post_id"`
+	Day       string `gorm:"size:10;not null;uniqueIndex:idx_daily_engagements_post_day,priority:2" bson:"day"`
+	Likes     int64  `gorm:"not null;default:0" bson:"likes"`
+	Bookmarks int64  `gorm:"not null;default:0" bson:"bookmarks"`
+}
+
+// EngagementRepository keeps the daily engagement counters of posts up to
+// date as users like and bookmark them.
+type EngagementRepository interface {
+	// Record adds delta to a post's counter on day. counter is
+	// EngagementLikes or EngagementBookmarks.
+	Record(ctx context.Context, postID uint, day, counter string, delta int64) error
+	// Daily returns a post's counters from day from on, oldest first. Days
+	// without changes are missing.
+	Daily(ctx context.Context, postID uint, from string) ([]DailyEngagement, error)
+}
+
+// EngagementBucket totals a post's engagement over one day or week starting
+// on Start.
+type EngagementBucket struct {
+	Start     string `json:"start"`
+	Views     int64  `json:"views"`
+	Likes     int64  `json:"likes"`
+	Bookmarks int64  `json:"bookmarks"`
+}
+
+// analyticsQuery is the query string of GET /posts/:id/analytics.
+type analyticsQuery struct {
+	Bucket string `form:"bucket" binding:"omitempty,oneof=day week"`
+	Days   int    `form:"days" binding:"omitempty,min=1,max=365"`
+}
+
+// countEngagement adds delta to the post's counter for today. Failing to is
+// logged but doesn't fail the request.
+func (a *API) countEngagement(c *gin.Context, postID uint, counter string, delta int64) {
+	day := time.Now().UTC().Format(viewDay)
+	if err := a.engagement.Record(c.Request.Context(), postID, day, counter, delta); err != nil {
+		logf(c.Request.Context(), "analytics: failed to count %s of post %d: %v", counter, postID, err)
+	}
+}
+
+// getPostAnalytics serves GET /posts/:id/analytics to the post's authors and
+// admins: its current totals, and the views, likes and bookmarks it gained
+// over the last ?days= days (30 by default) by ?bucket=day (the default) or
+// week, oldest first. Weeks start on Monday, so the first may start before
+// the range.
+func (a *API) getPostAnalytics(c *gin.Context) {
+	post, ok := a.modifiablePost(c)
+	if !ok {
+		return
+	}
+	query := analyticsQuery{Bucket: "day", Days: 30}
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, 1-query.Days)
+	views, err := a.views.Daily(ctx, post.ID, from.Format(viewDay))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch views")
+		return
+	}
+	engagement, err := a.engagement.Daily(ctx, post.ID, from.Format(viewDay))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch analytics")
+		return
+	}
+	viewCounts, err := a.views.Counts(ctx, []uint{post.ID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch views")
+		return
+	}
+	likes, err := a.reactions.Count(ctx, post.ID, ReactionLike)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch reactions")
+		return
+	}
+	bookmarks, err := a.bookmarks.Counts(ctx, []uint{post.ID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch bookmarks")
+		return
+	}
+
+	bucketStart := func(day time.Time) time.Time {
+		if query.Bucket == "week" {
+			return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		}
+		return day
+	}
+	buckets := []EngagementBucket{}
+	index := make(map[string]int)
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		start := bucketStart(day).Format(viewDay)
+		if len(buckets) == 0 || buckets[len(buckets)-1].Start != start {
+			buckets = append(buckets, EngagementBucket{Start: start})
+		}
+		index[day.Format(viewDay)] = len(buckets) - 1
+	}
+	for _, day := range views {
+		if i, ok := index[day.Day]; ok {
+			buckets[i].Views += day.Views
+		}
+	}
+	for _, day := range engagement {
+		if i, ok := index[day.Day]; ok {
+			buckets[i].Likes += day.Likes
+			buckets[i].Bookmarks += day.Bookmarks
+		}
+	}
+
+	respond(c, http.StatusOK, "", gin.H{
+		"totals": gin.H{
+			"views":     viewCounts[post.ID],
+			"likes":     likes,
+			"bookmarks": bookmarks[post.ID],
+		},
+		"bucket":  query.Bucket,
+		"buckets": buckets,
+	}, nil)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyPrefix starts every API key so leaked keys are easy to recognise.
+const apiKeyPrefix = "gga_"
+
+// contextAPIKeyKey is the gin context key authenticate stores the APIKey a
+// request was authenticated with under.
+const contextAPIKeyKey = "api_key"
+
+// API key scopes. A key can only be used on routes requiring one of its
+// scopes, on top of the role checks that apply to its user.
+const (
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+	ScopeUsersRead  = "users:read"
+	ScopeUsersAdmin = "users:admin"
+	// ScopeMessagesRead and ScopeMessagesWrite cover the key user's own
+	// direct messages.
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+	// ScopeAccountRead and ScopeAccountWrite cover the key user's own
+	// account: their profile, settings, follows, blocks, password and
+	// sign-out.
+	ScopeAccountRead  = "account:read"
+	ScopeAccountWrite = "account:write"
+	// ScopeKeysManage allows listing, creating and revoking the user's API
+	// keys.
+	ScopeKeysManage = "keys:manage"
+)
+
+// APIKey is a long-lived credential for machine clients, acting as the user
+// that created it. Only the SHA-256 hash of the key is stored; Prefix keeps
+// enough of it to tell keys apart in listings.
+type APIKey struct {
+	ID     uint   `json:"id" gorm:"primary_key" bson:"_id"`
+	UserID uint   `json:"-" gorm:"not null;index" bson:"user_id"`
+	Name   string `json:"name" gorm:"size:100;not null" bson:"name"`
+	Prefix string `json:"prefix" gorm:"size:16;not null" bson:"prefix"`
+	// Scopes limits what the key may be used for. Keys created before
+	// scopes existed have none and are unrestricted.
+	Scopes  []string `json:"scopes" gorm:"serializer:json;type:text" bson:"scopes"`
+	KeyHash string   `json:"-" gorm:"size:64;not null;uniqueIndex" bson:"key_hash"`
+	// RequireSignature keys must sign every request with SigningSecret;
+	// see verifySignature. The secret has to be kept as is to check
+	// signatures, unlike the key.
+	RequireSignature bool       `json:"require_signature" gorm:"not null;default:false" bson:"require_signature"`
+	SigningSecret    string     `json:"-" gorm:"size:64" bson:"signing_secret,omitempty"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	LastUsedAt       *time.Time `json:"last_used_at" bson:"last_used_at"`
+	RevokedAt        *time.Time `json:"revoked_at" bson:"revoked_at"`
+}
+
+// APIKeyRepository stores API keys.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	ListByUser(ctx context.Context, userID uint) ([]APIKey, error)
+	GetByHash(ctx context.Context, hash string) (APIKey, error)
+	// Revoke revokes the user's key with the given ID, returning ErrNotFound
+	// if the user has no such live key.
+	Revoke(ctx context.Context, id, userID uint) error
+	// Touch records that the key was just used.
+	Touch(ctx context.Context, id uint) error
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=posts:read posts:write users:read users:admin messages:read messages:write account:read account:write keys:manage"`
+	// RequireSignature gives the key a signing secret every request made
+	// with it must be signed with.
+	RequireSignature bool `json:"require_signature"`
+}
+
+// allows reports whether the key may be used where scope is required.
+func (k APIKey) allows(scope string) bool {
+	if k.Scopes == nil {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// currentAPIKey returns the API key the request was authenticated with, if
+// it was.
+func currentAPIKey(c *gin.Context) (APIKey, bool) {
+	value, ok := c.Get(contextAPIKeyKey)
+	if !ok {
+		return APIKey{}, false
+	}
+	key, ok := value.(APIKey)
+	return key, ok
+}
+
+// requireScope rejects requests authenticated with an API key that lacks
+// scope with 403. Other requests are left to the role checks.
+func (a *API) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := currentAPIKey(c); ok && !key.allows(scope) {
+			a.deny(c, "API key lacks the "+scope+" scope")
+			return
+		}
+		c.Next()
+	}
+}
+
+func (a *API) createAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	random, err := newRandomToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+	value := apiKeyPrefix + random
+
+	// A key can't mint a key more powerful than itself.
+	if parent, ok := currentAPIKey(c); ok {
+		for _, scope := range req.Scopes {
+			if !parent.allows(scope) {
+				a.deny(c, "API key lacks the "+scope+" scope")
+				return
+			}
+		}
+		if parent.RequireSignature && !req.RequireSignature {
+			a.deny(c, "An API key that signs its requests can only create keys that do too")
+			return
+		}
+	}
+
+	user, _ := currentUser(c)
+	key := APIKey{
+		UserID:  user.ID,
+		Name:    req.Name,
+		Prefix:  value[:12],
+		Scopes:  req.Scopes,
+		KeyHash: hashToken(value),
+	}
+	if req.RequireSignature {
+		key.RequireSignature = true
+		if key.SigningSecret, err = newRandomToken(); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to create API key")
+			return
+		}
+	}
+	if err := a.apiKeys.Create(c.Request.Context(), &key); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	a.audit(c, AuditAPIKeyCreated, user, "key "+strconv.FormatUint(uint64(key.ID), 10)+" ("+key.Name+")")
+
+	// The key itself, and its signing secret, are only ever shown in this
+	// response.
+	body := gin.H{
+		"id":                key.ID,
+		"name":              key.Name,
+		"prefix":            key.Prefix,
+		"scopes":            key.Scopes,
+		"require_signature": key.RequireSignature,
+		"key":               value,
+		"created_at":        key.CreatedAt,
+	}
+	if key.RequireSignature {
+		body["signing_secret"] = key.SigningSecret
+	}
+	respond(c, http.StatusCreated, "", body, nil)
+}
+
+func (a *API) getAPIKeys(c *gin.Context) {
+	user, _ := currentUser(c)
+	keys, err := a.apiKeys.ListByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch API keys")
+		return
+	}
+
+	respond(c, http.StatusOK, "api_keys", keys, gin.H{
+		"count": len(keys),
+	})
+}
+
+func (a *API) deleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	user, _ := currentUser(c)
+	if err := a.apiKeys.Revoke(c.Request.Context(), uint(id), user.ID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	a.audit(c, AuditAPIKeyRevoked, user, "key "+c.Param("id"))
+	respond(c, http.StatusOK, "", gin.H{"message": "API key revoked successfully"}, nil)
+}
+
+// authenticateAPIKey resolves an X-API-Key header value to the key and its
+// user. It returns ErrNotFound for unknown or revoked keys.
+func (a *API) authenticateAPIKey(ctx context.Context, value string) (User, APIKey, error) {
+	key, err := a.apiKeys.GetByHash(ctx, hashToken(value))
+	if err != nil {
+		return User{}, APIKey{}, err
+	}
+	if key.RevokedAt != nil {
+		return User{}, APIKey{}, ErrNotFound
+	}
+
+	user, err := a.users.Get(ctx, key.UserID)
+	if err != nil {
+		return User{}, APIKey{}, err
+	}
+
+	// Last-used tracking is informational; don't fail the request over it.
+	_ = a.apiKeys.Touch(ctx, key.ID)
+	return user, key, nil
+}
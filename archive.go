@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// archivePost serves POST /posts/:id/archive.
+func (a *API) archivePost(c *gin.Context) {
+	a.changeArchived(c, true)
+}
+
+// unarchivePost serves POST /posts/:id/unarchive.
+func (a *API) unarchivePost(c *gin.Context) {
+	a.changeArchived(c, false)
+}
+
+// changeArchived archives or unarchives the :id post on behalf of one of its
+// authors or an admin. A post already in that state is returned unchanged.
+func (a *API) changeArchived(c *gin.Context, archived bool) {
+	post, ok := a.modifiablePost(c)
+	if !ok {
+		return
+	}
+	if !a.checkPostPreconditions(c, post) {
+		return
+	}
+
+	if (post.ArchivedAt != nil) != archived {
+		post.ArchivedAt = nil
+		if archived {
+			now := time.Now()
+			post.ArchivedAt = &now
+		}
+		if err := a.posts.Update(c.Request.Context(), &post); err != nil {
+			respondStoreError(c, err, "post", "update")
+			return
+		}
+	}
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// checkNotArchived responds with 409 and returns false if post is archived,
+// for changes archived posts don't allow.
+func checkNotArchived(c *gin.Context, post Post) bool {
+	if post.ArchivedAt != nil {
+		respondError(c, http.StatusConflict, "Post is archived; unarchive it first")
+		return false
+	}
+	return true
+}
+
+// editablePost is modifiablePost for changes to the post's content, which
+// archived posts don't allow.
+func (a *API) editablePost(c *gin.Context) (Post, bool) {
+	post, ok := a.modifiablePost(c)
+	if !ok || !checkNotArchived(c, post) {
+		return Post{}, false
+	}
+	return post, true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Security audit event types.
+const (
+	AuditLogin            = "login"
+	AuditLoginFailed      = "login_failed"
+	AuditLoginLocked      = "login_locked"
+	AuditLogout           = "logout"
+	AuditTokenRefreshed   = "token_refreshed"
+	AuditTokenReused      = "token_reused"
+	AuditPasswordChanged  = "password_changed"
+	AuditPermissionDenied = "permission_denied"
+	AuditAPIKeyCreated    = "api_key_created"
+	AuditAPIKeyRevoked    = "api_key_revoked"
+	AuditImpersonation    = "impersonation"
+	// AuditMutation records a change made through the API; see
+	// auditMutations.
+	AuditMutation = "mutation"
+)
+
+// AuditEvent is an entry in the security audit log. UserID is nil when the
+// actor is unknown, e.g. a failed login for a username that doesn't exist.
+type AuditEvent struct {
+	ID        uint      `json:"id" gorm:"primary_key" bson:"_id"`
+	Type      string    `json:"type" gorm:"size:32;not null;index" bson:"type"`
+	UserID    *uint     `json:"user_id" gorm:"index" bson:"user_id"`
+	Username  string    `json:"username" gorm:"size:255" bson:"username"`
+	IP        string    `json:"ip" gorm:"size:64" bson:"ip"`
+	Detail    string    `json:"detail" gorm:"type:text" bson:"detail"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index" bson:"created_at"`
+	// Route, EntityID and Changes are set on mutation events: the route as
+	// registered ("PATCH /posts/:id"), the ID of the record changed and how
+	// its fields changed.
+	Route    string                 `json:"route,omitempty" gorm:"size:128;index" bson:"route,omitempty"`
+	EntityID string                 `json:"entity_id,omitempty" gorm:"size:64;index" bson:"entity_id,omitempty"`
+	Changes  map[string]AuditChange `json:"changes,omitempty" gorm:"serializer:json;type:text" bson:"changes,omitempty"`
+}
+
+// AuditQuery narrows what AuditRepository.List returns. Zero fields don't
+// filter.
+type AuditQuery struct {
+	From     time.Time
+	To       time.Time
+	Type     string
+	UserID   *uint
+	Route    string
+	EntityID string
+	Limit    int
+}
+
+// AuditRepository stores audit events. It is append-only: there is no way to
+// change or remove an event through it.
+type AuditRepository interface {
+	Append(ctx context.Context, event *AuditEvent) error
+	// List returns matching events, newest first.
+	List(ctx context.Context, query AuditQuery) ([]AuditEvent, error)
+}
+
+// audit records an event for the request. user may be the zero User when
+// the actor is unknown. Failing to record is logged but doesn't fail the
+// request.
+func (a *API) audit(c *gin.Context, eventType string, user User, detail string) {
+	event := AuditEvent{
+		Type:     eventType,
+		Username: user.Username,
+		IP:       c.ClientIP(),
+		Detail:   detail,
+	}
+	if user.ID != 0 {
+		id := user.ID
+		event.UserID = &id
+	}
+	if err := a.auditLog.Append(c.Request.Context(), &event); err != nil {
+		logf(c.Request.Context(), "audit: failed to record %s event: %v", eventType, err)
+	}
+}
+
+// deny responds 403 with message and records the denial.
+func (a *API) deny(c *gin.Context, message string) {
+	user, _ := currentUser(c)
+	a.audit(c, AuditPermissionDenied, user, c.Request.Method+" "+c.FullPath()+": "+message)
+	abortWithError(c, http.StatusForbidden, message)
+}
+
+// auditQuery is the query string GET /admin/audit filters by.
+type auditQuery struct {
+	From     time.Time `form:"from"`
+	To       time.Time `form:"to"`
+	Type     string    `form:"type"`
+	UserID   *uint     `form:"user_id" binding:"omitempty,min=1"`
+	Route    string    `form:"route"`
+	EntityID string    `form:"entity_id"`
+	Limit    *int      `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// listAuditEvents serves the audit log to admins, filtered by the from and
+// to (RFC 3339), type, user_id, route and entity_id query parameters.
+func (a *API) listAuditEvents(c *gin.Context) {
+	var params auditQuery
+	if !bindQuery(c, &params) {
+		return
+	}
+	query := AuditQuery{
+		From:     params.From,
+		To:       params.To,
+		Type:     params.Type,
+		UserID:   params.UserID,
+		Route:    params.Route,
+		EntityID: params.EntityID,
+		Limit:    100,
+	}
+	if params.Limit != nil {
+		query.Limit = *params.Limit
+	}
+
+	events, err := a.auditLog.List(c.Request.Context(), query)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch audit events")
+		return
+	}
+
+	respond(c, http.StatusOK, "events", events, gin.H{
+		"count": len(events),
+	})
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// contextUserKey is the gin context key requireAuth stores the
+// authenticated User under.
+const contextUserKey = "user"
+
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// tokenIssuer signs and verifies the HS256 access tokens handed out by the
+// auth endpoints. The token subject is the user's primary key. It also
+// carries the lifetime of the refresh tokens issued alongside them.
+type tokenIssuer struct {
+	secret     []byte
+	ttl        time.Duration
+	refreshTTL time.Duration
+}
+
+// newTokenIssuer reads JWT_SECRET, JWT_TTL and REFRESH_TOKEN_TTL. Without a secret a random one
+// is generated, so tokens stop working when the process restarts.
+func newTokenIssuer() *tokenIssuer {
+	secret := []byte(os.Getenv("JWT_SECRET"))
+	if len(secret) == 0 {
+		log.Println("JWT_SECRET is not set; using a random secret, tokens will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("failed to generate JWT secret: %v", err)
+		}
+	}
+	return &tokenIssuer{
+		secret:     secret,
+		ttl:        envDuration("JWT_TTL", 15*time.Minute),
+		refreshTTL: envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	}
+}
+
+// accessClaims are the parts of a verified access token the API uses.
+type accessClaims struct {
+	UserID uint
+	// ID is the token's unique jti, used to revoke it.
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Issue returns a signed token for user and its expiry time.
+func (t *tokenIssuer) Issue(user User) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(t.ttl)
+	claims := jwt.RegisteredClaims{
+		ID:        newUUID(),
+		Subject:   strconv.FormatUint(uint64(user.ID), 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+	return token, expiresAt, err
+}
+
+// Parse verifies token and returns its claims.
+func (t *tokenIssuer) Parse(token string) (accessClaims, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return accessClaims{}, err
+	}
+
+	id, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return accessClaims{}, errors.New("invalid token subject")
+	}
+	if claims.ID == "" {
+		return accessClaims{}, errors.New("token has no jti")
+	}
+	parsed := accessClaims{UserID: uint(id), ID: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+	if claims.IssuedAt != nil {
+		parsed.IssuedAt = claims.IssuedAt.Time
+	}
+	return parsed, nil
+}
+
+func (a *API) register(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := checkPasswordPolicy(req.Password, User{Username: req.Username, Email: req.Email}); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	user := User{
+		Username:     req.Username,
+		Email:        req.Email,
+		Role:         defaultRole,
+		PasswordHash: hash,
+	}
+
+	if err := a.users.Create(c.Request.Context(), &user); err != nil {
+		if errors.Is(err, ErrConflict) {
+			respondError(c, http.StatusConflict, "User already exists")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	a.sendVerificationEmail(c.Request.Context(), user)
+	a.respondWithTokens(c, http.StatusCreated, user, newUUID())
+}
+
+func (a *API) login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, ok := a.checkCredentials(c, req)
+	if !ok {
+		return
+	}
+
+	a.respondWithTokens(c, http.StatusOK, user, newUUID())
+}
+
+// checkCredentials returns the user the login request identifies, writing
+// the error response and returning ok == false if the credentials are
+// wrong or the account or client is locked out after too many failures.
+func (a *API) checkCredentials(c *gin.Context, req LoginRequest) (user User, ok bool) {
+	ip := c.ClientIP()
+	if wait := a.loginThrottle.locked(req.Username, ip); wait > 0 {
+		a.audit(c, AuditLoginLocked, User{Username: req.Username}, "")
+		setRetryAfter(c, wait)
+		respondError(c, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		return User{}, false
+	}
+
+	user, err := a.users.GetByUsername(c.Request.Context(), req.Username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		respondError(c, http.StatusInternalServerError, "Failed to log in")
+		return User{}, false
+	}
+	if err != nil || user.PasswordHash == "" || !checkPassword(user.PasswordHash, req.Password) {
+		a.loginThrottle.fail(req.Username, ip)
+		a.audit(c, AuditLoginFailed, User{ID: user.ID, Username: req.Username}, "")
+		respondError(c, http.StatusUnauthorized, "Invalid username or password")
+		return User{}, false
+	}
+	a.loginThrottle.succeed(req.Username)
+	a.audit(c, AuditLogin, user, "password")
+
+	// Upgrade hashes made with an older algorithm or weaker parameters
+	// while we have the plaintext. Failing to do so is not fatal.
+	if passwordNeedsRehash(user.PasswordHash) {
+		if hash, err := hashPassword(req.Password); err == nil {
+			updated := user
+			updated.PasswordHash = hash
+			if err := a.users.Update(c.Request.Context(), &updated); err == nil {
+				user = updated
+			}
+		}
+	}
+	return user, true
+}
+
+// respondWithTokens issues an access token and a refresh token in the given
+// family for user and writes them as the response.
+func (a *API) respondWithTokens(c *gin.Context, status int, user User, familyID string) {
+	token, expiresAt, err := a.tokens.Issue(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	refreshToken, stored, err := a.issueRefreshToken(c.Request.Context(), user.ID, familyID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	respond(c, status, "", gin.H{
+		"token":              token,
+		"token_type":         "Bearer",
+		"expires_at":         expiresAt.UTC(),
+		"refresh_token":      refreshToken,
+		"refresh_expires_at": stored.ExpiresAt.UTC(),
+		"user":               user,
+	}, nil)
+}
+
+// requireAuth rejects requests without a valid bearer token, X-API-Key
+// header or session cookie and stores the authenticated user in the context for currentUser.
+func (a *API) requireAuth(c *gin.Context) {
+	if !a.authenticate(c, true) {
+		return
+	}
+	c.Next()
+}
+
+// optionalAuth authenticates requests that carry credentials, as
+// requireAuth does, and lets anonymous requests through. Invalid
+// credentials are still rejected rather than silently ignored.
+func (a *API) optionalAuth(c *gin.Context) {
+	if !a.authenticate(c, false) {
+		return
+	}
+	c.Next()
+}
+
+// authenticate stores the user identified by the request's credentials in
+// the context and applies their rate limit. If the request has no
+// credentials it succeeds only when required is false; otherwise it aborts
+// with an error response.
+func (a *API) authenticate(c *gin.Context, required bool) bool {
+	ctx := c.Request.Context()
+	key := c.GetHeader("X-API-Key")
+	header := c.GetHeader("Authorization")
+
+	var user User
+	var err error
+	switch {
+	case key != "":
+		var apiKey APIKey
+		user, apiKey, err = a.authenticateAPIKey(ctx, key)
+		if err == nil && apiKey.RequireSignature && !a.verifySignature(c, apiKey) {
+			return false
+		}
+		if err == nil {
+			c.Set(contextAPIKeyKey, apiKey)
+		}
+	case header != "":
+		user, err = a.authenticateBearer(ctx, header)
+	default:
+		var found bool
+		user, found, err = a.authenticateSession(c)
+		if !found {
+			err = ErrNotFound
+		}
+		// Anonymous requests, including browsers with a stale session
+		// cookie, may still use public endpoints.
+		if errors.Is(err, ErrNotFound) && !required {
+			return true
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			abortWithError(c, http.StatusUnauthorized, "Missing or invalid credentials")
+			return false
+		}
+		abortWithError(c, http.StatusInternalServerError, "Failed to authenticate")
+		return false
+	}
+
+	c.Set(contextUserKey, user)
+	return a.rateLimits.allow(c)
+}
+
+// authenticateBearer resolves an Authorization header carrying one of our
+// access tokens, or a token from the OIDC provider if one is configured, to
+// its user. It returns ErrNotFound for missing, malformed, expired,
+// revoked or otherwise invalid tokens.
+func (a *API) authenticateBearer(ctx context.Context, header string) (User, error) {
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
+		return User{}, ErrNotFound
+	}
+
+	claims, err := a.tokens.Parse(token)
+	if err != nil {
+		if a.oidc != nil {
+			return a.authenticateOIDC(ctx, token)
+		}
+		return User{}, ErrNotFound
+	}
+	revoked, err := a.revocations.IsRevoked(ctx, claims)
+	if err != nil {
+		return User{}, err
+	}
+	if revoked {
+		return User{}, ErrNotFound
+	}
+	return a.users.Get(ctx, claims.UserID)
+}
+
+// currentUser returns the user authenticated by requireAuth or optionalAuth.
+func currentUser(c *gin.Context) (User, bool) {
+	value, ok := c.Get(contextUserKey)
+	if !ok {
+		return User{}, false
+	}
+	user, ok := value.(User)
+	return user, ok
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseIDList reads ?ids=, a comma-separated list of between 1 and
+// maxBulkItems public IDs of kind records. On invalid input it writes the
+// error response and returns ok == false.
+func parseIDList(c *gin.Context, kind string) (params []string, ok bool) {
+	for _, param := range strings.Split(c.Query("ids"), ",") {
+		if param = strings.TrimSpace(param); param != "" {
+			params = append(params, param)
+		}
+	}
+	if len(params) == 0 || len(params) > maxBulkItems {
+		respondError(c, http.StatusBadRequest, "ids must list between 1 and "+strconv.Itoa(maxBulkItems)+" "+kind+" IDs")
+		return nil, false
+	}
+	return params, true
+}
+
+// fetchByIDs loads the kind records listed in ?ids= with a single getMany,
+// in the order requested and without repeats. The public IDs that match no
+// live record are returned in missing. It writes the error response and
+// returns ok == false if the list or an ID in it is invalid, or for paging
+// or sorting, which a fixed list of IDs has no use for.
+func fetchByIDs[T any](c *gin.Context, kind string, resolve func(context.Context, string) (uint, error), getMany func(context.Context, []uint) ([]T, error), idOf func(T) uint) (records []T, missing []string, ok bool) {
+	for _, param := range []string{"page", "per_page", "sort", "cursor"} {
+		if _, set := c.GetQuery(param); set {
+			respondError(c, http.StatusBadRequest, param+" cannot be combined with ids")
+			return nil, nil, false
+		}
+	}
+	params, ok := parseIDList(c, kind)
+	if !ok {
+		return nil, nil, false
+	}
+
+	ctx := c.Request.Context()
+	ids := make([]uint, len(params))
+	var lookup []uint
+	for i, param := range params {
+		id, err := parseID(ctx, param, resolve)
+		switch {
+		case errors.Is(err, errInvalidID):
+			respondError(c, http.StatusBadRequest, "Invalid "+kind+" ID "+strconv.Quote(param))
+			return nil, nil, false
+		case errors.Is(err, ErrNotFound):
+			continue
+		case err != nil:
+			respondError(c, http.StatusInternalServerError, "Failed to fetch "+kind+"s")
+			return nil, nil, false
+		}
+		ids[i] = id
+		lookup = append(lookup, id)
+	}
+
+	found := make(map[uint]T)
+	if len(lookup) > 0 {
+		fetched, err := getMany(ctx, lookup)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch "+kind+"s")
+			return nil, nil, false
+		}
+		for _, record := range fetched {
+			found[idOf(record)] = record
+		}
+	}
+
+	records, missing = []T{}, []string{}
+	seen := make(map[uint]bool)
+	for i, param := range params {
+		record, exists := found[ids[i]]
+		switch {
+		case !exists:
+			missing = append(missing, param)
+		case !seen[ids[i]]:
+			seen[ids[i]] = true
+			records = append(records, record)
+		}
+	}
+	return records, missing, true
+}
+
+// getUsersByID serves GET /users?ids=1,5,9.
+func (a *API) getUsersByID(c *gin.Context, fields fieldSet, expand map[string]bool) {
+	users, missing, ok := fetchByIDs(c, "user", a.users.ResolveUUID, a.users.GetMany, func(user User) uint { return user.ID })
+	if !ok {
+		return
+	}
+	if expand["posts"] {
+		if err := a.expandPosts(c.Request.Context(), users); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+			return
+		}
+	}
+	if err := a.countFollows(c.Request.Context(), users); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch follows")
+		return
+	}
+
+	respondCacheable(c, render(c, "users", projectAll(fields, users), gin.H{
+		"count":   len(users),
+		"missing": missing,
+	}))
+}
+
+// getPostsByID serves GET /posts?ids=1,5,9, and the same for a user's posts
+// when author is set, where other authors' posts count as missing. So do
+// drafts the caller can't see.
+func (a *API) getPostsByID(c *gin.Context, author *User, fields fieldSet, expand map[string]bool) {
+	getMany := func(ctx context.Context, ids []uint) ([]Post, error) {
+		posts, err := a.posts.GetMany(ctx, ids)
+		visible := posts[:0]
+		for _, post := range posts {
+			if (author == nil || post.AuthorID == author.ID) && a.canViewPost(c, post) {
+				visible = append(visible, post)
+			}
+		}
+		return visible, err
+	}
+
+	posts, missing, ok := fetchByIDs(c, "post", a.posts.ResolveUUID, getMany, func(post Post) uint { return post.ID })
+	if !ok {
+		return
+	}
+	if expand["author"] {
+		if err := a.expandAuthors(c.Request.Context(), posts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+			return
+		}
+	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+
+	respondCacheable(c, render(c, "posts", projectAll(fields, posts), gin.H{
+		"count":   len(posts),
+		"missing": missing,
+	}))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Block records that a user blocked another. Their posts are left out of
+// the blocker's listings, and neither can follow or message the other.
+type Block struct {
+	ID        uint      `gorm:"primary_key" bson:"_id"`
+	BlockerID uint      `gorm:"not null;uniqueIndex:idx_blocks_blocker_blocked,priority:1" bson:"blocker_id"`
+	BlockedID uint      `gorm:"not null;uniqueIndex:idx_blocks_blocker_blocked,priority:2;index" bson:"blocked_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// BlockRepository stores blocks. Add does nothing if the user is already
+// blocked, and Remove does nothing if they weren't, so both are safe to
+// repeat.
+type BlockRepository interface {
+	Add(ctx context.Context, block *Block) error
+	Remove(ctx context.Context, blockerID, blockedID uint) error
+	// List returns the blocks blockerID made, newest first. It honours
+	// opts.Offset and opts.Limit only.
+	List(ctx context.Context, blockerID uint, opts ListOptions) ([]Block, error)
+	Count(ctx context.Context, blockerID uint) (int64, error)
+	// Blocked returns the IDs of the users blockerID blocked.
+	Blocked(ctx context.Context, blockerID uint) ([]uint, error)
+	// Between reports whether either user blocked the other.
+	Between(ctx context.Context, a, b uint) (bool, error)
+}
+
+// blockUser serves POST /users/:id/block, blocking the user for the caller,
+// and DELETE /users/:id/block, unblocking them. Blocking also removes any
+// follows between the two. Both respond with the user, and repeating either
+// changes nothing.
+func (a *API) blockUser(c *gin.Context) {
+	user, ok := a.pathUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	blocker, _ := currentUser(c)
+	if blocker.ID == user.ID {
+		respondError(c, http.StatusBadRequest, "You can't block yourself")
+		return
+	}
+	if c.Request.Method == http.MethodDelete {
+		if err := a.blocks.Remove(ctx, blocker.ID, user.ID); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to update blocks")
+			return
+		}
+		respond(c, http.StatusOK, "", user, nil)
+		return
+	}
+
+	if err := a.blocks.Add(ctx, &Block{BlockerID: blocker.ID, BlockedID: user.ID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update blocks")
+		return
+	}
+	for _, pair := range [][2]uint{{blocker.ID, user.ID}, {user.ID, blocker.ID}} {
+		if err := a.follows.Remove(ctx, pair[0], pair[1]); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to update follows")
+			return
+		}
+		a.recordActivity(c, Activity{UserID: pair[0], Type: ActivityFollowed, TargetUserID: pair[1]}, true)
+	}
+
+	if !a.countUserFollows(c, &user) {
+		return
+	}
+	respond(c, http.StatusOK, "", user, nil)
+}
+
+// getMyBlocks serves GET /users/me/blocks, the users the caller blocked a
+// page at a time, most recently blocked first. Users since deleted are left
+// out of the page.
+func (a *API) getMyBlocks(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, _ := currentUser(c)
+	total, err := a.blocks.Count(ctx, user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch blocks")
+		return
+	}
+	blocks, err := a.blocks.List(ctx, user.ID, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch blocks")
+		return
+	}
+
+	ids := make([]uint, len(blocks))
+	for i, block := range blocks {
+		ids[i] = block.BlockedID
+	}
+	found, err := a.users.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	byID := make(map[uint]User, len(found))
+	for _, u := range found {
+		byID[u.ID] = u
+	}
+	users := []User{}
+	for _, id := range ids {
+		if u, ok := byID[id]; ok {
+			users = append(users, u)
+		}
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "users", users, gin.H{
+		"count":      len(users),
+		"pagination": page.meta(total),
+	})
+}
+
+// hideBlocked narrows filter to leave out the posts of the users the caller
+// blocked. It writes the error response and returns false if it can't load
+// them.
+func (a *API) hideBlocked(c *gin.Context, filter *PostFilter) bool {
+	user, ok := currentUser(c)
+	if !ok {
+		return true
+	}
+	blocked, err := a.blocks.Blocked(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch blocks")
+		return false
+	}
+	filter.ExcludeAuthorIDs = blocked
+	return true
+}
+
+// checkNotBlocked responds 403 with message and returns false if the caller
+// and other have blocked one another in either direction.
+func (a *API) checkNotBlocked(c *gin.Context, other User, message string) bool {
+	user, _ := currentUser(c)
+	blocked, err := a.blocks.Between(c.Request.Context(), user.ID, other.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch blocks")
+		return false
+	}
+	if blocked {
+		respondError(c, http.StatusForbidden, message)
+		return false
+	}
+	return true
+}
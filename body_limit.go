@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limitBodySize caps request bodies at maxBytes, or for the routes in
+// perRoute, keyed like "POST /posts/:id/media", at their own limit. Bodies
+// that declare a larger Content-Length are refused with 413 straight away;
+// others are cut off once they exceed it, and the handler reading them
+// responds 413 (see bodyTooLarge). A limit of 0 or less disables the cap.
+func limitBodySize(defaultMax int64, perRoute map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxBytes, ok := perRoute[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			maxBytes = defaultMax
+		}
+		if maxBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			abortWithError(c, http.StatusRequestEntityTooLarge, bodyTooLargeMessage(maxBytes))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// bodyTooLarge reports whether err came from reading past the body size
+// limit, and if so writes the 413 response.
+func bodyTooLarge(c *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	abortWithError(c, http.StatusRequestEntityTooLarge, bodyTooLargeMessage(tooLarge.Limit))
+	return true
+}
+
+func bodyTooLargeMessage(maxBytes int64) string {
+	return "Request body must be at most " + strconv.FormatInt(maxBytes, 10) + " bytes"
+}
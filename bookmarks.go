@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bookmark records that a user saved a post for later.
+type Bookmark struct {
+	ID        uint      `gorm:"primary_key" bson:"_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_bookmarks_user_post,priority:1" bson:"user_id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_bookmarks_user_post,priority:2;index" bson:"post_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// BookmarkRepository stores bookmarks. Add does nothing if the user already
+// bookmarked the post, and Remove does nothing if they hadn't, so both are
+// safe to repeat; each reports whether it changed anything.
+type BookmarkRepository interface {
+	Add(ctx context.Context, bookmark *Bookmark) (bool, error)
+	Remove(ctx context.Context, userID, postID uint) (bool, error)
+	// List returns a user's bookmarks, newest first. It honours opts.Offset
+	// and opts.Limit only.
+	List(ctx context.Context, userID uint, opts ListOptions) ([]Bookmark, error)
+	Count(ctx context.Context, userID uint) (int64, error)
+	// Counts tallies how many users bookmarked each of postIDs. Posts nobody
+	// bookmarked are missing from the result.
+	Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error)
+}
+
+// bookmarkPost serves POST /posts/:id/bookmark, saving the post for the
+// caller, and DELETE /posts/:id/bookmark, removing it. Both respond with the
+// post, and repeating either changes nothing.
+func (a *API) bookmarkPost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.visiblePost(c, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+
+	user, _ := currentUser(c)
+	var changed bool
+	delta := int64(1)
+	if c.Request.Method == http.MethodDelete {
+		changed, err = a.bookmarks.Remove(ctx, user.ID, post.ID)
+		delta = -1
+	} else {
+		changed, err = a.bookmarks.Add(ctx, &Bookmark{UserID: user.ID, PostID: post.ID})
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update bookmarks")
+		return
+	}
+	if changed {
+		a.countEngagement(c, post.ID, EngagementBookmarks, delta)
+	}
+
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// getMyBookmarks serves GET /users/me/bookmarks, the posts the caller saved
+// a page at a time, most recently saved first. Posts since deleted or turned
+// back into drafts are left out of the page.
+func (a *API) getMyBookmarks(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, _ := currentUser(c)
+	total, err := a.bookmarks.Count(ctx, user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch bookmarks")
+		return
+	}
+	bookmarks, err := a.bookmarks.List(ctx, user.ID, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch bookmarks")
+		return
+	}
+
+	ids := make([]uint, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		ids[i] = bookmark.PostID
+	}
+	found, err := a.posts.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	byID := make(map[uint]Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+	posts := []Post{}
+	for _, id := range ids {
+		if post, ok := byID[id]; ok && a.canViewPost(c, post) {
+			posts = append(posts, post)
+		}
+	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "posts", posts, gin.H{
+		"count":      len(posts),
+		"pagination": page.meta(total),
+	})
+}
+
+// countBookmarks fills in the bookmark counts of the posts the caller may
+// see them on: their own, or any post for admins. Others' posts are left
+// without a count.
+func (a *API) countBookmarks(c *gin.Context, posts []Post) error {
+	user, ok := currentUser(c)
+	if !ok {
+		return nil
+	}
+	var ids []uint
+	for _, post := range posts {
+		if post.AuthorID == user.ID || hasRole(user, RoleAdmin) {
+			ids = append(ids, post.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	counts, err := a.bookmarks.Counts(c.Request.Context(), ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		if post := posts[i]; post.AuthorID == user.ID || hasRole(user, RoleAdmin) {
+			count := counts[post.ID]
+			posts[i].BookmarksCount = &count
+		}
+	}
+	return nil
+}
+
+// fillPostCounts fills in the reaction and view counts of posts, the
+// bookmark counts the caller may see, their authors, the users they
+// mention, their polls, their places in their series and whether they are
+// pinned, writing the error response and returning false if it can't.
+func (a *API) fillPostCounts(c *gin.Context, posts []Post) bool {
+	if err := a.countReactions(c.Request.Context(), posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch reactions")
+		return false
+	}
+	if err := a.countViews(c.Request.Context(), posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch views")
+		return false
+	}
+	if err := a.countBookmarks(c, posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch bookmarks")
+		return false
+	}
+	if err := a.fillAuthors(c.Request.Context(), posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+		return false
+	}
+	if err := a.fillMentions(c.Request.Context(), posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch mentions")
+		return false
+	}
+	if err := a.fillPolls(c, posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch polls")
+		return false
+	}
+	if err := a.fillSeries(c, posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch series")
+		return false
+	}
+	if err := a.fillPins(c.Request.Context(), posts); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch pins")
+		return false
+	}
+	return true
+}
+
+// fillPostCount is fillPostCounts for a single post.
+func (a *API) fillPostCount(c *gin.Context, post *Post) bool {
+	posts := []Post{*post}
+	if !a.fillPostCounts(c, posts) {
+		return false
+	}
+	*post = posts[0]
+	return true
+}
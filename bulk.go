@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// maxBulkItems caps how many items one bulk request may carry.
+const maxBulkItems = 100
+
+// bulkResult reports the outcome for one item of a bulk request. Status is
+// the HTTP status the item would have had as a request of its own.
+type bulkResult struct {
+	Index  int       `json:"index"`
+	Status int       `json:"status"`
+	User   *User     `json:"user,omitempty"`
+	Error  *APIError `json:"error,omitempty"`
+}
+
+// fail records that the item failed with the given status.
+func (r *bulkResult) fail(c *gin.Context, status int, message string) {
+	err := newAPIError(c, status, message, nil)
+	r.Status, r.Error = status, &err
+}
+
+// failBinding records that the item failed to bind, as respondBindError
+// would report it.
+func (r *bulkResult) failBinding(c *gin.Context, err error) {
+	message, fields := describeBindError(err)
+	r.fail(c, http.StatusBadRequest, message)
+	if fields != nil {
+		r.Error.Details = gin.H{"errors": fields}
+	}
+}
+
+// createUsers serves POST /users/bulk. Each element of the JSON array body is
+// a CreateUserRequest, validated and created independently, so some may
+// succeed while others fail; the 207 response reports each item's outcome in
+// order.
+func (a *API) createUsers(c *gin.Context) {
+	var items []json.RawMessage
+	if err := c.ShouldBindJSON(&items); err != nil {
+		if bodyTooLarge(c, err) {
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Body must be a JSON array of users")
+		return
+	}
+	if len(items) == 0 || len(items) > maxBulkItems {
+		respondError(c, http.StatusBadRequest, "Send between 1 and "+strconv.Itoa(maxBulkItems)+" users")
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := make([]bulkResult, len(items))
+	usernames := make(map[string]bool)
+	emails := make(map[string]bool)
+	created := 0
+	for i, item := range items {
+		result := &results[i]
+		result.Index = i
+
+		var req CreateUserRequest
+		err := json.Unmarshal(item, &req)
+		if err == nil {
+			err = binding.Validator.ValidateStruct(&req)
+		}
+		if err != nil {
+			result.failBinding(c, err)
+			continue
+		}
+
+		// Catch duplicates within the batch, which the repository would
+		// otherwise report as conflicts with the earlier item.
+		username, email := strings.ToLower(req.Username), strings.ToLower(req.Email)
+		if usernames[username] || emails[email] {
+			result.fail(c, http.StatusConflict, "Duplicate username or email in request")
+			continue
+		}
+		usernames[username], emails[email] = true, true
+
+		user := User{
+			Username: req.Username,
+			Email:    req.Email,
+			Role:     req.Role,
+		}
+		if user.Role == "" {
+			user.Role = defaultRole
+		}
+		if err := a.users.Create(ctx, &user); err != nil {
+			if errors.Is(err, ErrConflict) {
+				result.fail(c, http.StatusConflict, "User already exists")
+				continue
+			}
+			result.fail(c, http.StatusInternalServerError, "Failed to create user")
+			continue
+		}
+		result.Status, result.User = http.StatusCreated, &user
+		created++
+	}
+
+	respond(c, http.StatusMultiStatus, "results", results, gin.H{
+		"created": created,
+		"failed":  len(items) - created,
+	})
+}
+
+// deletePosts serves DELETE /posts?ids=1,2,3. Every post must be one the
+// caller may delete, or nothing is deleted; the rest are deleted together.
+// IDs of posts that don't exist are reported in not_found rather than
+// failing the request.
+func (a *API) deletePosts(c *gin.Context) {
+	params, ok := parseIDList(c, "post")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, _ := currentUser(c)
+	var ids []uint
+	deleted, notFound, forbidden := []string{}, []string{}, []string{}
+	seen := make(map[uint]bool)
+	for _, param := range params {
+		id, err := a.parsePostID(ctx, param)
+		if errors.Is(err, errInvalidID) {
+			respondError(c, http.StatusBadRequest, "Invalid post ID "+strconv.Quote(param))
+			return
+		}
+		if err == nil && seen[id] {
+			continue
+		}
+
+		var post Post
+		if err == nil {
+			post, err = a.posts.Get(ctx, id)
+		}
+		if errors.Is(err, ErrNotFound) {
+			notFound = append(notFound, param)
+			continue
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+			return
+		}
+
+		seen[id] = true
+		author, err := a.isAuthor(ctx, post, user)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+			return
+		}
+		if !author && !hasRole(user, RoleAdmin) {
+			forbidden = append(forbidden, param)
+			continue
+		}
+		ids = append(ids, id)
+		deleted = append(deleted, param)
+	}
+
+	if len(forbidden) > 0 {
+		a.deny(c, "Only the author or an admin can delete posts "+strings.Join(forbidden, ", "))
+		return
+	}
+	if len(ids) > 0 {
+		if err := a.posts.DeleteMany(ctx, ids); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to delete posts")
+			return
+		}
+	}
+
+	respond(c, http.StatusOK, "", gin.H{
+		"deleted":   deleted,
+		"not_found": notFound,
+	}, nil)
+}
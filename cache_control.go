@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache-Control values for responses that mustn't be cached at all, and for
+// ones only the client itself may keep, revalidating them with the ETag.
+const (
+	cacheNoStore = "no-store"
+	cachePrivate = "private, no-cache"
+)
+
+// cacheControl sets Cache-Control on every response. Mutations and errors
+// are no-store, and GETs made with credentials private. Anonymous GETs may
+// be cached by anyone for CACHE_MAX_AGE and served stale for
+// CACHE_STALE_WHILE_REVALIDATE more while a fresh copy is fetched.
+//
+// perRoute, added to by CACHE_CONTROL_ROUTES, sets the value for particular
+// routes instead, e.g. "GET /posts/:id=public, max-age=300". Entries are
+// separated by semicolons, since values have commas in them. Handlers may
+// still set their own.
+func (a *API) cacheControl(perRoute map[string]string) gin.HandlerFunc {
+	public := fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d",
+		int(envDuration("CACHE_MAX_AGE", 30*time.Second).Seconds()),
+		int(envDuration("CACHE_STALE_WHILE_REVALIDATE", time.Minute).Seconds()))
+
+	if routes := os.Getenv("CACHE_CONTROL_ROUTES"); routes != "" {
+		for _, entry := range strings.Split(routes, ";") {
+			route, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || len(strings.Fields(route)) != 2 || strings.TrimSpace(value) == "" {
+				log.Fatalf("invalid CACHE_CONTROL_ROUTES entry %q; want like \"GET /posts/:id=public, max-age=300\"", entry)
+			}
+			perRoute[strings.Join(strings.Fields(route), " ")] = strings.TrimSpace(value)
+		}
+	}
+
+	return func(c *gin.Context) {
+		value, ok := perRoute[c.Request.Method+" "+c.FullPath()]
+		switch {
+		case ok:
+		case c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead:
+			value = cacheNoStore
+		case a.hasCredentials(c):
+			value = cachePrivate
+		default:
+			value = public
+			// The same URL gets a private response with credentials. Added
+			// to, not replacing, the Vary the compression middleware sets.
+			vary := "Authorization, X-API-Key"
+			if a.sessions.store != nil {
+				vary += ", Cookie"
+			}
+			c.Writer.Header().Add("Vary", vary)
+		}
+		c.Header("Cache-Control", value)
+		c.Next()
+	}
+}
+
+// hasCredentials reports whether the request carries a bearer token, API key
+// or session cookie, whether or not it is valid.
+func (a *API) hasCredentials(c *gin.Context) bool {
+	if c.GetHeader("Authorization") != "" || c.GetHeader("X-API-Key") != "" {
+		return true
+	}
+	if a.sessions.store == nil {
+		return false
+	}
+	_, err := c.Cookie(a.sessions.cookie)
+	return err == nil
+}
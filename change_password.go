@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// changePassword sets a new password for the authenticated user after
+// checking their current one, then logs them out everywhere, this request's
+// login included.
+func (a *API) changePassword(c *gin.Context) {
+	if _, ok := currentAPIKey(c); ok {
+		a.deny(c, "Passwords can't be changed with an API key")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, _ := currentUser(c)
+	if user.PasswordHash == "" || !checkPassword(user.PasswordHash, req.CurrentPassword) {
+		a.audit(c, AuditLoginFailed, user, "wrong current password on password change")
+		respondError(c, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+	if err := checkPasswordPolicy(req.NewPassword, user); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		respondError(c, http.StatusBadRequest, "New password must differ from the current one")
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = a.modifyUser(ctx, user.ID, func(u *User) { u.PasswordHash = hash })
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	if err := a.logOutEverywhere(ctx, user.ID); err != nil {
+		logf(c.Request.Context(), "change password: failed to log out user %d: %v", user.ID, err)
+	}
+	if _, err := c.Cookie(a.sessions.cookie); err == nil && a.sessions.store != nil {
+		a.setSessionCookie(c, "", -1)
+	}
+
+	a.audit(c, AuditPasswordChanged, user, "changed")
+	respond(c, http.StatusOK, "", gin.H{"message": "Password changed; log in again with the new password"}, nil)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// circuitOpenError is returned in place of calling a dependency whose
+// circuit is open.
+type circuitOpenError struct {
+	name       string
+	retryAfter time.Duration
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("%s unavailable: circuit open", e.name)
+}
+
+// circuitTripsKey is the request context key trackCircuitTrips stores the
+// request's *circuitTrips under.
+type circuitTripsKey struct{}
+
+// circuitTrips holds the open circuit, if any, a request's calls were
+// turned away by.
+type circuitTrips struct {
+	mu   sync.Mutex
+	open *circuitOpenError
+}
+
+// trackCircuitTrips notes in the request context the calls an open circuit
+// turns away, so that respondErrorDetails can answer 503 whichever error
+// the handler passed on, or none.
+func trackCircuitTrips(c *gin.Context) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), circuitTripsKey{}, &circuitTrips{}))
+	c.Next()
+}
+
+// noteCircuitTrip records err against the request ctx belongs to if it is
+// a *circuitOpenError.
+func noteCircuitTrip(ctx context.Context, err error) {
+	var open *circuitOpenError
+	trips, ok := ctx.Value(circuitTripsKey{}).(*circuitTrips)
+	if !ok || !errors.As(err, &open) {
+		return
+	}
+	trips.mu.Lock()
+	trips.open = open
+	trips.mu.Unlock()
+}
+
+// unavailable turns a 500 for a request an open circuit turned away into a
+// 503 with Retry-After.
+func unavailable(c *gin.Context, status int, message string) (int, string) {
+	if status != http.StatusInternalServerError {
+		return status, message
+	}
+	trips, ok := c.Request.Context().Value(circuitTripsKey{}).(*circuitTrips)
+	if !ok {
+		return status, message
+	}
+	trips.mu.Lock()
+	open := trips.open
+	trips.mu.Unlock()
+	if open == nil {
+		return status, message
+	}
+	setRetryAfter(c, open.retryAfter)
+	return http.StatusServiceUnavailable, "Service temporarily unavailable; try again later"
+}
+
+// circuitBreakers are all the breakers created, for GET /health.
+var (
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   []*circuitBreaker
+)
+
+// circuitBreaker stops calling a dependency once threshold calls in a row
+// have failed, failing fast instead for cooldown. It then lets one call
+// through as a probe: if that succeeds the circuit closes again, and if it
+// fails the circuit stays open for another cooldown.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probedAt time.Time
+}
+
+// newCircuitBreaker returns the breaker for the named dependency, configured
+// by CIRCUIT_<NAME>_THRESHOLD and CIRCUIT_<NAME>_COOLDOWN. A threshold of 0
+// disables it.
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	prefix := "CIRCUIT_" + strings.ToUpper(name)
+	b := &circuitBreaker{
+		name:      name,
+		threshold: envInt(prefix+"_THRESHOLD", threshold),
+		cooldown:  envDuration(prefix+"_COOLDOWN", cooldown),
+	}
+	circuitBreakersMu.Lock()
+	circuitBreakers = append(circuitBreakers, b)
+	circuitBreakersMu.Unlock()
+	return b
+}
+
+// allow returns a *circuitOpenError if the call may not go ahead. A probe
+// that never reports back is given up on after a cooldown, so a lost call
+// can't hold the circuit open forever.
+func (b *circuitBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case circuitOpen:
+		if wait := b.cooldown - now.Sub(b.openedAt); wait > 0 {
+			return &circuitOpenError{name: b.name, retryAfter: wait}
+		}
+		b.state = circuitHalfOpen
+		b.probedAt = now
+	case circuitHalfOpen:
+		if now.Sub(b.probedAt) < b.cooldown {
+			return &circuitOpenError{name: b.name, retryAfter: b.cooldown - now.Sub(b.probedAt)}
+		}
+		b.probedAt = now
+	}
+	return nil
+}
+
+// record reports how an allowed call went.
+func (b *circuitBreaker) record(failed bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		if b.state != circuitClosed {
+			log.Printf("circuit %s: closed", b.name)
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state == circuitClosed {
+			log.Printf("circuit %s: open after %d failures", b.name, b.failures)
+		}
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// do calls fn if the circuit allows it. failure says which errors count
+// against the dependency, as opposed to e.g. a record not being found.
+func (b *circuitBreaker) do(fn func() error, failure func(error) bool) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err != nil && failure(err))
+	return err
+}
+
+func (b *circuitBreaker) currentState() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// circuitStates reports the state of every breaker by name.
+func circuitStates() map[string]string {
+	circuitBreakersMu.Lock()
+	defer circuitBreakersMu.Unlock()
+	states := make(map[string]string, len(circuitBreakers))
+	for _, b := range circuitBreakers {
+		states[b.name] = b.currentState().String()
+	}
+	return states
+}
+
+// canceled reports whether err is the caller giving up rather than the
+// dependency failing.
+func canceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// breakGorm guards every query db runs with b. Missing records and
+// constraint violations don't count as failures.
+func breakGorm(db *gorm.DB, b *circuitBreaker) {
+	before := func(tx *gorm.DB) {
+		if err := b.allow(); err != nil {
+			noteCircuitTrip(tx.Statement.Context, err)
+			tx.AddError(err)
+			tx.InstanceSet("circuit:rejected", true)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		if _, rejected := tx.InstanceGet("circuit:rejected"); rejected {
+			return
+		}
+		err := tx.Error
+		b.record(err != nil && !canceled(err) &&
+			!errors.Is(err, gorm.ErrRecordNotFound) &&
+			!errors.Is(err, gorm.ErrForeignKeyViolated) &&
+			!isDuplicateKey(err))
+	}
+
+	callbacks := db.Callback()
+	for _, register := range []func() error{
+		func() error { return callbacks.Create().Before("*").Register("circuit:before_create", before) },
+		func() error { return callbacks.Create().After("*").Register("circuit:after_create", after) },
+		func() error { return callbacks.Query().Before("*").Register("circuit:before_query", before) },
+		func() error { return callbacks.Query().After("*").Register("circuit:after_query", after) },
+		func() error { return callbacks.Update().Before("*").Register("circuit:before_update", before) },
+		func() error { return callbacks.Update().After("*").Register("circuit:after_update", after) },
+		func() error { return callbacks.Delete().Before("*").Register("circuit:before_delete", before) },
+		func() error { return callbacks.Delete().After("*").Register("circuit:after_delete", after) },
+		func() error { return callbacks.Row().Before("*").Register("circuit:before_row", before) },
+		func() error { return callbacks.Row().After("*").Register("circuit:after_row", after) },
+		func() error { return callbacks.Raw().Before("*").Register("circuit:before_raw", before) },
+		func() error { return callbacks.Raw().After("*").Register("circuit:after_raw", after) },
+	} {
+		if err := register(); err != nil {
+			log.Fatalf("failed to register database circuit breaker: %v", err)
+		}
+	}
+}
+
+// redisBreaker is a go-redis hook guarding every command with a breaker.
+// Replies from the server, redis.Nil included, don't count as failures.
+type redisBreaker struct {
+	breaker *circuitBreaker
+}
+
+func redisFailure(err error) bool {
+	var reply redis.Error
+	return !canceled(err) && !errors.As(err, &reply)
+}
+
+func (h redisBreaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h redisBreaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := h.breaker.do(func() error { return next(ctx, cmd) }, redisFailure)
+		var open *circuitOpenError
+		if errors.As(err, &open) {
+			noteCircuitTrip(ctx, err)
+			cmd.SetErr(err)
+		}
+		return err
+	}
+}
+
+func (h redisBreaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := h.breaker.do(func() error { return next(ctx, cmds) }, redisFailure)
+		var open *circuitOpenError
+		if errors.As(err, &open) {
+			noteCircuitTrip(ctx, err)
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCoAuthors caps how many co-authors one post can have.
+const maxCoAuthors = 10
+
+// CoAuthor records that a user co-authors a post alongside its author. Co-
+// authors can do anything with the post its author can, except manage its
+// co-authors.
+type CoAuthor struct {
+	ID        uint      `gorm:"primary_key" bson:"_id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_co_authors_post_user,priority:1" bson:"post_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_co_authors_post_user,priority:2;index" bson:"user_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// CoAuthorRepository stores co-authors. Add does nothing if the user
+// already co-authors the post, and Remove does nothing if they don't, so
+// both are safe to repeat.
+type CoAuthorRepository interface {
+	Add(ctx context.Context, coAuthor *CoAuthor) error
+	Remove(ctx context.Context, postID, userID uint) error
+	// ForPosts returns the co-authors of each of postIDs, in the order they
+	// were added. Posts without co-authors are missing from the result.
+	ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error)
+	// Is reports whether userID co-authors the post.
+	Is(ctx context.Context, postID, userID uint) (bool, error)
+}
+
+type CoAuthorRequest struct {
+	UserID publicID `json:"user_id" binding:"required"`
+}
+
+// addCoAuthor serves POST /posts/:id/authors, adding the user_id user as a
+// co-author of the post, for its author and admins. It responds with the
+// post and its updated authors.
+func (a *API) addCoAuthor(c *gin.Context) {
+	post, ok := a.ownedPost(c)
+	if !ok {
+		return
+	}
+	var req CoAuthorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	id, err := parseID(ctx, string(req.UserID), a.users.ResolveUUID)
+	var user User
+	if err == nil {
+		user, err = a.users.Get(ctx, id)
+	}
+	if errors.Is(err, errInvalidID) {
+		respondError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	if user.ID == post.AuthorID {
+		respondError(c, http.StatusBadRequest, "The author can't be a co-author")
+		return
+	}
+	coAuthors, err := a.coAuthors.ForPosts(ctx, []uint{post.ID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+		return
+	}
+	if len(coAuthors[post.ID]) >= maxCoAuthors {
+		respondError(c, http.StatusConflict, "Posts can't have more than 10 co-authors")
+		return
+	}
+	if !a.auditPostBefore(c, post) {
+		return
+	}
+
+	if err := a.coAuthors.Add(ctx, &CoAuthor{PostID: post.ID, UserID: user.ID}); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update authors")
+		return
+	}
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// removeCoAuthor serves DELETE /posts/:id/authors/:user_id, removing a co-
+// author from the post. It is open to the post's author and admins, and to
+// co-authors removing themselves.
+func (a *API) removeCoAuthor(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	post, err := a.posts.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	userID, err := parseID(ctx, c.Param("user_id"), a.users.ResolveUUID)
+	if errors.Is(err, errInvalidID) {
+		respondError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	if user, _ := currentUser(c); user.ID != userID && !a.ownsPost(c, post) {
+		return
+	}
+	if !a.auditPostBefore(c, post) {
+		return
+	}
+
+	if err := a.coAuthors.Remove(ctx, post.ID, userID); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update authors")
+		return
+	}
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// ownedPost loads the :id post for a change only its author and admins may
+// make, writing the error response and returning ok == false if it doesn't
+// exist or the caller isn't one of them.
+func (a *API) ownedPost(c *gin.Context) (Post, bool) {
+	id, ok := a.postID(c)
+	if !ok {
+		return Post{}, false
+	}
+	post, err := a.posts.Get(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return Post{}, false
+	}
+	if !a.ownsPost(c, post) {
+		return Post{}, false
+	}
+	return post, true
+}
+
+// auditPostBefore notes post, with its authors filled in as the response
+// will have them, as the state before a change to its co-authors.
+func (a *API) auditPostBefore(c *gin.Context, post Post) bool {
+	if !a.fillPostCount(c, &post) {
+		return false
+	}
+	auditBefore(c, post)
+	return true
+}
+
+// ownsPost reports whether the caller is the author of post or an admin.
+// For anyone else, co-authors included, it responds with 403.
+func (a *API) ownsPost(c *gin.Context, post Post) bool {
+	user, ok := currentUser(c)
+	if ok && (post.AuthorID == user.ID || hasRole(user, RoleAdmin)) {
+		return true
+	}
+	a.deny(c, "Only the author or an admin can manage co-authors")
+	return false
+}
+
+// isAuthor reports whether user wrote post, alone or as a co-author.
+func (a *API) isAuthor(ctx context.Context, post Post, user User) (bool, error) {
+	if post.AuthorID == user.ID {
+		return true, nil
+	}
+	return a.coAuthors.Is(ctx, post.ID, user.ID)
+}
+
+// fillAuthors fills in the authors of posts: the author first, then the
+// co-authors in the order they were added. Users since deleted are left
+// out.
+func (a *API) fillAuthors(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(posts))
+	userIDs := []uint{}
+	for i, post := range posts {
+		ids[i] = post.ID
+		userIDs = append(userIDs, post.AuthorID)
+	}
+	coAuthors, err := a.coAuthors.ForPosts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, users := range coAuthors {
+		userIDs = append(userIDs, users...)
+	}
+	found, err := a.users.GetMany(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]User, len(found))
+	for _, user := range found {
+		byID[user.ID] = user
+	}
+
+	for i := range posts {
+		posts[i].Authors = []UserLink{}
+		for _, id := range append([]uint{posts[i].AuthorID}, coAuthors[posts[i].ID]...) {
+			if user, ok := byID[id]; ok {
+				posts[i].Authors = append(posts[i].Authors, linkToUser(user))
+			}
+		}
+	}
+	return nil
+}
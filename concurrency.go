@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// concurrencyLimit is how many requests to a route may run at once and how
+// many more may wait for a slot.
+type concurrencyLimit struct {
+	running int
+	queue   int
+}
+
+// routeSemaphore holds a route's slots and counts the requests waiting for
+// one.
+type routeSemaphore struct {
+	slots   chan struct{}
+	queue   int64
+	waiting atomic.Int64
+}
+
+// limitConcurrency caps how many requests to each route in perRoute, keyed
+// like "GET /posts/search", run at once, so expensive routes can't take
+// every database connection and CPU from the rest of the API. Requests
+// beyond the cap wait up to CONCURRENCY_QUEUE_TIMEOUT for a slot; once the
+// queue is full, or the wait runs out, they get 503 with Retry-After
+// straight away. CONCURRENCY_LIMITS adds to or overrides perRoute with a
+// comma-separated list such as "GET /posts/search=8:16", each running and
+// queued requests. Limits are per instance.
+func limitConcurrency(perRoute map[string]concurrencyLimit) gin.HandlerFunc {
+	if limits := os.Getenv("CONCURRENCY_LIMITS"); limits != "" {
+		for _, entry := range strings.Split(limits, ",") {
+			route, limit, ok := parseConcurrencyLimit(entry)
+			if !ok {
+				log.Fatalf("invalid CONCURRENCY_LIMITS entry %q; want like \"GET /posts/search=8:16\"", entry)
+			}
+			perRoute[route] = limit
+		}
+	}
+	maxWait := envDuration("CONCURRENCY_QUEUE_TIMEOUT", 2*time.Second)
+
+	semaphores := make(map[string]*routeSemaphore, len(perRoute))
+	for route, limit := range perRoute {
+		if limit.running > 0 {
+			semaphores[route] = &routeSemaphore{
+				slots: make(chan struct{}, limit.running),
+				queue: int64(limit.queue),
+			}
+		}
+	}
+
+	return func(c *gin.Context) {
+		sem, ok := semaphores[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+		if !sem.acquire(c, maxWait) {
+			setRetryAfter(c, time.Second)
+			abortWithError(c, http.StatusServiceUnavailable, "Too many concurrent requests to this endpoint; try again shortly")
+			return
+		}
+		defer func() { <-sem.slots }()
+		c.Next()
+	}
+}
+
+// acquire takes a slot, waiting up to maxWait if there is room in the
+// queue, and reports whether it got one.
+func (s *routeSemaphore) acquire(c *gin.Context, maxWait time.Duration) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if s.waiting.Add(1) > s.queue {
+		s.waiting.Add(-1)
+		return false
+	}
+	defer s.waiting.Add(-1)
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+// parseConcurrencyLimit parses one CONCURRENCY_LIMITS entry.
+func parseConcurrencyLimit(entry string) (string, concurrencyLimit, bool) {
+	route, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+	if !ok || len(strings.Fields(route)) != 2 {
+		return "", concurrencyLimit{}, false
+	}
+	running, queue, ok := strings.Cut(spec, ":")
+	if !ok {
+		return "", concurrencyLimit{}, false
+	}
+	limit := concurrencyLimit{}
+	var err error
+	if limit.running, err = strconv.Atoi(running); err != nil {
+		return "", concurrencyLimit{}, false
+	}
+	if limit.queue, err = strconv.Atoi(queue); err != nil || limit.queue < 0 {
+		return "", concurrencyLimit{}, false
+	}
+	return strings.Join(strings.Fields(route), " "), limit, true
+}
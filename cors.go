@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Defaults for the CORS_* settings: every method the API routes, the
+// request headers clients send it and the response headers worth reading.
+const (
+	defaultCORSMethods       = "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS"
+	defaultCORSHeaders       = "Origin,Content-Type,Accept,Authorization,X-API-Key,If-Match,If-None-Match,Idempotency-Key,X-Request-ID"
+	defaultCORSExposeHeaders = "ETag,Link,Retry-After,X-Request-ID,X-Total-Count,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset"
+)
+
+// newCORS builds the CORS middleware from CORS_ALLOWED_ORIGINS, a
+// comma-separated list of origins such as "https://app.example.com" or, for
+// any subdomain, "https://*.example.com". "*" allows any origin. Unset, no
+// cross-origin requests are allowed at all, which is the safe default in
+// production. CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS,
+// CORS_EXPOSE_HEADERS, CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE configure the
+// rest of the policy. Invalid settings are fatal.
+func newCORS() gin.HandlerFunc {
+	origins := envList("CORS_ALLOWED_ORIGINS", "")
+	config := cors.Config{
+		AllowMethods:     envList("CORS_ALLOWED_METHODS", defaultCORSMethods),
+		AllowHeaders:     envList("CORS_ALLOWED_HEADERS", defaultCORSHeaders),
+		ExposeHeaders:    envList("CORS_EXPOSE_HEADERS", defaultCORSExposeHeaders),
+		AllowCredentials: os.Getenv("CORS_ALLOW_CREDENTIALS") == "true",
+		MaxAge:           envDuration("CORS_MAX_AGE", 12*time.Hour),
+		AllowWildcard:    true,
+	}
+	switch {
+	case slices.Contains(origins, "*"):
+		if config.AllowCredentials {
+			log.Fatal("CORS_ALLOW_CREDENTIALS can't be used with CORS_ALLOWED_ORIGINS=*; list the origins")
+		}
+		config.AllowAllOrigins = true
+	case len(origins) == 0:
+		config.AllowOriginFunc = func(string) bool { return false }
+	default:
+		config.AllowOrigins = origins
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalf("invalid CORS configuration: %v", err)
+	}
+	return cors.New(config)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countUsers serves GET /users/count, and HEAD /users with the count in
+// X-Total-Count and no body. It takes the same filters as GET /users.
+func (a *API) countUsers(c *gin.Context) {
+	deleted, ok := a.includeDeleted(c)
+	if !ok {
+		return
+	}
+	filter, ok := parseUserFilter(c)
+	if !ok {
+		return
+	}
+
+	count, err := a.users.Count(c.Request.Context(), ListOptions{IncludeDeleted: deleted, Users: filter})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to count users")
+		return
+	}
+	respondCount(c, count)
+}
+
+// countPosts serves GET /posts/count, and HEAD /posts with the count in
+// X-Total-Count and no body. It takes the same filters as GET /posts.
+func (a *API) countPosts(c *gin.Context) {
+	deleted, ok := a.includeDeleted(c)
+	if !ok {
+		return
+	}
+	filter, ok := parsePostFilter(c)
+	if !ok {
+		return
+	}
+	hideUnlisted(c, &filter)
+	if !a.hideBlocked(c, &filter) {
+		return
+	}
+
+	count, err := a.posts.Count(c.Request.Context(), ListOptions{IncludeDeleted: deleted, Posts: filter})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to count posts")
+		return
+	}
+	respondCount(c, count)
+}
+
+// respondCount sets X-Total-Count and, except for HEAD requests, writes the
+// count as the body.
+func respondCount(c *gin.Context, count int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(count, 10))
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	respond(c, http.StatusOK, "count", count, nil)
+}
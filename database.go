@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gin-golang-api/migrations"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dbDriver returns the configured DB_DRIVER, defaulting to postgres.
+func dbDriver() string {
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = "postgres"
+	}
+	return driver
+}
+
+// initDB connects to the database and refuses to continue if the schema
+// is behind the registered migrations. Queries fail fast while the database
+// circuit is open.
+func initDB(driver string) *gorm.DB {
+	db := connectDB(driver)
+	breakGorm(db, newCircuitBreaker("database", 5, 30*time.Second))
+
+	pending, err := migrations.Pending(db)
+	if err != nil {
+		log.Fatalf("failed to check migrations: %v", err)
+	}
+	if len(pending) > 0 {
+		log.Fatalf("database has pending migrations (%s); run `migrate up` first", strings.Join(pending, ", "))
+	}
+
+	return db
+}
+
+func connectDB(driver string) *gorm.DB {
+	dialector, err := openDialector(driver)
+	if err != nil {
+		log.Fatalf("failed to configure database: %v", err)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	traceGorm(db)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to access connection pool: %v", err)
+	}
+	configurePool(sqlDB)
+
+	return db
+}
+
+// configurePool applies the DB_* connection pool settings to sqlDB.
+func configurePool(sqlDB *sql.DB) {
+	sqlDB.SetMaxOpenConns(envInt("DB_MAX_OPEN_CONNS", 25))
+	sqlDB.SetMaxIdleConns(envInt("DB_MAX_IDLE_CONNS", 10))
+	sqlDB.SetConnMaxLifetime(envDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+	sqlDB.SetConnMaxIdleTime(envDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute))
+}
+
+// openDialector returns the GORM dialector for the given DB_DRIVER value,
+// connecting to DATABASE_URL.
+func openDialector(driver string) (gorm.Dialector, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		switch driver {
+		case "postgres":
+			dsn = "host=localhost user=postgres password=postgres dbname=gin_golang_api port=5432 sslmode=disable"
+		case "mysql":
+			dsn = "root:root@tcp(localhost:3306)/gin_golang_api?charset=utf8mb4&parseTime=True&loc=UTC"
+		case "sqlite":
+			dsn = "gin-golang-api.db"
+		}
+	}
+	return openDialectorDSN(driver, dsn)
+}
+
+// openDialectorDSN returns the GORM dialector for driver connecting to dsn.
+func openDialectorDSN(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "postgres":
+		return postgres.Open(dsn), nil
+	case "mysql":
+		return mysql.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q", driver)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policies for a new post that duplicates one its author created recently.
+const (
+	duplicatesAllow  = "allow"
+	duplicatesWarn   = "warn"
+	duplicatesReject = "reject"
+)
+
+// duplicateConfig holds the duplicate post policy and how far back to look
+// for matches.
+type duplicateConfig struct {
+	policy string
+	window time.Duration
+}
+
+// newDuplicateConfig reads DUPLICATE_POSTS ("allow", the default, "warn" or
+// "reject") and DUPLICATE_POST_WINDOW.
+func newDuplicateConfig() duplicateConfig {
+	cfg := duplicateConfig{window: envDuration("DUPLICATE_POST_WINDOW", 24*time.Hour)}
+	switch policy := os.Getenv("DUPLICATE_POSTS"); policy {
+	case "", duplicatesAllow:
+		cfg.policy = duplicatesAllow
+	case duplicatesWarn, duplicatesReject:
+		cfg.policy = policy
+	default:
+		log.Fatalf("unsupported DUPLICATE_POSTS %q", policy)
+	}
+	return cfg
+}
+
+// checkDuplicatePost applies the duplicate post policy to post before it is
+// created. A match is linked from the Link header; under "reject" it writes
+// a 409 and returns false, under "warn" it adds a Warning header and lets
+// the post through.
+func (a *API) checkDuplicatePost(c *gin.Context, post Post) bool {
+	if a.duplicates.policy == duplicatesAllow {
+		return true
+	}
+
+	existing, found, err := a.findDuplicatePost(c.Request.Context(), post)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create post")
+		return false
+	}
+	if !found {
+		return true
+	}
+
+	path := postPath(existing)
+	c.Header("Link", "<"+path+`>; rel="duplicate"`)
+	if a.duplicates.policy == duplicatesReject {
+		respondErrorDetails(c, http.StatusConflict, "You posted the same title and content recently", gin.H{"existing_post": path})
+		return false
+	}
+	c.Header("Warning", `299 - "Duplicate of `+path+`"`)
+	return true
+}
+
+// findDuplicatePost looks for a live post by the same author, created within
+// the window, whose title and content hash the same as post's.
+func (a *API) findDuplicatePost(ctx context.Context, post Post) (Post, bool, error) {
+	recent, err := a.posts.List(ctx, ListOptions{Posts: PostFilter{
+		AuthorID:     post.AuthorID,
+		CreatedAfter: time.Now().Add(-a.duplicates.window),
+	}})
+	if err != nil {
+		return Post{}, false, err
+	}
+
+	hash := postContentHash(post)
+	for _, candidate := range recent {
+		if postContentHash(candidate) == hash {
+			return candidate, true, nil
+		}
+	}
+	return Post{}, false, nil
+}
+
+// postContentHash hashes a post's title and content, ignoring surrounding
+// whitespace.
+func postContentHash(post Post) [sha256.Size]byte {
+	return sha256.Sum256([]byte(strings.TrimSpace(post.Title) + "\x00" + strings.TrimSpace(post.Content)))
+}
+
+// postPath returns the API path of post, by its public ID.
+func postPath(post Post) string {
+	if useUUIDs {
+		return "/posts/" + post.UUID
+	}
+	return "/posts/" + strconv.FormatUint(uint64(post.ID), 10)
+}
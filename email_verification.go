@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendVerificationEmail emails user a link to /auth/verify. Failures are
+// logged rather than returned; the user can ask for another link.
+func (a *API) sendVerificationEmail(ctx context.Context, user User) {
+	token, err := a.issueOneTimeToken(ctx, user.ID, TokenPurposeEmailVerification, a.emailVerificationTTL)
+	if err != nil {
+		logf(ctx, "email verification: failed to issue token for user %d: %v", user.ID, err)
+		return
+	}
+	a.sendMailAsync(user.Email, "Verify your email address",
+		"Welcome, "+user.Username+"!\n\n"+
+			"Confirm your email address within "+a.emailVerificationTTL.String()+" by opening:\n"+
+			appURL()+"/auth/verify?token="+token+"\n")
+}
+
+// verifyEmail redeems the token from a verification link.
+func (a *API) verifyEmail(c *gin.Context) {
+	value := c.Query("token")
+	if value == "" {
+		respondError(c, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := a.oneTimeTokens.Consume(ctx, TokenPurposeEmailVerification, hashToken(value))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusBadRequest, "Invalid or expired verification token")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	err = a.modifyUser(ctx, token.UserID, func(user *User) { user.EmailVerified = true })
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusBadRequest, "Invalid or expired verification token")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	respond(c, http.StatusOK, "", gin.H{"message": "Email verified"}, nil)
+}
+
+// resendVerification sends the authenticated user a new verification link.
+func (a *API) resendVerification(c *gin.Context) {
+	user, _ := currentUser(c)
+	if user.EmailVerified {
+		respondError(c, http.StatusConflict, "Email is already verified")
+		return
+	}
+
+	a.sendVerificationEmail(c.Request.Context(), user)
+	respond(c, http.StatusAccepted, "", gin.H{"message": "Verification email sent"}, nil)
+}
+
+// requireVerifiedEmail rejects users authenticated by requireAuth whose email
+// address is unverified, if REQUIRE_VERIFIED_EMAIL is true.
+func requireVerifiedEmail() gin.HandlerFunc {
+	if os.Getenv("REQUIRE_VERIFIED_EMAIL") != "true" {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok || !user.EmailVerified {
+			abortWithError(c, http.StatusForbidden, "Verify your email address first")
+			return
+		}
+		c.Next()
+	}
+}
+
+// modifyUser applies fn to the stored user and saves it, retrying if a
+// concurrent update bumps the version in between.
+func (a *API) modifyUser(ctx context.Context, id uint, fn func(user *User)) error {
+	for attempt := 0; ; attempt++ {
+		user, err := a.users.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		fn(&user)
+		err = a.users.Update(ctx, &user)
+		if !errors.Is(err, ErrVersionConflict) || attempt == 2 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envInt returns the integer value of the named environment variable, or def
+// if it is unset. An unparsable value is a fatal configuration error.
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", name, value, err)
+	}
+	return n
+}
+
+// envDuration returns the duration value (e.g. "30m") of the named
+// environment variable, or def if it is unset. An unparsable value is a
+// fatal configuration error.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", name, value, err)
+	}
+	return d
+}
+
+// envFloat returns the floating-point value of the named environment
+// variable, or def if it is unset. An unparsable value is a fatal
+// configuration error.
+func envFloat(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", name, value, err)
+	}
+	return f
+}
+
+// envList returns the comma-separated values of the named environment
+// variable, or of def if it is unset. Setting it empty gives none.
+func envList(name, def string) []string {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		value = def
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envPattern compiles the regular expression in the named environment
+// variable, or def if it is unset. Setting it empty disables the pattern.
+func envPattern(name, def string) *regexp.Regexp {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		value = def
+	}
+	if value == "" {
+		return nil
+	}
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", name, value, err)
+	}
+	return pattern
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextEnvelopeKey is the context key holding whether the current
+// request's successful responses are wrapped in an envelope.
+const contextEnvelopeKey = "envelope"
+
+// envelopeByDefault wraps successful responses as {"data": ..., "meta": ...}
+// unless a request asks otherwise. Errors keep their {"error": ...} shape
+// either way.
+var envelopeByDefault bool
+
+// configureEnvelope reads RESPONSE_ENVELOPE ("bare", the default, or
+// "envelope").
+func configureEnvelope() {
+	switch mode := os.Getenv("RESPONSE_ENVELOPE"); mode {
+	case "", "bare":
+		envelopeByDefault = false
+	case "envelope":
+		envelopeByDefault = true
+	default:
+		log.Fatalf("unsupported RESPONSE_ENVELOPE %q", mode)
+	}
+}
+
+// negotiateEnvelope applies the ?envelope=true|false override, rejecting
+// anything else with 400.
+func negotiateEnvelope(c *gin.Context) {
+	wrap := envelopeByDefault
+	if value, ok := c.GetQuery("envelope"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, "envelope must be true or false")
+			return
+		}
+		wrap = parsed
+	}
+	c.Set(contextEnvelopeKey, wrap)
+	c.Next()
+}
+
+// render shapes a successful response body. Enveloped, data goes under
+// "data" and meta, if any, under "meta". Bare, data is the body itself when
+// key is empty, or sits under key alongside meta's entries.
+func render(c *gin.Context, key string, data interface{}, meta gin.H) interface{} {
+	if wrap, ok := c.Get(contextEnvelopeKey); ok && wrap.(bool) {
+		body := gin.H{"data": data}
+		if len(meta) > 0 {
+			body["meta"] = meta
+		}
+		return body
+	}
+
+	if key == "" {
+		return data
+	}
+	body := gin.H{key: data}
+	for name, value := range meta {
+		body[name] = value
+	}
+	return body
+}
+
+// respond writes a successful JSON response shaped by render.
+func respond(c *gin.Context, status int, key string, data interface{}, meta gin.H) {
+	c.Set(contextAuditAfterKey, data)
+	c.JSON(status, render(c, key, data, meta))
+}
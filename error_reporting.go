@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// errorReportingEnabled is whether configureErrorReporting set up Sentry.
+var errorReportingEnabled bool
+
+// configureErrorReporting sets up reporting of panics and server errors to
+// Sentry if SENTRY_DSN is set, tagged with SENTRY_ENVIRONMENT and
+// SENTRY_RELEASE.
+func configureErrorReporting() {
+	dsn := os.Getenv("SENTRY_DSN")
+	if dsn == "" {
+		return
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              dsn,
+		Environment:      os.Getenv("SENTRY_ENVIRONMENT"),
+		Release:          os.Getenv("SENTRY_RELEASE"),
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		log.Fatalf("invalid SENTRY_DSN: %v", err)
+	}
+	errorReportingEnabled = true
+}
+
+// flushErrorReports waits for reports still being sent, until ctx's
+// deadline, or for a few seconds if it has none.
+func flushErrorReports(ctx context.Context) {
+	if !errorReportingEnabled {
+		return
+	}
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	sentry.Flush(timeout)
+}
+
+// recoverPanics turns a panic in a later handler into a 500, logging it
+// with its stack trace and reporting it to Sentry. Responses a handler
+// answered with a 5xx of its own are reported too. Panics from writing to a
+// client that has gone away are only logged.
+func recoverPanics(c *gin.Context) {
+	var hub *sentry.Hub
+	if errorReportingEnabled {
+		hub = sentry.CurrentHub().Clone()
+		hub.Scope().SetRequest(c.Request)
+	}
+
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if err, ok := recovered.(error); ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+			slog.WarnContext(c.Request.Context(), "connection lost", "error", err)
+			c.Abort()
+			return
+		}
+
+		slog.ErrorContext(c.Request.Context(), "panic", "error", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		abortWithError(c, http.StatusInternalServerError, "Internal server error")
+		if hub != nil {
+			tagErrorReport(hub, c)
+			hub.RecoverWithContext(c.Request.Context(), recovered)
+		}
+	}()
+
+	c.Next()
+
+	if status := c.Writer.Status(); hub != nil && status >= http.StatusInternalServerError {
+		tagErrorReport(hub, c)
+		hub.CaptureMessage(fmt.Sprintf("%d %s %s", status, c.Request.Method, c.FullPath()))
+	}
+}
+
+// tagErrorReport adds the route, status, request ID and the signed-in user,
+// if any, to hub's reports.
+func tagErrorReport(hub *sentry.Hub, c *gin.Context) {
+	scope := hub.Scope()
+	scope.SetTag("route", c.FullPath())
+	scope.SetTag("status", strconv.Itoa(c.Writer.Status()))
+	scope.SetTag("request_id", requestID(c))
+	if user, ok := currentUser(c); ok {
+		scope.SetUser(sentry.User{ID: strconv.FormatUint(uint64(user.ID), 10)})
+	}
+}
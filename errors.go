@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError is the body of every error response, wrapped as
+// {"error": {...}}. Code is a stable machine-readable identifier derived
+// from the status; Message is for humans and may change. Details carries
+// extra structured information for some errors.
+type APIError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// errorCodes maps response statuses to APIError codes.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusGone:                  "gone",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusPreconditionRequired:  "precondition_required",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+// errorCode returns the APIError code for status.
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return "internal_error"
+	}
+	return "error"
+}
+
+// errorStatus maps a repository error to the response status for it.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict), errors.Is(err, ErrVersionConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// newAPIError builds the error for a response with the given status.
+func newAPIError(c *gin.Context, status int, message string, details interface{}) APIError {
+	return APIError{
+		Code:      errorCode(status),
+		Message:   message,
+		Details:   details,
+		RequestID: requestID(c),
+	}
+}
+
+// respondError writes an error response.
+func respondError(c *gin.Context, status int, message string) {
+	respondErrorDetails(c, status, message, nil)
+}
+
+// respondErrorDetails writes an error response with details.
+func respondErrorDetails(c *gin.Context, status int, message string, details interface{}) {
+	status, message = timedOut(c, status, message)
+	status, message = unavailable(c, status, message)
+	c.Header("Cache-Control", cacheNoStore)
+	c.JSON(status, gin.H{"error": newAPIError(c, status, message, details)})
+}
+
+// abortWithError writes an error response and stops the handler chain, for
+// middleware.
+func abortWithError(c *gin.Context, status int, message string) {
+	abortWithErrorDetails(c, status, message, nil)
+}
+
+// abortWithErrorDetails writes an error response with details and stops
+// the handler chain.
+func abortWithErrorDetails(c *gin.Context, status int, message string, details interface{}) {
+	status, message = timedOut(c, status, message)
+	status, message = unavailable(c, status, message)
+	c.Header("Cache-Control", cacheNoStore)
+	c.AbortWithStatusJSON(status, gin.H{"error": newAPIError(c, status, message, details)})
+}
+
+// respondStoreError writes the response for err, returned by a repository
+// operation on a record of the given kind ("user", "post", ...), where
+// action describes the operation ("fetch", "update", ...).
+func respondStoreError(c *gin.Context, err error, kind, action string) {
+	status := errorStatus(err)
+	title := strings.ToUpper(kind[:1]) + kind[1:]
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(c, status, title+" not found")
+	case errors.Is(err, ErrConflict):
+		respondError(c, status, title+" already exists")
+	case errors.Is(err, ErrVersionConflict):
+		respondError(c, status, title+" was modified by another request")
+	default:
+		respondError(c, status, "Failed to "+action+" "+kind)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondCacheable writes body as a 200 JSON response with an ETag derived
+// from its content, or responds 304 Not Modified without a body if the
+// client's If-None-Match already holds that ETag.
+func respondCacheable(c *gin.Context, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to render response")
+		return
+	}
+
+	tag := etag(data)
+	c.Header("ETag", tag)
+	if etagMatches(c.GetHeader("If-None-Match"), tag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// etag returns a strong entity tag for a serialized representation.
+func etag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether header, an If-None-Match or If-Match list of
+// entity tags, includes tag or is "*". Weak tags match their strong
+// equivalents.
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
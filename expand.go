@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Associations that ?expand= can embed.
+var (
+	userExpansions = []string{"posts"}
+	postExpansions = []string{"author"}
+)
+
+// parseExpand reads ?expand=, a comma-separated list of associations from
+// allowed to embed in the response. On invalid input it writes the error
+// response and returns ok == false.
+func parseExpand(c *gin.Context, allowed []string) (expand map[string]bool, ok bool) {
+	expand = make(map[string]bool)
+	value := c.Query("expand")
+	if value == "" {
+		return expand, true
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(allowed, name) {
+			respondErrorDetails(c, http.StatusBadRequest, "Cannot expand "+strconv.Quote(name), gin.H{"expandable": allowed})
+			return nil, false
+		}
+		expand[name] = true
+	}
+	return expand, true
+}
+
+// expandAuthors sets Author on each post, loading all the authors in one
+// query. Posts whose author has been deleted are left without one.
+func (a *API) expandAuthors(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	var ids []uint
+	for _, post := range posts {
+		if !slices.Contains(ids, post.AuthorID) {
+			ids = append(ids, post.AuthorID)
+		}
+	}
+	authors, err := a.users.GetMany(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[uint]*User, len(authors))
+	for i := range authors {
+		byID[authors[i].ID] = &authors[i]
+	}
+	for i := range posts {
+		posts[i].Author = byID[posts[i].AuthorID]
+	}
+	return nil
+}
+
+// expandPosts sets Posts on each user to their live posts, loading them all
+// in one query.
+func (a *API) expandPosts(ctx context.Context, users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	posts, err := a.posts.List(ctx, ListOptions{Posts: PostFilter{AuthorIDs: ids}})
+	if err != nil {
+		return err
+	}
+
+	byAuthor := make(map[uint][]Post)
+	for _, post := range posts {
+		byAuthor[post.AuthorID] = append(byAuthor[post.AuthorID], post)
+	}
+	for i := range users {
+		users[i].Posts = byAuthor[users[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fields that ?fields= can select on users and posts.
+var (
+	userFields = []string{"id", "username", "email", "role", "email_verified", "display_name", "bio", "avatar_url", "website", "created_at", "updated_at", "deleted_at", "version", "posts", "followers_count", "following_count"}
+	postFields = []string{"id", "title", "content", "word_count", "reading_minutes", "author_id", "author", "authors", "created_at", "updated_at", "deleted_at", "version", "status", "published_at", "visibility", "archived_at", "likes_count", "reactions", "bookmarks_count", "views_count", "mentions", "poll", "series", "pinned"}
+)
+
+// fieldSet is the set of fields a client asked for with ?fields=. A nil
+// fieldSet selects every field.
+type fieldSet []string
+
+// parseFields reads ?fields=, a comma-separated list of fields from
+// allowed. On invalid input it writes the error response and returns
+// ok == false.
+func parseFields(c *gin.Context, allowed []string) (fields fieldSet, ok bool) {
+	value := c.Query("fields")
+	if value == "" {
+		return nil, true
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(allowed, field) {
+			respondErrorDetails(c, http.StatusBadRequest, "Unknown field "+strconv.Quote(field), gin.H{"selectable": allowed})
+			return nil, false
+		}
+		fields = append(fields, field)
+	}
+	return fields, true
+}
+
+// project renders record as JSON and keeps only the selected fields, so the
+// projection sees exactly what clients would, IDs included. With no
+// selection it returns record unchanged.
+func (f fieldSet) project(record interface{}) interface{} {
+	if f == nil {
+		return record
+	}
+
+	// Marshalling users and posts, and unmarshalling the result, can't fail.
+	data, _ := json.Marshal(record)
+	var all map[string]json.RawMessage
+	_ = json.Unmarshal(data, &all)
+
+	selected := make(map[string]json.RawMessage, len(f))
+	for _, field := range f {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
+
+// projectAll projects each record in records.
+func projectAll[T any](f fieldSet, records []T) interface{} {
+	if f == nil {
+		return records
+	}
+	projected := make([]interface{}, len(records))
+	for i, record := range records {
+		projected[i] = f.project(record)
+	}
+	return projected
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userFilterQuery is the query string GET /users filters by.
+type userFilterQuery struct {
+	EmailDomain string `form:"email_domain" binding:"omitempty,hostname_rfc1123"`
+}
+
+// parseUserFilter reads the filters GET /users accepts:
+// ?email_domain=example.com. On invalid input it writes the error response
+// and returns ok == false.
+func parseUserFilter(c *gin.Context) (filter UserFilter, ok bool) {
+	var query userFilterQuery
+	if !bindQuery(c, &query) {
+		return UserFilter{}, false
+	}
+	filter.EmailDomain = strings.ToLower(query.EmailDomain)
+	return filter, true
+}
+
+// postFilterQuery is the query string GET /posts filters by.
+type postFilterQuery struct {
+	AuthorID      string `form:"author_id" binding:"omitempty,public_id"`
+	CreatedAfter  string `form:"created_after" binding:"omitempty,filter_time"`
+	CreatedBefore string `form:"created_before" binding:"omitempty,filter_time"`
+	TitleContains string `form:"title_contains"`
+	Status        string `form:"status" binding:"omitempty,oneof=draft published"`
+}
+
+// parsePostFilter reads the filters GET /posts accepts: ?author_id=,
+// ?created_after=, ?created_before=, ?title_contains= and ?status=. On
+// invalid input it writes the error response and returns ok == false.
+func parsePostFilter(c *gin.Context) (filter PostFilter, ok bool) {
+	var query postFilterQuery
+	if !bindQuery(c, &query) {
+		return PostFilter{}, false
+	}
+
+	if query.AuthorID != "" {
+		if useUUIDs {
+			filter.AuthorUUID = strings.ToLower(query.AuthorID)
+		} else {
+			id, _ := strconv.ParseUint(query.AuthorID, 10, 32)
+			filter.AuthorID = uint(id)
+		}
+	}
+	if query.CreatedAfter != "" {
+		filter.CreatedAfter, _ = parseFilterTime(query.CreatedAfter)
+	}
+	if query.CreatedBefore != "" {
+		filter.CreatedBefore, _ = parseFilterTime(query.CreatedBefore)
+	}
+	filter.TitleContains = query.TitleContains
+	filter.Status = query.Status
+	return filter, true
+}
+
+// parseFilterTime accepts either a date, meaning midnight UTC, or an RFC
+// 3339 timestamp.
+func parseFilterTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
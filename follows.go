@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Follow records that one user follows another.
+type Follow struct {
+	ID         uint      `gorm:"primary_key" bson:"_id"`
+	FollowerID uint      `gorm:"not null;uniqueIndex:idx_follows_follower_followee,priority:1" bson:"follower_id"`
+	FolloweeID uint      `gorm:"not null;uniqueIndex:idx_follows_follower_followee,priority:2;index" bson:"followee_id"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// FollowRepository stores follows. Add does nothing if the follow already
+// exists, and Remove does nothing if it doesn't, so both are safe to repeat.
+type FollowRepository interface {
+	Add(ctx context.Context, follow *Follow) error
+	Remove(ctx context.Context, followerID, followeeID uint) error
+	// Followers lists the follows of userID and Following the follows by
+	// userID, oldest first. Both honour opts.Offset and opts.Limit only.
+	Followers(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error)
+	Following(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error)
+	// Counts tallies how many followers each of userIDs has and how many
+	// users each follows. Users with none are missing from the maps.
+	Counts(ctx context.Context, userIDs []uint) (followers, following map[uint]int64, err error)
+}
+
+// followUser serves POST /users/:id/follow, making the caller follow the
+// user, and DELETE /users/:id/follow, unfollowing them. Both respond with
+// the user and their updated counts, and repeating either changes nothing.
+func (a *API) followUser(c *gin.Context) {
+	id, ok := a.userID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := a.users.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+
+	follower, _ := currentUser(c)
+	if follower.ID == user.ID {
+		respondError(c, http.StatusBadRequest, "You can't follow yourself")
+		return
+	}
+	if c.Request.Method == http.MethodDelete {
+		err = a.follows.Remove(ctx, follower.ID, user.ID)
+	} else if !a.checkNotBlocked(c, user, "You can't follow this user") {
+		return
+	} else {
+		err = a.follows.Add(ctx, &Follow{FollowerID: follower.ID, FolloweeID: user.ID})
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update follows")
+		return
+	}
+	activity := Activity{UserID: follower.ID, Type: ActivityFollowed, TargetUserID: user.ID}
+	a.recordActivity(c, activity, c.Request.Method == http.MethodDelete)
+
+	if !a.countUserFollows(c, &user) {
+		return
+	}
+	respond(c, http.StatusOK, "", user, nil)
+}
+
+// getFollowers serves GET /users/:id/followers, the users following a user,
+// a page at a time in the order they followed.
+func (a *API) getFollowers(c *gin.Context) {
+	a.listFollows(c, true)
+}
+
+// getFollowing serves GET /users/:id/following, the users a user follows,
+// a page at a time in the order they were followed.
+func (a *API) getFollowing(c *gin.Context) {
+	a.listFollows(c, false)
+}
+
+// listFollows lists the :id user's followers, or the users they follow.
+// Deleted users are left out of the page.
+func (a *API) listFollows(c *gin.Context, followers bool) {
+	user, ok := a.pathUser(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	followerCounts, followingCounts, err := a.follows.Counts(ctx, []uint{user.ID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch follows")
+		return
+	}
+	list, other, total := a.follows.Following, func(follow Follow) uint { return follow.FolloweeID }, followingCounts[user.ID]
+	if followers {
+		list, other, total = a.follows.Followers, func(follow Follow) uint { return follow.FollowerID }, followerCounts[user.ID]
+	}
+
+	follows, err := list(ctx, user.ID, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch follows")
+		return
+	}
+	ids := make([]uint, len(follows))
+	for i, follow := range follows {
+		ids[i] = other(follow)
+	}
+	found, err := a.users.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	byID := make(map[uint]User, len(found))
+	for _, u := range found {
+		byID[u.ID] = u
+	}
+	users := []User{}
+	for _, id := range ids {
+		if u, ok := byID[id]; ok {
+			users = append(users, u)
+		}
+	}
+	if err := a.countFollows(ctx, users); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch follows")
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "users", users, gin.H{
+		"count":      len(users),
+		"pagination": page.meta(total),
+	})
+}
+
+// countFollows fills in the follower and following counts of users.
+func (a *API) countFollows(ctx context.Context, users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	followers, following, err := a.follows.Counts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		users[i].FollowersCount = followers[users[i].ID]
+		users[i].FollowingCount = following[users[i].ID]
+	}
+	return nil
+}
+
+// countUserFollows fills in the follow counts of a single user, writing the
+// error response and returning false if it can't.
+func (a *API) countUserFollows(c *gin.Context, user *User) bool {
+	users := []User{*user}
+	if err := a.countFollows(c.Request.Context(), users); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch follows")
+		return false
+	}
+	*user = users[0]
+	return true
+}
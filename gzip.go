@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compression gzips responses for clients that accept it, once they reach
+// minBytes and if their Content-Type is one of types, given as a full media
+// type ("application/json") or a prefix ending in "/" ("text/").
+type compression struct {
+	level    int
+	minBytes int
+	types    []string
+	pool     sync.Pool
+}
+
+// newCompression reads GZIP_LEVEL (1 to 9, -1 for gzip's default, 0
+// disables compression), GZIP_MIN_BYTES and GZIP_TYPES, a comma-separated
+// list of content types.
+func newCompression() *compression {
+	c := &compression{
+		level:    envInt("GZIP_LEVEL", gzip.DefaultCompression),
+		minBytes: envInt("GZIP_MIN_BYTES", 1024),
+		types:    []string{"application/json", "text/"},
+	}
+	if c.level < gzip.DefaultCompression || c.level > gzip.BestCompression {
+		log.Fatalf("invalid GZIP_LEVEL %d; want -1 to 9", c.level)
+	}
+	if types := os.Getenv("GZIP_TYPES"); types != "" {
+		c.types = strings.Split(types, ",")
+		for i := range c.types {
+			c.types[i] = strings.ToLower(strings.TrimSpace(c.types[i]))
+		}
+	}
+	c.pool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, c.level)
+		return w
+	}
+	return c
+}
+
+// middleware compresses the response if the client accepts gzip. The body
+// is held back until it reaches minBytes, so small responses go out as they
+// are.
+func (z *compression) middleware(c *gin.Context) {
+	if z.level == gzip.NoCompression || c.Request.Method == http.MethodHead ||
+		c.GetHeader("Range") != "" || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+		c.Next()
+		return
+	}
+
+	original := c.Writer
+	w := &gzipWriter{ResponseWriter: original, compression: z}
+	c.Writer = w
+	w.Header().Add("Vary", "Accept-Encoding")
+	// Deferred so that if the handler panics, whatever it wrote is flushed
+	// and recoverPanics writes its response to the real writer.
+	defer func() {
+		w.finish(c.Request.Context())
+		c.Writer = original
+	}()
+	c.Next()
+}
+
+// compressible reports whether responses of contentType are compressed.
+func (z *compression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range z.types {
+		if mediaType == t || strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				weight, err := strconv.ParseFloat(q, 64)
+				return err == nil && weight > 0
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter buffers the body until it reaches minBytes, then decides
+// whether to compress it.
+type gzipWriter struct {
+	gin.ResponseWriter
+	compression *compression
+
+	buf     bytes.Buffer
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf.Write(data)
+	if w.buf.Len() >= w.compression.minBytes {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written counts a body still held back as written.
+func (w *gzipWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// decide starts compressing if big is true and the response is of a
+// compressible type not already encoded, and writes out what was buffered.
+func (w *gzipWriter) decide(big bool) error {
+	w.decided = true
+	header := w.Header()
+	status := w.Status()
+	if big && header.Get("Content-Encoding") == "" && status != http.StatusNoContent &&
+		status != http.StatusNotModified && w.compression.compressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		// The compressed bytes differ, so a strong ETag no longer applies.
+		if tag := header.Get("ETag"); strings.HasPrefix(tag, `"`) {
+			header.Set("ETag", "W/"+tag)
+		}
+		w.gz = w.compression.pool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf.Bytes())
+		return err
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
+
+// finish writes out a body too small to compress, or ends the compressed
+// one.
+func (w *gzipWriter) finish(ctx context.Context) {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			logf(ctx, "gzip: %v", err)
+		}
+		return
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			logf(ctx, "gzip: %v", err)
+		}
+		w.gz.Reset(nil)
+		w.compression.pool.Put(w.gz)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (a *API) health(c *gin.Context) {
+	status := http.StatusOK
+	body := gin.H{
+		"status":    "healthy",
+		"service":   "gin-golang-api",
+		"timestamp": time.Now().UTC(),
+	}
+
+	if a.db != nil {
+		database := gin.H{"status": "up"}
+		sqlDB, err := a.db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(c.Request.Context())
+		}
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			body["status"] = "unhealthy"
+			database["status"] = "down"
+			// The endpoint is public, so the details only go to the log.
+			logf(c.Request.Context(), "health: database unreachable: %v", err)
+			database["error"] = "unavailable"
+		}
+		if sqlDB != nil {
+			stats := sqlDB.Stats()
+			database["pool"] = gin.H{
+				"max_open":             stats.MaxOpenConnections,
+				"open":                 stats.OpenConnections,
+				"in_use":               stats.InUse,
+				"idle":                 stats.Idle,
+				"wait_count":           stats.WaitCount,
+				"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+				"max_idle_closed":      stats.MaxIdleClosed,
+				"max_idle_time_closed": stats.MaxIdleTimeClosed,
+				"max_lifetime_closed":  stats.MaxLifetimeClosed,
+			}
+		}
+		body["database"] = database
+	}
+	if circuits := circuitStates(); len(circuits) > 0 {
+		body["circuits"] = circuits
+	}
+
+	c.JSON(status, body)
+}
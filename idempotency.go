@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyStore remembers the responses to requests sent with an
+// Idempotency-Key header.
+type IdempotencyStore interface {
+	// Reserve claims key for a new request. If key is already claimed it
+	// returns the existing record instead, with reserved == false.
+	Reserve(ctx context.Context, key string, record idempotencyRecord, ttl time.Duration) (existing idempotencyRecord, reserved bool, err error)
+	// Complete stores the response to the request that reserved key.
+	Complete(ctx context.Context, key string, record idempotencyRecord, ttl time.Duration) error
+	// Release forgets key, so that the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// idempotencyRecord is what an IdempotencyStore holds for a key: a hash of
+// the request body and, once the request has completed, its response.
+type idempotencyRecord struct {
+	BodyHash string          `json:"body_hash"`
+	Response *storedResponse `json:"response,omitempty"`
+}
+
+// storedResponse is a response captured for replay.
+type storedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// maxIdempotencyKeyLength caps the length of Idempotency-Key headers.
+const maxIdempotencyKeyLength = 255
+
+// idempotencyConfig holds the store and how long responses are replayed.
+type idempotencyConfig struct {
+	store IdempotencyStore
+	ttl   time.Duration
+}
+
+// newIdempotencyConfig reads IDEMPOTENCY_TTL and IDEMPOTENCY_STORE
+// ("memory", the default, or "redis").
+func newIdempotencyConfig() idempotencyConfig {
+	cfg := idempotencyConfig{ttl: envDuration("IDEMPOTENCY_TTL", 24*time.Hour)}
+	switch store := os.Getenv("IDEMPOTENCY_STORE"); store {
+	case "", "memory":
+		cfg.store = newMemoryIdempotencyStore()
+	case "redis":
+		cfg.store = newRedisIdempotencyStore()
+	default:
+		log.Fatalf("unsupported IDEMPOTENCY_STORE %q", store)
+	}
+	return cfg
+}
+
+// idempotent makes a POST safe to retry. The first request with a given
+// Idempotency-Key header runs normally and its response is stored; retries
+// with the same key, from the same user to the same path, get the stored
+// response back instead of running again. Reusing a key with a different
+// body is rejected with 422, and retrying while the first request is still
+// running with 409. Server errors aren't stored, so those can be retried.
+func (a *API) idempotent(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		abortWithError(c, http.StatusBadRequest, "Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		if bodyTooLarge(c, err) {
+			return
+		}
+		abortWithError(c, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	user, _ := currentUser(c)
+	scoped := sha256.Sum256([]byte(strconv.FormatUint(uint64(user.ID), 10) + "\x00" + c.Request.Method + " " + c.Request.URL.Path + "\x00" + key))
+	storeKey := hex.EncodeToString(scoped[:])
+	bodyHash := sha256.Sum256(body)
+	record := idempotencyRecord{BodyHash: hex.EncodeToString(bodyHash[:])}
+
+	ctx := c.Request.Context()
+	existing, reserved, err := a.idempotency.store.Reserve(ctx, storeKey, record, a.idempotency.ttl)
+	if err != nil {
+		// Serve the request without the guarantee rather than not at all.
+		logf(c.Request.Context(), "idempotency: %v", err)
+		c.Next()
+		return
+	}
+	if !reserved {
+		switch {
+		case existing.BodyHash != record.BodyHash:
+			abortWithError(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		case existing.Response == nil:
+			abortWithError(c, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+		default:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(existing.Response.Status, existing.Response.ContentType, existing.Response.Body)
+			c.Abort()
+		}
+		return
+	}
+
+	// Store the outcome even if the client has gone away.
+	ctx = context.WithoutCancel(ctx)
+	// Unless the response gets stored, release the key so retries can go
+	// ahead; deferred so that happens when the handler panics too.
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		if err := a.idempotency.store.Release(ctx, storeKey); err != nil {
+			logf(c.Request.Context(), "idempotency: %v", err)
+		}
+	}()
+
+	capture := &capturingWriter{ResponseWriter: c.Writer}
+	c.Writer = capture
+	c.Next()
+
+	status := capture.Status()
+	if status >= http.StatusInternalServerError {
+		return
+	}
+	record.Response = &storedResponse{
+		Status:      status,
+		ContentType: capture.Header().Get("Content-Type"),
+		Body:        capture.body.Bytes(),
+	}
+	if err := a.idempotency.store.Complete(ctx, storeKey, record, a.idempotency.ttl); err != nil {
+		logf(c.Request.Context(), "idempotency: %v", err)
+		return
+	}
+	completed = true
+}
+
+// capturingWriter keeps a copy of the response body as it is written.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// memoryIdempotencyStore keeps records in process memory, so retries must
+// reach the same instance to be recognised.
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryIdempotencyEntry
+	lastPrune time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	record    idempotencyRecord
+	expiresAt time.Time
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]memoryIdempotencyEntry{}}
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, key string, record idempotencyRecord, ttl time.Duration) (idempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > time.Minute {
+		s.lastPrune = now
+		for k, entry := range s.records {
+			if !now.Before(entry.expiresAt) {
+				delete(s.records, k)
+			}
+		}
+	}
+
+	if entry, ok := s.records[key]; ok && now.Before(entry.expiresAt) {
+		return entry.record, false, nil
+	}
+	s.records[key] = memoryIdempotencyEntry{record: record, expiresAt: now.Add(ttl)}
+	return idempotencyRecord{}, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key string, record idempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryIdempotencyEntry{record: record, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// useUUIDs switches the public IDs of users and posts, in JSON and in path
+// parameters, from their numeric primary keys to their UUIDv7s. Records
+// always carry both; the numeric key stays the internal join key.
+var useUUIDs bool
+
+// configureIDFormat reads ID_FORMAT ("int", the default, or "uuid").
+func configureIDFormat() {
+	switch format := os.Getenv("ID_FORMAT"); format {
+	case "", "int":
+		useUUIDs = false
+	case "uuid":
+		useUUIDs = true
+	default:
+		log.Fatalf("unsupported ID_FORMAT %q", format)
+	}
+}
+
+// newUUID returns a new time-ordered UUIDv7 string.
+func newUUID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// userID resolves the :id path parameter to a user's primary key, writing
+// the error response and returning ok == false if it can't.
+func (a *API) userID(c *gin.Context) (id uint, ok bool) {
+	return resolveID(c, "user", a.users.ResolveUUID)
+}
+
+// postID resolves the :id path parameter to a post's primary key, writing
+// the error response and returning ok == false if it can't.
+func (a *API) postID(c *gin.Context) (id uint, ok bool) {
+	return resolveID(c, "post", a.posts.ResolveUUID)
+}
+
+func resolveID(c *gin.Context, kind string, resolve func(context.Context, string) (uint, error)) (uint, bool) {
+	id, err := parseID(c.Request.Context(), c.Param("id"), resolve)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidID):
+			respondError(c, http.StatusBadRequest, "Invalid "+kind+" ID")
+		case errors.Is(err, ErrNotFound):
+			respondError(c, http.StatusNotFound, strings.ToUpper(kind[:1])+kind[1:]+" not found")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to fetch "+kind)
+		}
+		return 0, false
+	}
+	return id, true
+}
+
+// errInvalidID is returned by parseID for strings that aren't IDs in the
+// configured format.
+var errInvalidID = errors.New("invalid ID")
+
+// parseID resolves a public ID to a primary key. It returns errInvalidID if
+// param isn't an ID and, in UUID mode, ErrNotFound if no record has it.
+func parseID(ctx context.Context, param string, resolve func(context.Context, string) (uint, error)) (uint, error) {
+	if !useUUIDs {
+		id, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			return 0, errInvalidID
+		}
+		return uint(id), nil
+	}
+
+	if _, err := uuid.Parse(param); err != nil {
+		return 0, errInvalidID
+	}
+	return resolve(ctx, strings.ToLower(param))
+}
+
+// validPublicID reports whether param is a nonzero ID in the configured
+// format, without resolving it.
+func validPublicID(param string) bool {
+	if useUUIDs {
+		_, err := uuid.Parse(param)
+		return err == nil
+	}
+	id, err := strconv.ParseUint(param, 10, 32)
+	return err == nil && id != 0
+}
+
+// publicID is a public ID in a request body. It takes a JSON string, or a
+// number for numeric IDs.
+type publicID string
+
+func (p *publicID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = publicID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*p = publicID(n.String())
+	return nil
+}
+
+// parsePostID resolves a public post ID, as parseID does.
+func (a *API) parsePostID(ctx context.Context, param string) (uint, error) {
+	return parseID(ctx, param, a.posts.ResolveUUID)
+}
+
+// MarshalJSON renders the user with its UUID as "id" in UUID mode.
+func (u User) MarshalJSON() ([]byte, error) {
+	type plain User
+	if !useUUIDs {
+		return json.Marshal(plain(u))
+	}
+	return json.Marshal(struct {
+		ID string `json:"id"`
+		plain
+	}{u.UUID, plain(u)})
+}
+
+// MarshalJSON renders the post with its own and its author's UUIDs as "id"
+// and "author_id" in UUID mode.
+func (p Post) MarshalJSON() ([]byte, error) {
+	type plain Post
+	if !useUUIDs {
+		return json.Marshal(plain(p))
+	}
+	return json.Marshal(struct {
+		ID       string `json:"id"`
+		AuthorID string `json:"author_id"`
+		plain
+	}{p.UUID, p.AuthorUUID, plain(p)})
+}
+
+// MarshalJSON renders the linked post's UUID as "id" in UUID mode.
+func (l PostLink) MarshalJSON() ([]byte, error) {
+	type plain PostLink
+	if !useUUIDs {
+		return json.Marshal(plain(l))
+	}
+	return json.Marshal(struct {
+		ID string `json:"id"`
+		plain
+	}{l.UUID, plain(l)})
+}
+
+// MarshalJSON renders the linked user's UUID as "id" in UUID mode.
+func (l UserLink) MarshalJSON() ([]byte, error) {
+	type plain UserLink
+	if !useUUIDs {
+		return json.Marshal(plain(l))
+	}
+	return json.Marshal(struct {
+		ID string `json:"id"`
+		plain
+	}{l.UUID, plain(l)})
+}
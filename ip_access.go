@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trustProxies makes gin take the client IP from X-Forwarded-For and
+// X-Real-IP only on requests from the proxies in TRUSTED_PROXIES, a
+// comma-separated list of CIDR ranges or addresses. Unset, no proxy is
+// trusted and the client IP is always the connection's peer, so clients
+// can't get past the IP lists and limits by forging the headers.
+func trustProxies(r *gin.Engine) {
+	if err := r.SetTrustedProxies(envList("TRUSTED_PROXIES", "")); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+}
+
+// adminPathPrefixes are the paths the admin lists apply to as well.
+var adminPathPrefixes = []string{"/admin/", "/debug/pprof/"}
+
+// ipAccessFile is the layout of IP_ACCESS_FILE. Each list holds CIDR
+// ranges ("10.0.0.0/8") or single addresses.
+type ipAccessFile struct {
+	Allow      []string `json:"allow"`
+	Deny       []string `json:"deny"`
+	AdminAllow []string `json:"admin_allow"`
+	AdminDeny  []string `json:"admin_deny"`
+}
+
+// ipRules are the parsed lists. A client matching a deny list is refused;
+// otherwise, if the allow list isn't empty, only clients matching it are
+// let in.
+type ipRules struct {
+	allow, deny           []netip.Prefix
+	adminAllow, adminDeny []netip.Prefix
+}
+
+// ipAccess refuses requests from client IPs the rules in IP_ACCESS_FILE
+// exclude, reloading the file when it changes.
+type ipAccess struct {
+	path     string
+	rules    atomic.Pointer[ipRules]
+	modified time.Time
+}
+
+// newIPAccess reads IP_ACCESS_FILE, if set. A file that can't be loaded at
+// startup is a fatal configuration error.
+func newIPAccess() *ipAccess {
+	a := &ipAccess{path: os.Getenv("IP_ACCESS_FILE")}
+	a.rules.Store(&ipRules{})
+	if a.path != "" {
+		if err := a.reload(); err != nil {
+			log.Fatalf("invalid IP_ACCESS_FILE: %v", err)
+		}
+	}
+	return a
+}
+
+// watch reloads the file every interval if it has been modified, until ctx
+// is done. A file that fails to load is logged and the rules in force are
+// kept.
+func (a *ipAccess) watch(ctx context.Context, interval time.Duration) {
+	if a.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(a.path)
+			if err == nil && info.ModTime().Equal(a.modified) {
+				continue
+			}
+			if err == nil {
+				err = a.reload()
+			}
+			if err != nil {
+				log.Printf("ip access: keeping the current rules: %v", err)
+				continue
+			}
+			log.Printf("ip access: reloaded %s", a.path)
+		}
+	}
+}
+
+// reload reads and parses the file and puts its rules in force.
+func (a *ipAccess) reload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return err
+	}
+	var file ipAccessFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	var rules ipRules
+	for _, list := range []struct {
+		name  string
+		from  []string
+		parse *[]netip.Prefix
+	}{
+		{"allow", file.Allow, &rules.allow},
+		{"deny", file.Deny, &rules.deny},
+		{"admin_allow", file.AdminAllow, &rules.adminAllow},
+		{"admin_deny", file.AdminDeny, &rules.adminDeny},
+	} {
+		for _, entry := range list.from {
+			prefix, err := parseIPPrefix(entry)
+			if err != nil {
+				return fmt.Errorf("%s: %w", list.name, err)
+			}
+			*list.parse = append(*list.parse, prefix)
+		}
+	}
+	a.rules.Store(&rules)
+	a.modified = info.ModTime()
+	return nil
+}
+
+// parseIPPrefix parses a CIDR range or a single address.
+func parseIPPrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// middleware aborts with 403 if the client's IP is excluded, by the general
+// lists or, for admin paths, the admin lists as well.
+func (a *ipAccess) middleware(c *gin.Context) {
+	rules := a.rules.Load()
+	addr, _ := netip.ParseAddr(c.ClientIP())
+	addr = addr.Unmap()
+
+	allowed := ipAllowed(addr, rules.allow, rules.deny)
+	if allowed && isAdminPath(c.Request.URL.Path) {
+		allowed = ipAllowed(addr, rules.adminAllow, rules.adminDeny)
+	}
+	if !allowed {
+		abortWithError(c, http.StatusForbidden, "Requests from this address are not allowed")
+		return
+	}
+	c.Next()
+}
+
+// ipAllowed applies one pair of lists to addr. An address that can't be
+// parsed only gets through when allow is empty.
+func ipAllowed(addr netip.Addr, allow, deny []netip.Prefix) bool {
+	if !addr.IsValid() {
+		return len(allow) == 0
+	}
+	if matchesAny(addr, deny) {
+		return false
+	}
+	return len(allow) == 0 || matchesAny(addr, allow)
+}
+
+func matchesAny(addr netip.Addr, prefixes []netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAdminPath(path string) bool {
+	for _, prefix := range adminPathPrefixes {
+		if strings.HasPrefix(path+"/", prefix) {
+			return true
+		}
+	}
+	return false
+}
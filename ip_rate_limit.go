@@ -0,0 +1,155 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenRate is a token bucket's refill rate, in requests per second, and how
+// many requests it holds when full. A rate of 0 or less disables the limit.
+type tokenRate struct {
+	perSecond float64
+	burst     int
+}
+
+// ipLimiter throttles requests per client IP with token buckets: each
+// client gets burst requests at once, then perSecond after that. Routes in
+// perRoute, keyed like "POST /auth/login", have their own rate and bucket;
+// every other route shares the default one. Buckets are kept in process
+// memory, so each instance limits clients separately.
+type ipLimiter struct {
+	defaultRate tokenRate
+	perRoute    map[string]tokenRate
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newIPLimiter reads IP_RATE_LIMIT, IP_RATE_LIMIT_BURST and
+// IP_RATE_LIMIT_ROUTES, a comma-separated list of per-route rates such as
+// "POST /auth/login=0.5:5", each requests per second and burst.
+func newIPLimiter() *ipLimiter {
+	l := &ipLimiter{
+		defaultRate: tokenRate{
+			perSecond: envFloat("IP_RATE_LIMIT", 20),
+			burst:     envInt("IP_RATE_LIMIT_BURST", 40),
+		},
+		perRoute: map[string]tokenRate{},
+		buckets:  map[string]*tokenBucket{},
+	}
+	if routes := os.Getenv("IP_RATE_LIMIT_ROUTES"); routes != "" {
+		for _, entry := range strings.Split(routes, ",") {
+			route, rate, ok := parseRouteRate(entry)
+			if !ok {
+				log.Fatalf("invalid IP_RATE_LIMIT_ROUTES entry %q; want like \"POST /auth/login=0.5:5\"", entry)
+			}
+			l.perRoute[route] = rate
+		}
+	}
+	return l
+}
+
+// parseRouteRate parses one IP_RATE_LIMIT_ROUTES entry.
+func parseRouteRate(entry string) (string, tokenRate, bool) {
+	route, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+	if !ok || len(strings.Fields(route)) != 2 {
+		return "", tokenRate{}, false
+	}
+	perSecond, burst, ok := strings.Cut(spec, ":")
+	if !ok {
+		return "", tokenRate{}, false
+	}
+	rate := tokenRate{}
+	var err error
+	if rate.perSecond, err = strconv.ParseFloat(perSecond, 64); err != nil {
+		return "", tokenRate{}, false
+	}
+	if rate.burst, err = strconv.Atoi(burst); err != nil || rate.burst < 1 {
+		return "", tokenRate{}, false
+	}
+	return strings.Join(strings.Fields(route), " "), rate, true
+}
+
+// middleware takes a token from the client's bucket for the route, sets the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers and, if
+// the bucket is empty, aborts with 429 and a Retry-After header.
+func (l *ipLimiter) middleware(c *gin.Context) {
+	route := c.Request.Method + " " + c.FullPath()
+	rate, ok := l.perRoute[route]
+	if !ok {
+		route, rate = "", l.defaultRate
+	}
+	if rate.perSecond <= 0 {
+		c.Next()
+		return
+	}
+
+	remaining, wait := l.take(route+"|"+c.ClientIP(), rate)
+	c.Header("RateLimit-Limit", strconv.Itoa(rate.burst))
+	c.Header("RateLimit-Remaining", strconv.Itoa(int(remaining)))
+	refill := time.Duration((float64(rate.burst) - remaining) / rate.perSecond * float64(time.Second))
+	c.Header("RateLimit-Reset", strconv.Itoa(int(math.Ceil(refill.Seconds()))))
+
+	if wait > 0 {
+		setRetryAfter(c, wait)
+		abortWithError(c, http.StatusTooManyRequests, "Too many requests from this address")
+		return
+	}
+	c.Next()
+}
+
+// take refills the bucket for key by the time since it was last used and
+// takes a token from it, returning the tokens left and, if there wasn't a
+// whole one to take, how long until there is.
+func (l *ipLimiter) take(key string, rate tokenRate) (float64, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > time.Minute {
+		l.lastPrune = now
+		// Buckets that have refilled are the same as new ones.
+		for k, b := range l.buckets {
+			r := l.rateOf(k)
+			if b.tokens+now.Sub(b.last).Seconds()*r.perSecond >= float64(r.burst) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(rate.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(rate.burst), b.tokens+now.Sub(b.last).Seconds()*rate.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return b.tokens, time.Duration((1 - b.tokens) / rate.perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return b.tokens, 0
+}
+
+// rateOf returns the rate of the bucket keyed key.
+func (l *ipLimiter) rateOf(key string) tokenRate {
+	route, _, _ := strings.Cut(key, "|")
+	if rate, ok := l.perRoute[route]; ok {
+		return rate
+	}
+	return l.defaultRate
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatedSuffix is the layout of the timestamp appended to rotated log
+// files, which sorts them oldest first.
+const rotatedSuffix = "20060102T150405.000"
+
+// rotatingFile is a log file that is renamed aside, to path plus a
+// timestamp, once it grows past maxBytes or is older than maxAge, with a
+// new one started in its place. Rotated files older than retention, and
+// those beyond the newest maxFiles, are deleted. A limit of 0 or less
+// disables it.
+type rotatingFile struct {
+	path      string
+	maxBytes  int64
+	maxAge    time.Duration
+	retention time.Duration
+	maxFiles  int
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// openRotatingFile opens path for appending, creating it and its directory
+// if needed.
+func openRotatingFile(path string, maxBytes int64, maxAge, retention time.Duration, maxFiles int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxBytes: maxBytes, maxAge: maxAge, retention: retention, maxFiles: maxFiles}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past maxBytes or
+// the file is older than maxAge. A single write is never split across
+// files.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tooBig := f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes
+	tooOld := f.maxAge > 0 && time.Since(f.opened) >= f.maxAge
+	if tooBig || tooOld {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file aside, starts a new one and deletes
+// rotated files past retention.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, f.path+"."+time.Now().UTC().Format(rotatedSuffix)); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// prune deletes rotated files older than retention or beyond the newest
+// maxFiles. Failures are left for the next rotation.
+func (f *rotatingFile) prune() {
+	rotated, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+	kept := 0
+	for _, path := range rotated {
+		stamp, err := time.Parse(rotatedSuffix, path[len(f.path)+1:])
+		if err != nil {
+			continue
+		}
+		if (f.maxFiles > 0 && kept >= f.maxFiles) || (f.retention > 0 && time.Since(stamp) > f.retention) {
+			os.Remove(path)
+			continue
+		}
+		kept++
+	}
+}
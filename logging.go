@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redacted replaces the values of sensitive log fields.
+const redacted = "[REDACTED]"
+
+// sensitiveLogKeys are log field names, in lower case, whose values are
+// always redacted.
+var sensitiveLogKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"x-api-key":     true,
+	"x-debug-token": true,
+	"password":      true,
+	"token":         true,
+	"email":         true,
+}
+
+// emailPattern matches email addresses in log messages and field values.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@([A-Za-z0-9-]+\.)+[A-Za-z]{2,}`)
+
+// accessLogSampleRate is the fraction of successful requests the access log
+// records; see configureLogging.
+var accessLogSampleRate = 1.0
+
+// accessLogger writes the access log: to stderr like every other log line,
+// and to ACCESS_LOG_FILE as well if it is set.
+var accessLogger = slog.Default()
+
+// configureLogging sets up the default logger, which the log package writes
+// through as well, from LOG_LEVEL ("debug", "info", the default, "warn" or
+// "error"), LOG_FORMAT ("console", the default, or "json"), LOG_REDACT
+// ("true", the default, or "false") and LOG_SAMPLE_RATE, the fraction of
+// requests answered below 400 the access log records (1 by default).
+//
+// ACCESS_LOG_FILE names a file the access log is also written to, rotated
+// after ACCESS_LOG_MAX_BYTES or ACCESS_LOG_MAX_AGE, with rotated files kept
+// for ACCESS_LOG_RETENTION, up to ACCESS_LOG_MAX_FILES of them.
+func configureLogging() {
+	var level slog.Level
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			log.Fatalf("invalid LOG_LEVEL %q: %v", value, err)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if os.Getenv("LOG_REDACT") != "false" {
+		opts.ReplaceAttr = redactAttr
+	}
+
+	format := os.Getenv("LOG_FORMAT")
+	newLogger := func(w io.Writer) *slog.Logger {
+		var handler slog.Handler
+		switch format {
+		case "", "console":
+			handler = slog.NewTextHandler(w, opts)
+		case "json":
+			handler = slog.NewJSONHandler(w, opts)
+		default:
+			log.Fatalf("unsupported LOG_FORMAT %q", format)
+		}
+		return slog.New(requestIDHandler{handler})
+	}
+	slog.SetDefault(newLogger(os.Stderr))
+
+	accessLogger = slog.Default()
+	if path := os.Getenv("ACCESS_LOG_FILE"); path != "" {
+		file, err := openRotatingFile(path,
+			int64(envInt("ACCESS_LOG_MAX_BYTES", 100<<20)),
+			envDuration("ACCESS_LOG_MAX_AGE", 24*time.Hour),
+			envDuration("ACCESS_LOG_RETENTION", 7*24*time.Hour),
+			envInt("ACCESS_LOG_MAX_FILES", 10))
+		if err != nil {
+			log.Fatalf("failed to open ACCESS_LOG_FILE: %v", err)
+		}
+		accessLogger = newLogger(io.MultiWriter(os.Stderr, file))
+	}
+
+	accessLogSampleRate = envFloat("LOG_SAMPLE_RATE", 1)
+	if accessLogSampleRate < 0 || accessLogSampleRate > 1 {
+		log.Fatalf("invalid LOG_SAMPLE_RATE %v; want 0 to 1", accessLogSampleRate)
+	}
+}
+
+// redactAttr hides the values of sensitiveLogKeys and masks email addresses
+// anywhere else, message included.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveLogKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	if a.Value.Kind() == slog.KindString {
+		if s := a.Value.String(); strings.Contains(s, "@") {
+			return slog.String(a.Key, emailPattern.ReplaceAllString(s, redacted))
+		}
+	}
+	return a
+}
+
+// requestIDHandler adds the ID of the request a record was logged for, if
+// any, as a request_id field, and the trace it belongs to, if traced, as
+// trace_id and span_id.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// logf logs a warning like log.Printf, with the ID of the request ctx
+// belongs to, if any.
+func logf(ctx context.Context, format string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(format, args...))
+}
+
+// accessLog logs each request once it has been handled: server errors at
+// error level, client errors at warn and the rest at info, sampled at
+// accessLogSampleRate. At debug level the request headers are logged too.
+func accessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	status := c.Writer.Status()
+	level := slog.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	case accessLogSampleRate < 1 && rand.Float64() >= accessLogSampleRate:
+		return
+	}
+
+	ctx := c.Request.Context()
+	attrs := []slog.Attr{
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.String("route", c.FullPath()),
+		slog.Int("status", status),
+		slog.Duration("latency", time.Since(start)),
+		slog.Int("size", c.Writer.Size()),
+		slog.String("ip", c.ClientIP()),
+		slog.String("user_agent", c.Request.UserAgent()),
+	}
+	if user, ok := currentUser(c); ok {
+		attrs = append(attrs, slog.Any("user_id", user.ID))
+	}
+	if accessLogger.Enabled(ctx, slog.LevelDebug) {
+		headers := make([]interface{}, 0, len(c.Request.Header))
+		for name, values := range c.Request.Header {
+			headers = append(headers, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+		}
+		attrs = append(attrs, slog.Group("headers", headers...))
+	}
+	accessLogger.LogAttrs(ctx, level, "request", attrs...)
+}
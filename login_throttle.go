@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginThrottle counts failed logins per account and per client IP. Once a
+// key has used up its free attempts every further failure locks it out for
+// twice as long as the last, up to maxLockout. Counters are kept in process
+// memory, so each instance throttles independently.
+type loginThrottle struct {
+	mu        sync.Mutex
+	attempts  map[string]*loginAttempts
+	lastPrune time.Time
+
+	maxPerAccount int
+	maxPerIP      int
+	baseLockout   time.Duration
+	maxLockout    time.Duration
+	// window is how long a key must go without failures before its count
+	// starts over.
+	window time.Duration
+}
+
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// newLoginThrottle reads LOGIN_MAX_ATTEMPTS, LOGIN_MAX_ATTEMPTS_PER_IP,
+// LOGIN_LOCKOUT_BASE, LOGIN_LOCKOUT_MAX and LOGIN_ATTEMPT_WINDOW.
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{
+		attempts:      map[string]*loginAttempts{},
+		maxPerAccount: envInt("LOGIN_MAX_ATTEMPTS", 5),
+		maxPerIP:      envInt("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+		baseLockout:   envDuration("LOGIN_LOCKOUT_BASE", 30*time.Second),
+		maxLockout:    envDuration("LOGIN_LOCKOUT_MAX", 15*time.Minute),
+		window:        envDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+	}
+}
+
+func accountKey(username string) string { return "account:" + username }
+func ipKey(ip string) string            { return "ip:" + ip }
+
+// locked returns how much longer the account or IP is locked out for, or 0
+// if neither is.
+func (t *loginThrottle) locked(username, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{accountKey(username), ipKey(ip)} {
+		if a, ok := t.attempts[key]; ok && a.lockedUntil.After(now) {
+			if d := a.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// fail records a failed login for the account and IP.
+func (t *loginThrottle) fail(username, ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.prune(now)
+	t.record(accountKey(username), t.maxPerAccount, now)
+	t.record(ipKey(ip), t.maxPerIP, now)
+}
+
+// succeed clears the account's failures. The IP's are kept, so that one
+// valid login doesn't reset a credential stuffing run from that address.
+func (t *loginThrottle) succeed(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, accountKey(username))
+}
+
+func (t *loginThrottle) record(key string, free int, now time.Time) {
+	a, ok := t.attempts[key]
+	if !ok || now.Sub(a.lastFailure) > t.window {
+		a = &loginAttempts{}
+		t.attempts[key] = a
+	}
+	a.failures++
+	a.lastFailure = now
+
+	if over := a.failures - free; over > 0 {
+		lockout := time.Duration(float64(t.baseLockout) * math.Pow(2, float64(over-1)))
+		if lockout > t.maxLockout || lockout <= 0 {
+			lockout = t.maxLockout
+		}
+		a.lockedUntil = now.Add(lockout)
+	}
+}
+
+// prune forgets keys that are past their window and no longer locked, at
+// most once a minute.
+func (t *loginThrottle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+	t.lastPrune = now
+	for key, a := range t.attempts {
+		if now.Sub(a.lastFailure) > t.window && !a.lockedUntil.After(now) {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+// setRetryAfter sets the Retry-After header to wait, rounding up to a
+// whole second.
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
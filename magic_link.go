@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type RedeemMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// requestMagicLink emails a one-time login link to the account with the
+// given address. Like forgotPassword it responds the same way whether or not
+// there is one.
+func (a *API) requestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := a.users.GetByEmail(ctx, req.Email)
+	switch {
+	case err == nil:
+		token, err := a.issueOneTimeToken(ctx, user.ID, TokenPurposeMagicLink, a.magicLinkTTL)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to send login link")
+			return
+		}
+		a.sendMailAsync(user.Email, "Your login link",
+			"Hi "+user.Username+",\n\n"+
+				"Log in within "+a.magicLinkTTL.String()+" by opening:\n"+
+				appURL()+"/magic-link?token="+token+"\n\n"+
+				"If you didn't ask for this, ignore this email.\n")
+	case !errors.Is(err, ErrNotFound):
+		respondError(c, http.StatusInternalServerError, "Failed to send login link")
+		return
+	}
+
+	respond(c, http.StatusAccepted, "", gin.H{"message": "If the address is registered, a login link has been sent"}, nil)
+}
+
+// redeemMagicLink exchanges the token from a login link for an access token
+// and refresh token, as login does. Since following the link proves the
+// user controls their address, it also verifies their email.
+func (a *API) redeemMagicLink(c *gin.Context) {
+	var req RedeemMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := a.oneTimeTokens.Consume(ctx, TokenPurposeMagicLink, hashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusUnauthorized, "Invalid or expired login link")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	user, err := a.users.Get(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusUnauthorized, "Invalid or expired login link")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	if !user.EmailVerified {
+		err := a.modifyUser(ctx, user.ID, func(u *User) { u.EmailVerified = true })
+		if err != nil {
+			logf(c.Request.Context(), "magic link: failed to verify email of user %d: %v", user.ID, err)
+		} else if updated, err := a.users.Get(ctx, user.ID); err == nil {
+			user = updated
+		}
+	}
+
+	a.audit(c, AuditLogin, user, "magic link")
+	a.respondWithTokens(c, http.StatusOK, user, newUUID())
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Mailer sends plain-text email.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// newMailer returns an SMTP mailer if SMTP_HOST is set, and otherwise one
+// that only logs messages.
+func newMailer() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return logMailer{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return &smtpMailer{addr: host + ":" + port, from: from, auth: auth}
+}
+
+type logMailer struct{}
+
+func (logMailer) Send(ctx context.Context, to, subject, body string) error {
+	log.Printf("mail: to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
+
+// smtpMailer delivers mail through an SMTP relay, using STARTTLS when the
+// server offers it.
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func (m *smtpMailer) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("mail: invalid header value")
+	}
+
+	msg := "From: " + m.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg))
+}
+
+// appURL returns APP_URL, the public base URL links in emails point at,
+// without a trailing slash.
+func appURL() string {
+	url := os.Getenv("APP_URL")
+	if url == "" {
+		url = "http://localhost:8080"
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// sendMailAsync sends mail in the background so that request latency doesn't
+// reveal whether a message was sent. Failures are logged.
+func (a *API) sendMailAsync(to, subject, body string) {
+	go func() {
+		if err := a.mailer.Send(context.Background(), to, subject, body); err != nil {
+			log.Printf("mail: failed to send %q to %s: %v", subject, to, err)
+		}
+	}()
+}
@@ -1,65 +1,332 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-contrib/logger"
+	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uint      `json:"id" gorm:"primary_key"`
-	Username  string    `json:"username" gorm:"unique;not null"`
-	Email     string    `json:"email" gorm:"unique;not null"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID        uint           `json:"id" gorm:"primary_key" bson:"_id"`
+	Username  string         `json:"username" gorm:"size:255;unique;not null" bson:"username"`
+	Email     string         `json:"email" gorm:"size:255;unique;not null" bson:"email"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime" bson:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index" bson:"deleted_at"`
+	Version   uint           `json:"version" gorm:"not null;default:1" bson:"version"`
+	UUID      string         `json:"-" gorm:"size:36;uniqueIndex" bson:"uuid"`
+	Role      string         `json:"role" gorm:"size:16;not null;default:reader" bson:"role"`
+	// EmailVerified is set once the user follows the link emailed on
+	// registration, and cleared when the email changes.
+	EmailVerified bool `json:"email_verified" gorm:"not null;default:false" bson:"email_verified"`
+	// ExternalID links the user to an OIDC provider identity, as
+	// "<issuer>|<subject>".
+	ExternalID *string `json:"-" gorm:"size:255;uniqueIndex" bson:"external_id,omitempty"`
+	// PasswordHash is empty for users created through POST /users, who
+	// cannot log in.
+	PasswordHash string `json:"-" gorm:"size:255" bson:"password_hash"`
+	// The profile fields are set by the user through PATCH /users/me.
+	DisplayName string `json:"display_name" gorm:"size:100;not null;default:''" bson:"display_name"`
+	Bio         string `json:"bio" gorm:"size:500;not null;default:''" bson:"bio"`
+	AvatarURL   string `json:"avatar_url" gorm:"size:2048;not null;default:''" bson:"avatar_url"`
+	Website     string `json:"website" gorm:"size:2048;not null;default:''" bson:"website"`
+	// Posts is only loaded for ?expand=posts.
+	Posts []Post `json:"posts,omitempty" gorm:"foreignKey:AuthorID" bson:"-"`
+	// FollowersCount and FollowingCount are filled in from the follows
+	// repository.
+	FollowersCount int64 `json:"followers_count" gorm:"-" bson:"-"`
+	FollowingCount int64 `json:"following_count" gorm:"-" bson:"-"`
 }
 
 type Post struct {
-	ID        uint      `json:"id" gorm:"primary_key"`
-	Title     string    `json:"title" gorm:"not null"`
-	Content   string    `json:"content" gorm:"not null"`
-	AuthorID  uint      `json:"author_id" gorm:"not null"`
-	Author    User      `json:"author" gorm:"foreignkey:AuthorID"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID       uint   `json:"id" gorm:"primary_key;index:idx_posts_created_at_id,priority:2" bson:"_id"`
+	Title    string `json:"title" gorm:"not null" bson:"title"`
+	Content  string `json:"content" gorm:"not null" bson:"content"`
+	AuthorID uint   `json:"author_id" gorm:"not null" bson:"author_id"`
+	// Author is only loaded for ?expand=author.
+	Author *User `json:"author,omitempty" gorm:"foreignkey:AuthorID" bson:"-"`
+	// Authors lists the author and then the co-authors, and is filled in
+	// from the co-authors repository.
+	Authors   []UserLink     `json:"authors" gorm:"-" bson:"-"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime;index:idx_posts_created_at_id,priority:1" bson:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime" bson:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index" bson:"deleted_at"`
+	Version   uint           `json:"version" gorm:"not null;default:1" bson:"version"`
+	UUID      string         `json:"-" gorm:"size:36;uniqueIndex" bson:"uuid"`
+	// AuthorUUID denormalizes the author's UUID so posts can be rendered
+	// with UUID author IDs without loading the author.
+	AuthorUUID string `json:"-" gorm:"size:36;index" bson:"author_uuid"`
+	// Status is "draft" or "published", and PublishedAt is when a published
+	// post last left draft.
+	Status      string     `json:"status" gorm:"size:16;not null;default:published;index" bson:"status"`
+	PublishedAt *time.Time `json:"published_at" bson:"published_at"`
+	// Visibility is "public", "unlisted" or "private".
+	Visibility string `json:"visibility" gorm:"size:16;not null;default:public;index" bson:"visibility"`
+	// ArchivedAt is when the post was archived, if it is. Archived posts are
+	// read-only and unlisted.
+	ArchivedAt *time.Time `json:"archived_at" bson:"archived_at"`
+	// WordCount and ReadingMinutes are computed from Content when it is
+	// written.
+	WordCount      int `json:"word_count" gorm:"not null;default:0" bson:"word_count"`
+	ReadingMinutes int `json:"reading_minutes" gorm:"not null;default:0" bson:"reading_minutes"`
+	// LikesCount and Reactions, the count of each reaction type, are
+	// filled in from the reactions repository.
+	LikesCount int64            `json:"likes_count" gorm:"-" bson:"-"`
+	Reactions  map[string]int64 `json:"reactions,omitempty" gorm:"-" bson:"-"`
+	// BookmarksCount is only filled in for the post's author and admins.
+	BookmarksCount *int64 `json:"bookmarks_count,omitempty" gorm:"-" bson:"-"`
+	// ViewsCount is filled in from the views repository.
+	ViewsCount int64 `json:"views_count" gorm:"-" bson:"-"`
+	// Mentions, the users the content @mentions, is filled in from the
+	// mentions repository.
+	Mentions []UserLink `json:"mentions" gorm:"-" bson:"-"`
+	// Poll, with its results, is filled in from the polls repository.
+	Poll *Poll `json:"poll" gorm:"-" bson:"-"`
+	// Series places the post in its series, if it is in one.
+	Series *SeriesNav `json:"series" gorm:"-" bson:"-"`
+	// Pinned is filled in from the pins repository.
+	Pinned bool `json:"pinned" gorm:"-" bson:"-"`
 }
 
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
+	// Role defaults to DEFAULT_ROLE on create and is left unchanged on
+	// update when omitted.
+	Role string `json:"role" binding:"omitempty,oneof=admin editor reader"`
 }
 
 type CreatePostRequest struct {
 	Title   string `json:"title" binding:"required"`
 	Content string `json:"content" binding:"required"`
+	// Status defaults to published on create and is left unchanged on
+	// update when omitted.
+	Status string `json:"status" binding:"omitempty,oneof=draft published"`
+	// Visibility defaults to the author's default_visibility setting on
+	// create and is left unchanged on update when omitted.
+	Visibility string `json:"visibility" binding:"omitempty,oneof=public unlisted private"`
 }
 
-var users []User
-var posts []Post
-var userCounter uint = 1
-var postCounter uint = 1
+type UpdateUserRequest struct {
+	CreateUserRequest
+	Version *uint `json:"version"`
+}
+
+type UpdatePostRequest struct {
+	CreatePostRequest
+	Version *uint `json:"version"`
+}
+
+// API holds the HTTP handlers and the storage they operate on.
+type API struct {
+	users         UserRepository
+	posts         PostRepository
+	refreshTokens RefreshTokenRepository
+	apiKeys       APIKeyRepository
+	oneTimeTokens OneTimeTokenRepository
+	db            *gorm.DB
+	tokens        *tokenIssuer
+	mailer        Mailer
+	sessions      sessionConfig
+	loginThrottle *loginThrottle
+	revocations   RevocationList
+	oidc          *oidcVerifier
+	rateLimits    *identityLimiter
+	auditLog      AuditRepository
+	reactions     ReactionRepository
+	follows       FollowRepository
+	bookmarks     BookmarkRepository
+	messages      MessageRepository
+	media         MediaRepository
+	mediaFiles    mediaConfig
+	activity      ActivityRepository
+	trending      *trending
+	related       *related
+	reports       ReportRepository
+	reportLimit   reportLimit
+	moderation    ModerationRepository
+	views         ViewRepository
+	viewDedup     time.Duration
+	blocks        BlockRepository
+	mentions      MentionRepository
+	polls         PollRepository
+	series        SeriesRepository
+	coAuthors     CoAuthorRepository
+	engagement    EngagementRepository
+	settings      SettingsRepository
+	pins          PinRepository
+	idempotency   idempotencyConfig
+	duplicates    duplicateConfig
+	// debugToken, if set, opens /debug/pprof to requests carrying it.
+	debugToken string
+	// signatureMaxSkew is how far the X-Timestamp of a signed request may
+	// be from now.
+	signatureMaxSkew time.Duration
+	// passwordResetTTL, emailVerificationTTL and magicLinkTTL are how long
+	// the links emailed by those flows stay valid.
+	passwordResetTTL     time.Duration
+	emailVerificationTTL time.Duration
+	magicLinkTTL         time.Duration
+}
+
+func NewAPI(storage Storage, tokens *tokenIssuer, mailer Mailer) *API {
+	return &API{
+		users:                storage.Users,
+		posts:                storage.Posts,
+		refreshTokens:        storage.RefreshTokens,
+		apiKeys:              storage.APIKeys,
+		oneTimeTokens:        storage.OneTimeTokens,
+		db:                   storage.DB,
+		tokens:               tokens,
+		mailer:               mailer,
+		sessions:             newSessionConfig(),
+		loginThrottle:        newLoginThrottle(),
+		revocations:          newRevocationList(),
+		oidc:                 newOIDCVerifier(),
+		rateLimits:           newIdentityLimiter(),
+		auditLog:             storage.AuditLog,
+		reactions:            storage.Reactions,
+		follows:              storage.Follows,
+		bookmarks:            storage.Bookmarks,
+		messages:             storage.Messages,
+		media:                storage.Media,
+		mediaFiles:           newMediaConfig(),
+		activity:             storage.Activity,
+		trending:             newTrending(storage.Reactions, storage.Views, storage.Posts),
+		related:              newRelated(storage.Posts),
+		reports:              storage.Reports,
+		reportLimit:          newReportLimit(),
+		moderation:           storage.Moderation,
+		views:                storage.Views,
+		viewDedup:            envDuration("VIEW_DEDUP_WINDOW", 30*time.Minute),
+		blocks:               storage.Blocks,
+		mentions:             storage.Mentions,
+		polls:                storage.Polls,
+		series:               storage.Series,
+		coAuthors:            storage.CoAuthors,
+		engagement:           storage.Engagement,
+		settings:             storage.Settings,
+		pins:                 storage.Pins,
+		idempotency:          newIdempotencyConfig(),
+		duplicates:           newDuplicateConfig(),
+		debugToken:           os.Getenv("DEBUG_TOKEN"),
+		signatureMaxSkew:     envDuration("SIGNATURE_MAX_SKEW", 5*time.Minute),
+		passwordResetTTL:     envDuration("PASSWORD_RESET_TTL", time.Hour),
+		emailVerificationTTL: envDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		magicLinkTTL:         envDuration("MAGIC_LINK_TTL", 15*time.Minute),
+	}
+}
 
 func main() {
+	configureLogging()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "seed":
+			runSeed(os.Args[2:])
+			return
+		case "role":
+			runRole(os.Args[2:])
+			return
+		}
+	}
+
+	configureIDFormat()
+	configureEnvelope()
+	configurePasswordHashing()
+	configureDefaultRole()
+	configureErrorReporting()
+	shutdownTracing := configureTracing()
+
+	storage := newStorage()
+	if os.Getenv("SEED") == "true" {
+		if err := seed(context.Background(), storage, envInt("SEED_USERS", 20), envInt("SEED_POSTS", 100)); err != nil {
+			log.Fatalf("seed failed: %v", err)
+		}
+	}
+
+	// background is cancelled on shutdown, stopping the background workers.
+	background, stopBackground := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	if storage.Outbox != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			runOutboxDispatcher(background, storage.Outbox, newPublisher(), envDuration("OUTBOX_POLL_INTERVAL", time.Second))
+		}()
+	}
+
+	api := NewAPI(storage, newTokenIssuer(), newMailer())
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		api.trending.run(background, envDuration("TRENDING_INTERVAL", 5*time.Minute))
+	}()
+
 	r := gin.New()
+	trustProxies(r)
 
 	// Middleware
-	r.Use(logger.SetLogger())
-	r.Use(gin.Recovery())
-	r.Use(cors.Default())
+	r.Use(traceRequests())
+	r.Use(assignRequestID)
+	r.Use(trackCircuitTrips)
+	r.Use(accessLog)
+	httpMetrics := newMetrics()
+	r.Use(httpMetrics.middleware)
+	ipRules := newIPAccess()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ipRules.watch(background, envDuration("IP_ACCESS_RELOAD_INTERVAL", 10*time.Second))
+	}()
+	r.Use(ipRules.middleware)
+	r.Use(recoverPanics)
+	r.Use(newCORS())
+	r.Use(newCompression().middleware)
+	r.Use(newIPLimiter().middleware)
+	r.Use(newBotFilter(api.hasCredentials).middleware)
+	r.Use(limitBodySize(int64(envInt("MAX_BODY_BYTES", 1<<20)), map[string]int64{
+		"POST /posts/:id/media": api.mediaFiles.maxBytes,
+	}))
+	r.Use(limitRequestTime(envDuration("REQUEST_TIMEOUT", 10*time.Second), map[string]time.Duration{
+		"POST /posts/:id/media": envDuration("MEDIA_UPLOAD_TIMEOUT", 2*time.Minute),
+		// These take ?seconds= to sample for.
+		"GET /debug/pprof/profile": 0,
+		"GET /debug/pprof/trace":   0,
+	}))
+	r.Use(limitConcurrency(map[string]concurrencyLimit{
+		"GET /posts/search":      {running: 8, queue: 16},
+		"GET /search/suggest":    {running: 16, queue: 32},
+		"GET /posts/:id/related": {running: 8, queue: 16},
+		"POST /users/bulk":       {running: 2, queue: 4},
+	}))
+	r.Use(negotiateEnvelope)
+	r.Use(api.cacheControl(map[string]string{
+		"GET /health":  cacheNoStore,
+		"GET /metrics": cacheNoStore,
+	}))
+	r.Use(api.auditMutations)
 
 	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":    "healthy",
-			"service":   "gin-golang-api",
-			"timestamp": time.Now().UTC(),
-		})
-	})
+	r.GET("/health", api.health)
+
+	// Prometheus metrics
+	r.GET("/metrics", httpMetrics.handler())
+
+	// Uploaded attachments
+	r.Static("/media", api.mediaFiles.dir)
 
 	// Root endpoint
 	r.GET("/", func(c *gin.Context) {
@@ -67,249 +334,839 @@ func main() {
 			"message": "Gin Golang API Starter",
 			"version": "1.0.0",
 			"endpoints": gin.H{
-				"health": "/health",
-				"users": gin.H{
-					"GET":    "/users",
-					"POST":   "/users",
-					"GET":    "/users/:id",
-					"PUT":    "/users/:id",
-					"DELETE": "/users/:id",
+				"health":  "/health",
+				"metrics": "/metrics",
+				"auth": []string{
+					"POST /auth/register",
+					"POST /auth/login",
+					"POST /auth/refresh",
+					"POST /auth/logout",
+					"POST /auth/magic-link",
+					"POST /auth/magic-link/redeem",
+					"POST /auth/session",
+					"DELETE /auth/session",
+					"POST /auth/forgot-password",
+					"POST /auth/reset-password",
+					"GET /auth/verify",
+					"POST /auth/verify/resend",
+				},
+				"api_keys": []string{
+					"GET /api-keys",
+					"POST /api-keys",
+					"DELETE /api-keys/:id",
+				},
+				"users": []string{
+					"GET /users",
+					"HEAD /users",
+					"GET /users/count",
+					"POST /users",
+					"POST /users/bulk",
+					"GET /users/:id",
+					"PUT /users/:id",
+					"DELETE /users/:id",
+					"GET /users/trash",
+					"POST /users/:id/restore",
+					"GET /users/me",
+					"PATCH /users/me",
+					"GET /users/me/bookmarks",
+					"GET /users/me/blocks",
+					"GET /users/me/settings",
+					"PATCH /users/me/settings",
+					"PUT /users/me/password",
+					"GET /users/:id/posts",
+					"POST /users/:id/posts",
+					"POST /users/:id/follow",
+					"DELETE /users/:id/follow",
+					"GET /users/:id/followers",
+					"GET /users/:id/following",
+					"GET /users/:id/activity",
+					"POST /users/:id/block",
+					"DELETE /users/:id/block",
+				},
+				"feed": []string{
+					"GET /feed",
+				},
+				"series": []string{
+					"POST /series",
+					"GET /series/:id",
+					"PUT /series/:id/posts",
+					"DELETE /series/:id",
+				},
+				"messages": []string{
+					"GET /messages",
+					"GET /messages/:id",
+					"POST /messages/:id",
+				},
+				"search": []string{
+					"GET /search/suggest",
 				},
-				"posts": gin.H{
-					"GET":    "/posts",
-					"POST":   "/posts",
-					"GET":    "/posts/:id",
-					"PUT":    "/posts/:id",
-					"DELETE": "/posts/:id",
+				"admin": []string{
+					"GET /admin/users",
+					"DELETE /admin/posts/:id",
+					"DELETE /admin/users/:id",
+					"POST /admin/users/:id/impersonate",
+					"GET /admin/stats",
+					"GET /admin/audit",
+					"GET /admin/reports",
+					"GET /admin/moderation",
+					"POST /admin/moderation/posts/:id",
+					"GET /admin/moderation/decisions",
+					"GET /debug/pprof/",
+				},
+				"posts": []string{
+					"GET /posts",
+					"HEAD /posts",
+					"GET /posts/count",
+					"POST /posts",
+					"DELETE /posts?ids=",
+					"GET /posts/search",
+					"GET /posts/trending",
+					"GET /posts/:id",
+					"PUT /posts/:id",
+					"DELETE /posts/:id",
+					"GET /posts/trash",
+					"POST /posts/:id/restore",
+					"POST /posts/:id/like",
+					"DELETE /posts/:id/like",
+					"GET /posts/:id/likes",
+					"GET /posts/:id/stats",
+					"GET /posts/:id/analytics",
+					"GET /posts/:id/related",
+					"POST /posts/:id/bookmark",
+					"DELETE /posts/:id/bookmark",
+					"POST /posts/:id/report",
+					"GET /posts/:id/poll",
+					"PUT /posts/:id/poll",
+					"DELETE /posts/:id/poll",
+					"POST /posts/:id/poll/vote",
+					"POST /posts/:id/pin",
+					"DELETE /posts/:id/pin",
+					"POST /posts/:id/authors",
+					"DELETE /posts/:id/authors/:user_id",
+					"POST /posts/:id/publish",
+					"POST /posts/:id/unpublish",
+					"POST /posts/:id/archive",
+					"POST /posts/:id/unarchive",
+					"GET /posts/:id/media",
+					"POST /posts/:id/media",
+					"PATCH /posts/:id/media/:media_id",
+					"DELETE /posts/:id/media/:media_id",
 				},
 			},
 		})
 	})
 
+	// Auth routes
+	authGroup := r.Group("/auth")
+	{
+		authGroup.POST("/register", api.register)
+		authGroup.POST("/login", api.login)
+		authGroup.POST("/refresh", api.refresh)
+		authGroup.POST("/logout", api.requireAuth, api.requireScope(ScopeAccountWrite), api.logout)
+		authGroup.POST("/magic-link", api.requestMagicLink)
+		authGroup.POST("/magic-link/redeem", api.redeemMagicLink)
+		authGroup.POST("/session", api.createSession)
+		authGroup.DELETE("/session", api.deleteSession)
+		authGroup.POST("/forgot-password", api.forgotPassword)
+		authGroup.POST("/reset-password", api.resetPassword)
+		authGroup.GET("/verify", api.verifyEmail)
+		authGroup.POST("/verify/resend", api.requireAuth, api.requireScope(ScopeAccountWrite), api.resendVerification)
+	}
+
+	// API key routes
+	apiKeysGroup := r.Group("/api-keys", api.requireAuth, api.requireScope(ScopeKeysManage))
+	{
+		apiKeysGroup.GET("", api.getAPIKeys)
+		apiKeysGroup.POST("", api.createAPIKey)
+		apiKeysGroup.DELETE("/:id", api.deleteAPIKey)
+	}
+
 	// User routes
 	usersGroup := r.Group("/users")
 	{
-		usersGroup.GET("", getUsers)
-		usersGroup.POST("", createUser)
-		usersGroup.GET("/:id", getUser)
-		usersGroup.PUT("/:id", updateUser)
-		usersGroup.DELETE("/:id", deleteUser)
+		usersGroup.GET("", api.optionalAuth, api.requireScope(ScopeUsersRead), api.getUsers)
+		usersGroup.HEAD("", api.optionalAuth, api.requireScope(ScopeUsersRead), api.countUsers)
+		usersGroup.GET("/count", api.optionalAuth, api.requireScope(ScopeUsersRead), api.countUsers)
+		usersGroup.POST("", api.requireAuth, api.requireScope(ScopeUsersAdmin), api.requireRole(RoleAdmin), api.idempotent, api.createUser)
+		usersGroup.POST("/bulk", api.requireAuth, api.requireScope(ScopeUsersAdmin), api.requireRole(RoleAdmin), api.createUsers)
+		usersGroup.GET("/:id", api.optionalAuth, api.requireScope(ScopeUsersRead), api.getUser)
+		usersGroup.PUT("/:id", api.requireAuth, api.requireScope(ScopeUsersAdmin), api.requireRole(RoleAdmin), api.updateUser)
+		usersGroup.DELETE("/:id", api.requireAuth, api.requireScope(ScopeUsersAdmin), api.requireRole(RoleAdmin), api.deleteUser)
+		usersGroup.GET("/trash", api.requireAuth, api.requireScope(ScopeUsersAdmin), api.requireRole(RoleAdmin), api.getUserTrash)
+		usersGroup.POST("/:id/restore", api.requireAuth, api.requireScope(ScopeUsersAdmin), api.requireRole(RoleAdmin), api.restoreUser)
+		usersGroup.GET("/me", api.requireAuth, api.requireScope(ScopeAccountRead), api.getMe)
+		usersGroup.PATCH("/me", api.requireAuth, api.requireScope(ScopeAccountWrite), api.updateMe)
+		usersGroup.GET("/me/bookmarks", api.requireAuth, api.requireScope(ScopePostsRead), api.getMyBookmarks)
+		usersGroup.GET("/me/blocks", api.requireAuth, api.requireScope(ScopeAccountRead), api.getMyBlocks)
+		usersGroup.GET("/me/settings", api.requireAuth, api.requireScope(ScopeAccountRead), api.getSettings)
+		usersGroup.PATCH("/me/settings", api.requireAuth, api.requireScope(ScopeAccountWrite), api.updateSettings)
+		usersGroup.PUT("/me/password", api.requireAuth, api.requireScope(ScopeAccountWrite), api.changePassword)
+		usersGroup.GET("/:id/posts", api.optionalAuth, api.requireScope(ScopePostsRead), api.getUserPosts)
+		usersGroup.POST("/:id/posts", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin), api.idempotent, api.createUserPost)
+		usersGroup.POST("/:id/follow", api.requireAuth, api.requireScope(ScopeAccountWrite), api.followUser)
+		usersGroup.DELETE("/:id/follow", api.requireAuth, api.requireScope(ScopeAccountWrite), api.followUser)
+		usersGroup.GET("/:id/followers", api.optionalAuth, api.requireScope(ScopeUsersRead), api.getFollowers)
+		usersGroup.GET("/:id/following", api.optionalAuth, api.requireScope(ScopeUsersRead), api.getFollowing)
+		usersGroup.GET("/:id/activity", api.optionalAuth, api.requireScope(ScopeUsersRead), api.getUserActivity)
+		usersGroup.POST("/:id/block", api.requireAuth, api.requireScope(ScopeAccountWrite), api.blockUser)
+		usersGroup.DELETE("/:id/block", api.requireAuth, api.requireScope(ScopeAccountWrite), api.blockUser)
 	}
 
 	// Post routes
 	postsGroup := r.Group("/posts")
 	{
-		postsGroup.GET("", getPosts)
-		postsGroup.POST("", createPost)
-		postsGroup.GET("/:id", getPost)
-		postsGroup.PUT("/:id", updatePost)
-		postsGroup.DELETE("/:id", deletePost)
+		postsGroup.GET("", api.optionalAuth, api.requireScope(ScopePostsRead), api.getPosts)
+		postsGroup.HEAD("", api.optionalAuth, api.requireScope(ScopePostsRead), api.countPosts)
+		postsGroup.GET("/count", api.optionalAuth, api.requireScope(ScopePostsRead), api.countPosts)
+		postsGroup.DELETE("", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.deletePosts)
+		postsGroup.POST("", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), requireVerifiedEmail(), api.idempotent, api.createPost)
+		postsGroup.GET("/search", api.optionalAuth, api.requireScope(ScopePostsRead), api.searchPosts)
+		postsGroup.GET("/trending", api.optionalAuth, api.requireScope(ScopePostsRead), api.getTrendingPosts)
+		postsGroup.GET("/:id", api.optionalAuth, api.requireScope(ScopePostsRead), api.getPost)
+		postsGroup.PUT("/:id", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.updatePost)
+		postsGroup.DELETE("/:id", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.deletePost)
+		postsGroup.GET("/trash", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.getPostTrash)
+		postsGroup.POST("/:id/restore", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.restorePost)
+		postsGroup.POST("/:id/like", api.requireAuth, api.requireScope(ScopePostsWrite), api.likePost)
+		postsGroup.DELETE("/:id/like", api.requireAuth, api.requireScope(ScopePostsWrite), api.likePost)
+		postsGroup.GET("/:id/likes", api.optionalAuth, api.requireScope(ScopePostsRead), api.getPostLikes)
+		postsGroup.GET("/:id/stats", api.requireAuth, api.requireScope(ScopePostsRead), api.getPostStats)
+		postsGroup.GET("/:id/analytics", api.requireAuth, api.requireScope(ScopePostsRead), api.getPostAnalytics)
+		postsGroup.GET("/:id/related", api.optionalAuth, api.requireScope(ScopePostsRead), api.getRelatedPosts)
+		postsGroup.POST("/:id/bookmark", api.requireAuth, api.requireScope(ScopePostsWrite), api.bookmarkPost)
+		postsGroup.DELETE("/:id/bookmark", api.requireAuth, api.requireScope(ScopePostsWrite), api.bookmarkPost)
+		postsGroup.POST("/:id/report", api.requireAuth, api.requireScope(ScopePostsWrite), api.reportPost)
+		postsGroup.GET("/:id/poll", api.optionalAuth, api.requireScope(ScopePostsRead), api.getPoll)
+		postsGroup.PUT("/:id/poll", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.putPoll)
+		postsGroup.DELETE("/:id/poll", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.deletePoll)
+		postsGroup.POST("/:id/poll/vote", api.requireAuth, api.requireScope(ScopePostsWrite), api.votePoll)
+		postsGroup.POST("/:id/pin", api.requireAuth, api.requireScope(ScopePostsWrite), api.pinPost)
+		postsGroup.DELETE("/:id/pin", api.requireAuth, api.requireScope(ScopePostsWrite), api.pinPost)
+		postsGroup.POST("/:id/authors", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.addCoAuthor)
+		postsGroup.DELETE("/:id/authors/:user_id", api.requireAuth, api.requireScope(ScopePostsWrite), api.removeCoAuthor)
+		postsGroup.POST("/:id/publish", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.publishPost)
+		postsGroup.POST("/:id/unpublish", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.unpublishPost)
+		postsGroup.POST("/:id/archive", api.requireAuth, api.requireScope(ScopePostsWrite), api.archivePost)
+		postsGroup.POST("/:id/unarchive", api.requireAuth, api.requireScope(ScopePostsWrite), api.unarchivePost)
+		postsGroup.GET("/:id/media", api.optionalAuth, api.requireScope(ScopePostsRead), api.getPostMedia)
+		postsGroup.POST("/:id/media", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.uploadMedia)
+		postsGroup.PATCH("/:id/media/:media_id", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.updateMedia)
+		postsGroup.DELETE("/:id/media/:media_id", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.deleteMedia)
+	}
+
+	// Feed routes
+	r.GET("/feed", api.requireAuth, api.requireScope(ScopePostsRead), api.getFeed)
+
+	// Series routes
+	seriesGroup := r.Group("/series")
+	{
+		seriesGroup.POST("", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.createSeries)
+		seriesGroup.GET("/:id", api.optionalAuth, api.requireScope(ScopePostsRead), api.getSeries)
+		seriesGroup.PUT("/:id/posts", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.setSeriesPosts)
+		seriesGroup.DELETE("/:id", api.requireAuth, api.requireScope(ScopePostsWrite), api.requireRole(RoleAdmin, RoleEditor), api.deleteSeries)
+	}
+
+	// Direct message routes
+	messagesGroup := r.Group("/messages", api.requireAuth)
+	{
+		messagesGroup.GET("", api.requireScope(ScopeMessagesRead), api.getConversations)
+		messagesGroup.GET("/:id", api.requireScope(ScopeMessagesRead), api.getMessageThread)
+		messagesGroup.POST("/:id", api.requireScope(ScopeMessagesWrite), api.sendMessage)
+	}
+
+	// Search routes
+	searchGroup := r.Group("/search", api.optionalAuth, api.requireScope(ScopePostsRead), api.requireScope(ScopeUsersRead))
+	{
+		searchGroup.GET("/suggest", api.suggest)
+	}
+
+	// Admin routes
+	adminGroup := r.Group("/admin", api.requireAuth, api.requireScope(ScopeUsersAdmin), api.requireRole(RoleAdmin))
+	{
+		adminGroup.GET("/users", api.adminListUsers)
+		adminGroup.DELETE("/posts/:id", api.adminForceDeletePost)
+		adminGroup.DELETE("/users/:id", api.adminPurgeUser)
+		adminGroup.POST("/users/:id/impersonate", api.adminImpersonate)
+		adminGroup.GET("/stats", api.adminStats)
+		adminGroup.GET("/audit", api.listAuditEvents)
+		adminGroup.GET("/reports", api.adminListReports)
+		adminGroup.GET("/moderation", api.getModerationQueue)
+		adminGroup.POST("/moderation/posts/:id", api.moderatePost)
+		adminGroup.GET("/moderation/decisions", api.getModerationDecisions)
 	}
 
+	// Profiling
+	api.mountPprof(r)
+
+	handleUnmatched(r)
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	r.Run(":" + port)
-}
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server failed: %v", err)
+		}
+	}()
+	log.Printf("listening on :%s", port)
 
-func getUsers(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"users": users,
-		"count": len(users),
-	})
+	// On SIGINT or SIGTERM, stop accepting connections and give in-flight
+	// requests SHUTDOWN_TIMEOUT to finish before closing connections to
+	// the database and other services.
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-signals.Done()
+	stopSignals()
+	log.Println("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: requests still in flight were cut off: %v", err)
+	}
+	stopBackground()
+	workers.Wait()
+	if err := storage.Close(ctx); err != nil {
+		log.Printf("shutdown: failed to close storage: %v", err)
+	}
+	if err := closeSharedRedisClient(); err != nil {
+		log.Printf("shutdown: failed to close redis: %v", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("shutdown: failed to flush traces: %v", err)
+	}
+	flushErrorReports(ctx)
+	log.Println("shut down")
 }
 
-func createUser(c *gin.Context) {
-	var req CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (a *API) getUsers(c *gin.Context) {
+	deleted, ok := a.includeDeleted(c)
+	if !ok {
 		return
 	}
 
-	// Check if user already exists
-	for _, user := range users {
-		if user.Username == req.Username || user.Email == req.Email {
-			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	sort, ok := parseSort(c, userSortFields)
+	if !ok {
+		return
+	}
+	filter, ok := parseUserFilter(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(c, userFields)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c, userExpansions)
+	if !ok {
+		return
+	}
+	if _, ok := c.GetQuery("ids"); ok {
+		a.getUsersByID(c, fields, expand)
+		return
+	}
+
+	ctx := c.Request.Context()
+	opts := ListOptions{IncludeDeleted: deleted, Sort: sort, Users: filter}
+	total, err := a.users.Count(ctx, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	users, err := a.users.List(ctx, page.apply(opts))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	if expand["posts"] {
+		if err := a.expandPosts(ctx, users); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
 			return
 		}
 	}
+	if err := a.countFollows(ctx, users); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch follows")
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respondCacheable(c, render(c, "users", projectAll(fields, users), gin.H{
+		"count":      len(users),
+		"pagination": page.meta(total),
+	}))
+}
+
+func (a *API) createUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
 
 	user := User{
-		ID:       userCounter,
 		Username: req.Username,
 		Email:    req.Email,
+		Role:     req.Role,
+	}
+	if user.Role == "" {
+		user.Role = defaultRole
 	}
 
-	users = append(users, user)
-	userCounter++
+	if err := a.users.Create(c.Request.Context(), &user); err != nil {
+		if errors.Is(err, ErrConflict) {
+			respondError(c, http.StatusConflict, "User already exists")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
 
-	c.JSON(http.StatusCreated, user)
+	respond(c, http.StatusCreated, "", user, nil)
 }
 
-func getUser(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+func (a *API) getUser(c *gin.Context) {
+	id, ok := a.userID(c)
+	if !ok {
+		return
+	}
+
+	deleted, ok := a.includeDeleted(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(c, userFields)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c, userExpansions)
+	if !ok {
+		return
+	}
+
+	get := a.users.Get
+	if deleted {
+		get = a.users.GetIncludingDeleted
+	}
+
+	user, err := get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	body, ok := a.renderUser(c, user, fields, expand)
+	if !ok {
 		return
 	}
 
-	for _, user := range users {
-		if user.ID == uint(id) {
-			c.JSON(http.StatusOK, user)
-			return
+	respondCacheable(c, body)
+}
+
+func (a *API) updateUser(c *gin.Context) {
+	id, ok := a.userID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, err := a.users.Get(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	auditBefore(c, user)
+
+	if !a.checkUserPreconditions(c, user) {
+		return
+	}
+	version, ok := expectedVersion(c, req.Version, user.Version)
+	if !ok {
+		return
+	}
+
+	user.Username = req.Username
+	if req.Email != user.Email {
+		user.Email = req.Email
+		user.EmailVerified = false
+	}
+	if req.Role != "" {
+		user.Role = req.Role
+	}
+	user.Version = version
+
+	if err := a.users.Update(c.Request.Context(), &user); err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			respondError(c, http.StatusNotFound, "User not found")
+		case errors.Is(err, ErrVersionConflict):
+			respondError(c, http.StatusConflict, "User was modified by another request")
+		case errors.Is(err, ErrConflict):
+			respondError(c, http.StatusConflict, "Username or email already exists")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to update user")
 		}
+		return
+	}
+	if !a.countUserFollows(c, &user) {
+		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	respond(c, http.StatusOK, "", user, nil)
 }
 
-func updateUser(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+func (a *API) deleteUser(c *gin.Context) {
+	id, ok := a.userID(c)
+	if !ok {
+		return
+	}
+
+	user, err := a.users.Get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	auditBefore(c, user)
+	if !a.checkUserPreconditions(c, user) {
 		return
 	}
 
-	var req CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := a.users.Delete(c.Request.Context(), id); err != nil {
+		respondStoreError(c, err, "user", "delete")
+		return
+	}
+
+	respond(c, http.StatusOK, "", gin.H{"message": "User deleted successfully"}, nil)
+}
+
+func (a *API) getPosts(c *gin.Context) {
+	a.listPosts(c, nil)
+}
+
+// listPosts serves a page of posts, narrowed to those by author if it isn't
+// nil.
+func (a *API) listPosts(c *gin.Context, author *User) {
+	deleted, ok := a.includeDeleted(c)
+	if !ok {
 		return
 	}
 
-	for i, user := range users {
-		if user.ID == uint(id) {
-			// Check if new username/email conflicts with existing users
-			for _, otherUser := range users {
-				if otherUser.ID != user.ID && (otherUser.Username == req.Username || otherUser.Email == req.Email) {
-					c.JSON(http.StatusConflict, gin.H{"error": "Username or email already exists"})
-					return
-				}
-			}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	sort, ok := parseSort(c, postSortFields)
+	if !ok {
+		return
+	}
+	filter, ok := parsePostFilter(c)
+	if !ok {
+		return
+	}
+	if author != nil {
+		filter.AuthorID, filter.AuthorUUID = author.ID, ""
+	} else if !a.hideBlocked(c, &filter) {
+		return
+	}
+	hideUnlisted(c, &filter)
+	fields, ok := parseFields(c, postFields)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c, postExpansions)
+	if !ok {
+		return
+	}
+	if _, ok := c.GetQuery("ids"); ok {
+		a.getPostsByID(c, author, fields, expand)
+		return
+	}
 
-			users[i].Username = req.Username
-			users[i].Email = req.Email
-			users[i].UpdatedAt = time.Now()
+	ctx := c.Request.Context()
+	opts := ListOptions{IncludeDeleted: deleted, Sort: sort, Posts: filter}
+	// A user's pinned posts lead the first page of their posts in the
+	// default order, and are left out of the rest.
+	var pinned []Post
+	if author != nil && sort == nil {
+		var err error
+		if pinned, err = a.pinnedPosts(ctx, &opts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+			return
+		}
+	}
+	if token, ok := c.GetQuery("cursor"); ok {
+		a.getPostsAfter(c, token, page.PerPage, opts, fields, expand)
+		return
+	}
 
-			c.JSON(http.StatusOK, users[i])
+	total, err := a.posts.Count(ctx, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	posts, err := a.posts.List(ctx, page.apply(opts))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	// Cursors follow the default order, so there is none for sorted lists.
+	var next interface{}
+	if sort == nil && len(posts) > 0 && int64(page.Page*page.PerPage) < total {
+		next = encodeCursor(postCursor(posts[len(posts)-1]))
+	}
+	if page.Page == 1 {
+		posts = append(pinned, posts...)
+	}
+	total += int64(len(pinned))
+	if expand["author"] {
+		if err := a.expandAuthors(ctx, posts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
 			return
 		}
 	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	respondCacheable(c, render(c, "posts", projectAll(fields, posts), gin.H{
+		"count":       len(posts),
+		"pagination":  page.meta(total),
+		"next_cursor": next,
+	}))
 }
 
-func deleteUser(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+// getPostsAfter serves GET /posts?cursor=, returning up to perPage posts
+// following the cursor. It skips the total count, so it stays cheap however
+// deep the client scrolls.
+func (a *API) getPostsAfter(c *gin.Context, token string, perPage int, opts ListOptions, fields fieldSet, expand map[string]bool) {
+	if c.Query("page") != "" || opts.Sort != nil {
+		respondError(c, http.StatusBadRequest, "page and sort cannot be combined with cursor")
+		return
+	}
+	cursor, err := decodeCursor(token)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	// Fetch one extra post to learn whether there is another page.
+	opts.After = &cursor
+	opts.Limit = perPage + 1
+	posts, err := a.posts.List(c.Request.Context(), opts)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
 		return
 	}
 
-	for i, user := range users {
-		if user.ID == uint(id) {
-			users = append(users[:i], users[i+1:]...)
-			c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	var next interface{}
+	if len(posts) > perPage {
+		posts = posts[:perPage]
+		next = encodeCursor(postCursor(posts[perPage-1]))
+	}
+	if expand["author"] {
+		if err := a.expandAuthors(c.Request.Context(), posts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
 			return
 		}
 	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	respondCacheable(c, render(c, "posts", projectAll(fields, posts), gin.H{
+		"count":       len(posts),
+		"next_cursor": next,
+	}))
 }
 
-func getPosts(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
-		"count": len(posts),
-	})
+func (a *API) createPost(c *gin.Context) {
+	author, _ := currentUser(c)
+	a.createPostBy(c, author)
 }
 
-func createPost(c *gin.Context) {
+// createPostBy creates a post from the request body with the given author.
+func (a *API) createPostBy(c *gin.Context, author User) {
 	var req CreatePostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	// For demo purposes, assign to first user or create one
-	var authorID uint = 1
-	if len(users) > 0 {
-		authorID = users[0].ID
-	}
-
 	post := Post{
-		ID:       postCounter,
-		Title:    req.Title,
-		Content:  req.Content,
-		AuthorID: authorID,
+		Title:      req.Title,
+		Content:    req.Content,
+		AuthorID:   author.ID,
+		AuthorUUID: author.UUID,
+	}
+	post.countWords()
+	if req.Status == "" {
+		req.Status = PostPublished
+	}
+	post.setStatus(req.Status)
+	if req.Visibility == "" {
+		settings, err := a.userSettings(c.Request.Context(), author.ID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch settings")
+			return
+		}
+		req.Visibility = settings.DefaultVisibility
+	}
+	post.Visibility = req.Visibility
+	if !a.checkDuplicatePost(c, post) {
+		return
 	}
 
-	posts = append(posts, post)
-	postCounter++
+	if err := a.posts.Create(c.Request.Context(), &post); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create post")
+		return
+	}
+	if post.Status != PostDraft {
+		a.recordActivity(c, Activity{UserID: author.ID, Type: ActivityPosted, PostID: post.ID}, false)
+	}
+	a.syncMentions(c, post, post.Status != PostDraft)
+	if !a.fillPostCount(c, &post) {
+		return
+	}
 
-	c.JSON(http.StatusCreated, post)
+	respond(c, http.StatusCreated, "", post, nil)
 }
 
-func getPost(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+func (a *API) getPost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
 		return
 	}
 
-	for _, post := range posts {
-		if post.ID == uint(id) {
-			c.JSON(http.StatusOK, post)
-			return
-		}
+	deleted, ok := a.includeDeleted(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(c, postFields)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c, postExpansions)
+	if !ok {
+		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
-}
+	get := a.posts.Get
+	if deleted {
+		get = a.posts.GetIncludingDeleted
+	}
 
-func updatePost(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	post, err := get(c.Request.Context(), id)
+	if err == nil && !a.canViewPost(c, post) {
+		err = ErrNotFound
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	if !post.DeletedAt.Valid {
+		a.countView(c, post)
+	}
+	body, ok := a.renderPost(c, post, fields, expand)
+	if !ok {
 		return
 	}
 
-	var req CreatePostRequest
+	respondCacheable(c, body)
+}
+
+func (a *API) updatePost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdatePostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	for i, post := range posts {
-		if post.ID == uint(id) {
-			posts[i].Title = req.Title
-			posts[i].Content = req.Content
-			posts[i].UpdatedAt = time.Now()
+	post, err := a.posts.Get(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
 
-			c.JSON(http.StatusOK, posts[i])
-			return
-		}
+	if !a.canModifyPost(c, post) || !checkNotArchived(c, post) {
+		return
+	}
+	if !a.checkPostPreconditions(c, post) {
+		return
+	}
+	version, ok := expectedVersion(c, req.Version, post.Version)
+	if !ok {
+		return
+	}
+
+	wasDraft := post.Status == PostDraft
+	post.Title = req.Title
+	post.Content = req.Content
+	post.countWords()
+	if req.Status != "" {
+		post.setStatus(req.Status)
+	}
+	if req.Visibility != "" {
+		post.Visibility = req.Visibility
 	}
+	post.Version = version
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	if err := a.posts.Update(c.Request.Context(), &post); err != nil {
+		respondStoreError(c, err, "post", "update")
+		return
+	}
+	a.syncMentions(c, post, wasDraft && post.Status != PostDraft)
+	if isDraft := post.Status == PostDraft; isDraft != wasDraft {
+		activity := Activity{UserID: post.AuthorID, Type: ActivityPosted, PostID: post.ID}
+		a.recordActivity(c, activity, isDraft)
+	}
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", post, nil)
 }
 
-func deletePost(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+func (a *API) deletePost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	post, err := a.posts.Get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		respondStoreError(c, err, "post", "fetch")
 		return
 	}
 
-	for i, post := range posts {
-		if post.ID == uint(id) {
-			posts = append(posts[:i], posts[i+1:]...)
-			c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
-			return
-		}
+	if !a.canModifyPost(c, post) {
+		return
+	}
+	if !a.checkPostPreconditions(c, post) {
+		return
+	}
+
+	if err := a.posts.Delete(c.Request.Context(), id); err != nil {
+		respondStoreError(c, err, "post", "delete")
+		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	respond(c, http.StatusOK, "", gin.H{"message": "Post deleted successfully"}, nil)
 }
\ No newline at end of file
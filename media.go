@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMediaPerPost caps how many attachments a post can have.
+const maxMediaPerPost = 20
+
+// mediaTypes are the content types accepted for upload, sniffed from the
+// file itself, with the extension files of each type are stored under.
+// Anything a browser would render as a page is left out, since uploads
+// are served from the API's own origin.
+var mediaTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// Media is a file attached to a post. A post's attachments are ordered by
+// Position, then by upload.
+type Media struct {
+	ID          uint      `json:"id" gorm:"primary_key" bson:"_id"`
+	PostID      uint      `json:"-" gorm:"not null;index" bson:"post_id"`
+	Filename    string    `json:"filename" gorm:"size:255;not null" bson:"filename"`
+	StoredName  string    `json:"-" gorm:"size:64;not null;uniqueIndex" bson:"stored_name"`
+	ContentType string    `json:"content_type" gorm:"size:64;not null" bson:"content_type"`
+	Size        int64     `json:"size" gorm:"not null" bson:"size"`
+	AltText     string    `json:"alt_text" gorm:"size:500;not null;default:''" bson:"alt_text"`
+	Position    int       `json:"position" gorm:"not null;default:0" bson:"position"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	// URL is where the file is served, under /media.
+	URL string `json:"url" gorm:"-" bson:"-"`
+}
+
+// MediaRepository stores attachment metadata; the files themselves live in
+// MEDIA_DIR.
+type MediaRepository interface {
+	Create(ctx context.Context, media *Media) error
+	Get(ctx context.Context, id uint) (Media, error)
+	// ListByPost returns a post's attachments in order.
+	ListByPost(ctx context.Context, postID uint) ([]Media, error)
+	Update(ctx context.Context, media *Media) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// mediaConfig says where uploads are kept and how large they may be.
+type mediaConfig struct {
+	dir      string
+	maxBytes int64
+}
+
+// newMediaConfig reads MEDIA_DIR and MEDIA_MAX_BYTES.
+func newMediaConfig() mediaConfig {
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "media"
+	}
+	return mediaConfig{dir: dir, maxBytes: int64(envInt("MEDIA_MAX_BYTES", 10<<20))}
+}
+
+type uploadMediaForm struct {
+	File    *multipart.FileHeader `form:"file" binding:"required"`
+	AltText string                `form:"alt_text" binding:"max=500"`
+	// Position defaults to after the post's other attachments.
+	Position *int `form:"position" binding:"omitempty,min=0"`
+}
+
+type UpdateMediaRequest struct {
+	AltText  *string `json:"alt_text" binding:"omitempty,max=500"`
+	Position *int    `json:"position" binding:"omitempty,min=0"`
+}
+
+// uploadMedia serves POST /posts/:id/media, a multipart/form-data upload of
+// a file to attach to the post, with optional alt_text and position fields.
+func (a *API) uploadMedia(c *gin.Context) {
+	post, ok := a.editablePost(c)
+	if !ok {
+		return
+	}
+	auditBefore(c, nil)
+	var form uploadMediaForm
+	if err := c.ShouldBind(&form); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := a.media.ListByPost(ctx, post.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch attachments")
+		return
+	}
+	if len(existing) >= maxMediaPerPost {
+		respondError(c, http.StatusConflict, "A post can have at most "+strconv.Itoa(maxMediaPerPost)+" attachments")
+		return
+	}
+
+	media := Media{
+		PostID:   post.ID,
+		Filename: filepath.Base(form.File.Filename),
+		Size:     form.File.Size,
+		AltText:  form.AltText,
+		Position: len(existing),
+	}
+	if form.Position != nil {
+		media.Position = *form.Position
+	}
+	if !a.storeMediaFile(c, form.File, &media) {
+		return
+	}
+	if err := a.media.Create(ctx, &media); err != nil {
+		a.removeMediaFile(media)
+		respondError(c, http.StatusInternalServerError, "Failed to attach file")
+		return
+	}
+
+	respond(c, http.StatusCreated, "", a.withURL(media), nil)
+}
+
+// storeMediaFile checks the upload's type and writes it to MEDIA_DIR under
+// a fresh name, filling in media's StoredName and ContentType. It writes the
+// error response and returns false if it can't.
+func (a *API) storeMediaFile(c *gin.Context, header *multipart.FileHeader, media *Media) bool {
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to read file")
+		return false
+	}
+	defer file.Close()
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		respondError(c, http.StatusBadRequest, "Failed to read file")
+		return false
+	}
+	contentType := http.DetectContentType(sniff[:n])
+	ext, ok := mediaTypes[contentType]
+	if !ok {
+		respondError(c, http.StatusUnsupportedMediaType, "Files must be JPEG, PNG, GIF, WebP or PDF")
+		return false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to store file")
+		return false
+	}
+
+	media.StoredName, media.ContentType = newUUID()+ext, contentType
+	if err := os.MkdirAll(a.mediaFiles.dir, 0o755); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to store file")
+		return false
+	}
+	out, err := os.Create(filepath.Join(a.mediaFiles.dir, media.StoredName))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to store file")
+		return false
+	}
+	_, err = io.Copy(out, file)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		a.removeMediaFile(*media)
+		respondError(c, http.StatusInternalServerError, "Failed to store file")
+		return false
+	}
+	return true
+}
+
+// getPostMedia serves GET /posts/:id/media, a post's attachments in order.
+func (a *API) getPostMedia(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := a.visiblePost(c, id); err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	media, err := a.media.ListByPost(ctx, id)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch attachments")
+		return
+	}
+	for i := range media {
+		media[i] = a.withURL(media[i])
+	}
+
+	respondCacheable(c, render(c, "media", media, gin.H{"count": len(media)}))
+}
+
+// updateMedia serves PATCH /posts/:id/media/:media_id, changing an
+// attachment's alt text or position.
+func (a *API) updateMedia(c *gin.Context) {
+	media, ok := a.pathMedia(c)
+	if !ok {
+		return
+	}
+	var req UpdateMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if req.AltText != nil {
+		media.AltText = *req.AltText
+	}
+	if req.Position != nil {
+		media.Position = *req.Position
+	}
+	if err := a.media.Update(c.Request.Context(), &media); err != nil {
+		respondStoreError(c, err, "attachment", "update")
+		return
+	}
+
+	respond(c, http.StatusOK, "", a.withURL(media), nil)
+}
+
+// deleteMedia serves DELETE /posts/:id/media/:media_id, removing an
+// attachment and its file.
+func (a *API) deleteMedia(c *gin.Context) {
+	media, ok := a.pathMedia(c)
+	if !ok {
+		return
+	}
+
+	if err := a.media.Delete(c.Request.Context(), media.ID); err != nil {
+		respondStoreError(c, err, "attachment", "delete")
+		return
+	}
+	a.removeMediaFile(media)
+
+	respond(c, http.StatusOK, "", gin.H{"message": "Attachment deleted successfully"}, nil)
+}
+
+// modifiablePost loads the :id post for a change by the caller, writing the
+// error response and returning ok == false if it doesn't exist or they
+// can't modify it.
+func (a *API) modifiablePost(c *gin.Context) (Post, bool) {
+	id, ok := a.postID(c)
+	if !ok {
+		return Post{}, false
+	}
+	post, err := a.posts.Get(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return Post{}, false
+	}
+	if !a.canModifyPost(c, post) {
+		return Post{}, false
+	}
+	return post, true
+}
+
+// pathMedia loads the :media_id attachment of the :id post for a change by
+// the caller, writing the error response and returning ok == false if it
+// can't.
+func (a *API) pathMedia(c *gin.Context) (Media, bool) {
+	post, ok := a.editablePost(c)
+	if !ok {
+		return Media{}, false
+	}
+	id, err := strconv.ParseUint(c.Param("media_id"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid attachment ID")
+		return Media{}, false
+	}
+
+	media, err := a.media.Get(c.Request.Context(), uint(id))
+	if err == nil && media.PostID != post.ID {
+		err = ErrNotFound
+	}
+	if err != nil {
+		respondStoreError(c, err, "attachment", "fetch")
+		return Media{}, false
+	}
+	auditBefore(c, a.withURL(media))
+	return media, true
+}
+
+// withURL returns media with its URL filled in.
+func (a *API) withURL(media Media) Media {
+	media.URL = "/media/" + media.StoredName
+	return media
+}
+
+// removeMediaFile deletes an attachment's file, logging rather than failing
+// the request if it can't, since the attachment is already gone or was
+// never recorded.
+func (a *API) removeMediaFile(media Media) {
+	err := os.Remove(filepath.Join(a.mediaFiles.dir, media.StoredName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("media: failed to remove %s: %v", media.StoredName, err)
+	}
+}
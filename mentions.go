@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventPostMentioned is recorded for each user a published post mentions.
+const EventPostMentioned = "post.mentioned"
+
+// maxMentions caps how many users one post can mention. Further mentions
+// are left as plain text.
+const maxMentions = 50
+
+// mentionPattern matches an @username mention. The @ must not follow a
+// word character, so email addresses don't count, and a trailing dot or
+// hyphen is taken as punctuation.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w(?:[\w.-]*\w)?)`)
+
+// Mention records that a post mentions a user.
+type Mention struct {
+	ID        uint      `gorm:"primary_key" bson:"_id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_mentions_post_user,priority:1" bson:"post_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_mentions_post_user,priority:2;index" bson:"user_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// UserLink refers to a user by ID and username, as rendered in responses
+// so clients can link to them.
+type UserLink struct {
+	ID       uint   `json:"id"`
+	UUID     string `json:"-"`
+	Username string `json:"username"`
+}
+
+// linkToUser returns a link to user.
+func linkToUser(user User) UserLink {
+	return UserLink{ID: user.ID, UUID: user.UUID, Username: user.Username}
+}
+
+// mentionEvent is the payload of a post.mentioned event, for consumers that
+// notify the mentioned user.
+type mentionEvent struct {
+	PostID   uint `json:"post_id"`
+	AuthorID uint `json:"author_id"`
+	UserID   uint `json:"user_id"`
+}
+
+// MentionRepository stores the users each post mentions.
+type MentionRepository interface {
+	// Set replaces the users post mentions with userIDs, and records a
+	// post.mentioned event for each of notify where the driver supports
+	// the outbox.
+	Set(ctx context.Context, post Post, userIDs, notify []uint) error
+	// ForPosts returns the users each of postIDs mentions, in the order
+	// they were first mentioned. Posts without mentions are missing from
+	// the result.
+	ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error)
+}
+
+// parseMentions returns the distinct usernames content mentions, in order,
+// up to maxMentions.
+func parseMentions(content string) []string {
+	usernames := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if len(usernames) == maxMentions {
+			break
+		}
+		if !slices.Contains(usernames, match[1]) {
+			usernames = append(usernames, match[1])
+		}
+	}
+	return usernames
+}
+
+// syncMentions stores the users post mentions after it was written, logging
+// rather than failing the request if it can't. Drafts and private posts
+// notify no one. A post just published notifies everyone it mentions;
+// otherwise only users newly mentioned are notified. The author, and users
+// blocking or blocked by them, are never notified.
+func (a *API) syncMentions(c *gin.Context, post Post, justPublished bool) {
+	ctx := c.Request.Context()
+	err := func() error {
+		userIDs := []uint{}
+		for _, username := range parseMentions(post.Content) {
+			user, err := a.users.GetByUsername(ctx, username)
+			if err != nil {
+				continue
+			}
+			userIDs = append(userIDs, user.ID)
+		}
+
+		previous, err := a.mentions.ForPosts(ctx, []uint{post.ID})
+		if err != nil {
+			return err
+		}
+		notify := []uint{}
+		for _, id := range userIDs {
+			if post.Status == PostDraft || post.visibility() == VisibilityPrivate || id == post.AuthorID {
+				continue
+			}
+			if !justPublished && slices.Contains(previous[post.ID], id) {
+				continue
+			}
+			blocked, err := a.blocks.Between(ctx, post.AuthorID, id)
+			if err != nil {
+				return err
+			}
+			if !blocked {
+				notify = append(notify, id)
+			}
+		}
+		return a.mentions.Set(ctx, post, userIDs, notify)
+	}()
+	if err != nil {
+		logf(c.Request.Context(), "mentions: failed to update mentions of post %d: %v", post.ID, err)
+	}
+}
+
+// fillMentions fills in the users posts mention. Users since deleted are
+// left out.
+func (a *API) fillMentions(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	mentioned, err := a.mentions.ForPosts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	userIDs := []uint{}
+	for _, users := range mentioned {
+		userIDs = append(userIDs, users...)
+	}
+	found, err := a.users.GetMany(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]User, len(found))
+	for _, user := range found {
+		byID[user.ID] = user
+	}
+	for i := range posts {
+		posts[i].Mentions = []UserLink{}
+		for _, id := range mentioned[posts[i].ID] {
+			if user, ok := byID[id]; ok {
+				posts[i].Mentions = append(posts[i].Mentions, linkToUser(user))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Message is a direct message from one user to another. Conversation is the
+// same for both directions between a pair of users, so a thread is one
+// indexed lookup.
+type Message struct {
+	ID           uint       `json:"-" gorm:"primary_key" bson:"_id"`
+	Conversation string     `json:"-" gorm:"size:41;not null;index" bson:"conversation"`
+	SenderID     uint       `json:"-" gorm:"not null;index" bson:"sender_id"`
+	RecipientID  uint       `json:"-" gorm:"not null;index" bson:"recipient_id"`
+	Body         string     `json:"body" gorm:"type:text;not null" bson:"body"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	ReadAt       *time.Time `json:"read_at" bson:"read_at"`
+	// Sender is loaded for responses.
+	Sender *User `json:"sender,omitempty" gorm:"-" bson:"-"`
+}
+
+// conversationKey identifies the conversation between two users, in either
+// direction.
+func conversationKey(a, b uint) string {
+	return fmt.Sprintf("%d:%d", min(a, b), max(a, b))
+}
+
+// messageEvent is the payload of a message.sent event, for consumers that
+// notify the recipient.
+type messageEvent struct {
+	SenderID    uint      `json:"sender_id"`
+	RecipientID uint      `json:"recipient_id"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (m Message) event() messageEvent {
+	return messageEvent{SenderID: m.SenderID, RecipientID: m.RecipientID, Body: m.Body, CreatedAt: m.CreatedAt}
+}
+
+// MessageRepository stores direct messages. Creating one records a
+// message.sent event where the driver supports the outbox.
+type MessageRepository interface {
+	Create(ctx context.Context, message *Message) error
+	// Thread returns the messages in a conversation, newest first. It
+	// honours opts.Offset and opts.Limit only.
+	Thread(ctx context.Context, conversation string, opts ListOptions) ([]Message, error)
+	CountThread(ctx context.Context, conversation string) (int64, error)
+	// Conversations returns the latest message of each conversation userID
+	// is in, newest first. It honours opts.Offset and opts.Limit only.
+	Conversations(ctx context.Context, userID uint, opts ListOptions) ([]Message, error)
+	CountConversations(ctx context.Context, userID uint) (int64, error)
+	// MarkRead marks the unread messages senderID sent recipientID as read.
+	MarkRead(ctx context.Context, recipientID, senderID uint) error
+	// Unread counts userID's unread messages by sender. Senders with none
+	// are missing from the result.
+	Unread(ctx context.Context, userID uint) (map[uint]int64, error)
+}
+
+type SendMessageRequest struct {
+	Body string `json:"body" binding:"required,max=2000"`
+}
+
+// conversation summarizes a conversation for GET /messages.
+type conversation struct {
+	User        *User   `json:"user"`
+	LastMessage Message `json:"last_message"`
+	Unread      int64   `json:"unread"`
+}
+
+// sendMessage serves POST /messages/:id, sending the :id user a message from
+// the caller.
+func (a *API) sendMessage(c *gin.Context) {
+	recipient, ok := a.pathUser(c)
+	if !ok {
+		return
+	}
+	var req SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	sender, _ := currentUser(c)
+	if sender.ID == recipient.ID {
+		respondError(c, http.StatusBadRequest, "You can't message yourself")
+		return
+	}
+	if !a.checkNotBlocked(c, recipient, "You can't message this user") {
+		return
+	}
+
+	message := Message{
+		Conversation: conversationKey(sender.ID, recipient.ID),
+		SenderID:     sender.ID,
+		RecipientID:  recipient.ID,
+		Body:         req.Body,
+	}
+	if err := a.messages.Create(c.Request.Context(), &message); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to send message")
+		return
+	}
+	message.Sender = &sender
+
+	respond(c, http.StatusCreated, "", message, nil)
+}
+
+// getMessageThread serves GET /messages/:id, the caller's conversation with
+// the :id user a page at a time, newest first. Fetching it marks the
+// messages they sent the caller as read.
+func (a *API) getMessageThread(c *gin.Context) {
+	other, ok := a.pathUser(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, _ := currentUser(c)
+	key := conversationKey(user.ID, other.ID)
+	total, err := a.messages.CountThread(ctx, key)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch messages")
+		return
+	}
+	messages, err := a.messages.Thread(ctx, key, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch messages")
+		return
+	}
+	if err := a.messages.MarkRead(ctx, user.ID, other.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch messages")
+		return
+	}
+	for i := range messages {
+		if messages[i].SenderID == user.ID {
+			messages[i].Sender = &user
+		} else {
+			messages[i].Sender = &other
+		}
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "messages", messages, gin.H{
+		"count":      len(messages),
+		"pagination": page.meta(total),
+	})
+}
+
+// getConversations serves GET /messages, the caller's conversations a page
+// at a time, most recently active first, each with the other user, the
+// latest message and how many of theirs the caller hasn't read.
+// Conversations with since-deleted users are left out of the page.
+func (a *API) getConversations(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, _ := currentUser(c)
+	total, err := a.messages.CountConversations(ctx, user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+	latest, err := a.messages.Conversations(ctx, user.ID, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+	unread, err := a.messages.Unread(ctx, user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+
+	ids := make([]uint, len(latest))
+	for i, message := range latest {
+		ids[i] = message.SenderID
+		if message.SenderID == user.ID {
+			ids[i] = message.RecipientID
+		}
+	}
+	users, err := a.users.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	byID := make(map[uint]User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	byID[user.ID] = user
+
+	conversations := []conversation{}
+	for i, message := range latest {
+		other, ok := byID[ids[i]]
+		if !ok {
+			continue
+		}
+		sender := byID[message.SenderID]
+		message.Sender = &sender
+		conversations = append(conversations, conversation{User: &other, LastMessage: message, Unread: unread[other.ID]})
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "conversations", conversations, gin.H{
+		"count":      len(conversations),
+		"pagination": page.meta(total),
+	})
+}
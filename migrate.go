@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"gin-golang-api/migrations"
+)
+
+// runMigrate implements the `migrate` subcommand:
+//
+//	migrate up      apply all pending migrations
+//	migrate down    roll back the most recently applied migration
+//	migrate status  list pending migrations
+func runMigrate(args []string) {
+	driver := dbDriver()
+	if driver == "memory" || driver == "mongo" {
+		log.Fatalf("migrate is not available with DB_DRIVER=%s", driver)
+	}
+	db := connectDB(driver)
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	m := migrations.New(db)
+	switch action {
+	case "up":
+		if err := m.Migrate(); err != nil {
+			log.Fatalf("migrate up failed: %v", err)
+		}
+		log.Println("migrations applied")
+	case "down":
+		if err := m.RollbackLast(); err != nil {
+			log.Fatalf("migrate down failed: %v", err)
+		}
+		log.Println("last migration rolled back")
+	case "status":
+		pending, err := migrations.Pending(db)
+		if err != nil {
+			log.Fatalf("migrate status failed: %v", err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("no pending migrations")
+			return
+		}
+		for _, id := range pending {
+			fmt.Println("pending:", id)
+		}
+	default:
+		log.Fatalf("unknown migrate action %q (expected up, down or status)", action)
+	}
+}
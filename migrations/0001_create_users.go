@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type user struct {
+		ID        uint   `gorm:"primaryKey"`
+		Username  string `gorm:"unique;not null"`
+		Email     string `gorm:"unique;not null"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0001_create_users",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("users").AutoMigrate(&user{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("users")
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type post struct {
+		ID        uint   `gorm:"primaryKey"`
+		Title     string `gorm:"not null"`
+		Content   string `gorm:"not null"`
+		AuthorID  uint   `gorm:"not null;index"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0002_create_posts",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("posts").AutoMigrate(&post{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("posts")
+		},
+	})
+}
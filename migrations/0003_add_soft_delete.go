@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type softDelete struct {
+		DeletedAt gorm.DeletedAt `gorm:"index"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0003_add_soft_delete",
+		Migrate: func(tx *gorm.DB) error {
+			for _, table := range []string{"users", "posts"} {
+				if err := tx.Table(table).AutoMigrate(&softDelete{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, table := range []string{"users", "posts"} {
+				if err := tx.Table(table).Migrator().DropColumn(&softDelete{}, "deleted_at"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
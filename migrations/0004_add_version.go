@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type versioned struct {
+		Version uint `gorm:"not null;default:1"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0004_add_version",
+		Migrate: func(tx *gorm.DB) error {
+			for _, table := range []string{"users", "posts"} {
+				if err := tx.Table(table).AutoMigrate(&versioned{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, table := range []string{"users", "posts"} {
+				if err := tx.Table(table).Migrator().DropColumn(&versioned{}, "version"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
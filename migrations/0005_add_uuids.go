@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type withUUID struct {
+		UUID string `gorm:"size:36"`
+	}
+	type withAuthorUUID struct {
+		AuthorUUID string `gorm:"size:36"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0005_add_uuids",
+		Migrate: func(tx *gorm.DB) error {
+			for _, table := range []string{"users", "posts"} {
+				if err := tx.Table(table).AutoMigrate(&withUUID{}); err != nil {
+					return err
+				}
+				if err := backfillUUIDs(tx, table); err != nil {
+					return err
+				}
+			}
+			if err := tx.Table("posts").AutoMigrate(&withAuthorUUID{}); err != nil {
+				return err
+			}
+			err := tx.Exec("UPDATE posts SET author_uuid = (SELECT uuid FROM users WHERE users.id = posts.author_id)").Error
+			if err != nil {
+				return err
+			}
+
+			for _, stmt := range []string{
+				"CREATE UNIQUE INDEX idx_users_uuid ON users (uuid)",
+				"CREATE UNIQUE INDEX idx_posts_uuid ON posts (uuid)",
+				"CREATE INDEX idx_posts_author_uuid ON posts (author_uuid)",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Table("posts").Migrator().DropColumn(&withAuthorUUID{}, "author_uuid"); err != nil {
+				return err
+			}
+			for _, table := range []string{"users", "posts"} {
+				if err := tx.Table(table).Migrator().DropColumn(&withUUID{}, "uuid"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// backfillUUIDs gives every row of table that lacks one a new UUIDv7.
+func backfillUUIDs(tx *gorm.DB, table string) error {
+	var ids []uint
+	if err := tx.Table(table).Where("uuid IS NULL OR uuid = ''").Order("id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := tx.Table(table).Where("id = ?", id).Update("uuid", uuid.Must(uuid.NewV7()).String()).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
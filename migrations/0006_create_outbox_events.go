@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type outboxEvent struct {
+		ID          uint            `gorm:"primaryKey"`
+		Type        string          `gorm:"size:64;not null"`
+		AggregateID uint            `gorm:"not null"`
+		Payload     json.RawMessage `gorm:"type:text;not null"`
+		CreatedAt   time.Time
+		PublishedAt *time.Time `gorm:"index"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0006_create_outbox_events",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("outbox_events").AutoMigrate(&outboxEvent{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("outbox_events")
+		},
+	})
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type user struct {
+		PasswordHash string `gorm:"size:255"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0007_add_password_hash",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("users").AutoMigrate(&user{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("users").Migrator().DropColumn(&user{}, "password_hash")
+		},
+	})
+}
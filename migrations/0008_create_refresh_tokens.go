@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type refreshToken struct {
+		ID        uint      `gorm:"primaryKey"`
+		UserID    uint      `gorm:"not null;index"`
+		FamilyID  string    `gorm:"size:36;not null;index"`
+		TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+		ExpiresAt time.Time `gorm:"not null"`
+		UsedAt    *time.Time
+		RevokedAt *time.Time
+		CreatedAt time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0008_create_refresh_tokens",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("refresh_tokens").AutoMigrate(&refreshToken{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("refresh_tokens")
+		},
+	})
+}
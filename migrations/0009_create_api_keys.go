@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type apiKey struct {
+		ID         uint   `gorm:"primaryKey"`
+		UserID     uint   `gorm:"not null;index"`
+		Name       string `gorm:"size:100;not null"`
+		Prefix     string `gorm:"size:16;not null"`
+		KeyHash    string `gorm:"size:64;not null;uniqueIndex"`
+		CreatedAt  time.Time
+		LastUsedAt *time.Time
+		RevokedAt  *time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0009_create_api_keys",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("api_keys").AutoMigrate(&apiKey{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("api_keys")
+		},
+	})
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type user struct {
+		Role string `gorm:"size:16;not null;default:reader"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0010_add_user_roles",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("users").AutoMigrate(&user{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("users").Migrator().DropColumn(&user{}, "role")
+		},
+	})
+}
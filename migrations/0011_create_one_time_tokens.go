@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type oneTimeToken struct {
+		ID        uint      `gorm:"primaryKey"`
+		UserID    uint      `gorm:"not null;index"`
+		Purpose   string    `gorm:"size:32;not null"`
+		TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+		ExpiresAt time.Time `gorm:"not null"`
+		UsedAt    *time.Time
+		CreatedAt time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0011_create_one_time_tokens",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("one_time_tokens").AutoMigrate(&oneTimeToken{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("one_time_tokens")
+		},
+	})
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type user struct {
+		EmailVerified bool `gorm:"not null;default:false"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0012_add_email_verified",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("users").AutoMigrate(&user{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("users").Migrator().DropColumn(&user{}, "email_verified")
+		},
+	})
+}
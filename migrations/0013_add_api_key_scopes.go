@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type apiKey struct {
+		Scopes string `gorm:"type:text"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0013_add_api_key_scopes",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("api_keys").AutoMigrate(&apiKey{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("api_keys").Migrator().DropColumn(&apiKey{}, "scopes")
+		},
+	})
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type user struct {
+		ExternalID *string `gorm:"size:255;uniqueIndex"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0014_add_external_id",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("users").AutoMigrate(&user{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("users").Migrator().DropColumn(&user{}, "external_id")
+		},
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type auditEvent struct {
+		ID        uint      `gorm:"primaryKey"`
+		Type      string    `gorm:"size:32;not null;index"`
+		UserID    *uint     `gorm:"index"`
+		Username  string    `gorm:"size:255"`
+		IP        string    `gorm:"size:64"`
+		Detail    string    `gorm:"type:text"`
+		CreatedAt time.Time `gorm:"index"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0015_create_audit_events",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("audit_events").AutoMigrate(&auditEvent{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("audit_events")
+		},
+	})
+}
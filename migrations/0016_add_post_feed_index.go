@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type post struct {
+		ID        uint      `gorm:"primaryKey;index:idx_posts_created_at_id,priority:2"`
+		CreatedAt time.Time `gorm:"index:idx_posts_created_at_id,priority:1"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0016_add_post_feed_index",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("posts").Migrator().CreateIndex(&post{}, "idx_posts_created_at_id")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("posts").Migrator().DropIndex(&post{}, "idx_posts_created_at_id")
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type reaction struct {
+		ID        uint   `gorm:"primaryKey"`
+		PostID    uint   `gorm:"not null;uniqueIndex:idx_reactions_post_user_type,priority:1"`
+		UserID    uint   `gorm:"not null;uniqueIndex:idx_reactions_post_user_type,priority:2;index"`
+		Type      string `gorm:"size:16;not null;uniqueIndex:idx_reactions_post_user_type,priority:3"`
+		CreatedAt time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0017_create_reactions",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("reactions").AutoMigrate(&reaction{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("reactions")
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type follow struct {
+		ID         uint `gorm:"primaryKey"`
+		FollowerID uint `gorm:"not null;uniqueIndex:idx_follows_follower_followee,priority:1"`
+		FolloweeID uint `gorm:"not null;uniqueIndex:idx_follows_follower_followee,priority:2;index"`
+		CreatedAt  time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0018_create_follows",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("follows").AutoMigrate(&follow{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("follows")
+		},
+	})
+}
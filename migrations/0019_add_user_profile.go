@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type user struct {
+		DisplayName string `gorm:"size:100;not null;default:''"`
+		Bio         string `gorm:"size:500;not null;default:''"`
+		AvatarURL   string `gorm:"size:2048;not null;default:''"`
+		Website     string `gorm:"size:2048;not null;default:''"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0019_add_user_profile",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("users").AutoMigrate(&user{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, column := range []string{"display_name", "bio", "avatar_url", "website"} {
+				if err := tx.Table("users").Migrator().DropColumn(&user{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
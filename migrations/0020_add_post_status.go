@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type post struct {
+		Status      string `gorm:"size:16;not null;default:published;index"`
+		PublishedAt *time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0020_add_post_status",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.Table("posts").AutoMigrate(&post{}); err != nil {
+				return err
+			}
+			// Existing posts were published when they were created.
+			return tx.Exec("UPDATE posts SET published_at = created_at WHERE published_at IS NULL").Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Table("posts").Migrator().DropColumn(&post{}, "published_at"); err != nil {
+				return err
+			}
+			return tx.Table("posts").Migrator().DropColumn(&post{}, "status")
+		},
+	})
+}
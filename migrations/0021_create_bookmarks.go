@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type bookmark struct {
+		ID        uint `gorm:"primaryKey"`
+		UserID    uint `gorm:"not null;uniqueIndex:idx_bookmarks_user_post,priority:1"`
+		PostID    uint `gorm:"not null;uniqueIndex:idx_bookmarks_user_post,priority:2;index"`
+		CreatedAt time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0021_create_bookmarks",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("bookmarks").AutoMigrate(&bookmark{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("bookmarks")
+		},
+	})
+}
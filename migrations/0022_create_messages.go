@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type message struct {
+		ID           uint   `gorm:"primaryKey"`
+		Conversation string `gorm:"size:41;not null;index"`
+		SenderID     uint   `gorm:"not null;index"`
+		RecipientID  uint   `gorm:"not null;index"`
+		Body         string `gorm:"type:text;not null"`
+		CreatedAt    time.Time
+		ReadAt       *time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0022_create_messages",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("messages").AutoMigrate(&message{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("messages")
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type media struct {
+		ID          uint   `gorm:"primaryKey"`
+		PostID      uint   `gorm:"not null;index"`
+		Filename    string `gorm:"size:255;not null"`
+		StoredName  string `gorm:"size:64;not null;uniqueIndex"`
+		ContentType string `gorm:"size:64;not null"`
+		Size        int64  `gorm:"not null"`
+		AltText     string `gorm:"size:500;not null;default:''"`
+		Position    int    `gorm:"not null;default:0"`
+		CreatedAt   time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0023_create_media",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("media").AutoMigrate(&media{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("media")
+		},
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type activity struct {
+		ID           uint   `gorm:"primaryKey"`
+		UserID       uint   `gorm:"not null;index"`
+		Type         string `gorm:"size:16;not null"`
+		PostID       uint   `gorm:"not null;default:0"`
+		TargetUserID uint   `gorm:"not null;default:0"`
+		Reaction     string `gorm:"size:16;not null;default:''"`
+		CreatedAt    time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0024_create_activities",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("activities").AutoMigrate(&activity{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("activities")
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type reaction struct {
+		CreatedAt time.Time `gorm:"index:idx_reactions_created_at"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0025_add_reaction_created_at_index",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("reactions").Migrator().CreateIndex(&reaction{}, "idx_reactions_created_at")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("reactions").Migrator().DropIndex(&reaction{}, "idx_reactions_created_at")
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type report struct {
+		ID         uint   `gorm:"primaryKey"`
+		PostID     uint   `gorm:"not null;uniqueIndex:idx_reports_post_reporter,priority:1"`
+		ReporterID uint   `gorm:"not null;uniqueIndex:idx_reports_post_reporter,priority:2;index"`
+		Reason     string `gorm:"size:16;not null"`
+		Details    string `gorm:"size:500;not null;default:''"`
+		CreatedAt  time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0026_create_reports",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("reports").AutoMigrate(&report{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("reports")
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type report struct {
+		ResolvedAt *time.Time `gorm:"index"`
+	}
+	type moderationDecision struct {
+		ID          uint   `gorm:"primaryKey"`
+		PostID      uint   `gorm:"not null;index"`
+		AuthorID    uint   `gorm:"not null;index"`
+		ModeratorID uint   `gorm:"not null;index"`
+		Action      string `gorm:"size:16;not null"`
+		Note        string `gorm:"size:1000;not null;default:''"`
+		CreatedAt   time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0027_add_moderation",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.Table("reports").AutoMigrate(&report{}); err != nil {
+				return err
+			}
+			return tx.Table("moderation_decisions").AutoMigrate(&moderationDecision{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("moderation_decisions"); err != nil {
+				return err
+			}
+			return tx.Table("reports").Migrator().DropColumn(&report{}, "resolved_at")
+		},
+	})
+}
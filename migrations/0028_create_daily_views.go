@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type dailyViews struct {
+		ID     uint   `gorm:"primaryKey"`
+		PostID uint   `gorm:"not null;uniqueIndex:idx_daily_views_post_day,priority:1"`
+		Day    string `gorm:"size:10;not null;uniqueIndex:idx_daily_views_post_day,priority:2;index"`
+		Views  int64  `gorm:"not null;default:0"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0028_create_daily_views",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("daily_views").AutoMigrate(&dailyViews{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("daily_views")
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type block struct {
+		ID        uint      `gorm:"primaryKey"`
+		BlockerID uint      `gorm:"not null;uniqueIndex:idx_blocks_blocker_blocked,priority:1"`
+		BlockedID uint      `gorm:"not null;uniqueIndex:idx_blocks_blocker_blocked,priority:2;index"`
+		CreatedAt time.Time `gorm:"autoCreateTime"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0029_create_blocks",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("blocks").AutoMigrate(&block{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("blocks")
+		},
+	})
+}
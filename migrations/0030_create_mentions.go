@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type mention struct {
+		ID        uint      `gorm:"primaryKey"`
+		PostID    uint      `gorm:"not null;uniqueIndex:idx_mentions_post_user,priority:1"`
+		UserID    uint      `gorm:"not null;uniqueIndex:idx_mentions_post_user,priority:2;index"`
+		CreatedAt time.Time `gorm:"autoCreateTime"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0030_create_mentions",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("mentions").AutoMigrate(&mention{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("mentions")
+		},
+	})
+}
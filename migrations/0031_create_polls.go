@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type poll struct {
+		ID        uint   `gorm:"primaryKey"`
+		PostID    uint   `gorm:"not null;uniqueIndex"`
+		Question  string `gorm:"size:300;not null"`
+		Options   string `gorm:"type:text;not null"`
+		ClosesAt  *time.Time
+		CreatedAt time.Time `gorm:"autoCreateTime"`
+	}
+	type pollVote struct {
+		ID        uint      `gorm:"primaryKey"`
+		PostID    uint      `gorm:"not null;uniqueIndex:idx_poll_votes_post_user,priority:1"`
+		UserID    uint      `gorm:"not null;uniqueIndex:idx_poll_votes_post_user,priority:2;index"`
+		Choice    int       `gorm:"not null"`
+		CreatedAt time.Time `gorm:"autoCreateTime"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0031_create_polls",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.Table("polls").AutoMigrate(&poll{}); err != nil {
+				return err
+			}
+			return tx.Table("poll_votes").AutoMigrate(&pollVote{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("poll_votes"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable("polls")
+		},
+	})
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type series struct {
+		ID          uint      `gorm:"primaryKey"`
+		AuthorID    uint      `gorm:"not null;index"`
+		Title       string    `gorm:"size:200;not null"`
+		Description string    `gorm:"size:1000;not null;default:''"`
+		CreatedAt   time.Time `gorm:"autoCreateTime"`
+	}
+	type seriesEntry struct {
+		ID       uint `gorm:"primaryKey"`
+		SeriesID uint `gorm:"not null;index"`
+		PostID   uint `gorm:"not null;uniqueIndex"`
+		Position int  `gorm:"not null"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0032_create_series",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.Table("series").AutoMigrate(&series{}); err != nil {
+				return err
+			}
+			return tx.Table("series_entries").AutoMigrate(&seriesEntry{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable("series_entries"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable("series")
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type coAuthor struct {
+		ID        uint      `gorm:"primaryKey"`
+		PostID    uint      `gorm:"not null;uniqueIndex:idx_co_authors_post_user,priority:1"`
+		UserID    uint      `gorm:"not null;uniqueIndex:idx_co_authors_post_user,priority:2;index"`
+		CreatedAt time.Time `gorm:"autoCreateTime"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0033_create_co_authors",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("co_authors").AutoMigrate(&coAuthor{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("co_authors")
+		},
+	})
+}
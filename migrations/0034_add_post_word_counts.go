@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type withWordCounts struct {
+		WordCount      int `gorm:"not null;default:0"`
+		ReadingMinutes int `gorm:"not null;default:0"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0034_add_post_word_counts",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.Table("posts").AutoMigrate(&withWordCounts{}); err != nil {
+				return err
+			}
+			return backfillWordCounts(tx)
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, column := range []string{"reading_minutes", "word_count"} {
+				if err := tx.Table("posts").Migrator().DropColumn(&withWordCounts{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// backfillWordCounts counts the words of every post, deleted or not, and
+// estimates its reading time at 200 words a minute, as the API does.
+func backfillWordCounts(tx *gorm.DB) error {
+	var rows []struct {
+		ID      uint
+		Content string
+	}
+	if err := tx.Table("posts").Select("id, content").Order("id").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		words := len(strings.Fields(row.Content))
+		err := tx.Table("posts").Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"word_count":      words,
+			"reading_minutes": (words + 199) / 200,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type dailyEngagement struct {
+		ID        uint   `gorm:"primaryKey"`
+		PostID    uint   `gorm:"not null;uniqueIndex:idx_daily_engagements_post_day,priority:1"`
+		Day       string `gorm:"size:10;not null;uniqueIndex:idx_daily_engagements_post_day,priority:2"`
+		Likes     int64  `gorm:"not null;default:0"`
+		Bookmarks int64  `gorm:"not null;default:0"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0035_create_daily_engagements",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("daily_engagements").AutoMigrate(&dailyEngagement{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("daily_engagements")
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type settings struct {
+		UserID            uint      `gorm:"primaryKey;autoIncrement:false"`
+		Notifications     string    `gorm:"type:text;not null"`
+		DefaultVisibility string    `gorm:"size:16;not null;default:public"`
+		Locale            string    `gorm:"size:35;not null;default:en"`
+		Timezone          string    `gorm:"size:64;not null;default:UTC"`
+		UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0036_create_settings",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("settings").AutoMigrate(&settings{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("settings")
+		},
+	})
+}
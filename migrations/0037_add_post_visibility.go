@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type withVisibility struct {
+		Visibility string `gorm:"size:16;not null;default:public;index"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0037_add_post_visibility",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("posts").AutoMigrate(&withVisibility{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("posts").Migrator().DropColumn(&withVisibility{}, "visibility")
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type pin struct {
+		ID        uint      `gorm:"primaryKey"`
+		UserID    uint      `gorm:"not null;index"`
+		PostID    uint      `gorm:"not null;uniqueIndex"`
+		CreatedAt time.Time `gorm:"autoCreateTime"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0038_create_pins",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("pins").AutoMigrate(&pin{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("pins")
+		},
+	})
+}
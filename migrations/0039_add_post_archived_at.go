@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type withArchivedAt struct {
+		ArchivedAt *time.Time
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0039_add_post_archived_at",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("posts").AutoMigrate(&withArchivedAt{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Table("posts").Migrator().DropColumn(&withArchivedAt{}, "archived_at")
+		},
+	})
+}
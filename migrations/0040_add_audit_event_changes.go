@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type withChanges struct {
+		Route    string `gorm:"size:128;index"`
+		EntityID string `gorm:"size:64;index"`
+		Changes  string `gorm:"type:text"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0040_add_audit_event_changes",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("audit_events").AutoMigrate(&withChanges{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, column := range []string{"route", "entity_id", "changes"} {
+				if err := tx.Table("audit_events").Migrator().DropColumn(&withChanges{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
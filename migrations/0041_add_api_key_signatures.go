@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	type apiKey struct {
+		RequireSignature bool   `gorm:"not null;default:false"`
+		SigningSecret    string `gorm:"size:64"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0041_add_api_key_signatures",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Table("api_keys").AutoMigrate(&apiKey{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, column := range []string{"require_signature", "signing_secret"} {
+				if err := tx.Table("api_keys").Migrator().DropColumn(&apiKey{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+func init() {
+	// 0001 left the column sizes to the dialect, which on MySQL means
+	// varchar(191) for unique columns; the model allows 255 everywhere.
+	type widened struct {
+		Username string `gorm:"size:255;unique;not null"`
+		Email    string `gorm:"size:255;unique;not null"`
+	}
+	type original struct {
+		Username string `gorm:"unique;not null"`
+		Email    string `gorm:"unique;not null"`
+	}
+
+	register(&gormigrate.Migration{
+		ID: "0042_widen_user_username_email",
+		Migrate: func(tx *gorm.DB) error {
+			for _, column := range []string{"username", "email"} {
+				if err := tx.Table("users").Migrator().AlterColumn(&widened{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, column := range []string{"username", "email"} {
+				if err := tx.Table("users").Migrator().AlterColumn(&original{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,55 @@
+// Package migrations holds the versioned schema migrations for the API.
+//
+// Each migration lives in its own file and declares the table shapes it
+// needs locally, so later changes to the application models never alter
+// what an already-applied migration did.
+package migrations
+
+import (
+	"sort"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// tableName is where gormigrate records applied migration IDs.
+const tableName = "migrations"
+
+var all []*gormigrate.Migration
+
+// register adds a migration to the list. Migration files call it from init;
+// migrations run in ID order regardless of registration order.
+func register(m *gormigrate.Migration) {
+	all = append(all, m)
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+}
+
+// New returns a migrator for db with every registered migration.
+func New(db *gorm.DB) *gormigrate.Gormigrate {
+	options := *gormigrate.DefaultOptions
+	options.TableName = tableName
+	return gormigrate.New(db, &options, all)
+}
+
+// Pending returns the IDs of registered migrations that have not been
+// applied to db yet, in the order they would run.
+func Pending(db *gorm.DB) ([]string, error) {
+	applied := map[string]bool{}
+	if db.Migrator().HasTable(tableName) {
+		var ids []string
+		if err := db.Table(tableName).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			applied[id] = true
+		}
+	}
+
+	var pending []string
+	for _, m := range all {
+		if !applied[m.ID] {
+			pending = append(pending, m.ID)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Moderation actions on a reported post. Approving keeps the post as it is;
+// hiding turns it back into a draft; deleting soft-deletes it; banning
+// soft-deletes its author, which signs them out everywhere.
+const (
+	ModerationApprove = "approve"
+	ModerationHide    = "hide"
+	ModerationDelete  = "delete"
+	ModerationBan     = "ban"
+)
+
+// ModerationDecision records a moderator's action on a post, for the
+// moderation audit trail. Decisions are never changed or removed.
+type ModerationDecision struct {
+	ID          uint      `json:"id" gorm:"primary_key" bson:"_id"`
+	PostID      uint      `json:"post_id" gorm:"not null;index" bson:"post_id"`
+	AuthorID    uint      `json:"author_id" gorm:"not null;index" bson:"author_id"`
+	ModeratorID uint      `json:"moderator_id" gorm:"not null;index" bson:"moderator_id"`
+	Action      string    `json:"action" gorm:"size:16;not null" bson:"action"`
+	Note        string    `json:"note" gorm:"size:1000;not null;default:''" bson:"note"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// ModerationFilter narrows the decisions listed. Zero fields don't filter.
+type ModerationFilter struct {
+	PostID      uint
+	ModeratorID uint
+	Action      string
+}
+
+// ModerationRepository stores moderation decisions. It is append-only.
+type ModerationRepository interface {
+	Create(ctx context.Context, decision *ModerationDecision) error
+	// List returns matching decisions, newest first. It honours opts.Offset
+	// and opts.Limit only.
+	List(ctx context.Context, filter ModerationFilter, opts ListOptions) ([]ModerationDecision, error)
+	Count(ctx context.Context, filter ModerationFilter) (int64, error)
+}
+
+type ModerationRequest struct {
+	Action string `json:"action" binding:"required,oneof=approve hide delete ban"`
+	Note   string `json:"note" binding:"max=1000"`
+}
+
+// moderationQuery is the query string of GET /admin/moderation/decisions.
+type moderationQuery struct {
+	PostID      uint   `form:"post_id"`
+	ModeratorID uint   `form:"moderator_id"`
+	Action      string `form:"action" binding:"omitempty,oneof=approve hide delete ban"`
+}
+
+// getModerationQueue serves GET /admin/moderation, the posts with open
+// reports a page at a time, most reported first.
+func (a *API) getModerationQueue(c *gin.Context) {
+	a.listReports(c, true)
+}
+
+// moderatePost serves POST /admin/moderation/posts/:id, taking a moderation
+// action on the post. It resolves the post's open reports and records the
+// decision, which it responds with.
+func (a *API) moderatePost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+	var req ModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.posts.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	// The response is the decision recorded, which is new.
+	auditBefore(c, nil)
+	if !a.applyModeration(c, post, req.Action) {
+		return
+	}
+	if err := a.reports.Resolve(ctx, post.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to resolve reports")
+		return
+	}
+
+	moderator, _ := currentUser(c)
+	decision := ModerationDecision{
+		PostID:      post.ID,
+		AuthorID:    post.AuthorID,
+		ModeratorID: moderator.ID,
+		Action:      req.Action,
+		Note:        req.Note,
+	}
+	if err := a.moderation.Create(ctx, &decision); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to record decision")
+		return
+	}
+
+	respond(c, http.StatusCreated, "", decision, nil)
+}
+
+// applyModeration carries out action on post, writing the error response
+// and returning false if it can't.
+func (a *API) applyModeration(c *gin.Context, post Post, action string) bool {
+	ctx := c.Request.Context()
+	switch action {
+	case ModerationHide:
+		if post.Status == PostDraft {
+			return true
+		}
+		post.setStatus(PostDraft)
+		if err := a.posts.Update(ctx, &post); err != nil {
+			respondStoreError(c, err, "post", "update")
+			return false
+		}
+		a.recordActivity(c, Activity{UserID: post.AuthorID, Type: ActivityPosted, PostID: post.ID}, true)
+	case ModerationDelete:
+		if err := a.posts.Delete(ctx, post.ID); err != nil {
+			respondStoreError(c, err, "post", "delete")
+			return false
+		}
+	case ModerationBan:
+		author, err := a.users.Get(ctx, post.AuthorID)
+		if err != nil {
+			respondStoreError(c, err, "author", "fetch")
+			return false
+		}
+		if hasRole(author, RoleAdmin) {
+			respondError(c, http.StatusConflict, "Admins can't be banned")
+			return false
+		}
+		if err := a.users.Delete(ctx, author.ID); err != nil {
+			respondStoreError(c, err, "author", "ban")
+			return false
+		}
+	}
+	return true
+}
+
+// getModerationDecisions serves GET /admin/moderation/decisions, the
+// moderation audit trail a page at a time, newest first, filtered by the
+// post_id, moderator_id and action query parameters.
+func (a *API) getModerationDecisions(c *gin.Context) {
+	var query moderationQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	filter := ModerationFilter{PostID: query.PostID, ModeratorID: query.ModeratorID, Action: query.Action}
+	total, err := a.moderation.Count(ctx, filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch decisions")
+		return
+	}
+	decisions, err := a.moderation.List(ctx, filter, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch decisions")
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "decisions", decisions, gin.H{
+		"count":      len(decisions),
+		"pagination": page.meta(total),
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin context keys auditMutations reads the changed record from, before and
+// after the change.
+const (
+	contextAuditBeforeKey = "audit_before"
+	contextAuditAfterKey  = "audit_after"
+)
+
+// auditRedactedFields are response fields never copied into the audit log,
+// since they hold credentials.
+var auditRedactedFields = map[string]bool{
+	"token":          true,
+	"access_token":   true,
+	"refresh_token":  true,
+	"key":            true,
+	"signing_secret": true,
+	"password":       true,
+}
+
+// AuditChange is how one field of a record changed. From is missing for
+// records created, and To for records deleted.
+type AuditChange struct {
+	From interface{} `json:"from,omitempty" bson:"from,omitempty"`
+	To   interface{} `json:"to,omitempty" bson:"to,omitempty"`
+}
+
+// auditBefore notes record as the state of what the request is about to
+// change, replacing any noted earlier: canModifyPost notes the post, and
+// handlers changing something attached to it, such as its poll, then note
+// that instead, or nil if they are creating it.
+func auditBefore(c *gin.Context, record interface{}) {
+	c.Set(contextAuditBeforeKey, record)
+}
+
+// auditMutations records every successful POST, PUT, PATCH and DELETE in
+// the audit log as a mutation event: who made it, the route, the ID of the
+// record changed and the fields that changed. The record after the change
+// is what the handler passed to respond; the record before is whatever it
+// passed to auditBefore, if anything. Toggles such as likes and follows
+// note no record before, and respond with a message rather than a record,
+// so their events only say who did what to which ID. Requests under /auth,
+// which hand out credentials, are left to the security events, and
+// idempotent replays aren't recorded again.
+func (a *API) auditMutations(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		c.Next()
+		return
+	}
+	c.Next()
+
+	if c.Writer.Status() >= http.StatusBadRequest || c.FullPath() == "" || strings.HasPrefix(c.FullPath(), "/auth/") ||
+		c.Writer.Header().Get("Idempotent-Replayed") != "" {
+		return
+	}
+	before, _ := c.Get(contextAuditBeforeKey)
+	after, _ := c.Get(contextAuditAfterKey)
+	if c.Request.Method == http.MethodDelete {
+		after = nil
+	}
+	beforeFields, afterFields := auditFields(before), auditFields(after)
+
+	entityID := c.Param("id")
+	if entityID == "" && afterFields["id"] != nil {
+		entityID = fmt.Sprint(afterFields["id"])
+	}
+	user, _ := currentUser(c)
+	event := AuditEvent{
+		Type:     AuditMutation,
+		Username: user.Username,
+		IP:       c.ClientIP(),
+		Route:    c.Request.Method + " " + c.FullPath(),
+		EntityID: entityID,
+		Changes:  auditChanges(beforeFields, afterFields),
+	}
+	if user.ID != 0 {
+		id := user.ID
+		event.UserID = &id
+	}
+	if err := a.auditLog.Append(c.Request.Context(), &event); err != nil {
+		logf(c.Request.Context(), "audit: failed to record %s: %v", event.Route, err)
+	}
+}
+
+// auditFields returns record's JSON fields, less auditRedactedFields, or nil
+// if it isn't a JSON object.
+func auditFields(record interface{}) map[string]interface{} {
+	if record == nil {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(data, &fields) != nil {
+		return nil
+	}
+	for name := range fields {
+		if auditRedactedFields[name] {
+			fields[name] = redacted
+		}
+	}
+	return fields
+}
+
+// auditChanges returns the fields that differ between before and after.
+// Either may be nil, for records created or deleted.
+func auditChanges(before, after map[string]interface{}) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+	for name, from := range before {
+		if to, ok := after[name]; after == nil || !ok || !reflect.DeepEqual(from, to) {
+			changes[name] = AuditChange{From: from, To: to}
+		}
+	}
+	for name, to := range after {
+		if _, ok := before[name]; !ok {
+			changes[name] = AuditChange{To: to}
+		}
+	}
+	return changes
+}
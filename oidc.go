@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcVerifier validates RS256 ID and access tokens issued by an external
+// OpenID Connect provider, using the signing keys it publishes.
+type oidcVerifier struct {
+	issuer   string
+	audience string
+	jwksURI  string
+	client   *http.Client
+
+	mu         sync.Mutex
+	keys       map[string]*rsa.PublicKey
+	fetchedAt  time.Time
+	refreshing chan struct{} // closed when the refetch under way finishes
+}
+
+// oidcClaims are the claims read from provider tokens.
+type oidcClaims struct {
+	jwt.RegisteredClaims
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// newOIDCVerifier reads OIDC_ISSUER and OIDC_AUDIENCE and discovers the
+// provider's JWKS. It returns nil if OIDC_ISSUER is unset.
+func newOIDCVerifier() *oidcVerifier {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	audience := os.Getenv("OIDC_AUDIENCE")
+	if audience == "" {
+		log.Fatal("OIDC_AUDIENCE is required when OIDC_ISSUER is set")
+	}
+
+	v := &oidcVerifier{issuer: issuer, audience: audience, client: &http.Client{Timeout: 10 * time.Second}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := v.discover(ctx); err != nil {
+		log.Fatalf("oidc: discovery failed for %s: %v", issuer, err)
+	}
+	if err := v.refreshKeys(ctx); err != nil {
+		log.Fatalf("oidc: failed to fetch signing keys: %v", err)
+	}
+	return v
+}
+
+// discover reads the jwks_uri from the issuer's discovery document.
+func (v *oidcVerifier) discover(ctx context.Context) error {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	url := strings.TrimSuffix(v.issuer, "/") + "/.well-known/openid-configuration"
+	if err := v.getJSON(ctx, url, &doc); err != nil {
+		return err
+	}
+	if doc.Issuer != v.issuer {
+		return fmt.Errorf("discovery document is for issuer %q", doc.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return errors.New("discovery document has no jwks_uri")
+	}
+	v.jwksURI = doc.JWKSURI
+	return nil
+}
+
+// refreshKeys replaces the cached keys with the RSA signing keys currently
+// in the JWKS. mu is only held to swap them in, not for the fetch.
+func (v *oidcVerifier) refreshKeys(ctx context.Context) error {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, v.jwksURI, &set); err != nil {
+		return err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+	return nil
+}
+
+// key returns the signing key with the given ID. Providers rotate keys, so
+// an unknown ID triggers a refetch, at most once a minute. Requests wanting
+// a key while one is under way wait for it rather than fetching again.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	done := v.refreshing
+	if !ok && done == nil && time.Since(v.fetchedAt) > time.Minute {
+		done = make(chan struct{})
+		v.refreshing = done
+		go v.refresh(done)
+	}
+	v.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		v.mu.Lock()
+		key, ok = v.keys[kid]
+		v.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// refresh refetches the JWKS for key and closes done. It has its own
+// timeout, so a request giving up doesn't cut short the fetch others are
+// waiting on.
+func (v *oidcVerifier) refresh(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := v.refreshKeys(ctx); err != nil {
+		logf(ctx, "oidc: failed to refresh signing keys: %v", err)
+	}
+
+	v.mu.Lock()
+	v.refreshing = nil
+	v.mu.Unlock()
+	close(done)
+}
+
+// Verify checks the token's RS256 signature, issuer, audience and expiry and
+// returns its claims.
+func (v *oidcVerifier) Verify(ctx context.Context, token string) (oidcClaims, error) {
+	var claims oidcClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return oidcClaims{}, err
+	}
+	if claims.Subject == "" {
+		return oidcClaims{}, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s responded %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// authenticateOIDC resolves a provider token to the local user linked to
+// its issuer and subject, creating one on first login. It returns
+// ErrNotFound for tokens that don't verify.
+func (a *API) authenticateOIDC(ctx context.Context, token string) (User, error) {
+	claims, err := a.oidc.Verify(ctx, token)
+	if err != nil {
+		return User{}, ErrNotFound
+	}
+
+	externalID := claims.Issuer + "|" + claims.Subject
+	user, err := a.users.GetByExternalID(ctx, externalID)
+	if !errors.Is(err, ErrNotFound) {
+		return user, err
+	}
+	return a.provisionOIDCUser(ctx, externalID, claims)
+}
+
+// provisionOIDCUser links the provider identity to the local account with
+// the same email if both the provider and the account have verified the
+// address, and otherwise creates a new user, adding a suffix to the username
+// if it is taken. An account whose address is unverified isn't linked, as
+// whoever registered it may not own the address.
+func (a *API) provisionOIDCUser(ctx context.Context, externalID string, claims oidcClaims) (User, error) {
+	if claims.Email == "" {
+		logf(ctx, "oidc: token for %s has no email claim", externalID)
+		return User{}, ErrNotFound
+	}
+
+	if claims.EmailVerified {
+		user, err := a.users.GetByEmail(ctx, claims.Email)
+		switch {
+		case err == nil && user.ExternalID == nil && user.EmailVerified:
+			err = a.modifyUser(ctx, user.ID, func(u *User) {
+				u.ExternalID = &externalID
+			})
+			if err != nil {
+				return User{}, err
+			}
+			logf(ctx, "oidc: linked %s to user %d", externalID, user.ID)
+			return a.users.Get(ctx, user.ID)
+		case err == nil:
+			logf(ctx, "oidc: not linking %s to user %d, whose email is unverified or already linked", externalID, user.ID)
+			return User{}, ErrNotFound
+		case !errors.Is(err, ErrNotFound):
+			return User{}, err
+		}
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		user := User{
+			Username:      username,
+			Email:         claims.Email,
+			Role:          defaultRole,
+			EmailVerified: claims.EmailVerified,
+			ExternalID:    &externalID,
+		}
+		if attempt > 0 {
+			suffix := make([]byte, 3)
+			if _, err := rand.Read(suffix); err != nil {
+				return User{}, err
+			}
+			user.Username = username + "-" + hex.EncodeToString(suffix)
+		}
+
+		err := a.users.Create(ctx, &user)
+		if err == nil {
+			logf(ctx, "oidc: created user %d for %s", user.ID, externalID)
+			return user, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return User{}, err
+		}
+	}
+
+	// Most likely the email belongs to an account we couldn't link.
+	logf(ctx, "oidc: could not create a user for %s", externalID)
+	return User{}, ErrNotFound
+}
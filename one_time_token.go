@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Purposes of one-time tokens.
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// OneTimeToken is a single-use, expiring token emailed to a user to prove
+// they control their address. As with refresh tokens only its SHA-256 hash
+// is stored.
+type OneTimeToken struct {
+	ID        uint       `json:"id" gorm:"primary_key" bson:"_id"`
+	UserID    uint       `json:"user_id" gorm:"not null;index" bson:"user_id"`
+	Purpose   string     `json:"purpose" gorm:"size:32;not null" bson:"purpose"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex" bson:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null" bson:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" bson:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// OneTimeTokenRepository stores one-time tokens.
+type OneTimeTokenRepository interface {
+	Create(ctx context.Context, token *OneTimeToken) error
+	// Consume marks the unused, unexpired token with the given purpose and
+	// hash as used and returns it. Only one caller can consume a token; the
+	// rest, like callers presenting unknown or expired tokens, get
+	// ErrNotFound.
+	Consume(ctx context.Context, purpose, hash string) (OneTimeToken, error)
+}
+
+// issueOneTimeToken creates and stores a token for userID valid for ttl,
+// returning the raw token value.
+func (a *API) issueOneTimeToken(ctx context.Context, userID uint, purpose string, ttl time.Duration) (string, error) {
+	value, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := OneTimeToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(value),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := a.oneTimeTokens.Create(ctx, &token); err != nil {
+		return "", err
+	}
+	return value, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Domain event types written to the outbox.
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventPostCreated = "post.created"
+	EventPostUpdated = "post.updated"
+	EventMessageSent = "message.sent"
+)
+
+// OutboxEvent is a domain event recorded alongside the write that caused it
+// and published asynchronously by the outbox dispatcher.
+type OutboxEvent struct {
+	ID          uint            `json:"id" gorm:"primary_key"`
+	Type        string          `json:"type" gorm:"size:64;not null"`
+	AggregateID uint            `json:"aggregate_id" gorm:"not null"`
+	Payload     json.RawMessage `json:"payload" gorm:"type:text;not null"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	PublishedAt *time.Time      `json:"-" gorm:"index"`
+}
+
+// OutboxStore gives the dispatcher access to recorded events.
+type OutboxStore interface {
+	// Pending returns up to limit unpublished events, oldest first.
+	Pending(ctx context.Context, limit int) ([]OutboxEvent, error)
+	MarkPublished(ctx context.Context, id uint) error
+}
+
+// Publisher delivers outbox events to downstream consumers.
+type Publisher interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
+
+// newOutboxEvent builds an event of the given type with payload encoded as
+// JSON.
+func newOutboxEvent(eventType string, aggregateID uint, payload interface{}) (OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	return OutboxEvent{Type: eventType, AggregateID: aggregateID, Payload: data}, nil
+}
+
+// newPublisher returns a webhook publisher if OUTBOX_WEBHOOK_URL is set, and
+// otherwise one that only logs events.
+func newPublisher() Publisher {
+	if url := os.Getenv("OUTBOX_WEBHOOK_URL"); url != "" {
+		return &webhookPublisher{
+			url:     url,
+			client:  &http.Client{Timeout: 10 * time.Second},
+			breaker: newCircuitBreaker("webhook", 3, time.Minute),
+		}
+	}
+	return logPublisher{}
+}
+
+type logPublisher struct{}
+
+func (logPublisher) Publish(ctx context.Context, event OutboxEvent) error {
+	log.Printf("outbox: %s %d %s", event.Type, event.AggregateID, event.Payload)
+	return nil
+}
+
+// webhookPublisher POSTs each event as JSON to a URL. Any non-2xx response
+// is a failure and the event is retried. While the webhook circuit is open
+// events wait in the outbox without being sent.
+type webhookPublisher struct {
+	url     string
+	client  *http.Client
+	breaker *circuitBreaker
+}
+
+func (p *webhookPublisher) Publish(ctx context.Context, event OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.breaker.do(func() error { return p.post(ctx, body) }, func(err error) bool { return !canceled(err) })
+}
+
+func (p *webhookPublisher) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// runOutboxDispatcher publishes pending events every interval until ctx is
+// done. Events are published in order; a failure stops the batch so the
+// event is retried on the next tick. Delivery is at-least-once.
+func runOutboxDispatcher(ctx context.Context, store OutboxStore, publisher Publisher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		events, err := store.Pending(ctx, 100)
+		if err != nil {
+			log.Printf("outbox: failed to load pending events: %v", err)
+			continue
+		}
+		for _, event := range events {
+			if err := publisher.Publish(ctx, event); err != nil {
+				log.Printf("outbox: failed to publish event %d: %v", event.ID, err)
+				break
+			}
+			if err := store.MarkPublished(ctx, event.ID); err != nil {
+				log.Printf("outbox: failed to mark event %d published: %v", event.ID, err)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page size bounds for list endpoints.
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// pageRequest is a requested page of a list, numbered from 1.
+type pageRequest struct {
+	Page    int
+	PerPage int
+}
+
+// pageQuery is the query string of a paginated list. Its per_page bound
+// must match maxPerPage.
+type pageQuery struct {
+	Page    *int `form:"page" binding:"omitempty,min=1"`
+	PerPage *int `form:"per_page" binding:"omitempty,min=1,max=100"`
+}
+
+// parsePage reads the ?page and ?per_page query parameters, writing the
+// error response and returning ok == false if they are invalid.
+func parsePage(c *gin.Context) (page pageRequest, ok bool) {
+	var query pageQuery
+	if !bindQuery(c, &query) {
+		return pageRequest{}, false
+	}
+
+	page = pageRequest{Page: 1, PerPage: defaultPerPage}
+	if query.Page != nil {
+		page.Page = *query.Page
+	}
+	if query.PerPage != nil {
+		page.PerPage = *query.PerPage
+	}
+	return page, true
+}
+
+// apply sets the offset and limit for the page on opts.
+func (p pageRequest) apply(opts ListOptions) ListOptions {
+	opts.Offset = (p.Page - 1) * p.PerPage
+	opts.Limit = p.PerPage
+	return opts
+}
+
+// meta describes the page within a list of total records, for the
+// "pagination" field of list responses. next_page and prev_page are null at
+// either end.
+func (p pageRequest) meta(total int64) gin.H {
+	totalPages := int((total + int64(p.PerPage) - 1) / int64(p.PerPage))
+
+	var next, prev interface{}
+	if p.Page < totalPages {
+		next = p.Page + 1
+	}
+	if p.Page > 1 {
+		prev = p.Page - 1
+		if p.Page > totalPages+1 {
+			prev = totalPages
+		}
+	}
+
+	return gin.H{
+		"page":        p.Page,
+		"per_page":    p.PerPage,
+		"total":       total,
+		"total_pages": totalPages,
+		"next_page":   next,
+		"prev_page":   prev,
+	}
+}
+
+// errInvalidCursor is returned by decodeCursor for malformed cursors.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken is the JSON form of a Cursor inside the opaque token clients
+// see.
+type cursorToken struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"i"`
+}
+
+// encodeCursor returns the opaque token for cursor.
+func encodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursorToken{CreatedAt: cursor.CreatedAt, ID: cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a token produced by encodeCursor.
+func decodeCursor(token string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	var decoded cursorToken
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID == 0 {
+		return Cursor{}, errInvalidCursor
+	}
+	return Cursor{CreatedAt: decoded.CreatedAt, ID: decoded.ID}, nil
+}
+
+// postCursor returns the cursor positioned at post.
+func postCursor(post Post) Cursor {
+	return Cursor{CreatedAt: post.CreatedAt, ID: post.ID}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2Params are the argon2id cost parameters used for new hashes.
+type argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var passwordParams = argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// configurePasswordHashing reads ARGON2_MEMORY (KiB), ARGON2_ITERATIONS,
+// ARGON2_PARALLELISM and PASSWORD_MIN_LENGTH.
+func configurePasswordHashing() {
+	passwordParams.Memory = uint32(envInt("ARGON2_MEMORY", int(passwordParams.Memory)))
+	passwordParams.Iterations = uint32(envInt("ARGON2_ITERATIONS", int(passwordParams.Iterations)))
+	passwordParams.Parallelism = uint8(envInt("ARGON2_PARALLELISM", int(passwordParams.Parallelism)))
+	passwordMinLength = envInt("PASSWORD_MIN_LENGTH", passwordMinLength)
+}
+
+// passwordMinLength and passwordMaxLength bound password length in
+// characters. The maximum keeps hashing cost bounded.
+var (
+	passwordMinLength = 8
+	passwordMaxLength = 256
+)
+
+// checkPasswordPolicy returns an error describing why password is not
+// acceptable for user, or nil. User fields that are empty are not checked.
+func checkPasswordPolicy(password string, user User) error {
+	n := utf8.RuneCountInString(password)
+	switch {
+	case n < passwordMinLength:
+		return fmt.Errorf("password must be at least %d characters", passwordMinLength)
+	case n > passwordMaxLength:
+		return fmt.Errorf("password must be at most %d characters", passwordMaxLength)
+	case user.Username != "" && strings.EqualFold(password, user.Username),
+		user.Email != "" && strings.EqualFold(password, user.Email):
+		return errors.New("password must not be the username or email")
+	}
+	return nil
+}
+
+// hashPassword returns the argon2id hash of password in the PHC string
+// format, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func hashPassword(password string) (string, error) {
+	p := passwordParams
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// checkPassword reports whether password matches hash. Besides argon2id it
+// accepts the bcrypt hashes written before argon2id was introduced.
+func checkPassword(hash, password string) bool {
+	if isBcryptHash(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+
+	p, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// passwordNeedsRehash reports whether hash was made with another algorithm
+// or other parameters than hashPassword currently uses.
+func passwordNeedsRehash(hash string) bool {
+	if isBcryptHash(hash) {
+		return true
+	}
+	p, _, _, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	current := passwordParams
+	return p.Memory != current.Memory || p.Iterations != current.Iterations || p.Parallelism != current.Parallelism
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func decodeArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, errors.New("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, err
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
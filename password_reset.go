@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// forgotPassword emails a password reset link to the account with the given
+// address. It responds the same way whether or not there is one, so it can't
+// be used to discover registered addresses.
+func (a *API) forgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := a.users.GetByEmail(ctx, req.Email)
+	switch {
+	case err == nil && user.PasswordHash != "":
+		token, err := a.issueOneTimeToken(ctx, user.ID, TokenPurposePasswordReset, a.passwordResetTTL)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to start password reset")
+			return
+		}
+		a.sendMailAsync(user.Email, "Reset your password",
+			"Someone asked to reset the password for "+user.Username+".\n\n"+
+				"Use this link within "+a.passwordResetTTL.String()+" to choose a new one:\n"+
+				appURL()+"/reset-password?token="+token+"\n\n"+
+				"If it wasn't you, ignore this email.\n")
+	case err != nil && !errors.Is(err, ErrNotFound):
+		respondError(c, http.StatusInternalServerError, "Failed to start password reset")
+		return
+	}
+
+	respond(c, http.StatusAccepted, "", gin.H{"message": "If the address is registered, a reset link has been sent"}, nil)
+}
+
+// resetPassword redeems a password reset token. The token is consumed before
+// the password changes, so a token can only ever set one password.
+func (a *API) resetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// The account isn't known until the token is consumed, so only the
+	// account-independent rules can be checked up front.
+	if err := checkPasswordPolicy(req.Password, User{}); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := a.oneTimeTokens.Consume(ctx, TokenPurposePasswordReset, hashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	err = a.modifyUser(ctx, token.UserID, func(user *User) { user.PasswordHash = hash })
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		logf(c.Request.Context(), "password reset: failed to update user %d: %v", token.UserID, err)
+		respondError(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	// Whoever knew the old password shouldn't stay logged in.
+	if err := a.logOutEverywhere(ctx, token.UserID); err != nil {
+		logf(c.Request.Context(), "password reset: failed to log out user %d: %v", token.UserID, err)
+	}
+
+	a.audit(c, AuditPasswordChanged, User{ID: token.UserID}, "reset")
+	respond(c, http.StatusOK, "", gin.H{"message": "Password has been reset"}, nil)
+}
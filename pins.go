@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPinnedPosts caps how many posts each user can pin.
+const maxPinnedPosts = 3
+
+// Pin records that a user pinned one of their posts to the top of their
+// posts.
+type Pin struct {
+	ID        uint      `gorm:"primary_key" bson:"_id"`
+	UserID    uint      `gorm:"not null;index" bson:"user_id"`
+	PostID    uint      `gorm:"not null;uniqueIndex" bson:"post_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// PinRepository stores pins. Add does nothing if the post is already
+// pinned, and Remove does nothing if it isn't, so both are safe to repeat.
+type PinRepository interface {
+	Add(ctx context.Context, pin *Pin) error
+	Remove(ctx context.Context, postID uint) error
+	// List returns the IDs of the posts a user pinned, most recently pinned
+	// first.
+	List(ctx context.Context, userID uint) ([]uint, error)
+	// Pinned returns which of postIDs are pinned.
+	Pinned(ctx context.Context, postIDs []uint) (map[uint]bool, error)
+}
+
+// pinPost serves POST /posts/:id/pin, pinning the post to the top of its
+// author's posts, and DELETE /posts/:id/pin, unpinning it, for the author
+// and admins. Both respond with the post, and repeating either changes
+// nothing.
+func (a *API) pinPost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.posts.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	user, _ := currentUser(c)
+	if post.AuthorID != user.ID && !hasRole(user, RoleAdmin) {
+		a.deny(c, "Only the author or an admin can pin this post")
+		return
+	}
+
+	if c.Request.Method == http.MethodDelete {
+		err = a.pins.Remove(ctx, post.ID)
+	} else {
+		var pinned []uint
+		pinned, err = a.pins.List(ctx, post.AuthorID)
+		if err == nil && !slices.Contains(pinned, post.ID) {
+			if len(pinned) >= maxPinnedPosts {
+				respondError(c, http.StatusConflict, "Users can't pin more than 3 posts")
+				return
+			}
+			err = a.pins.Add(ctx, &Pin{UserID: post.AuthorID, PostID: post.ID})
+		}
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update pins")
+		return
+	}
+
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// pinnedPosts returns the posts the author of opts.Posts pinned that match
+// opts, most recently pinned first, and leaves them out of opts so they
+// aren't listed twice.
+func (a *API) pinnedPosts(ctx context.Context, opts *ListOptions) ([]Post, error) {
+	ids, err := a.pins.List(ctx, opts.Posts.AuthorID)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	opts.Posts.ExcludeIDs = ids
+
+	pinnedOpts := *opts
+	pinnedOpts.Posts.ExcludeIDs = nil
+	pinnedOpts.Posts.IDs = ids
+	found, err := a.posts.List(ctx, pinnedOpts)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+	posts := []Post{}
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// fillPins flags which of posts are pinned.
+func (a *API) fillPins(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	pinned, err := a.pins.Pinned(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Pinned = pinned[posts[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Poll is a question attached to a post, with between two and ten options
+// users can vote for. Each user votes once. A poll with ClosesAt set stops
+// taking votes then.
+type Poll struct {
+	ID        uint       `json:"-" gorm:"primary_key" bson:"_id"`
+	PostID    uint       `json:"-" gorm:"not null;uniqueIndex" bson:"post_id"`
+	Question  string     `json:"question" gorm:"size:300;not null" bson:"question"`
+	Options   []string   `json:"-" gorm:"serializer:json;type:text;not null" bson:"options"`
+	ClosesAt  *time.Time `json:"closes_at" bson:"closes_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	// Results, TotalVotes and Closed are filled in for responses, and Voted
+	// with the index of the option the caller voted for, if they did.
+	Results    []PollResult `json:"options" gorm:"-" bson:"-"`
+	TotalVotes int64        `json:"total_votes" gorm:"-" bson:"-"`
+	Closed     bool         `json:"closed" gorm:"-" bson:"-"`
+	Voted      *int         `json:"voted" gorm:"-" bson:"-"`
+}
+
+// PollResult is one option of a poll and its votes so far.
+type PollResult struct {
+	Text  string `json:"text"`
+	Votes int64  `json:"votes"`
+}
+
+// PollVote is one user's vote in the poll on a post, for the option at
+// index Choice.
+type PollVote struct {
+	ID        uint      `gorm:"primary_key" bson:"_id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_poll_votes_post_user,priority:1" bson:"post_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_poll_votes_post_user,priority:2;index" bson:"user_id"`
+	Choice    int       `gorm:"not null" bson:"choice"`
+	CreatedAt time.Time `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// closed reports whether the poll has stopped taking votes.
+func (p Poll) closed() bool {
+	return p.ClosesAt != nil && !time.Now().Before(*p.ClosesAt)
+}
+
+// PollRepository stores polls, one per post, and their votes.
+type PollRepository interface {
+	// Get returns the poll on a post, or ErrNotFound.
+	Get(ctx context.Context, postID uint) (Poll, error)
+	// ForPosts returns the polls on each of postIDs. Posts without a poll
+	// are missing from the result.
+	ForPosts(ctx context.Context, postIDs []uint) (map[uint]Poll, error)
+	// Save creates the poll on poll.PostID, or replaces the one there.
+	Save(ctx context.Context, poll *Poll) error
+	// Delete removes the poll on a post and its votes, or returns
+	// ErrNotFound.
+	Delete(ctx context.Context, postID uint) error
+	// Vote records a vote. It returns ErrConflict if the user already voted
+	// in the poll.
+	Vote(ctx context.Context, vote *PollVote) error
+	// Tallies counts the votes for each option of the polls on postIDs.
+	// Options without votes are missing from the result.
+	Tallies(ctx context.Context, postIDs []uint) (map[uint]map[int]int64, error)
+	// VotesBy returns the option userID voted for in the polls on postIDs.
+	// Polls they didn't vote in are missing from the result.
+	VotesBy(ctx context.Context, userID uint, postIDs []uint) (map[uint]int, error)
+}
+
+type PollRequest struct {
+	Question string     `json:"question" binding:"required,max=300"`
+	Options  []string   `json:"options" binding:"required,min=2,max=10,dive,required,max=200"`
+	ClosesAt *time.Time `json:"closes_at"`
+}
+
+type VoteRequest struct {
+	Option *int `json:"option" binding:"required,min=0"`
+}
+
+// putPoll serves PUT /posts/:id/poll, attaching a poll to the post or
+// replacing the one there, for the post's author and admins. A poll can't be
+// replaced once it has votes.
+func (a *API) putPoll(c *gin.Context) {
+	post, ok := a.editablePost(c)
+	if !ok {
+		return
+	}
+	var req PollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.ClosesAt != nil && !req.ClosesAt.After(time.Now()) {
+		respondError(c, http.StatusBadRequest, "closes_at must be in the future")
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := a.polls.Get(ctx, post.ID)
+	switch {
+	case err == nil:
+		auditBefore(c, existing)
+	case errors.Is(err, ErrNotFound):
+		auditBefore(c, nil)
+	default:
+		respondStoreError(c, err, "poll", "fetch")
+		return
+	}
+	tallies, err := a.polls.Tallies(ctx, []uint{post.ID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch votes")
+		return
+	}
+	if len(tallies[post.ID]) > 0 {
+		respondError(c, http.StatusConflict, "Poll already has votes")
+		return
+	}
+
+	poll := Poll{PostID: post.ID, Question: req.Question, Options: req.Options, ClosesAt: req.ClosesAt}
+	if err := a.polls.Save(ctx, &poll); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to save poll")
+		return
+	}
+	if !a.fillPoll(c, &poll) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", poll, nil)
+}
+
+// deletePoll serves DELETE /posts/:id/poll, removing the post's poll and its
+// votes, for the post's author and admins.
+func (a *API) deletePoll(c *gin.Context) {
+	post, ok := a.editablePost(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	poll, err := a.polls.Get(ctx, post.ID)
+	if err != nil {
+		respondStoreError(c, err, "poll", "fetch")
+		return
+	}
+	auditBefore(c, poll)
+
+	if err := a.polls.Delete(ctx, post.ID); err != nil {
+		respondStoreError(c, err, "poll", "delete")
+		return
+	}
+
+	respond(c, http.StatusOK, "", gin.H{"message": "Poll deleted successfully"}, nil)
+}
+
+// getPoll serves GET /posts/:id/poll, the post's poll with its live results.
+func (a *API) getPoll(c *gin.Context) {
+	poll, ok := a.pathPoll(c)
+	if !ok {
+		return
+	}
+	if !a.fillPoll(c, &poll) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", poll, nil)
+}
+
+// votePoll serves POST /posts/:id/poll/vote, casting the caller's vote for
+// an option, by index, in the post's poll. Each user votes once, and closed
+// polls take no votes. It responds with the updated results.
+func (a *API) votePoll(c *gin.Context) {
+	poll, ok := a.pathPoll(c)
+	if !ok {
+		return
+	}
+	var req VoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if *req.Option >= len(poll.Options) {
+		respondError(c, http.StatusBadRequest, "Invalid option")
+		return
+	}
+	if poll.closed() {
+		respondError(c, http.StatusConflict, "Poll is closed")
+		return
+	}
+
+	user, _ := currentUser(c)
+	vote := PollVote{PostID: poll.PostID, UserID: user.ID, Choice: *req.Option}
+	if err := a.polls.Vote(c.Request.Context(), &vote); err != nil {
+		if errors.Is(err, ErrConflict) {
+			respondError(c, http.StatusConflict, "You already voted in this poll")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to record vote")
+		return
+	}
+	if !a.fillPoll(c, &poll) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", poll, nil)
+}
+
+// pathPoll loads the poll on the :id post, writing the error response and
+// returning ok == false if the caller can't see the post or it has no poll.
+func (a *API) pathPoll(c *gin.Context) (Poll, bool) {
+	id, ok := a.postID(c)
+	if !ok {
+		return Poll{}, false
+	}
+	post, err := a.visiblePost(c, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return Poll{}, false
+	}
+	poll, err := a.polls.Get(c.Request.Context(), post.ID)
+	if err != nil {
+		respondStoreError(c, err, "poll", "fetch")
+		return Poll{}, false
+	}
+	return poll, true
+}
+
+// fillPoll fills in the results of poll and the caller's vote, writing the
+// error response and returning false if it can't.
+func (a *API) fillPoll(c *gin.Context, poll *Poll) bool {
+	polls := map[uint]Poll{poll.PostID: *poll}
+	if err := a.tallyPolls(c, polls); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch votes")
+		return false
+	}
+	*poll = polls[poll.PostID]
+	return true
+}
+
+// fillPolls attaches their polls, with results, to posts.
+func (a *API) fillPolls(c *gin.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	polls, err := a.polls.ForPosts(c.Request.Context(), ids)
+	if err != nil {
+		return err
+	}
+	if err := a.tallyPolls(c, polls); err != nil {
+		return err
+	}
+	for i := range posts {
+		if poll, ok := polls[posts[i].ID]; ok {
+			posts[i].Poll = &poll
+		}
+	}
+	return nil
+}
+
+// tallyPolls fills in the results of polls, keyed by post, and the caller's
+// votes in them.
+func (a *API) tallyPolls(c *gin.Context, polls map[uint]Poll) error {
+	if len(polls) == 0 {
+		return nil
+	}
+	ctx := c.Request.Context()
+	ids := make([]uint, 0, len(polls))
+	for id := range polls {
+		ids = append(ids, id)
+	}
+	tallies, err := a.polls.Tallies(ctx, ids)
+	if err != nil {
+		return err
+	}
+	votes := map[uint]int{}
+	if user, ok := currentUser(c); ok {
+		if votes, err = a.polls.VotesBy(ctx, user.ID, ids); err != nil {
+			return err
+		}
+	}
+
+	for id, poll := range polls {
+		poll.Results = make([]PollResult, len(poll.Options))
+		poll.TotalVotes = 0
+		for i, text := range poll.Options {
+			poll.Results[i] = PollResult{Text: text, Votes: tallies[id][i]}
+			poll.TotalVotes += tallies[id][i]
+		}
+		poll.Closed = poll.closed()
+		poll.Voted = nil
+		if option, ok := votes[id]; ok {
+			poll.Voted = &option
+		}
+		polls[id] = poll
+	}
+	return nil
+}
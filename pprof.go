@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http/pprof"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mountPprof serves the net/http/pprof profiles under /debug/pprof to
+// admins, and to requests carrying DEBUG_TOKEN in X-Debug-Token, so that
+// tools without an account can profile an instance.
+func (a *API) mountPprof(r *gin.Engine) {
+	debug := r.Group("/debug/pprof", a.requireDebugAccess)
+	{
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		// heap, goroutine, allocs, block, mutex and threadcreate.
+		debug.GET("/:profile", gin.WrapF(pprof.Index))
+	}
+}
+
+// requireDebugAccess lets through requests with the debug token, and
+// otherwise requires an admin whose API key, if they use one, has the
+// users:admin scope.
+func (a *API) requireDebugAccess(c *gin.Context) {
+	if token := c.GetHeader("X-Debug-Token"); a.debugToken != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(a.debugToken)) == 1 {
+		c.Next()
+		return
+	}
+
+	if !a.authenticate(c, true) {
+		return
+	}
+	user, _ := currentUser(c)
+	if key, ok := currentAPIKey(c); ok && !key.allows(ScopeUsersAdmin) {
+		a.deny(c, "API key lacks the "+ScopeUsersAdmin+" scope")
+		return
+	}
+	if !hasRole(user, RoleAdmin) {
+		a.deny(c, "Insufficient permissions")
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateProfileRequest is the body of PATCH /users/me. Fields left out are
+// unchanged, and an empty string clears one.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,max=2048,web_url"`
+	Website     *string `json:"website" binding:"omitempty,max=2048,web_url"`
+	Version     *uint   `json:"version"`
+}
+
+// getMe serves GET /users/me, the authenticated user, so clients needn't
+// know their own ID. It takes ?fields= like GET /users/:id.
+func (a *API) getMe(c *gin.Context) {
+	fields, ok := parseFields(c, userFields)
+	if !ok {
+		return
+	}
+
+	user, _ := currentUser(c)
+	if !a.countUserFollows(c, &user) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", fields.project(user), nil)
+}
+
+// updateMe serves PATCH /users/me, editing the authenticated user's profile.
+// Username, email and role stay with PUT /users/:id.
+func (a *API) updateMe(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, _ := currentUser(c)
+	auditBefore(c, user)
+	if !a.checkUserPreconditions(c, user) {
+		return
+	}
+	version, ok := expectedVersion(c, req.Version, user.Version)
+	if !ok {
+		return
+	}
+
+	if req.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Bio != nil {
+		user.Bio = strings.TrimSpace(*req.Bio)
+	}
+	if req.AvatarURL != nil {
+		user.AvatarURL = *req.AvatarURL
+	}
+	if req.Website != nil {
+		user.Website = *req.Website
+	}
+	user.Version = version
+
+	if err := a.users.Update(c.Request.Context(), &user); err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			respondError(c, http.StatusNotFound, "User not found")
+		case errors.Is(err, ErrVersionConflict):
+			respondError(c, http.StatusConflict, "User was modified by another request")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to update profile")
+		}
+		return
+	}
+	if !a.countUserFollows(c, &user) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", user, nil)
+}
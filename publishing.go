@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Post statuses. Drafts are only shown to their authors and admins. Posts
+// stored before statuses existed, with none, count as published.
+const (
+	PostDraft     = "draft"
+	PostPublished = "published"
+)
+
+// setStatus moves post to status, stamping PublishedAt when it goes from
+// draft to published and clearing it when it goes back.
+func (p *Post) setStatus(status string) {
+	switch {
+	case status == PostDraft:
+		p.Status, p.PublishedAt = PostDraft, nil
+	case p.Status == PostDraft || p.PublishedAt == nil:
+		now := time.Now()
+		p.Status, p.PublishedAt = PostPublished, &now
+	default:
+		p.Status = PostPublished
+	}
+}
+
+// publishPost serves POST /posts/:id/publish.
+func (a *API) publishPost(c *gin.Context) {
+	a.changePostStatus(c, PostPublished)
+}
+
+// unpublishPost serves POST /posts/:id/unpublish, turning a post back into
+// a draft.
+func (a *API) unpublishPost(c *gin.Context) {
+	a.changePostStatus(c, PostDraft)
+}
+
+// changePostStatus moves the :id post to status on behalf of its author or
+// an admin. A post already there is returned unchanged.
+func (a *API) changePostStatus(c *gin.Context, status string) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.posts.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	if !a.canModifyPost(c, post) || !checkNotArchived(c, post) {
+		return
+	}
+	if !a.checkPostPreconditions(c, post) {
+		return
+	}
+
+	if (post.Status == PostDraft) != (status == PostDraft) {
+		post.setStatus(status)
+		if err := a.posts.Update(ctx, &post); err != nil {
+			respondStoreError(c, err, "post", "update")
+			return
+		}
+		activity := Activity{UserID: post.AuthorID, Type: ActivityPosted, PostID: post.ID}
+		a.recordActivity(c, activity, status == PostDraft)
+		a.syncMentions(c, post, status != PostDraft)
+	}
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// canViewPost reports whether the caller may see post: it is published and
+// not private, or they are its author, a co-author or an admin. Failing to
+// look up co-authors is logged and hides the post.
+func (a *API) canViewPost(c *gin.Context, post Post) bool {
+	if post.Status != PostDraft && post.visibility() != VisibilityPrivate {
+		return true
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return false
+	}
+	if hasRole(user, RoleAdmin) {
+		return true
+	}
+	author, err := a.isAuthor(c.Request.Context(), post, user)
+	if err != nil {
+		logf(c.Request.Context(), "co-authors: failed to look up co-authors of post %d: %v", post.ID, err)
+	}
+	return author
+}
+
+// visiblePost is a.posts.Get that reports another user's draft or private
+// post as not found.
+func (a *API) visiblePost(c *gin.Context, id uint) (Post, error) {
+	post, err := a.posts.Get(c.Request.Context(), id)
+	if err == nil && !a.canViewPost(c, post) {
+		return Post{}, ErrNotFound
+	}
+	return post, err
+}
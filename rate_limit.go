@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitStore counts requests per key in fixed windows.
+type RateLimitStore interface {
+	// Incr counts a request against key in the current window and returns
+	// the count so far and when the window ends.
+	Incr(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+}
+
+// identityLimiter enforces request quotas per authenticated user and per
+// API key. A limit of 0 disables that quota.
+type identityLimiter struct {
+	store     RateLimitStore
+	perUser   int
+	perAPIKey int
+	window    time.Duration
+}
+
+// newIdentityLimiter reads RATE_LIMIT_PER_USER, RATE_LIMIT_PER_API_KEY,
+// RATE_LIMIT_WINDOW and RATE_LIMIT_STORE ("memory", the default, or
+// "redis").
+func newIdentityLimiter() *identityLimiter {
+	l := &identityLimiter{
+		perUser:   envInt("RATE_LIMIT_PER_USER", 1000),
+		perAPIKey: envInt("RATE_LIMIT_PER_API_KEY", 1000),
+		window:    envDuration("RATE_LIMIT_WINDOW", time.Hour),
+	}
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		l.store = newMemoryRateLimitStore()
+	case "redis":
+		l.store = newRedisRateLimitStore()
+	default:
+		log.Fatalf("unsupported RATE_LIMIT_STORE %q", store)
+	}
+	return l
+}
+
+// allow counts the request against the quota of the identity authenticate
+// just stored in the context, sets the X-RateLimit-* headers and, if the
+// quota is used up, aborts with 429. Requests made with an API key count
+// against the key rather than its user.
+func (l *identityLimiter) allow(c *gin.Context) bool {
+	var key string
+	var limit int
+	if apiKey, ok := currentAPIKey(c); ok {
+		key, limit = "key:"+strconv.FormatUint(uint64(apiKey.ID), 10), l.perAPIKey
+	} else if user, ok := currentUser(c); ok {
+		key, limit = "user:"+strconv.FormatUint(uint64(user.ID), 10), l.perUser
+	}
+	if key == "" || limit <= 0 {
+		return true
+	}
+
+	count, resetAt, err := l.store.Incr(c.Request.Context(), key, l.window)
+	if err != nil {
+		// Don't take the API down with the rate limit store.
+		logf(c.Request.Context(), "rate limit: %v", err)
+		return true
+	}
+
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+
+	if count > limit {
+		setRetryAfter(c, time.Until(resetAt))
+		abortWithErrorDetails(c, http.StatusTooManyRequests, "Rate limit exceeded", gin.H{"reset_at": resetAt.UTC()})
+		return false
+	}
+	return true
+}
+
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// memoryRateLimitStore counts in process memory, so each instance enforces
+// the quotas separately.
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastPrune time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{windows: map[string]*rateWindow{}}
+}
+
+func (s *memoryRateLimitStore) Incr(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > time.Minute {
+		s.lastPrune = now
+		for k, w := range s.windows {
+			if !now.Before(w.resetAt) {
+				delete(s.windows, k)
+			}
+		}
+	}
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &rateWindow{resetAt: now.Truncate(window).Add(window)}
+		s.windows[key] = w
+	}
+	w.count++
+	return w.count, w.resetAt, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// User roles. Readers can only read, editors can also write posts and
+// manage their own, and admins can manage any post and user and see deleted
+// records.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleReader = "reader"
+)
+
+// defaultRole is the role given to users who register themselves or are
+// created without one.
+var defaultRole = RoleReader
+
+// configureDefaultRole reads DEFAULT_ROLE ("reader" by default).
+func configureDefaultRole() {
+	role := os.Getenv("DEFAULT_ROLE")
+	if role == "" {
+		return
+	}
+	if !validRole(role) {
+		log.Fatalf("unsupported DEFAULT_ROLE %q", role)
+	}
+	defaultRole = role
+}
+
+func validRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleEditor, RoleReader:
+		return true
+	}
+	return false
+}
+
+// hasRole reports whether user has one of roles. Users stored before roles
+// existed have none and count as readers.
+func hasRole(user User, roles ...string) bool {
+	role := user.Role
+	if role == "" {
+		role = RoleReader
+	}
+	for _, r := range roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// requireRole allows the request through only if the user authenticated by
+// requireAuth has one of roles, and responds 403 otherwise.
+func (a *API) requireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok || !hasRole(user, roles...) {
+			a.deny(c, "Insufficient permissions")
+			return
+		}
+		c.Next()
+	}
+}
+
+// canModifyPost reports whether the authenticated user may update or delete
+// post: its author, a co-author or an admin. For anyone else it responds
+// with 403.
+func (a *API) canModifyPost(c *gin.Context, post Post) bool {
+	user, ok := currentUser(c)
+	if ok && hasRole(user, RoleAdmin) {
+		auditBefore(c, post)
+		return true
+	}
+	if ok {
+		author, err := a.isAuthor(c.Request.Context(), post, user)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+			return false
+		}
+		if author {
+			auditBefore(c, post)
+			return true
+		}
+	}
+	a.deny(c, "Only the author or an admin can modify this post")
+	return false
+}
+
+// runRole implements the `role` subcommand, which is how the first admin is
+// created:
+//
+//	role <username> <admin|editor|reader>
+func runRole(args []string) {
+	if len(args) != 2 || !validRole(args[1]) {
+		log.Fatal("usage: role <username> <admin|editor|reader>")
+	}
+	if driver := dbDriver(); driver == "memory" {
+		log.Fatalf("role is not available with DB_DRIVER=%s", driver)
+	}
+
+	ctx := context.Background()
+	users := newStorage().Users
+	user, err := users.GetByUsername(ctx, args[0])
+	if err != nil {
+		log.Fatalf("failed to load user %q: %v", args[0], err)
+	}
+
+	user.Role = args[1]
+	if err := users.Update(ctx, &user); err != nil {
+		log.Fatalf("failed to update user %q: %v", args[0], err)
+	}
+	log.Printf("%s is now %s", user.Username, user.Role)
+}
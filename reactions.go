@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reaction types users can leave on posts.
+const (
+	ReactionLike  = "like"
+	ReactionHeart = "heart"
+	ReactionLaugh = "laugh"
+)
+
+// Reaction is one user's reaction of one type to a post. A user can leave
+// several types on the same post, but each only once.
+type Reaction struct {
+	ID        uint      `json:"-" gorm:"primary_key" bson:"_id"`
+	PostID    uint      `json:"-" gorm:"not null;uniqueIndex:idx_reactions_post_user_type,priority:1" bson:"post_id"`
+	UserID    uint      `json:"-" gorm:"not null;uniqueIndex:idx_reactions_post_user_type,priority:2;index" bson:"user_id"`
+	Type      string    `json:"type" gorm:"size:16;not null;uniqueIndex:idx_reactions_post_user_type,priority:3" bson:"type"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index" bson:"created_at"`
+	// User is loaded for listings.
+	User *User `json:"user,omitempty" gorm:"-" bson:"-"`
+}
+
+// ReactionRepository stores reactions. Add does nothing if the user already
+// left that reaction, and Remove does nothing if they hadn't, so both are
+// safe to repeat; each reports whether it changed anything.
+type ReactionRepository interface {
+	Add(ctx context.Context, reaction *Reaction) (bool, error)
+	Remove(ctx context.Context, postID, userID uint, reactionType string) (bool, error)
+	// List returns a post's reactions, oldest first, of the given type or of
+	// any type if it is empty. It honours opts.Offset and opts.Limit only.
+	List(ctx context.Context, postID uint, reactionType string, opts ListOptions) ([]Reaction, error)
+	Count(ctx context.Context, postID uint, reactionType string) (int64, error)
+	// Counts tallies the reactions to each of postIDs by type. Posts without
+	// reactions are missing from the result.
+	Counts(ctx context.Context, postIDs []uint) (map[uint]map[string]int64, error)
+	// Recent returns the reactions left since since, on any post.
+	Recent(ctx context.Context, since time.Time) ([]Reaction, error)
+}
+
+// reactionQuery is the query string of the reaction endpoints.
+type reactionQuery struct {
+	Type string `form:"type" binding:"omitempty,oneof=like heart laugh"`
+}
+
+// parseReactionType reads ?type=, defaulting to ReactionLike when
+// orDefault is set. On invalid input it writes the error response and
+// returns ok == false.
+func parseReactionType(c *gin.Context, orDefault bool) (reactionType string, ok bool) {
+	var query reactionQuery
+	if !bindQuery(c, &query) {
+		return "", false
+	}
+	if query.Type == "" && orDefault {
+		return ReactionLike, true
+	}
+	return query.Type, true
+}
+
+// likePost serves POST /posts/:id/like?type=, leaving the caller's reaction
+// (a like by default) on the post, and DELETE /posts/:id/like?type=,
+// taking it back. Both respond with the post and its updated counts, and
+// repeating either changes nothing.
+func (a *API) likePost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+	reactionType, ok := parseReactionType(c, true)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.visiblePost(c, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+
+	user, _ := currentUser(c)
+	var changed bool
+	delta := int64(1)
+	if c.Request.Method == http.MethodDelete {
+		changed, err = a.reactions.Remove(ctx, post.ID, user.ID, reactionType)
+		delta = -1
+	} else {
+		changed, err = a.reactions.Add(ctx, &Reaction{PostID: post.ID, UserID: user.ID, Type: reactionType})
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update reactions")
+		return
+	}
+	if changed && reactionType == ReactionLike {
+		a.countEngagement(c, post.ID, EngagementLikes, delta)
+	}
+	activity := Activity{UserID: user.ID, Type: ActivityReacted, PostID: post.ID, Reaction: reactionType}
+	a.recordActivity(c, activity, c.Request.Method == http.MethodDelete)
+
+	if !a.fillPostCount(c, &post) {
+		return
+	}
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// getPostLikes serves GET /posts/:id/likes, the users who reacted to a post
+// a page at a time, oldest first. ?type= narrows it to one reaction type.
+func (a *API) getPostLikes(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+	reactionType, ok := parseReactionType(c, false)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := a.visiblePost(c, id); err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	total, err := a.reactions.Count(ctx, id, reactionType)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch reactions")
+		return
+	}
+	reactions, err := a.reactions.List(ctx, id, reactionType, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch reactions")
+		return
+	}
+
+	ids := make([]uint, len(reactions))
+	for i, reaction := range reactions {
+		ids[i] = reaction.UserID
+	}
+	users, err := a.users.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	byID := make(map[uint]User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	// Reactions from deleted users are listed without them.
+	for i := range reactions {
+		if user, ok := byID[reactions[i].UserID]; ok {
+			reactions[i].User = &user
+		}
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "reactions", reactions, gin.H{
+		"count":      len(reactions),
+		"pagination": page.meta(total),
+	})
+}
+
+// countReactions fills in the reaction counts of posts.
+func (a *API) countReactions(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	counts, err := a.reactions.Counts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reactions = counts[posts[i].ID]
+		posts[i].LikesCount = counts[posts[i].ID][ReactionLike]
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import "strings"
+
+// wordsPerMinute is the reading speed reading times are estimated at.
+const wordsPerMinute = 200
+
+// countWords fills in the word count of the post's content and the minutes
+// it takes to read, rounded up. It is called whenever the content is
+// written, so responses carry both without recounting.
+func (p *Post) countWords() {
+	p.WordCount = len(strings.Fields(p.Content))
+	p.ReadingMinutes = (p.WordCount + wordsPerMinute - 1) / wordsPerMinute
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisOnce   sync.Once
+	redisClient *redis.Client
+)
+
+// sharedRedisClient connects to the Redis server at REDIS_URL on first use.
+// The stores backed by Redis share the one client, and its cache circuit
+// breaker.
+func sharedRedisClient() *redis.Client {
+	redisOnce.Do(func() {
+		url := os.Getenv("REDIS_URL")
+		if url == "" {
+			url = "redis://localhost:6379/0"
+		}
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			log.Fatalf("invalid REDIS_URL: %v", err)
+		}
+
+		redisClient = redis.NewClient(opts)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			log.Fatalf("failed to connect to redis: %v", err)
+		}
+		redisClient.AddHook(redisBreaker{breaker: newCircuitBreaker("cache", 5, 10*time.Second)})
+	})
+	return redisClient
+}
+
+// closeSharedRedisClient closes the shared client, if it was ever used.
+func closeSharedRedisClient() error {
+	if redisClient == nil {
+		return nil
+	}
+	return redisClient.Close()
+}
+
+// redisSessionStore keeps sessions in Redis as session:<hash> keys holding
+// the user ID, expired by Redis itself, with a user-sessions:<user ID> set
+// of each user's hashes for DeleteUser. Sessions survive restarts and are
+// shared between instances.
+type redisSessionStore struct {
+	client *redis.Client
+}
+
+func newRedisSessionStore() *redisSessionStore {
+	return &redisSessionStore{client: sharedRedisClient()}
+}
+
+func (s *redisSessionStore) Save(ctx context.Context, hash string, userID uint, ttl time.Duration) error {
+	index := userSessionsKey(userID)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, "session:"+hash, userID, ttl)
+	pipe.SAdd(ctx, index, hash)
+	pipe.Expire(ctx, index, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisSessionStore) Get(ctx context.Context, hash string) (uint, error) {
+	value, err := s.client.Get(ctx, "session:"+hash).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+func (s *redisSessionStore) Delete(ctx context.Context, hash string) error {
+	return s.client.Del(ctx, "session:"+hash).Err()
+}
+
+// DeleteUser deletes the sessions in the user's set, and the set. Hashes
+// of sessions that have expired are deleted along with it.
+func (s *redisSessionStore) DeleteUser(ctx context.Context, userID uint) error {
+	index := userSessionsKey(userID)
+	hashes, err := s.client.SMembers(ctx, index).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{index}
+	for _, hash := range hashes {
+		keys = append(keys, "session:"+hash)
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+func userSessionsKey(userID uint) string {
+	return "user-sessions:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// redisRevocationList keeps revoked token IDs in Redis as revoked:<jti> keys
+// that expire along with the tokens, so every instance sees a revocation.
+// RevokeUser sets revoked-user:<user ID> to the Unix time tokens must have
+// been issued at or after.
+type redisRevocationList struct {
+	client *redis.Client
+}
+
+func newRedisRevocationList() *redisRevocationList {
+	return &redisRevocationList{client: sharedRedisClient()}
+}
+
+func (l *redisRevocationList) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return l.client.Set(ctx, "revoked:"+jti, 1, ttl).Err()
+}
+
+func (l *redisRevocationList) RevokeUser(ctx context.Context, userID uint, before, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	return l.client.Set(ctx, revokedUserKey(userID), before.Unix(), ttl).Err()
+}
+
+func (l *redisRevocationList) IsRevoked(ctx context.Context, token accessClaims) (bool, error) {
+	values, err := l.client.MGet(ctx, "revoked:"+token.ID, revokedUserKey(token.UserID)).Result()
+	if err != nil {
+		return false, err
+	}
+	if values[0] != nil {
+		return true, nil
+	}
+	before, ok := values[1].(string)
+	if !ok {
+		return false, nil
+	}
+	unix, err := strconv.ParseInt(before, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return token.IssuedAt.Before(time.Unix(unix, 0)), nil
+}
+
+func revokedUserKey(userID uint) string {
+	return "revoked-user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// redisRateLimitStore counts requests in Redis as
+// ratelimit:<key>:<window start> keys, so quotas hold across instances.
+type redisRateLimitStore struct {
+	client *redis.Client
+}
+
+func newRedisRateLimitStore() *redisRateLimitStore {
+	return &redisRateLimitStore{client: sharedRedisClient()}
+}
+
+func (s *redisRateLimitStore) Incr(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	start := time.Now().Truncate(window)
+	resetAt := start.Add(window)
+	name := "ratelimit:" + key + ":" + strconv.FormatInt(start.Unix(), 10)
+
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, name)
+	pipe.ExpireAt(ctx, name, resetAt)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, time.Time{}, err
+	}
+	return int(incr.Val()), resetAt, nil
+}
+
+// redisIdempotencyStore keeps idempotency records in Redis as
+// idempotency:<key> keys holding JSON, so retries are recognised by every
+// instance.
+type redisIdempotencyStore struct {
+	client *redis.Client
+}
+
+func newRedisIdempotencyStore() *redisIdempotencyStore {
+	return &redisIdempotencyStore{client: sharedRedisClient()}
+}
+
+func (s *redisIdempotencyStore) Reserve(ctx context.Context, key string, record idempotencyRecord, ttl time.Duration) (idempotencyRecord, bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+	reserved, err := s.client.SetNX(ctx, "idempotency:"+key, data, ttl).Result()
+	if err != nil || reserved {
+		return idempotencyRecord{}, reserved, err
+	}
+
+	stored, err := s.client.Get(ctx, "idempotency:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Expired or released in between; let the caller go ahead.
+		return s.Reserve(ctx, key, record, ttl)
+	}
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+	var existing idempotencyRecord
+	err = json.Unmarshal(stored, &existing)
+	return existing, false, err
+}
+
+func (s *redisIdempotencyStore) Complete(ctx context.Context, key string, record idempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, "idempotency:"+key, data, ttl).Err()
+}
+
+func (s *redisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, "idempotency:"+key).Err()
+}
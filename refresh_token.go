@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshToken is a stored, single-use refresh token. Tokens issued by
+// rotating one another share a FamilyID, so presenting an already-used token
+// can revoke every token descended from the same login.
+type RefreshToken struct {
+	ID        uint       `gorm:"primary_key" bson:"_id"`
+	UserID    uint       `gorm:"not null;index" bson:"user_id"`
+	FamilyID  string     `gorm:"size:36;not null;index" bson:"family_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" bson:"token_hash"`
+	ExpiresAt time.Time  `gorm:"not null" bson:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at"`
+	RevokedAt *time.Time `bson:"revoked_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" bson:"created_at"`
+}
+
+// RefreshTokenRepository stores refresh tokens by the SHA-256 hash of their
+// value; the raw token is never stored.
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (RefreshToken, error)
+	// MarkUsed marks the token used, returning ErrConflict if it already was.
+	MarkUsed(ctx context.Context, id uint) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUser revokes every refresh token issued to the user.
+	RevokeUser(ctx context.Context, userID uint) error
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// hashToken returns the hex SHA-256 of a random token value.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRandomToken returns 32 random bytes, base64url encoded.
+func newRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// issueRefreshToken creates and stores a refresh token for userID in the
+// given family, returning the raw token value.
+func (a *API) issueRefreshToken(ctx context.Context, userID uint, familyID string) (string, RefreshToken, error) {
+	value, err := newRandomToken()
+	if err != nil {
+		return "", RefreshToken{}, err
+	}
+
+	token := RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(value),
+		ExpiresAt: time.Now().Add(a.tokens.refreshTTL),
+	}
+	if err := a.refreshTokens.Create(ctx, &token); err != nil {
+		return "", RefreshToken{}, err
+	}
+	return value, token, nil
+}
+
+// refresh exchanges a refresh token for a new access token and a new refresh
+// token in the same family. Presenting a token that has already been
+// exchanged revokes the whole family, logging out whoever holds the latest
+// token as well as whoever replayed the old one.
+func (a *API) refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := a.refreshTokens.GetByHash(ctx, hashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		respondError(c, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	if token.UsedAt != nil {
+		a.revokeReusedFamily(c, token)
+		return
+	}
+	if err := a.refreshTokens.MarkUsed(ctx, token.ID); err != nil {
+		if errors.Is(err, ErrConflict) {
+			a.revokeReusedFamily(c, token)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	user, err := a.users.Get(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	a.audit(c, AuditTokenRefreshed, user, "")
+	a.respondWithTokens(c, http.StatusOK, user, token.FamilyID)
+}
+
+func (a *API) revokeReusedFamily(c *gin.Context, token RefreshToken) {
+	a.audit(c, AuditTokenReused, User{ID: token.UserID}, "family "+token.FamilyID+" revoked")
+	if err := a.refreshTokens.RevokeFamily(c.Request.Context(), token.FamilyID); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+	respondError(c, http.StatusUnauthorized, "Refresh token reuse detected; session revoked")
+}
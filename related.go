@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxRelatedPosts caps how many related posts are kept per post.
+	maxRelatedPosts = 10
+	// maxRelatedCandidates caps how many published posts, the most recent
+	// first, are scored against a post.
+	maxRelatedCandidates = 500
+	// maxRelatedCached caps how many posts' related posts are cached.
+	maxRelatedCached = 10000
+	// minRelatedTermLength is the shortest word counted as a term, so that
+	// words such as "the" and "and" don't make posts related.
+	minRelatedTermLength = 4
+)
+
+// Weights of each signal in a related post's score. Term overlap is the
+// Jaccard index of the two posts' terms, between 0 and 1.
+const (
+	relatedAuthorWeight  = 1
+	relatedTitleWeight   = 3
+	relatedContentWeight = 2
+)
+
+// related caches the posts related to each post. A cached entry older than
+// ttl is still served, but is recomputed in the background, so only the
+// first request for a post waits on the scoring.
+type related struct {
+	posts PostRepository
+	ttl   time.Duration
+
+	mu         sync.Mutex
+	cache      map[uint]relatedEntry
+	refreshing map[uint]bool
+}
+
+// relatedEntry is the related posts of one post, best match first, and when
+// they were computed.
+type relatedEntry struct {
+	ids        []uint
+	computedAt time.Time
+}
+
+// newRelated reads RELATED_POSTS_TTL.
+func newRelated(posts PostRepository) *related {
+	return &related{
+		posts:      posts,
+		ttl:        envDuration("RELATED_POSTS_TTL", time.Hour),
+		cache:      make(map[uint]relatedEntry),
+		refreshing: make(map[uint]bool),
+	}
+}
+
+// get returns the IDs of the posts related to post and when they were
+// computed, computing them now if they aren't cached.
+func (r *related) get(ctx context.Context, post Post) ([]uint, time.Time, error) {
+	r.mu.Lock()
+	entry, ok := r.cache[post.ID]
+	stale := ok && time.Since(entry.computedAt) > r.ttl && !r.refreshing[post.ID]
+	if stale {
+		r.refreshing[post.ID] = true
+	}
+	r.mu.Unlock()
+
+	if stale {
+		go func() {
+			if _, err := r.refresh(context.Background(), post); err != nil {
+				log.Printf("related: failed to score posts related to %d: %v", post.ID, err)
+			}
+		}()
+	}
+	if ok {
+		return entry.ids, entry.computedAt, nil
+	}
+	entry, err := r.refresh(ctx, post)
+	return entry.ids, entry.computedAt, err
+}
+
+// refresh scores the most recent listed posts against post and caches
+// the best matches.
+func (r *related) refresh(ctx context.Context, post Post) (relatedEntry, error) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.refreshing, post.ID)
+		r.mu.Unlock()
+	}()
+
+	candidates, err := r.posts.List(ctx, ListOptions{
+		Posts: PostFilter{ListedOnly: true},
+		Sort:  []SortField{{Field: "created_at", Desc: true}},
+		Limit: maxRelatedCandidates,
+	})
+	if err != nil {
+		return relatedEntry{}, err
+	}
+
+	title, content := relatedTerms(post.Title), relatedTerms(post.Content)
+	scores := make(map[uint]float64)
+	ids := []uint{}
+	for _, candidate := range candidates {
+		if candidate.ID == post.ID {
+			continue
+		}
+		score := relatedTitleWeight*jaccard(title, relatedTerms(candidate.Title)) +
+			relatedContentWeight*jaccard(content, relatedTerms(candidate.Content))
+		if score == 0 {
+			continue
+		}
+		if candidate.AuthorID == post.AuthorID {
+			score += relatedAuthorWeight
+		}
+		scores[candidate.ID] = score
+		ids = append(ids, candidate.ID)
+	}
+	// Ties go to the newer post.
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] > ids[j]
+	})
+	if len(ids) > maxRelatedPosts {
+		ids = ids[:maxRelatedPosts]
+	}
+
+	entry := relatedEntry{ids: ids, computedAt: time.Now()}
+	r.mu.Lock()
+	if _, ok := r.cache[post.ID]; !ok && len(r.cache) >= maxRelatedCached {
+		// Evict an arbitrary entry; a post asked about again is rescored.
+		for id := range r.cache {
+			delete(r.cache, id)
+			break
+		}
+	}
+	r.cache[post.ID] = entry
+	r.mu.Unlock()
+	return entry, nil
+}
+
+// relatedTerms returns the distinct words of text, in lower case, that are
+// at least minRelatedTermLength letters or digits long.
+func relatedTerms(text string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) >= minRelatedTermLength {
+			terms[word] = true
+		}
+	}
+	return terms
+}
+
+// jaccard returns how many terms a and b share, as a fraction of the terms
+// in either.
+func jaccard(a, b map[string]bool) float64 {
+	shared := 0
+	for term := range a {
+		if b[term] {
+			shared++
+		}
+	}
+	if shared == 0 {
+		return 0
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// getRelatedPosts serves GET /posts/:id/related, up to 10 published posts
+// similar to the post, best match first. It takes ?fields= and ?expand= like
+// GET /posts.
+func (a *API) getRelatedPosts(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(c, postFields)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c, postExpansions)
+	if !ok {
+		return
+	}
+	post, err := a.visiblePost(c, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	var blocked PostFilter
+	if !a.hideBlocked(c, &blocked) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	ids, computedAt, err := a.related.get(ctx, post)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch related posts")
+		return
+	}
+	found, err := a.posts.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+
+	// Posts deleted, unpublished or hidden since they were scored are left
+	// out, as are those by users the caller blocked.
+	byID := make(map[uint]Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+	posts := []Post{}
+	for _, id := range ids {
+		if post, ok := byID[id]; ok && post.listed() && !slices.Contains(blocked.ExcludeAuthorIDs, post.AuthorID) {
+			posts = append(posts, post)
+		}
+	}
+	if expand["author"] {
+		if err := a.expandAuthors(ctx, posts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+			return
+		}
+	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+
+	respondCacheable(c, render(c, "posts", projectAll(fields, posts), gin.H{
+		"count":       len(posts),
+		"computed_at": computedAt,
+	}))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// replicaCooldown is how long reads stay on the primary after the replica
+// fails a query.
+const replicaCooldown = 30 * time.Second
+
+// readRouter sends list queries to the read replica configured in
+// DB_READ_DSN, falling back to the primary while the replica is failing.
+type readRouter struct {
+	primary *gorm.DB
+	replica *gorm.DB
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// close closes the primary's and the replica's connection pools.
+func (r *readRouter) close() error {
+	var errs []error
+	for _, db := range []*gorm.DB{r.primary, r.replica} {
+		if db == nil {
+			continue
+		}
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// newReadRouter connects to DB_READ_DSN, if set, using the same driver as the
+// primary. Without a replica every read goes to the primary.
+func newReadRouter(driver string, primary *gorm.DB) *readRouter {
+	r := &readRouter{primary: primary}
+
+	dsn := os.Getenv("DB_READ_DSN")
+	if dsn == "" {
+		return r
+	}
+
+	dialector, err := openDialectorDSN(driver, dsn)
+	if err != nil {
+		log.Fatalf("failed to configure read replica: %v", err)
+	}
+
+	// Don't ping on open: an unreachable replica at startup should degrade
+	// to primary reads, not stop the server.
+	replica, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true, TranslateError: true})
+	if err != nil {
+		log.Printf("read replica unavailable, reading from primary: %v", err)
+		return r
+	}
+	if sqlDB, err := replica.DB(); err == nil {
+		configurePool(sqlDB)
+	}
+	traceGorm(replica)
+
+	r.replica = replica
+	return r
+}
+
+// read runs fn against the replica, retrying on the primary if the replica
+// is unconfigured, cooling down, or fails.
+func (r *readRouter) read(ctx context.Context, fn func(db *gorm.DB) error) error {
+	if r.replica != nil && r.replicaUp() {
+		err := fn(r.replica.WithContext(ctx))
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		logf(ctx, "read replica query failed, falling back to primary: %v", err)
+		r.markDown()
+	}
+	return fn(r.primary.WithContext(ctx))
+}
+
+func (r *readRouter) replicaUp() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Now().After(r.downUntil)
+}
+
+func (r *readRouter) markDown() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downUntil = time.Now().Add(replicaCooldown)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Report reasons.
+const (
+	ReportSpam           = "spam"
+	ReportHarassment     = "harassment"
+	ReportHate           = "hate"
+	ReportViolence       = "violence"
+	ReportSexual         = "sexual"
+	ReportMisinformation = "misinformation"
+	ReportOther          = "other"
+)
+
+// Report is a user's report of a post to the moderators. Each user can
+// report a post once. Reports are open until a moderator decides on the
+// post, which resolves them.
+type Report struct {
+	ID         uint       `json:"id" gorm:"primary_key" bson:"_id"`
+	PostID     uint       `json:"post_id" gorm:"not null;uniqueIndex:idx_reports_post_reporter,priority:1" bson:"post_id"`
+	ReporterID uint       `json:"reporter_id" gorm:"not null;uniqueIndex:idx_reports_post_reporter,priority:2;index" bson:"reporter_id"`
+	Reason     string     `json:"reason" gorm:"size:16;not null" bson:"reason"`
+	Details    string     `json:"details" gorm:"size:500;not null;default:''" bson:"details"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at" gorm:"index" bson:"resolved_at"`
+}
+
+// ReportSummary aggregates the reports against one post.
+type ReportSummary struct {
+	PostID  uint             `json:"-"`
+	Count   int64            `json:"count"`
+	Reasons map[string]int64 `json:"reasons"`
+	// Post is loaded for responses.
+	Post *Post `json:"post"`
+}
+
+// ReportRepository stores reports. Create returns ErrConflict if the
+// reporter already reported the post.
+type ReportRepository interface {
+	Create(ctx context.Context, report *Report) error
+	// Summaries aggregates reports by post, most reported first, then most
+	// recently reported, counting only open reports if open is set. It
+	// honours opts.Offset and opts.Limit only.
+	Summaries(ctx context.Context, open bool, opts ListOptions) ([]ReportSummary, error)
+	// CountSummaries counts the reported posts, or those with open reports
+	// if open is set.
+	CountSummaries(ctx context.Context, open bool) (int64, error)
+	// Resolve resolves the open reports against a post.
+	Resolve(ctx context.Context, postID uint) error
+}
+
+type ReportRequest struct {
+	Reason  string `json:"reason" binding:"required,oneof=spam harassment hate violence sexual misinformation other"`
+	Details string `json:"details" binding:"max=500"`
+}
+
+// reportLimit caps how many reports each user can file per window, using
+// the rate limit store. A limit of 0 disables it.
+type reportLimit struct {
+	perReporter int
+	window      time.Duration
+}
+
+// newReportLimit reads REPORT_RATE_LIMIT and REPORT_RATE_LIMIT_WINDOW.
+func newReportLimit() reportLimit {
+	return reportLimit{
+		perReporter: envInt("REPORT_RATE_LIMIT", 10),
+		window:      envDuration("REPORT_RATE_LIMIT_WINDOW", time.Hour),
+	}
+}
+
+// reportPost serves POST /posts/:id/report, reporting the post to the
+// moderators for one of the report reasons, with optional details.
+func (a *API) reportPost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.visiblePost(c, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	user, _ := currentUser(c)
+	if post.AuthorID == user.ID {
+		respondError(c, http.StatusBadRequest, "You can't report your own post")
+		return
+	}
+	if !a.allowReport(c, user) {
+		return
+	}
+
+	report := Report{PostID: post.ID, ReporterID: user.ID, Reason: req.Reason, Details: req.Details}
+	if err := a.reports.Create(ctx, &report); err != nil {
+		if errors.Is(err, ErrConflict) {
+			respondError(c, http.StatusConflict, "You already reported this post")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to report post")
+		return
+	}
+
+	respond(c, http.StatusCreated, "", report, nil)
+}
+
+// allowReport counts a report against the user's limit, writing a 429 and
+// returning false if it is used up.
+func (a *API) allowReport(c *gin.Context, user User) bool {
+	if a.reportLimit.perReporter <= 0 {
+		return true
+	}
+	key := "report:" + strconv.FormatUint(uint64(user.ID), 10)
+	count, resetAt, err := a.rateLimits.store.Incr(c.Request.Context(), key, a.reportLimit.window)
+	if err != nil {
+		// As with the request quotas, don't fail on the store.
+		logf(c.Request.Context(), "report limit: %v", err)
+		return true
+	}
+	if count > a.reportLimit.perReporter {
+		setRetryAfter(c, time.Until(resetAt))
+		respondErrorDetails(c, http.StatusTooManyRequests, "Too many reports; try again later", gin.H{"reset_at": resetAt.UTC()})
+		return false
+	}
+	return true
+}
+
+// adminListReports serves GET /admin/reports, every reported post a page at
+// a time, most reported first, each with its report count by reason.
+func (a *API) adminListReports(c *gin.Context) {
+	a.listReports(c, false)
+}
+
+// listReports serves a page of report summaries, of open reports only if
+// open is set. Posts deleted since they were reported are left out of the
+// page.
+func (a *API) listReports(c *gin.Context, open bool) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	total, err := a.reports.CountSummaries(ctx, open)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch reports")
+		return
+	}
+	summaries, err := a.reports.Summaries(ctx, open, page.apply(ListOptions{}))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch reports")
+		return
+	}
+
+	ids := make([]uint, len(summaries))
+	for i, summary := range summaries {
+		ids[i] = summary.PostID
+	}
+	posts, err := a.posts.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	byID := make(map[uint]Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+	items := []ReportSummary{}
+	for _, summary := range summaries {
+		post, ok := byID[summary.PostID]
+		if !ok {
+			continue
+		}
+		summary.Post = &post
+		items = append(items, summary)
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "reports", items, gin.H{
+		"count":      len(items),
+		"pagination": page.meta(total),
+	})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a write would violate a uniqueness rule.
+	ErrConflict = errors.New("record conflicts with an existing one")
+	// ErrVersionConflict is returned by Update when the stored record's
+	// version no longer matches the version being updated.
+	ErrVersionConflict = errors.New("record was modified concurrently")
+)
+
+// ListOptions narrows what List returns.
+type ListOptions struct {
+	// IncludeDeleted also returns soft-deleted records, and OnlyDeleted
+	// returns nothing else.
+	IncludeDeleted bool
+	OnlyDeleted    bool
+	// Offset skips that many records and Limit, if positive, caps how many
+	// are returned. Count ignores both, as well as After and Sort.
+	Offset int
+	Limit  int
+	// After, if set, returns only records that come after the cursor in
+	// (created_at, id) order. Only PostRepository supports it, and only
+	// without Sort.
+	After *Cursor
+	// Reverse lists posts newest first by default, and makes After return
+	// those before the cursor. Only PostRepository supports it.
+	Reverse bool
+	// Sort orders the list by the given fields in turn, ahead of the
+	// repository's default order. Callers validate the field names.
+	Sort []SortField
+	// Users and Posts narrow the list, and its Count, to matching records.
+	// Each repository ignores the other's filter.
+	Users UserFilter
+	Posts PostFilter
+}
+
+// UserFilter narrows a list of users. Zero fields match every user.
+type UserFilter struct {
+	// EmailDomain matches users whose email address is at the domain, in any
+	// case.
+	EmailDomain string
+	// UsernamePrefix matches users whose username starts with it, in any
+	// case.
+	UsernamePrefix string
+}
+
+// PostFilter narrows a list of posts. Zero fields match every post.
+type PostFilter struct {
+	AuthorID   uint
+	AuthorUUID string
+	// AuthorIDs, if not empty, matches posts by any of the authors, and
+	// ExcludeAuthorIDs leaves out posts by any of those.
+	AuthorIDs        []uint
+	ExcludeAuthorIDs []uint
+	// IDs, if not empty, matches only those posts, and ExcludeIDs leaves
+	// those out.
+	IDs        []uint
+	ExcludeIDs []uint
+	// CreatedAfter and CreatedBefore are exclusive bounds on created_at.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// TitleContains matches posts whose title contains it, and TitlePrefix
+	// those whose title starts with it, in any case.
+	TitleContains string
+	TitlePrefix   string
+	// Terms matches posts whose title or content contains every term, in
+	// any case.
+	Terms []string
+	// Status, if set, matches posts with that status.
+	Status string
+	// ListedOnly leaves out drafts, archived posts and posts that aren't
+	// public, except those by ListedFor if it is set.
+	ListedOnly bool
+	ListedFor  uint
+}
+
+// SortField orders a list by one field, named as in the API's JSON.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Cursor is a position in a list ordered by creation time, then ID.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// precedes reports whether a record created at createdAt with the given ID
+// comes after the cursor.
+func (c Cursor) precedes(createdAt time.Time, id uint) bool {
+	if createdAt.Equal(c.CreatedAt) {
+		return id > c.ID
+	}
+	return createdAt.After(c.CreatedAt)
+}
+
+// follows reports whether a record created at createdAt with the given ID
+// comes before the cursor.
+func (c Cursor) follows(createdAt time.Time, id uint) bool {
+	return !c.precedes(createdAt, id) && (id != c.ID || !createdAt.Equal(c.CreatedAt))
+}
+
+// UserRepository stores users. Create assigns the ID, UUID and version, and
+// Create and Update return ErrConflict when the username or email is already
+// taken by another user.
+//
+// Update is optimistic: it only succeeds if the stored version equals
+// user.Version, and on success increments user.Version. Otherwise it returns
+// ErrVersionConflict.
+//
+// Delete is a soft delete: the record is kept but hidden from List, Get and
+// GetByUsername unless explicitly requested. Deleted users still hold on to their
+// username and email. Restore undoes a soft delete, returning ErrNotFound if
+// the user doesn't exist or isn't deleted. ForceDelete removes a user,
+// deleted or not, for good.
+type UserRepository interface {
+	List(ctx context.Context, opts ListOptions) ([]User, error)
+	Count(ctx context.Context, opts ListOptions) (int64, error)
+	Get(ctx context.Context, id uint) (User, error)
+	GetIncludingDeleted(ctx context.Context, id uint) (User, error)
+	// GetMany returns the live users among ids, in no particular order.
+	GetMany(ctx context.Context, ids []uint) ([]User, error)
+	// ResolveUUID returns the primary key of the user, deleted or not, with
+	// the given UUID.
+	ResolveUUID(ctx context.Context, uuid string) (uint, error)
+	GetByUsername(ctx context.Context, username string) (User, error)
+	GetByEmail(ctx context.Context, email string) (User, error)
+	GetByExternalID(ctx context.Context, externalID string) (User, error)
+	Create(ctx context.Context, user *User) error
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+	ForceDelete(ctx context.Context, id uint) error
+}
+
+// PostRepository stores posts. List orders them by creation time, then ID.
+// Update is optimistic and Delete is a soft delete, as for users.
+// DeleteMany soft-deletes every live post among ids in a single write,
+// skipping any that are missing or already deleted. Restore and ForceDelete
+// behave as for users.
+type PostRepository interface {
+	List(ctx context.Context, opts ListOptions) ([]Post, error)
+	Count(ctx context.Context, opts ListOptions) (int64, error)
+	Get(ctx context.Context, id uint) (Post, error)
+	GetIncludingDeleted(ctx context.Context, id uint) (Post, error)
+	// GetMany returns the live posts among ids, in no particular order.
+	GetMany(ctx context.Context, ids []uint) ([]Post, error)
+	ResolveUUID(ctx context.Context, uuid string) (uint, error)
+	Create(ctx context.Context, post *Post) error
+	Update(ctx context.Context, post *Post) error
+	Delete(ctx context.Context, id uint) error
+	DeleteMany(ctx context.Context, ids []uint) error
+	Restore(ctx context.Context, id uint) error
+	ForceDelete(ctx context.Context, id uint) error
+}
+
+// Storage bundles the repositories with the SQL database connection backing
+// them and the outbox their writes record events in. DB is nil for the
+// in-memory and MongoDB drivers, and Outbox is nil for MongoDB.
+type Storage struct {
+	Users         UserRepository
+	Posts         PostRepository
+	RefreshTokens RefreshTokenRepository
+	APIKeys       APIKeyRepository
+	OneTimeTokens OneTimeTokenRepository
+	AuditLog      AuditRepository
+	Reactions     ReactionRepository
+	Follows       FollowRepository
+	Bookmarks     BookmarkRepository
+	Messages      MessageRepository
+	Media         MediaRepository
+	Activity      ActivityRepository
+	Reports       ReportRepository
+	Moderation    ModerationRepository
+	Views         ViewRepository
+	Blocks        BlockRepository
+	Mentions      MentionRepository
+	Polls         PollRepository
+	Series        SeriesRepository
+	CoAuthors     CoAuthorRepository
+	Engagement    EngagementRepository
+	Settings      SettingsRepository
+	Pins          PinRepository
+	DB            *gorm.DB
+	Outbox        OutboxStore
+	// Close releases the storage's connections, or for the memory store
+	// saves a last snapshot, once the server has stopped.
+	Close func(ctx context.Context) error
+}
+
+// newStorage builds the repositories for the configured DB_DRIVER.
+func newStorage() Storage {
+	driver := dbDriver()
+	switch driver {
+	case "memory":
+		return newMemoryStorage()
+	case "mongo":
+		return newMongoStorage()
+	}
+
+	db := initDB(driver)
+	reads := newReadRouter(driver, db)
+	return Storage{
+		Users:         newGormUserRepository(db, reads),
+		Posts:         newGormPostRepository(db, reads),
+		RefreshTokens: newGormRefreshTokenRepository(db),
+		APIKeys:       newGormAPIKeyRepository(db),
+		OneTimeTokens: newGormOneTimeTokenRepository(db),
+		AuditLog:      newGormAuditRepository(db),
+		Reactions:     newGormReactionRepository(db),
+		Follows:       newGormFollowRepository(db),
+		Bookmarks:     newGormBookmarkRepository(db),
+		Messages:      newGormMessageRepository(db),
+		Media:         newGormMediaRepository(db),
+		Activity:      newGormActivityRepository(db),
+		Reports:       newGormReportRepository(db),
+		Moderation:    newGormModerationRepository(db),
+		Views:         newGormViewRepository(db),
+		Blocks:        newGormBlockRepository(db),
+		Mentions:      newGormMentionRepository(db),
+		Polls:         newGormPollRepository(db),
+		Series:        newGormSeriesRepository(db),
+		CoAuthors:     newGormCoAuthorRepository(db),
+		Engagement:    newGormEngagementRepository(db),
+		Settings:      newGormSettingsRepository(db),
+		Pins:          newGormPinRepository(db),
+		DB:            db,
+		Outbox:        newGormOutboxStore(db),
+		Close:         func(context.Context) error { return reads.close() },
+	}
+}
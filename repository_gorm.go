@@ -0,0 +1,1504 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormUserRepository struct {
+	db    *gorm.DB
+	reads *readRouter
+}
+
+func newGormUserRepository(db *gorm.DB, reads *readRouter) *gormUserRepository {
+	return &gormUserRepository{db: db, reads: reads}
+}
+
+func (r *gormUserRepository) List(ctx context.Context, opts ListOptions) ([]User, error) {
+	var users []User
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return sorted(paged(filterUsers(scoped(db, opts), opts.Users), opts), opts, "id").Find(&users).Error
+	})
+	return users, err
+}
+
+func (r *gormUserRepository) Count(ctx context.Context, opts ListOptions) (int64, error) {
+	var count int64
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return filterUsers(scoped(db, opts), opts.Users).Model(&User{}).Count(&count).Error
+	})
+	return count, err
+}
+
+func (r *gormUserRepository) Get(ctx context.Context, id uint) (User, error) {
+	var user User
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	return user, translateError(err)
+}
+
+func (r *gormUserRepository) GetIncludingDeleted(ctx context.Context, id uint) (User, error) {
+	var user User
+	err := r.db.WithContext(ctx).Unscoped().First(&user, id).Error
+	return user, translateError(err)
+}
+
+func (r *gormUserRepository) GetMany(ctx context.Context, ids []uint) ([]User, error) {
+	var users []User
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+func (r *gormUserRepository) GetByUsername(ctx context.Context, username string) (User, error) {
+	var user User
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	return user, translateError(err)
+}
+
+func (r *gormUserRepository) GetByEmail(ctx context.Context, email string) (User, error) {
+	var user User
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	return user, translateError(err)
+}
+
+func (r *gormUserRepository) GetByExternalID(ctx context.Context, externalID string) (User, error) {
+	var user User
+	err := r.db.WithContext(ctx).Where("external_id = ?", externalID).First(&user).Error
+	return user, translateError(err)
+}
+
+func (r *gormUserRepository) Restore(ctx context.Context, id uint) error {
+	return restoreRow(r.db.WithContext(ctx), &User{}, id)
+}
+
+func (r *gormUserRepository) ForceDelete(ctx context.Context, id uint) error {
+	return deleteRow(r.db.WithContext(ctx).Unscoped(), &User{}, id)
+}
+
+func (r *gormUserRepository) ResolveUUID(ctx context.Context, uuid string) (uint, error) {
+	var user User
+	err := r.db.WithContext(ctx).Unscoped().Select("id").Where("uuid = ?", uuid).First(&user).Error
+	return user.ID, translateError(err)
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *User) error {
+	if err := r.checkConflict(ctx, user); err != nil {
+		return err
+	}
+	user.UUID = newUUID()
+	user.Version = 1
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return translateError(err)
+		}
+		return enqueueEvent(tx, EventUserCreated, user.ID, user)
+	})
+}
+
+func (r *gormUserRepository) Update(ctx context.Context, user *User) error {
+	if err := r.checkConflict(ctx, user); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateRow(tx, user, user.ID, &user.Version); err != nil {
+			return err
+		}
+		return enqueueEvent(tx, EventUserUpdated, user.ID, user)
+	})
+}
+
+func (r *gormUserRepository) Delete(ctx context.Context, id uint) error {
+	return deleteRow(r.db.WithContext(ctx), &User{}, id)
+}
+
+// checkConflict returns ErrConflict if another user, deleted or not, already
+// has user's username or email.
+func (r *gormUserRepository) checkConflict(ctx context.Context, user *User) error {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&User{}).
+		Where("id <> ? AND (username = ? OR email = ?)", user.ID, user.Username, user.Email).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+type gormPostRepository struct {
+	db    *gorm.DB
+	reads *readRouter
+}
+
+func newGormPostRepository(db *gorm.DB, reads *readRouter) *gormPostRepository {
+	return &gormPostRepository{db: db, reads: reads}
+}
+
+func (r *gormPostRepository) List(ctx context.Context, opts ListOptions) ([]Post, error) {
+	var posts []Post
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		order := "created_at, id"
+		if opts.Reverse {
+			order = "created_at DESC, id DESC"
+		}
+		return sorted(paged(after(filterPosts(scoped(db, opts), opts.Posts), opts), opts), opts, order).Find(&posts).Error
+	})
+	return posts, err
+}
+
+func (r *gormPostRepository) Count(ctx context.Context, opts ListOptions) (int64, error) {
+	var count int64
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return filterPosts(scoped(db, opts), opts.Posts).Model(&Post{}).Count(&count).Error
+	})
+	return count, err
+}
+
+func (r *gormPostRepository) Get(ctx context.Context, id uint) (Post, error) {
+	var post Post
+	err := r.db.WithContext(ctx).First(&post, id).Error
+	return post, translateError(err)
+}
+
+func (r *gormPostRepository) GetIncludingDeleted(ctx context.Context, id uint) (Post, error) {
+	var post Post
+	err := r.db.WithContext(ctx).Unscoped().First(&post, id).Error
+	return post, translateError(err)
+}
+
+func (r *gormPostRepository) GetMany(ctx context.Context, ids []uint) ([]Post, error) {
+	var posts []Post
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&posts).Error
+	return posts, err
+}
+
+func (r *gormPostRepository) ResolveUUID(ctx context.Context, uuid string) (uint, error) {
+	var post Post
+	err := r.db.WithContext(ctx).Unscoped().Select("id").Where("uuid = ?", uuid).First(&post).Error
+	return post.ID, translateError(err)
+}
+
+func (r *gormPostRepository) Create(ctx context.Context, post *Post) error {
+	post.UUID = newUUID()
+	post.Version = 1
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(post).Error; err != nil {
+			return translateError(err)
+		}
+		return enqueueEvent(tx, EventPostCreated, post.ID, post)
+	})
+}
+
+func (r *gormPostRepository) Update(ctx context.Context, post *Post) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateRow(tx, post, post.ID, &post.Version); err != nil {
+			return err
+		}
+		return enqueueEvent(tx, EventPostUpdated, post.ID, post)
+	})
+}
+
+func (r *gormPostRepository) Delete(ctx context.Context, id uint) error {
+	return deleteRow(r.db.WithContext(ctx), &Post{}, id)
+}
+
+func (r *gormPostRepository) DeleteMany(ctx context.Context, ids []uint) error {
+	return r.db.WithContext(ctx).Delete(&Post{}, ids).Error
+}
+
+func (r *gormPostRepository) Restore(ctx context.Context, id uint) error {
+	return restoreRow(r.db.WithContext(ctx), &Post{}, id)
+}
+
+func (r *gormPostRepository) ForceDelete(ctx context.Context, id uint) error {
+	return deleteRow(r.db.WithContext(ctx).Unscoped(), &Post{}, id)
+}
+
+type gormRefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func newGormRefreshTokenRepository(db *gorm.DB) *gormRefreshTokenRepository {
+	return &gormRefreshTokenRepository{db: db}
+}
+
+func (r *gormRefreshTokenRepository) Create(ctx context.Context, token *RefreshToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormRefreshTokenRepository) GetByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	var token RefreshToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error
+	return token, translateError(err)
+}
+
+func (r *gormRefreshTokenRepository) MarkUsed(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *gormRefreshTokenRepository) RevokeUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *gormRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+type gormAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func newGormAPIKeyRepository(db *gorm.DB) *gormAPIKeyRepository {
+	return &gormAPIKeyRepository{db: db}
+}
+
+func (r *gormAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	return translateError(r.db.WithContext(ctx).Create(key).Error)
+}
+
+func (r *gormAPIKeyRepository) ListByUser(ctx context.Context, userID uint) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (r *gormAPIKeyRepository) GetByHash(ctx context.Context, hash string) (APIKey, error) {
+	var key APIKey
+	err := r.db.WithContext(ctx).Where("key_hash = ?", hash).First(&key).Error
+	return key, translateError(err)
+}
+
+func (r *gormAPIKeyRepository) Revoke(ctx context.Context, id, userID uint) error {
+	result := r.db.WithContext(ctx).Model(&APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormAPIKeyRepository) Touch(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
+
+type gormOneTimeTokenRepository struct {
+	db *gorm.DB
+}
+
+func newGormOneTimeTokenRepository(db *gorm.DB) *gormOneTimeTokenRepository {
+	return &gormOneTimeTokenRepository{db: db}
+}
+
+func (r *gormOneTimeTokenRepository) Create(ctx context.Context, token *OneTimeToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormOneTimeTokenRepository) Consume(ctx context.Context, purpose, hash string) (OneTimeToken, error) {
+	now := time.Now()
+	var token OneTimeToken
+	err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hash, purpose, now).
+		First(&token).Error
+	if err != nil {
+		return OneTimeToken{}, translateError(err)
+	}
+
+	// The conditional update makes sure only one concurrent caller wins.
+	result := r.db.WithContext(ctx).Model(&OneTimeToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return OneTimeToken{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return OneTimeToken{}, ErrNotFound
+	}
+	token.UsedAt = &now
+	return token, nil
+}
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func newGormAuditRepository(db *gorm.DB) *gormAuditRepository {
+	return &gormAuditRepository{db: db}
+}
+
+func (r *gormAuditRepository) Append(ctx context.Context, event *AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *gormAuditRepository) List(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	db := r.db.WithContext(ctx).Order("id DESC")
+	if !query.From.IsZero() {
+		db = db.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("created_at <= ?", query.To)
+	}
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if query.UserID != nil {
+		db = db.Where("user_id = ?", *query.UserID)
+	}
+	if query.Route != "" {
+		db = db.Where("route = ?", query.Route)
+	}
+	if query.EntityID != "" {
+		db = db.Where("entity_id = ?", query.EntityID)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	events := []AuditEvent{}
+	err := db.Find(&events).Error
+	return events, err
+}
+
+// enqueueEvent records a domain event in the outbox as part of tx.
+func enqueueEvent(tx *gorm.DB, eventType string, aggregateID uint, payload interface{}) error {
+	event, err := newOutboxEvent(eventType, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&event).Error
+}
+
+type gormReactionRepository struct {
+	db *gorm.DB
+}
+
+func newGormReactionRepository(db *gorm.DB) *gormReactionRepository {
+	return &gormReactionRepository{db: db}
+}
+
+func (r *gormReactionRepository) Add(ctx context.Context, reaction *Reaction) (bool, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormReactionRepository) Remove(ctx context.Context, postID, userID uint, reactionType string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("post_id = ? AND user_id = ? AND type = ?", postID, userID, reactionType).
+		Delete(&Reaction{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormReactionRepository) List(ctx context.Context, postID uint, reactionType string, opts ListOptions) ([]Reaction, error) {
+	reactions := []Reaction{}
+	err := paged(r.reactionsOf(ctx, postID, reactionType), opts).Order("id").Find(&reactions).Error
+	return reactions, err
+}
+
+func (r *gormReactionRepository) Count(ctx context.Context, postID uint, reactionType string) (int64, error) {
+	var count int64
+	err := r.reactionsOf(ctx, postID, reactionType).Model(&Reaction{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormReactionRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]map[string]int64, error) {
+	var rows []struct {
+		PostID uint
+		Type   string
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&Reaction{}).
+		Select("post_id, type, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).
+		Group("post_id, type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]map[string]int64)
+	for _, row := range rows {
+		if counts[row.PostID] == nil {
+			counts[row.PostID] = make(map[string]int64)
+		}
+		counts[row.PostID][row.Type] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *gormReactionRepository) Recent(ctx context.Context, since time.Time) ([]Reaction, error) {
+	reactions := []Reaction{}
+	err := r.db.WithContext(ctx).Where("created_at >= ?", since).Find(&reactions).Error
+	return reactions, err
+}
+
+// reactionsOf selects a post's reactions of reactionType, or of any type if
+// it is empty.
+func (r *gormReactionRepository) reactionsOf(ctx context.Context, postID uint, reactionType string) *gorm.DB {
+	db := r.db.WithContext(ctx).Where("post_id = ?", postID)
+	if reactionType != "" {
+		db = db.Where("type = ?", reactionType)
+	}
+	return db
+}
+
+type gormFollowRepository struct {
+	db *gorm.DB
+}
+
+func newGormFollowRepository(db *gorm.DB) *gormFollowRepository {
+	return &gormFollowRepository{db: db}
+}
+
+func (r *gormFollowRepository) Add(ctx context.Context, follow *Follow) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(follow).Error
+}
+
+func (r *gormFollowRepository) Remove(ctx context.Context, followerID, followeeID uint) error {
+	return r.db.WithContext(ctx).
+		Where("follower_id = ? AND followee_id = ?", followerID, followeeID).
+		Delete(&Follow{}).Error
+}
+
+func (r *gormFollowRepository) Followers(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error) {
+	follows := []Follow{}
+	err := paged(r.db.WithContext(ctx), opts).Where("followee_id = ?", userID).Order("id").Find(&follows).Error
+	return follows, err
+}
+
+func (r *gormFollowRepository) Following(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error) {
+	follows := []Follow{}
+	err := paged(r.db.WithContext(ctx), opts).Where("follower_id = ?", userID).Order("id").Find(&follows).Error
+	return follows, err
+}
+
+func (r *gormFollowRepository) Counts(ctx context.Context, userIDs []uint) (followers, following map[uint]int64, err error) {
+	if followers, err = r.countBy(ctx, "followee_id", userIDs); err != nil {
+		return nil, nil, err
+	}
+	if following, err = r.countBy(ctx, "follower_id", userIDs); err != nil {
+		return nil, nil, err
+	}
+	return followers, following, nil
+}
+
+// countBy counts follows grouped by column, one of follower_id or
+// followee_id, for the users in ids.
+func (r *gormFollowRepository) countBy(ctx context.Context, column string, ids []uint) (map[uint]int64, error) {
+	var rows []struct {
+		UserID uint
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&Follow{}).
+		Select(column+" AS user_id, COUNT(*) AS count").
+		Where(column+" IN ?", ids).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts, nil
+}
+
+type gormBookmarkRepository struct {
+	db *gorm.DB
+}
+
+func newGormBookmarkRepository(db *gorm.DB) *gormBookmarkRepository {
+	return &gormBookmarkRepository{db: db}
+}
+
+func (r *gormBookmarkRepository) Add(ctx context.Context, bookmark *Bookmark) (bool, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(bookmark)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormBookmarkRepository) Remove(ctx context.Context, userID, postID uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND post_id = ?", userID, postID).
+		Delete(&Bookmark{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormBookmarkRepository) List(ctx context.Context, userID uint, opts ListOptions) ([]Bookmark, error) {
+	bookmarks := []Bookmark{}
+	err := paged(r.db.WithContext(ctx), opts).Where("user_id = ?", userID).Order("id DESC").Find(&bookmarks).Error
+	return bookmarks, err
+}
+
+func (r *gormBookmarkRepository) Count(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Bookmark{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormBookmarkRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		PostID uint
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&Bookmark{}).
+		Select("post_id, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).
+		Group("post_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, nil
+}
+
+type gormMessageRepository struct {
+	db *gorm.DB
+}
+
+func newGormMessageRepository(db *gorm.DB) *gormMessageRepository {
+	return &gormMessageRepository{db: db}
+}
+
+func (r *gormMessageRepository) Create(ctx context.Context, message *Message) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return enqueueEvent(tx, EventMessageSent, message.ID, message.event())
+	})
+}
+
+func (r *gormMessageRepository) Thread(ctx context.Context, conversation string, opts ListOptions) ([]Message, error) {
+	messages := []Message{}
+	err := paged(r.db.WithContext(ctx), opts).Where("conversation = ?", conversation).Order("id DESC").Find(&messages).Error
+	return messages, err
+}
+
+func (r *gormMessageRepository) CountThread(ctx context.Context, conversation string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Message{}).Where("conversation = ?", conversation).Count(&count).Error
+	return count, err
+}
+
+func (r *gormMessageRepository) Conversations(ctx context.Context, userID uint, opts ListOptions) ([]Message, error) {
+	latest := r.db.Model(&Message{}).
+		Select("MAX(id)").
+		Where("sender_id = ? OR recipient_id = ?", userID, userID).
+		Group("conversation")
+
+	messages := []Message{}
+	err := paged(r.db.WithContext(ctx), opts).Where("id IN (?)", latest).Order("id DESC").Find(&messages).Error
+	return messages, err
+}
+
+func (r *gormMessageRepository) CountConversations(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Message{}).
+		Where("sender_id = ? OR recipient_id = ?", userID, userID).
+		Distinct("conversation").
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormMessageRepository) MarkRead(ctx context.Context, recipientID, senderID uint) error {
+	return r.db.WithContext(ctx).Model(&Message{}).
+		Where("recipient_id = ? AND sender_id = ? AND read_at IS NULL", recipientID, senderID).
+		Update("read_at", time.Now()).Error
+}
+
+func (r *gormMessageRepository) Unread(ctx context.Context, userID uint) (map[uint]int64, error) {
+	var rows []struct {
+		SenderID uint
+		Count    int64
+	}
+	err := r.db.WithContext(ctx).Model(&Message{}).
+		Select("sender_id, COUNT(*) AS count").
+		Where("recipient_id = ? AND read_at IS NULL", userID).
+		Group("sender_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	unread := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		unread[row.SenderID] = row.Count
+	}
+	return unread, nil
+}
+
+type gormMediaRepository struct {
+	db *gorm.DB
+}
+
+func newGormMediaRepository(db *gorm.DB) *gormMediaRepository {
+	return &gormMediaRepository{db: db}
+}
+
+func (r *gormMediaRepository) Create(ctx context.Context, media *Media) error {
+	return r.db.WithContext(ctx).Create(media).Error
+}
+
+func (r *gormMediaRepository) Get(ctx context.Context, id uint) (Media, error) {
+	var media Media
+	err := r.db.WithContext(ctx).First(&media, id).Error
+	return media, translateError(err)
+}
+
+func (r *gormMediaRepository) ListByPost(ctx context.Context, postID uint) ([]Media, error) {
+	media := []Media{}
+	err := r.db.WithContext(ctx).Where("post_id = ?", postID).Order("position, id").Find(&media).Error
+	return media, err
+}
+
+func (r *gormMediaRepository) Update(ctx context.Context, media *Media) error {
+	result := r.db.WithContext(ctx).Model(media).Select("alt_text", "position").Updates(media)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (r *gormMediaRepository) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&Media{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormActivityRepository struct {
+	db *gorm.DB
+}
+
+func newGormActivityRepository(db *gorm.DB) *gormActivityRepository {
+	return &gormActivityRepository{db: db}
+}
+
+func (r *gormActivityRepository) Record(ctx context.Context, activity *Activity) error {
+	return r.db.WithContext(ctx).Create(activity).Error
+}
+
+func (r *gormActivityRepository) Remove(ctx context.Context, activity Activity) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ? AND type = ? AND post_id = ? AND target_user_id = ? AND reaction = ?",
+			activity.UserID, activity.Type, activity.PostID, activity.TargetUserID, activity.Reaction).
+		Delete(&Activity{}).Error
+}
+
+func (r *gormActivityRepository) List(ctx context.Context, userID uint, opts ListOptions) ([]Activity, error) {
+	activity := []Activity{}
+	err := paged(r.db.WithContext(ctx), opts).Where("user_id = ?", userID).Order("id DESC").Find(&activity).Error
+	return activity, err
+}
+
+func (r *gormActivityRepository) Count(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Activity{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+type gormReportRepository struct {
+	db *gorm.DB
+}
+
+func newGormReportRepository(db *gorm.DB) *gormReportRepository {
+	return &gormReportRepository{db: db}
+}
+
+func (r *gormReportRepository) Create(ctx context.Context, report *Report) error {
+	return translateError(r.db.WithContext(ctx).Create(report).Error)
+}
+
+func (r *gormReportRepository) Summaries(ctx context.Context, open bool, opts ListOptions) ([]ReportSummary, error) {
+	var rows []struct {
+		PostID uint
+		Count  int64
+	}
+	err := paged(r.reportsOf(ctx, open), opts).Model(&Report{}).
+		Select("post_id, COUNT(*) AS count").
+		Group("post_id").
+		Order("COUNT(*) DESC, MAX(id) DESC").
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return []ReportSummary{}, err
+	}
+
+	summaries := make([]ReportSummary, len(rows))
+	ids := make([]uint, len(rows))
+	index := make(map[uint]int, len(rows))
+	for i, row := range rows {
+		summaries[i] = ReportSummary{PostID: row.PostID, Count: row.Count, Reasons: map[string]int64{}}
+		ids[i] = row.PostID
+		index[row.PostID] = i
+	}
+	var reasons []struct {
+		PostID uint
+		Reason string
+		Count  int64
+	}
+	err = r.reportsOf(ctx, open).Model(&Report{}).
+		Select("post_id, reason, COUNT(*) AS count").
+		Where("post_id IN ?", ids).
+		Group("post_id, reason").
+		Scan(&reasons).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range reasons {
+		summaries[index[row.PostID]].Reasons[row.Reason] = row.Count
+	}
+	return summaries, nil
+}
+
+func (r *gormReportRepository) CountSummaries(ctx context.Context, open bool) (int64, error) {
+	var count int64
+	err := r.reportsOf(ctx, open).Model(&Report{}).Distinct("post_id").Count(&count).Error
+	return count, err
+}
+
+func (r *gormReportRepository) Resolve(ctx context.Context, postID uint) error {
+	return r.db.WithContext(ctx).Model(&Report{}).
+		Where("post_id = ? AND resolved_at IS NULL", postID).
+		Update("resolved_at", time.Now()).Error
+}
+
+// reportsOf selects the open reports if open is set, or all of them.
+func (r *gormReportRepository) reportsOf(ctx context.Context, open bool) *gorm.DB {
+	db := r.db.WithContext(ctx)
+	if open {
+		db = db.Where("resolved_at IS NULL")
+	}
+	return db
+}
+
+type gormModerationRepository struct {
+	db *gorm.DB
+}
+
+func newGormModerationRepository(db *gorm.DB) *gormModerationRepository {
+	return &gormModerationRepository{db: db}
+}
+
+func (r *gormModerationRepository) Create(ctx context.Context, decision *ModerationDecision) error {
+	return r.db.WithContext(ctx).Create(decision).Error
+}
+
+func (r *gormModerationRepository) List(ctx context.Context, filter ModerationFilter, opts ListOptions) ([]ModerationDecision, error) {
+	decisions := []ModerationDecision{}
+	err := paged(r.decisions(ctx, filter), opts).Order("id DESC").Find(&decisions).Error
+	return decisions, err
+}
+
+func (r *gormModerationRepository) Count(ctx context.Context, filter ModerationFilter) (int64, error) {
+	var count int64
+	err := r.decisions(ctx, filter).Model(&ModerationDecision{}).Count(&count).Error
+	return count, err
+}
+
+// decisions selects the decisions matching filter.
+func (r *gormModerationRepository) decisions(ctx context.Context, filter ModerationFilter) *gorm.DB {
+	db := r.db.WithContext(ctx)
+	if filter.PostID != 0 {
+		db = db.Where("post_id = ?", filter.PostID)
+	}
+	if filter.ModeratorID != 0 {
+		db = db.Where("moderator_id = ?", filter.ModeratorID)
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	return db
+}
+
+type gormViewRepository struct {
+	db *gorm.DB
+}
+
+func newGormViewRepository(db *gorm.DB) *gormViewRepository {
+	return &gormViewRepository{db: db}
+}
+
+func (r *gormViewRepository) Record(ctx context.Context, postID uint, day string) error {
+	increment := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&DailyViews{}).
+			Where("post_id = ? AND day = ?", postID, day).
+			Update("views", gorm.Expr("views + 1"))
+	}
+	result := increment()
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	err := r.db.WithContext(ctx).Create(&DailyViews{PostID: postID, Day: day, Views: 1}).Error
+	if isDuplicateKey(err) {
+		// Another request counted the day's first view in the meantime.
+		return increment().Error
+	}
+	return err
+}
+
+func (r *gormViewRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		PostID uint
+		Views  int64
+	}
+	err := r.db.WithContext(ctx).Model(&DailyViews{}).
+		Select("post_id, SUM(views) AS views").
+		Where("post_id IN ?", postIDs).
+		Group("post_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PostID] = row.Views
+	}
+	return counts, nil
+}
+
+func (r *gormViewRepository) Daily(ctx context.Context, postID uint, from string) ([]DailyViews, error) {
+	daily := []DailyViews{}
+	err := r.db.WithContext(ctx).Where("post_id = ? AND day >= ?", postID, from).Order("day").Find(&daily).Error
+	return daily, err
+}
+
+func (r *gormViewRepository) Recent(ctx context.Context, from string) ([]DailyViews, error) {
+	daily := []DailyViews{}
+	err := r.db.WithContext(ctx).Where("day >= ?", from).Find(&daily).Error
+	return daily, err
+}
+
+type gormBlockRepository struct {
+	db *gorm.DB
+}
+
+func newGormBlockRepository(db *gorm.DB) *gormBlockRepository {
+	return &gormBlockRepository{db: db}
+}
+
+func (r *gormBlockRepository) Add(ctx context.Context, block *Block) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(block).Error
+}
+
+func (r *gormBlockRepository) Remove(ctx context.Context, blockerID, blockedID uint) error {
+	return r.db.WithContext(ctx).
+		Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Delete(&Block{}).Error
+}
+
+func (r *gormBlockRepository) List(ctx context.Context, blockerID uint, opts ListOptions) ([]Block, error) {
+	blocks := []Block{}
+	err := paged(r.db.WithContext(ctx), opts).Where("blocker_id = ?", blockerID).Order("id DESC").Find(&blocks).Error
+	return blocks, err
+}
+
+func (r *gormBlockRepository) Count(ctx context.Context, blockerID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Block{}).Where("blocker_id = ?", blockerID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormBlockRepository) Blocked(ctx context.Context, blockerID uint) ([]uint, error) {
+	blocked := []uint{}
+	err := r.db.WithContext(ctx).Model(&Block{}).Where("blocker_id = ?", blockerID).Pluck("blocked_id", &blocked).Error
+	return blocked, err
+}
+
+func (r *gormBlockRepository) Between(ctx context.Context, a, b uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+type gormMentionRepository struct {
+	db *gorm.DB
+}
+
+func newGormMentionRepository(db *gorm.DB) *gormMentionRepository {
+	return &gormMentionRepository{db: db}
+}
+
+func (r *gormMentionRepository) Set(ctx context.Context, post Post, userIDs, notify []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stale := tx.Where("post_id = ?", post.ID)
+		if len(userIDs) > 0 {
+			stale = stale.Where("user_id NOT IN ?", userIDs)
+		}
+		if err := stale.Delete(&Mention{}).Error; err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			mention := Mention{PostID: post.ID, UserID: userID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&mention).Error; err != nil {
+				return err
+			}
+		}
+		for _, userID := range notify {
+			event := mentionEvent{PostID: post.ID, AuthorID: post.AuthorID, UserID: userID}
+			if err := enqueueEvent(tx, EventPostMentioned, post.ID, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *gormMentionRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error) {
+	var mentions []Mention
+	err := r.db.WithContext(ctx).Where("post_id IN ?", postIDs).Order("id").Find(&mentions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mentioned := make(map[uint][]uint)
+	for _, mention := range mentions {
+		mentioned[mention.PostID] = append(mentioned[mention.PostID], mention.UserID)
+	}
+	return mentioned, nil
+}
+
+type gormPollRepository struct {
+	db *gorm.DB
+}
+
+func newGormPollRepository(db *gorm.DB) *gormPollRepository {
+	return &gormPollRepository{db: db}
+}
+
+func (r *gormPollRepository) Get(ctx context.Context, postID uint) (Poll, error) {
+	var poll Poll
+	err := r.db.WithContext(ctx).Where("post_id = ?", postID).First(&poll).Error
+	return poll, translateError(err)
+}
+
+func (r *gormPollRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint]Poll, error) {
+	var found []Poll
+	if err := r.db.WithContext(ctx).Where("post_id IN ?", postIDs).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	polls := make(map[uint]Poll, len(found))
+	for _, poll := range found {
+		polls[poll.PostID] = poll
+	}
+	return polls, nil
+}
+
+func (r *gormPollRepository) Save(ctx context.Context, poll *Poll) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", poll.PostID).Delete(&Poll{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(poll).Error
+	})
+}
+
+func (r *gormPollRepository) Delete(ctx context.Context, postID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("post_id = ?", postID).Delete(&Poll{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("post_id = ?", postID).Delete(&PollVote{}).Error
+	})
+}
+
+func (r *gormPollRepository) Vote(ctx context.Context, vote *PollVote) error {
+	return translateError(r.db.WithContext(ctx).Create(vote).Error)
+}
+
+func (r *gormPollRepository) Tallies(ctx context.Context, postIDs []uint) (map[uint]map[int]int64, error) {
+	var rows []struct {
+		PostID uint
+		Choice int
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&PollVote{}).
+		Select("post_id, choice, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).
+		Group("post_id, choice").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tallies := make(map[uint]map[int]int64)
+	for _, row := range rows {
+		if tallies[row.PostID] == nil {
+			tallies[row.PostID] = make(map[int]int64)
+		}
+		tallies[row.PostID][row.Choice] = row.Count
+	}
+	return tallies, nil
+}
+
+func (r *gormPollRepository) VotesBy(ctx context.Context, userID uint, postIDs []uint) (map[uint]int, error) {
+	var found []PollVote
+	err := r.db.WithContext(ctx).Where("user_id = ? AND post_id IN ?", userID, postIDs).Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make(map[uint]int, len(found))
+	for _, vote := range found {
+		votes[vote.PostID] = vote.Choice
+	}
+	return votes, nil
+}
+
+type gormSeriesRepository struct {
+	db *gorm.DB
+}
+
+func newGormSeriesRepository(db *gorm.DB) *gormSeriesRepository {
+	return &gormSeriesRepository{db: db}
+}
+
+func (r *gormSeriesRepository) Create(ctx context.Context, series *Series) error {
+	return r.db.WithContext(ctx).Create(series).Error
+}
+
+func (r *gormSeriesRepository) Get(ctx context.Context, id uint) (Series, error) {
+	var series Series
+	err := r.db.WithContext(ctx).First(&series, id).Error
+	return series, translateError(err)
+}
+
+func (r *gormSeriesRepository) GetMany(ctx context.Context, ids []uint) ([]Series, error) {
+	found := []Series{}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&found).Error
+	return found, err
+}
+
+func (r *gormSeriesRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&Series{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("series_id = ?", id).Delete(&SeriesEntry{}).Error
+	})
+}
+
+func (r *gormSeriesRepository) SetPosts(ctx context.Context, seriesID uint, postIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(postIDs) > 0 {
+			var taken int64
+			err := tx.Model(&SeriesEntry{}).
+				Where("post_id IN ? AND series_id <> ?", postIDs, seriesID).
+				Count(&taken).Error
+			if err != nil {
+				return err
+			}
+			if taken > 0 {
+				return ErrConflict
+			}
+		}
+		if err := tx.Where("series_id = ?", seriesID).Delete(&SeriesEntry{}).Error; err != nil {
+			return err
+		}
+		if len(postIDs) == 0 {
+			return nil
+		}
+		entries := make([]SeriesEntry, len(postIDs))
+		for i, postID := range postIDs {
+			entries[i] = SeriesEntry{SeriesID: seriesID, PostID: postID, Position: i}
+		}
+		return translateError(tx.Create(&entries).Error)
+	})
+}
+
+func (r *gormSeriesRepository) Posts(ctx context.Context, seriesID uint) ([]uint, error) {
+	ids := []uint{}
+	err := r.db.WithContext(ctx).Model(&SeriesEntry{}).
+		Where("series_id = ?", seriesID).
+		Order("position").
+		Pluck("post_id", &ids).Error
+	return ids, err
+}
+
+func (r *gormSeriesRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint]uint, error) {
+	var entries []SeriesEntry
+	if err := r.db.WithContext(ctx).Where("post_id IN ?", postIDs).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	memberships := make(map[uint]uint, len(entries))
+	for _, entry := range entries {
+		memberships[entry.PostID] = entry.SeriesID
+	}
+	return memberships, nil
+}
+
+type gormCoAuthorRepository struct {
+	db *gorm.DB
+}
+
+func newGormCoAuthorRepository(db *gorm.DB) *gormCoAuthorRepository {
+	return &gormCoAuthorRepository{db: db}
+}
+
+func (r *gormCoAuthorRepository) Add(ctx context.Context, coAuthor *CoAuthor) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(coAuthor).Error
+}
+
+func (r *gormCoAuthorRepository) Remove(ctx context.Context, postID, userID uint) error {
+	return r.db.WithContext(ctx).
+		Where("post_id = ? AND user_id = ?", postID, userID).
+		Delete(&CoAuthor{}).Error
+}
+
+func (r *gormCoAuthorRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error) {
+	var found []CoAuthor
+	err := r.db.WithContext(ctx).Where("post_id IN ?", postIDs).Order("id").Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+
+	coAuthors := make(map[uint][]uint)
+	for _, coAuthor := range found {
+		coAuthors[coAuthor.PostID] = append(coAuthors[coAuthor.PostID], coAuthor.UserID)
+	}
+	return coAuthors, nil
+}
+
+func (r *gormCoAuthorRepository) Is(ctx context.Context, postID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&CoAuthor{}).
+		Where("post_id = ? AND user_id = ?", postID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+type gormEngagementRepository struct {
+	db *gorm.DB
+}
+
+func newGormEngagementRepository(db *gorm.DB) *gormEngagementRepository {
+	return &gormEngagementRepository{db: db}
+}
+
+func (r *gormEngagementRepository) Record(ctx context.Context, postID uint, day, counter string, delta int64) error {
+	increment := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&DailyEngagement{}).
+			Where("post_id = ? AND day = ?", postID, day).
+			Update(counter, gorm.Expr(counter+" + ?", delta))
+	}
+	result := increment()
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	err := r.db.WithContext(ctx).Create(&DailyEngagement{PostID: postID, Day: day}).Error
+	if err != nil && !isDuplicateKey(err) {
+		return err
+	}
+	// Either way the day's row now exists.
+	return increment().Error
+}
+
+func (r *gormEngagementRepository) Daily(ctx context.Context, postID uint, from string) ([]DailyEngagement, error) {
+	daily := []DailyEngagement{}
+	err := r.db.WithContext(ctx).Where("post_id = ? AND day >= ?", postID, from).Order("day").Find(&daily).Error
+	return daily, err
+}
+
+type gormSettingsRepository struct {
+	db *gorm.DB
+}
+
+func newGormSettingsRepository(db *gorm.DB) *gormSettingsRepository {
+	return &gormSettingsRepository{db: db}
+}
+
+func (r *gormSettingsRepository) Get(ctx context.Context, userID uint) (Settings, error) {
+	var settings Settings
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&settings).Error
+	return settings, translateError(err)
+}
+
+func (r *gormSettingsRepository) Save(ctx context.Context, settings *Settings) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(settings).Error
+}
+
+type gormPinRepository struct {
+	db *gorm.DB
+}
+
+func newGormPinRepository(db *gorm.DB) *gormPinRepository {
+	return &gormPinRepository{db: db}
+}
+
+func (r *gormPinRepository) Add(ctx context.Context, pin *Pin) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(pin).Error
+}
+
+func (r *gormPinRepository) Remove(ctx context.Context, postID uint) error {
+	return r.db.WithContext(ctx).Where("post_id = ?", postID).Delete(&Pin{}).Error
+}
+
+func (r *gormPinRepository) List(ctx context.Context, userID uint) ([]uint, error) {
+	ids := []uint{}
+	err := r.db.WithContext(ctx).Model(&Pin{}).Where("user_id = ?", userID).Order("id DESC").Pluck("post_id", &ids).Error
+	return ids, err
+}
+
+func (r *gormPinRepository) Pinned(ctx context.Context, postIDs []uint) (map[uint]bool, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&Pin{}).Where("post_id IN ?", postIDs).Pluck("post_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		pinned[id] = true
+	}
+	return pinned, nil
+}
+
+type gormOutboxStore struct {
+	db *gorm.DB
+}
+
+func newGormOutboxStore(db *gorm.DB) *gormOutboxStore {
+	return &gormOutboxStore{db: db}
+}
+
+func (s *gormOutboxStore) Pending(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := s.db.WithContext(ctx).Where("published_at IS NULL").Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (s *gormOutboxStore) MarkPublished(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Model(&OutboxEvent{}).Where("id = ?", id).Update("published_at", time.Now()).Error
+}
+
+// scoped applies the soft-delete visibility from opts to db.
+func scoped(db *gorm.DB, opts ListOptions) *gorm.DB {
+	if opts.OnlyDeleted {
+		return db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if opts.IncludeDeleted {
+		return db.Unscoped()
+	}
+	return db
+}
+
+// filterUsers restricts db to users matching filter.
+func filterUsers(db *gorm.DB, filter UserFilter) *gorm.DB {
+	if filter.EmailDomain != "" {
+		db = db.Where("LOWER(email) LIKE ? ESCAPE '!'", "%@"+escapeLike(strings.ToLower(filter.EmailDomain)))
+	}
+	if filter.UsernamePrefix != "" {
+		db = db.Where("LOWER(username) LIKE ? ESCAPE '!'", escapeLike(strings.ToLower(filter.UsernamePrefix))+"%")
+	}
+	return db
+}
+
+// filterPosts restricts db to posts matching filter.
+func filterPosts(db *gorm.DB, filter PostFilter) *gorm.DB {
+	if filter.AuthorID != 0 {
+		db = db.Where("author_id = ?", filter.AuthorID)
+	}
+	if filter.AuthorUUID != "" {
+		db = db.Where("author_uuid = ?", filter.AuthorUUID)
+	}
+	if len(filter.AuthorIDs) > 0 {
+		db = db.Where("author_id IN ?", filter.AuthorIDs)
+	}
+	if len(filter.ExcludeAuthorIDs) > 0 {
+		db = db.Where("author_id NOT IN ?", filter.ExcludeAuthorIDs)
+	}
+	if len(filter.IDs) > 0 {
+		db = db.Where("id IN ?", filter.IDs)
+	}
+	if len(filter.ExcludeIDs) > 0 {
+		db = db.Where("id NOT IN ?", filter.ExcludeIDs)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		db = db.Where("created_at > ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", filter.CreatedBefore)
+	}
+	if filter.TitleContains != "" {
+		db = db.Where("LOWER(title) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(filter.TitleContains))+"%")
+	}
+	if filter.TitlePrefix != "" {
+		db = db.Where("LOWER(title) LIKE ? ESCAPE '!'", escapeLike(strings.ToLower(filter.TitlePrefix))+"%")
+	}
+	for _, term := range filter.Terms {
+		pattern := "%" + escapeLike(strings.ToLower(term)) + "%"
+		db = db.Where("(LOWER(title) LIKE ? ESCAPE '!' OR LOWER(content) LIKE ? ESCAPE '!')", pattern, pattern)
+	}
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.ListedOnly {
+		db = db.Where("((status <> ? AND visibility = ? AND archived_at IS NULL) OR author_id = ?)", PostDraft, VisibilityPublic, filter.ListedFor)
+	}
+	return db
+}
+
+// escapeLike escapes LIKE wildcards in s for use with ESCAPE '!', which,
+// unlike backslash, means the same in every supported dialect.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// after restricts db to rows past the cursor in opts, if any, or before it
+// for opts.Reverse.
+func after(db *gorm.DB, opts ListOptions) *gorm.DB {
+	if opts.After == nil {
+		return db
+	}
+	if opts.Reverse {
+		return db.Where("created_at < ? OR (created_at = ? AND id < ?)",
+			opts.After.CreatedAt, opts.After.CreatedAt, opts.After.ID)
+	}
+	return db.Where("created_at > ? OR (created_at = ? AND id > ?)",
+		opts.After.CreatedAt, opts.After.CreatedAt, opts.After.ID)
+}
+
+// sorted orders db by the sort fields in opts, then by defaultOrder. API
+// field names match column names.
+func sorted(db *gorm.DB, opts ListOptions, defaultOrder string) *gorm.DB {
+	for _, field := range opts.Sort {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Field}, Desc: field.Desc})
+	}
+	return db.Order(defaultOrder)
+}
+
+func paged(db *gorm.DB, opts ListOptions) *gorm.DB {
+	if opts.Offset > 0 {
+		db = db.Offset(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		db = db.Limit(opts.Limit)
+	}
+	return db
+}
+
+// updateRow writes every column of model except its ID and creation time,
+// provided the stored row is still at *version. On success *version is
+// incremented. It returns ErrNotFound if there is no row with the given ID
+// and ErrVersionConflict if the row has moved on.
+func updateRow(db *gorm.DB, model interface{}, id uint, version *uint) error {
+	expected := *version
+	*version = expected + 1
+
+	result := db.Model(model).Where("version = ?", expected).
+		Select("*").Omit("id", "created_at", "deleted_at", clause.Associations).
+		Updates(model)
+	if result.Error == nil && result.RowsAffected == 1 {
+		return nil
+	}
+
+	*version = expected
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+
+	var count int64
+	if err := db.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+// deleteRow deletes the row of model's table with the given ID, returning
+// ErrNotFound if there is none.
+func deleteRow(db *gorm.DB, model interface{}, id uint) error {
+	result := db.Delete(model, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// restoreRow clears the soft delete of the row with the given ID, returning
+// ErrNotFound if there is no such deleted row.
+func restoreRow(db *gorm.DB, model interface{}, id uint) error {
+	result := db.Unscoped().Model(model).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// mysqlDuplicateEntry is MySQL and MariaDB's ER_DUP_ENTRY error number.
+const mysqlDuplicateEntry = 1062
+
+// translateError maps GORM and driver errors onto the repository errors.
+// Unique constraint violations become ErrConflict, so a write that races
+// past checkConflict still maps to 409.
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case isDuplicateKey(err):
+		return ErrConflict
+	}
+	return err
+}
+
+// isDuplicateKey reports whether err is a unique constraint violation. GORM
+// translates these for the postgres and sqlite dialectors (see
+// TranslateError in connectDB); MySQL errors are checked by number as well so
+// MariaDB servers reporting through the MySQL driver are covered too.
+func isDuplicateKey(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
@@ -0,0 +1,1994 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryOutbox is the OutboxStore for the in-memory repositories, which
+// record events while holding their own lock.
+type memoryOutbox struct {
+	mu     sync.Mutex
+	events []OutboxEvent
+	nextID uint
+}
+
+func newMemoryOutbox() *memoryOutbox {
+	return &memoryOutbox{nextID: 1}
+}
+
+func (o *memoryOutbox) add(eventType string, aggregateID uint, payload interface{}) {
+	event, err := newOutboxEvent(eventType, aggregateID, payload)
+	if err != nil {
+		log.Printf("outbox: failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	event.ID = o.nextID
+	event.CreatedAt = time.Now()
+	o.events = append(o.events, event)
+	o.nextID++
+}
+
+func (o *memoryOutbox) Pending(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var events []OutboxEvent
+	for _, event := range o.events {
+		if len(events) == limit {
+			break
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// MarkPublished drops the event; there is no durable history to keep.
+func (o *memoryOutbox) MarkPublished(ctx context.Context, id uint) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, event := range o.events {
+		if event.ID == id {
+			o.events = append(o.events[:i], o.events[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type memoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[uint]User
+	nextID uint
+	outbox *memoryOutbox
+}
+
+func newMemoryUserRepository(outbox *memoryOutbox) *memoryUserRepository {
+	return &memoryUserRepository{users: map[uint]User{}, nextID: 1, outbox: outbox}
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, opts ListOptions) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]User, 0, len(r.users))
+	for _, user := range r.users {
+		if !opts.visible(user.DeletedAt) || !opts.Users.matches(user) {
+			continue
+		}
+		users = append(users, user)
+	}
+	sortRecords(users, opts.Sort, userSortValue, func(a, b User) bool { return a.ID < b.ID })
+	return paginate(users, opts), nil
+}
+
+func (r *memoryUserRepository) Count(ctx context.Context, opts ListOptions) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, user := range r.users {
+		if opts.visible(user.DeletedAt) && opts.Users.matches(user) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryUserRepository) Get(ctx context.Context, id uint) (User, error) {
+	user, err := r.GetIncludingDeleted(ctx, id)
+	if err == nil && user.DeletedAt.Valid {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+func (r *memoryUserRepository) GetMany(ctx context.Context, ids []uint) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []User{}
+	for _, id := range ids {
+		if user, ok := r.users[id]; ok && !user.DeletedAt.Valid {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) GetIncludingDeleted(ctx context.Context, id uint) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) GetByUsername(ctx context.Context, username string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Username == username && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) GetByExternalID(ctx context.Context, externalID string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.ExternalID != nil && *user.ExternalID == externalID && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) ResolveUUID(ctx context.Context, uuid string) (uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.UUID == uuid {
+			return user.ID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conflicts(user) {
+		return ErrConflict
+	}
+
+	now := time.Now()
+	user.ID = r.nextID
+	user.UUID = newUUID()
+	user.Version = 1
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.users[user.ID] = *user
+	r.nextID++
+	r.outbox.add(EventUserCreated, user.ID, user)
+	return nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.users[user.ID]
+	if !ok || existing.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if existing.Version != user.Version {
+		return ErrVersionConflict
+	}
+	if r.conflicts(user) {
+		return ErrConflict
+	}
+
+	user.Version++
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = *user
+	r.outbox.add(EventUserUpdated, user.ID, user)
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.users[id] = user
+	return nil
+}
+
+func (r *memoryUserRepository) Restore(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !user.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	r.users[id] = user
+	return nil
+}
+
+func (r *memoryUserRepository) ForceDelete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+// conflicts reports whether another user already has user's username or
+// email. Callers must hold r.mu.
+func (r *memoryUserRepository) conflicts(user *User) bool {
+	for _, other := range r.users {
+		if other.ID == user.ID {
+			continue
+		}
+		if other.Username == user.Username || other.Email == user.Email {
+			return true
+		}
+		if user.ExternalID != nil && other.ExternalID != nil && *other.ExternalID == *user.ExternalID {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryPostRepository struct {
+	mu     sync.RWMutex
+	posts  map[uint]Post
+	nextID uint
+	outbox *memoryOutbox
+}
+
+func newMemoryPostRepository(outbox *memoryOutbox) *memoryPostRepository {
+	return &memoryPostRepository{posts: map[uint]Post{}, nextID: 1, outbox: outbox}
+}
+
+func (r *memoryPostRepository) List(ctx context.Context, opts ListOptions) ([]Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	posts := make([]Post, 0, len(r.posts))
+	for _, post := range r.posts {
+		if !opts.visible(post.DeletedAt) || !opts.Posts.matches(post) {
+			continue
+		}
+		if opts.After != nil && !opts.Reverse && !opts.After.precedes(post.CreatedAt, post.ID) {
+			continue
+		}
+		if opts.After != nil && opts.Reverse && !opts.After.follows(post.CreatedAt, post.ID) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	sortRecords(posts, opts.Sort, postSortValue, func(a, b Post) bool {
+		if opts.Reverse {
+			return Cursor{CreatedAt: a.CreatedAt, ID: a.ID}.follows(b.CreatedAt, b.ID)
+		}
+		return Cursor{CreatedAt: a.CreatedAt, ID: a.ID}.precedes(b.CreatedAt, b.ID)
+	})
+	return paginate(posts, opts), nil
+}
+
+func (r *memoryPostRepository) Count(ctx context.Context, opts ListOptions) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, post := range r.posts {
+		if opts.visible(post.DeletedAt) && opts.Posts.matches(post) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryPostRepository) Get(ctx context.Context, id uint) (Post, error) {
+	post, err := r.GetIncludingDeleted(ctx, id)
+	if err == nil && post.DeletedAt.Valid {
+		return Post{}, ErrNotFound
+	}
+	return post, err
+}
+
+func (r *memoryPostRepository) GetIncludingDeleted(ctx context.Context, id uint) (Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	post, ok := r.posts[id]
+	if !ok {
+		return Post{}, ErrNotFound
+	}
+	return post, nil
+}
+
+func (r *memoryPostRepository) GetMany(ctx context.Context, ids []uint) ([]Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	posts := []Post{}
+	for _, id := range ids {
+		if post, ok := r.posts[id]; ok && !post.DeletedAt.Valid {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (r *memoryPostRepository) ResolveUUID(ctx context.Context, uuid string) (uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, post := range r.posts {
+		if post.UUID == uuid {
+			return post.ID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (r *memoryPostRepository) Create(ctx context.Context, post *Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	post.ID = r.nextID
+	post.UUID = newUUID()
+	post.Version = 1
+	post.CreatedAt = now
+	post.UpdatedAt = now
+	r.posts[post.ID] = *post
+	r.nextID++
+	r.outbox.add(EventPostCreated, post.ID, post)
+	return nil
+}
+
+func (r *memoryPostRepository) Update(ctx context.Context, post *Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.posts[post.ID]
+	if !ok || existing.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if existing.Version != post.Version {
+		return ErrVersionConflict
+	}
+
+	post.Version++
+	post.UpdatedAt = time.Now()
+	r.posts[post.ID] = *post
+	r.outbox.add(EventPostUpdated, post.ID, post)
+	return nil
+}
+
+func (r *memoryPostRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	post, ok := r.posts[id]
+	if !ok || post.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	post.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.posts[id] = post
+	return nil
+}
+
+func (r *memoryPostRepository) DeleteMany(ctx context.Context, ids []uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		post, ok := r.posts[id]
+		if !ok || post.DeletedAt.Valid {
+			continue
+		}
+		post.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		r.posts[id] = post
+	}
+	return nil
+}
+
+func (r *memoryPostRepository) Restore(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	post, ok := r.posts[id]
+	if !ok || !post.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	post.DeletedAt = gorm.DeletedAt{}
+	r.posts[id] = post
+	return nil
+}
+
+func (r *memoryPostRepository) ForceDelete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.posts[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.posts, id)
+	return nil
+}
+
+type memoryRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[uint]RefreshToken
+	nextID uint
+}
+
+func newMemoryRefreshTokenRepository() *memoryRefreshTokenRepository {
+	return &memoryRefreshTokenRepository{tokens: map[uint]RefreshToken{}, nextID: 1}
+}
+
+func (r *memoryRefreshTokenRepository) Create(ctx context.Context, token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token.ID = r.nextID
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = *token
+	r.nextID++
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) GetByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			return token, nil
+		}
+	}
+	return RefreshToken{}, ErrNotFound
+}
+
+func (r *memoryRefreshTokenRepository) MarkUsed(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if token.UsedAt != nil {
+		return ErrConflict
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	r.tokens[id] = token
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeUser(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			r.tokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			r.tokens[id] = token
+		}
+	}
+	return nil
+}
+
+type memoryAPIKeyRepository struct {
+	mu     sync.Mutex
+	keys   map[uint]APIKey
+	nextID uint
+}
+
+func newMemoryAPIKeyRepository() *memoryAPIKeyRepository {
+	return &memoryAPIKeyRepository{keys: map[uint]APIKey{}, nextID: 1}
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key.ID = r.nextID
+	key.CreatedAt = time.Now()
+	r.keys[key.ID] = *key
+	r.nextID++
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) ListByUser(ctx context.Context, userID uint) ([]APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []APIKey{}
+	for _, key := range r.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) GetByHash(ctx context.Context, hash string) (APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == hash {
+			return key, nil
+		}
+	}
+	return APIKey{}, ErrNotFound
+}
+
+func (r *memoryAPIKeyRepository) Revoke(ctx context.Context, id, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	r.keys[id] = key
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) Touch(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	key.LastUsedAt = &now
+	r.keys[id] = key
+	return nil
+}
+
+type memoryOneTimeTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[uint]OneTimeToken
+	nextID uint
+}
+
+func newMemoryOneTimeTokenRepository() *memoryOneTimeTokenRepository {
+	return &memoryOneTimeTokenRepository{tokens: map[uint]OneTimeToken{}, nextID: 1}
+}
+
+func (r *memoryOneTimeTokenRepository) Create(ctx context.Context, token *OneTimeToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token.ID = r.nextID
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = *token
+	r.nextID++
+	return nil
+}
+
+func (r *memoryOneTimeTokenRepository) Consume(ctx context.Context, purpose, hash string) (OneTimeToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range r.tokens {
+		if token.TokenHash != hash || token.Purpose != purpose {
+			continue
+		}
+		if token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+			break
+		}
+		token.UsedAt = &now
+		r.tokens[id] = token
+		return token, nil
+	}
+	return OneTimeToken{}, ErrNotFound
+}
+
+type memoryReactionRepository struct {
+	mu        sync.RWMutex
+	reactions []Reaction
+	nextID    uint
+}
+
+func newMemoryReactionRepository() *memoryReactionRepository {
+	return &memoryReactionRepository{nextID: 1}
+}
+
+func (r *memoryReactionRepository) Add(ctx context.Context, reaction *Reaction) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.reactions {
+		if existing.PostID == reaction.PostID && existing.UserID == reaction.UserID && existing.Type == reaction.Type {
+			return false, nil
+		}
+	}
+	reaction.ID = r.nextID
+	reaction.CreatedAt = time.Now()
+	r.reactions = append(r.reactions, *reaction)
+	r.nextID++
+	return true, nil
+}
+
+func (r *memoryReactionRepository) Remove(ctx context.Context, postID, userID uint, reactionType string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.reactions)
+	r.reactions = slices.DeleteFunc(r.reactions, func(reaction Reaction) bool {
+		return reaction.PostID == postID && reaction.UserID == userID && reaction.Type == reactionType
+	})
+	return len(r.reactions) < before, nil
+}
+
+func (r *memoryReactionRepository) List(ctx context.Context, postID uint, reactionType string, opts ListOptions) ([]Reaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Reactions are appended in ID order, so they are already oldest first.
+	reactions := []Reaction{}
+	for _, reaction := range r.reactions {
+		if reaction.PostID == postID && (reactionType == "" || reaction.Type == reactionType) {
+			reactions = append(reactions, reaction)
+		}
+	}
+	return paginate(reactions, opts), nil
+}
+
+func (r *memoryReactionRepository) Count(ctx context.Context, postID uint, reactionType string) (int64, error) {
+	reactions, _ := r.List(ctx, postID, reactionType, ListOptions{})
+	return int64(len(reactions)), nil
+}
+
+func (r *memoryReactionRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]map[string]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[uint]map[string]int64)
+	for _, reaction := range r.reactions {
+		if !slices.Contains(postIDs, reaction.PostID) {
+			continue
+		}
+		if counts[reaction.PostID] == nil {
+			counts[reaction.PostID] = make(map[string]int64)
+		}
+		counts[reaction.PostID][reaction.Type]++
+	}
+	return counts, nil
+}
+
+func (r *memoryReactionRepository) Recent(ctx context.Context, since time.Time) ([]Reaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reactions := []Reaction{}
+	for _, reaction := range r.reactions {
+		if !reaction.CreatedAt.Before(since) {
+			reactions = append(reactions, reaction)
+		}
+	}
+	return reactions, nil
+}
+
+type memoryFollowRepository struct {
+	mu      sync.RWMutex
+	follows []Follow
+	nextID  uint
+}
+
+func newMemoryFollowRepository() *memoryFollowRepository {
+	return &memoryFollowRepository{nextID: 1}
+}
+
+func (r *memoryFollowRepository) Add(ctx context.Context, follow *Follow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.follows {
+		if existing.FollowerID == follow.FollowerID && existing.FolloweeID == follow.FolloweeID {
+			return nil
+		}
+	}
+	follow.ID = r.nextID
+	follow.CreatedAt = time.Now()
+	r.follows = append(r.follows, *follow)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryFollowRepository) Remove(ctx context.Context, followerID, followeeID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.follows = slices.DeleteFunc(r.follows, func(follow Follow) bool {
+		return follow.FollowerID == followerID && follow.FolloweeID == followeeID
+	})
+	return nil
+}
+
+func (r *memoryFollowRepository) Followers(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error) {
+	return r.list(func(follow Follow) bool { return follow.FolloweeID == userID }, opts), nil
+}
+
+func (r *memoryFollowRepository) Following(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error) {
+	return r.list(func(follow Follow) bool { return follow.FollowerID == userID }, opts), nil
+}
+
+// list returns the follows matching keep, which are already oldest first
+// since they are appended in ID order.
+func (r *memoryFollowRepository) list(keep func(Follow) bool, opts ListOptions) []Follow {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	follows := []Follow{}
+	for _, follow := range r.follows {
+		if keep(follow) {
+			follows = append(follows, follow)
+		}
+	}
+	return paginate(follows, opts)
+}
+
+func (r *memoryFollowRepository) Counts(ctx context.Context, userIDs []uint) (followers, following map[uint]int64, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	followers, following = make(map[uint]int64), make(map[uint]int64)
+	for _, follow := range r.follows {
+		if slices.Contains(userIDs, follow.FolloweeID) {
+			followers[follow.FolloweeID]++
+		}
+		if slices.Contains(userIDs, follow.FollowerID) {
+			following[follow.FollowerID]++
+		}
+	}
+	return followers, following, nil
+}
+
+type memoryBookmarkRepository struct {
+	mu        sync.RWMutex
+	bookmarks []Bookmark
+	nextID    uint
+}
+
+func newMemoryBookmarkRepository() *memoryBookmarkRepository {
+	return &memoryBookmarkRepository{nextID: 1}
+}
+
+func (r *memoryBookmarkRepository) Add(ctx context.Context, bookmark *Bookmark) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.bookmarks {
+		if existing.UserID == bookmark.UserID && existing.PostID == bookmark.PostID {
+			return false, nil
+		}
+	}
+	bookmark.ID = r.nextID
+	bookmark.CreatedAt = time.Now()
+	r.bookmarks = append(r.bookmarks, *bookmark)
+	r.nextID++
+	return true, nil
+}
+
+func (r *memoryBookmarkRepository) Remove(ctx context.Context, userID, postID uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.bookmarks)
+	r.bookmarks = slices.DeleteFunc(r.bookmarks, func(bookmark Bookmark) bool {
+		return bookmark.UserID == userID && bookmark.PostID == postID
+	})
+	return len(r.bookmarks) < before, nil
+}
+
+func (r *memoryBookmarkRepository) List(ctx context.Context, userID uint, opts ListOptions) ([]Bookmark, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bookmarks := []Bookmark{}
+	for i := len(r.bookmarks) - 1; i >= 0; i-- {
+		if r.bookmarks[i].UserID == userID {
+			bookmarks = append(bookmarks, r.bookmarks[i])
+		}
+	}
+	return paginate(bookmarks, opts), nil
+}
+
+func (r *memoryBookmarkRepository) Count(ctx context.Context, userID uint) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, bookmark := range r.bookmarks {
+		if bookmark.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryBookmarkRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[uint]int64)
+	for _, bookmark := range r.bookmarks {
+		if slices.Contains(postIDs, bookmark.PostID) {
+			counts[bookmark.PostID]++
+		}
+	}
+	return counts, nil
+}
+
+type memoryMessageRepository struct {
+	mu       sync.RWMutex
+	messages []Message
+	nextID   uint
+	outbox   *memoryOutbox
+}
+
+func newMemoryMessageRepository(outbox *memoryOutbox) *memoryMessageRepository {
+	return &memoryMessageRepository{nextID: 1, outbox: outbox}
+}
+
+func (r *memoryMessageRepository) Create(ctx context.Context, message *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message.ID = r.nextID
+	message.CreatedAt = time.Now()
+	r.messages = append(r.messages, *message)
+	r.nextID++
+	r.outbox.add(EventMessageSent, message.ID, message.event())
+	return nil
+}
+
+func (r *memoryMessageRepository) Thread(ctx context.Context, conversation string, opts ListOptions) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []Message{}
+	for i := len(r.messages) - 1; i >= 0; i-- {
+		if r.messages[i].Conversation == conversation {
+			messages = append(messages, r.messages[i])
+		}
+	}
+	return paginate(messages, opts), nil
+}
+
+func (r *memoryMessageRepository) CountThread(ctx context.Context, conversation string) (int64, error) {
+	messages, _ := r.Thread(ctx, conversation, ListOptions{})
+	return int64(len(messages)), nil
+}
+
+func (r *memoryMessageRepository) Conversations(ctx context.Context, userID uint, opts ListOptions) ([]Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []Message{}
+	seen := make(map[string]bool)
+	for i := len(r.messages) - 1; i >= 0; i-- {
+		message := r.messages[i]
+		if (message.SenderID == userID || message.RecipientID == userID) && !seen[message.Conversation] {
+			seen[message.Conversation] = true
+			messages = append(messages, message)
+		}
+	}
+	return paginate(messages, opts), nil
+}
+
+func (r *memoryMessageRepository) CountConversations(ctx context.Context, userID uint) (int64, error) {
+	messages, _ := r.Conversations(ctx, userID, ListOptions{})
+	return int64(len(messages)), nil
+}
+
+func (r *memoryMessageRepository) MarkRead(ctx context.Context, recipientID, senderID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i, message := range r.messages {
+		if message.RecipientID == recipientID && message.SenderID == senderID && message.ReadAt == nil {
+			r.messages[i].ReadAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryMessageRepository) Unread(ctx context.Context, userID uint) (map[uint]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	unread := make(map[uint]int64)
+	for _, message := range r.messages {
+		if message.RecipientID == userID && message.ReadAt == nil {
+			unread[message.SenderID]++
+		}
+	}
+	return unread, nil
+}
+
+type memoryMediaRepository struct {
+	mu     sync.RWMutex
+	media  map[uint]Media
+	nextID uint
+}
+
+func newMemoryMediaRepository() *memoryMediaRepository {
+	return &memoryMediaRepository{media: make(map[uint]Media), nextID: 1}
+}
+
+func (r *memoryMediaRepository) Create(ctx context.Context, media *Media) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	media.ID = r.nextID
+	media.CreatedAt = time.Now()
+	r.media[media.ID] = *media
+	r.nextID++
+	return nil
+}
+
+func (r *memoryMediaRepository) Get(ctx context.Context, id uint) (Media, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	media, ok := r.media[id]
+	if !ok {
+		return Media{}, ErrNotFound
+	}
+	return media, nil
+}
+
+func (r *memoryMediaRepository) ListByPost(ctx context.Context, postID uint) ([]Media, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	media := []Media{}
+	for _, m := range r.media {
+		if m.PostID == postID {
+			media = append(media, m)
+		}
+	}
+	sort.Slice(media, func(i, j int) bool {
+		if media[i].Position != media[j].Position {
+			return media[i].Position < media[j].Position
+		}
+		return media[i].ID < media[j].ID
+	})
+	return media, nil
+}
+
+func (r *memoryMediaRepository) Update(ctx context.Context, media *Media) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.media[media.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.AltText, stored.Position = media.AltText, media.Position
+	r.media[media.ID] = stored
+	return nil
+}
+
+func (r *memoryMediaRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.media[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.media, id)
+	return nil
+}
+
+type memoryActivityRepository struct {
+	mu       sync.RWMutex
+	activity []Activity
+	nextID   uint
+}
+
+func newMemoryActivityRepository() *memoryActivityRepository {
+	return &memoryActivityRepository{nextID: 1}
+}
+
+func (r *memoryActivityRepository) Record(ctx context.Context, activity *Activity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	activity.ID = r.nextID
+	activity.CreatedAt = time.Now()
+	r.activity = append(r.activity, *activity)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryActivityRepository) Remove(ctx context.Context, activity Activity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.activity = slices.DeleteFunc(r.activity, func(a Activity) bool {
+		return a.UserID == activity.UserID && a.Type == activity.Type && a.PostID == activity.PostID &&
+			a.TargetUserID == activity.TargetUserID && a.Reaction == activity.Reaction
+	})
+	return nil
+}
+
+func (r *memoryActivityRepository) List(ctx context.Context, userID uint, opts ListOptions) ([]Activity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	activity := []Activity{}
+	for i := len(r.activity) - 1; i >= 0; i-- {
+		if r.activity[i].UserID == userID {
+			activity = append(activity, r.activity[i])
+		}
+	}
+	return paginate(activity, opts), nil
+}
+
+func (r *memoryActivityRepository) Count(ctx context.Context, userID uint) (int64, error) {
+	activity, _ := r.List(ctx, userID, ListOptions{})
+	return int64(len(activity)), nil
+}
+
+type memoryReportRepository struct {
+	mu      sync.RWMutex
+	reports []Report
+	nextID  uint
+}
+
+func newMemoryReportRepository() *memoryReportRepository {
+	return &memoryReportRepository{nextID: 1}
+}
+
+func (r *memoryReportRepository) Create(ctx context.Context, report *Report) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.reports {
+		if existing.PostID == report.PostID && existing.ReporterID == report.ReporterID {
+			return ErrConflict
+		}
+	}
+	report.ID = r.nextID
+	report.CreatedAt = time.Now()
+	r.reports = append(r.reports, *report)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryReportRepository) Summaries(ctx context.Context, open bool, opts ListOptions) ([]ReportSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	summaries := []ReportSummary{}
+	index := make(map[uint]int)
+	latest := make(map[uint]uint)
+	for _, report := range r.reports {
+		if open && report.ResolvedAt != nil {
+			continue
+		}
+		i, ok := index[report.PostID]
+		if !ok {
+			i = len(summaries)
+			index[report.PostID] = i
+			summaries = append(summaries, ReportSummary{PostID: report.PostID, Reasons: map[string]int64{}})
+		}
+		summaries[i].Count++
+		summaries[i].Reasons[report.Reason]++
+		latest[report.PostID] = report.ID
+	}
+	slices.SortFunc(summaries, func(a, b ReportSummary) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(latest[b.PostID], latest[a.PostID])
+	})
+	return paginate(summaries, opts), nil
+}
+
+func (r *memoryReportRepository) CountSummaries(ctx context.Context, open bool) (int64, error) {
+	summaries, _ := r.Summaries(ctx, open, ListOptions{})
+	return int64(len(summaries)), nil
+}
+
+func (r *memoryReportRepository) Resolve(ctx context.Context, postID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i, report := range r.reports {
+		if report.PostID == postID && report.ResolvedAt == nil {
+			r.reports[i].ResolvedAt = &now
+		}
+	}
+	return nil
+}
+
+type memoryModerationRepository struct {
+	mu        sync.RWMutex
+	decisions []ModerationDecision
+	nextID    uint
+}
+
+func newMemoryModerationRepository() *memoryModerationRepository {
+	return &memoryModerationRepository{nextID: 1}
+}
+
+func (r *memoryModerationRepository) Create(ctx context.Context, decision *ModerationDecision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	decision.ID = r.nextID
+	decision.CreatedAt = time.Now()
+	r.decisions = append(r.decisions, *decision)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryModerationRepository) List(ctx context.Context, filter ModerationFilter, opts ListOptions) ([]ModerationDecision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	decisions := []ModerationDecision{}
+	for i := len(r.decisions) - 1; i >= 0; i-- {
+		if filter.matches(r.decisions[i]) {
+			decisions = append(decisions, r.decisions[i])
+		}
+	}
+	return paginate(decisions, opts), nil
+}
+
+func (r *memoryModerationRepository) Count(ctx context.Context, filter ModerationFilter) (int64, error) {
+	decisions, _ := r.List(ctx, filter, ListOptions{})
+	return int64(len(decisions)), nil
+}
+
+type memoryViewRepository struct {
+	mu sync.RWMutex
+	// views counts views by post, then by day.
+	views map[uint]map[string]int64
+}
+
+func newMemoryViewRepository() *memoryViewRepository {
+	return &memoryViewRepository{views: make(map[uint]map[string]int64)}
+}
+
+func (r *memoryViewRepository) Record(ctx context.Context, postID uint, day string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.views[postID] == nil {
+		r.views[postID] = make(map[string]int64)
+	}
+	r.views[postID][day]++
+	return nil
+}
+
+func (r *memoryViewRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[uint]int64)
+	for _, id := range postIDs {
+		for _, views := range r.views[id] {
+			counts[id] += views
+		}
+	}
+	return counts, nil
+}
+
+func (r *memoryViewRepository) Daily(ctx context.Context, postID uint, from string) ([]DailyViews, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	daily := []DailyViews{}
+	for day, views := range r.views[postID] {
+		if day >= from {
+			daily = append(daily, DailyViews{PostID: postID, Day: day, Views: views})
+		}
+	}
+	slices.SortFunc(daily, func(a, b DailyViews) int { return cmp.Compare(a.Day, b.Day) })
+	return daily, nil
+}
+
+func (r *memoryViewRepository) Recent(ctx context.Context, from string) ([]DailyViews, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	daily := []DailyViews{}
+	for postID, days := range r.views {
+		for day, views := range days {
+			if day >= from {
+				daily = append(daily, DailyViews{PostID: postID, Day: day, Views: views})
+			}
+		}
+	}
+	return daily, nil
+}
+
+type memoryBlockRepository struct {
+	mu     sync.RWMutex
+	blocks []Block
+	nextID uint
+}
+
+func newMemoryBlockRepository() *memoryBlockRepository {
+	return &memoryBlockRepository{nextID: 1}
+}
+
+func (r *memoryBlockRepository) Add(ctx context.Context, block *Block) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.blocks {
+		if existing.BlockerID == block.BlockerID && existing.BlockedID == block.BlockedID {
+			return nil
+		}
+	}
+	block.ID = r.nextID
+	block.CreatedAt = time.Now()
+	r.blocks = append(r.blocks, *block)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryBlockRepository) Remove(ctx context.Context, blockerID, blockedID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.blocks = slices.DeleteFunc(r.blocks, func(block Block) bool {
+		return block.BlockerID == blockerID && block.BlockedID == blockedID
+	})
+	return nil
+}
+
+func (r *memoryBlockRepository) List(ctx context.Context, blockerID uint, opts ListOptions) ([]Block, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	blocks := []Block{}
+	for i := len(r.blocks) - 1; i >= 0; i-- {
+		if r.blocks[i].BlockerID == blockerID {
+			blocks = append(blocks, r.blocks[i])
+		}
+	}
+	return paginate(blocks, opts), nil
+}
+
+func (r *memoryBlockRepository) Count(ctx context.Context, blockerID uint) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, block := range r.blocks {
+		if block.BlockerID == blockerID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryBlockRepository) Blocked(ctx context.Context, blockerID uint) ([]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	blocked := []uint{}
+	for _, block := range r.blocks {
+		if block.BlockerID == blockerID {
+			blocked = append(blocked, block.BlockedID)
+		}
+	}
+	return blocked, nil
+}
+
+func (r *memoryBlockRepository) Between(ctx context.Context, a, b uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, block := range r.blocks {
+		if (block.BlockerID == a && block.BlockedID == b) || (block.BlockerID == b && block.BlockedID == a) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryMentionRepository struct {
+	mu       sync.RWMutex
+	mentions []Mention
+	nextID   uint
+	outbox   *memoryOutbox
+}
+
+func newMemoryMentionRepository(outbox *memoryOutbox) *memoryMentionRepository {
+	return &memoryMentionRepository{nextID: 1, outbox: outbox}
+}
+
+func (r *memoryMentionRepository) Set(ctx context.Context, post Post, userIDs, notify []uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing := []uint{}
+	r.mentions = slices.DeleteFunc(r.mentions, func(mention Mention) bool {
+		if mention.PostID != post.ID {
+			return false
+		}
+		if !slices.Contains(userIDs, mention.UserID) {
+			return true
+		}
+		existing = append(existing, mention.UserID)
+		return false
+	})
+	for _, userID := range userIDs {
+		if slices.Contains(existing, userID) {
+			continue
+		}
+		r.mentions = append(r.mentions, Mention{ID: r.nextID, PostID: post.ID, UserID: userID, CreatedAt: time.Now()})
+		r.nextID++
+	}
+	for _, userID := range notify {
+		r.outbox.add(EventPostMentioned, post.ID, mentionEvent{PostID: post.ID, AuthorID: post.AuthorID, UserID: userID})
+	}
+	return nil
+}
+
+func (r *memoryMentionRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mentioned := make(map[uint][]uint)
+	for _, mention := range r.mentions {
+		if slices.Contains(postIDs, mention.PostID) {
+			mentioned[mention.PostID] = append(mentioned[mention.PostID], mention.UserID)
+		}
+	}
+	return mentioned, nil
+}
+
+type memoryPollRepository struct {
+	mu     sync.RWMutex
+	polls  map[uint]Poll
+	votes  []PollVote
+	nextID uint
+}
+
+func newMemoryPollRepository() *memoryPollRepository {
+	return &memoryPollRepository{polls: make(map[uint]Poll), nextID: 1}
+}
+
+func (r *memoryPollRepository) Get(ctx context.Context, postID uint) (Poll, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	poll, ok := r.polls[postID]
+	if !ok {
+		return Poll{}, ErrNotFound
+	}
+	return poll, nil
+}
+
+func (r *memoryPollRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint]Poll, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	polls := make(map[uint]Poll)
+	for _, id := range postIDs {
+		if poll, ok := r.polls[id]; ok {
+			polls[id] = poll
+		}
+	}
+	return polls, nil
+}
+
+func (r *memoryPollRepository) Save(ctx context.Context, poll *Poll) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	poll.ID = r.nextID
+	poll.CreatedAt = time.Now()
+	r.polls[poll.PostID] = *poll
+	r.nextID++
+	return nil
+}
+
+func (r *memoryPollRepository) Delete(ctx context.Context, postID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.polls[postID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.polls, postID)
+	r.votes = slices.DeleteFunc(r.votes, func(vote PollVote) bool {
+		return vote.PostID == postID
+	})
+	return nil
+}
+
+func (r *memoryPollRepository) Vote(ctx context.Context, vote *PollVote) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.votes {
+		if existing.PostID == vote.PostID && existing.UserID == vote.UserID {
+			return ErrConflict
+		}
+	}
+	vote.ID = r.nextID
+	vote.CreatedAt = time.Now()
+	r.votes = append(r.votes, *vote)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryPollRepository) Tallies(ctx context.Context, postIDs []uint) (map[uint]map[int]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tallies := make(map[uint]map[int]int64)
+	for _, vote := range r.votes {
+		if !slices.Contains(postIDs, vote.PostID) {
+			continue
+		}
+		if tallies[vote.PostID] == nil {
+			tallies[vote.PostID] = make(map[int]int64)
+		}
+		tallies[vote.PostID][vote.Choice]++
+	}
+	return tallies, nil
+}
+
+func (r *memoryPollRepository) VotesBy(ctx context.Context, userID uint, postIDs []uint) (map[uint]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	votes := make(map[uint]int)
+	for _, vote := range r.votes {
+		if vote.UserID == userID && slices.Contains(postIDs, vote.PostID) {
+			votes[vote.PostID] = vote.Choice
+		}
+	}
+	return votes, nil
+}
+
+type memorySeriesRepository struct {
+	mu      sync.RWMutex
+	series  map[uint]Series
+	entries []SeriesEntry
+	nextID  uint
+}
+
+func newMemorySeriesRepository() *memorySeriesRepository {
+	return &memorySeriesRepository{series: make(map[uint]Series), nextID: 1}
+}
+
+func (r *memorySeriesRepository) Create(ctx context.Context, series *Series) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series.ID = r.nextID
+	series.CreatedAt = time.Now()
+	r.series[series.ID] = *series
+	r.nextID++
+	return nil
+}
+
+func (r *memorySeriesRepository) Get(ctx context.Context, id uint) (Series, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	series, ok := r.series[id]
+	if !ok {
+		return Series{}, ErrNotFound
+	}
+	return series, nil
+}
+
+func (r *memorySeriesRepository) GetMany(ctx context.Context, ids []uint) ([]Series, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := []Series{}
+	for _, id := range ids {
+		if series, ok := r.series[id]; ok {
+			found = append(found, series)
+		}
+	}
+	return found, nil
+}
+
+func (r *memorySeriesRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.series[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.series, id)
+	r.entries = slices.DeleteFunc(r.entries, func(entry SeriesEntry) bool {
+		return entry.SeriesID == id
+	})
+	return nil
+}
+
+func (r *memorySeriesRepository) SetPosts(ctx context.Context, seriesID uint, postIDs []uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range r.entries {
+		if entry.SeriesID != seriesID && slices.Contains(postIDs, entry.PostID) {
+			return ErrConflict
+		}
+	}
+	r.entries = slices.DeleteFunc(r.entries, func(entry SeriesEntry) bool {
+		return entry.SeriesID == seriesID
+	})
+	for i, postID := range postIDs {
+		r.entries = append(r.entries, SeriesEntry{SeriesID: seriesID, PostID: postID, Position: i})
+	}
+	return nil
+}
+
+func (r *memorySeriesRepository) Posts(ctx context.Context, seriesID uint) ([]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Entries are appended in order, so they are kept in it.
+	ids := []uint{}
+	for _, entry := range r.entries {
+		if entry.SeriesID == seriesID {
+			ids = append(ids, entry.PostID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memorySeriesRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	memberships := make(map[uint]uint)
+	for _, entry := range r.entries {
+		if slices.Contains(postIDs, entry.PostID) {
+			memberships[entry.PostID] = entry.SeriesID
+		}
+	}
+	return memberships, nil
+}
+
+type memoryCoAuthorRepository struct {
+	mu        sync.RWMutex
+	coAuthors []CoAuthor
+	nextID    uint
+}
+
+func newMemoryCoAuthorRepository() *memoryCoAuthorRepository {
+	return &memoryCoAuthorRepository{nextID: 1}
+}
+
+func (r *memoryCoAuthorRepository) Add(ctx context.Context, coAuthor *CoAuthor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.coAuthors {
+		if existing.PostID == coAuthor.PostID && existing.UserID == coAuthor.UserID {
+			return nil
+		}
+	}
+	coAuthor.ID = r.nextID
+	coAuthor.CreatedAt = time.Now()
+	r.coAuthors = append(r.coAuthors, *coAuthor)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryCoAuthorRepository) Remove(ctx context.Context, postID, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.coAuthors = slices.DeleteFunc(r.coAuthors, func(coAuthor CoAuthor) bool {
+		return coAuthor.PostID == postID && coAuthor.UserID == userID
+	})
+	return nil
+}
+
+func (r *memoryCoAuthorRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coAuthors := make(map[uint][]uint)
+	for _, coAuthor := range r.coAuthors {
+		if slices.Contains(postIDs, coAuthor.PostID) {
+			coAuthors[coAuthor.PostID] = append(coAuthors[coAuthor.PostID], coAuthor.UserID)
+		}
+	}
+	return coAuthors, nil
+}
+
+func (r *memoryCoAuthorRepository) Is(ctx context.Context, postID, userID uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, coAuthor := range r.coAuthors {
+		if coAuthor.PostID == postID && coAuthor.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryEngagementRepository struct {
+	mu     sync.RWMutex
+	counts map[uint]map[string]DailyEngagement
+}
+
+func newMemoryEngagementRepository() *memoryEngagementRepository {
+	return &memoryEngagementRepository{counts: make(map[uint]map[string]DailyEngagement)}
+}
+
+func (r *memoryEngagementRepository) Record(ctx context.Context, postID uint, day, counter string, delta int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.counts[postID] == nil {
+		r.counts[postID] = make(map[string]DailyEngagement)
+	}
+	daily := r.counts[postID][day]
+	daily.PostID, daily.Day = postID, day
+	switch counter {
+	case EngagementLikes:
+		daily.Likes += delta
+	case EngagementBookmarks:
+		daily.Bookmarks += delta
+	}
+	r.counts[postID][day] = daily
+	return nil
+}
+
+func (r *memoryEngagementRepository) Daily(ctx context.Context, postID uint, from string) ([]DailyEngagement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	daily := []DailyEngagement{}
+	for day, counts := range r.counts[postID] {
+		if day >= from {
+			daily = append(daily, counts)
+		}
+	}
+	slices.SortFunc(daily, func(a, b DailyEngagement) int { return cmp.Compare(a.Day, b.Day) })
+	return daily, nil
+}
+
+type memorySettingsRepository struct {
+	mu       sync.RWMutex
+	settings map[uint]Settings
+}
+
+func newMemorySettingsRepository() *memorySettingsRepository {
+	return &memorySettingsRepository{settings: make(map[uint]Settings)}
+}
+
+func (r *memorySettingsRepository) Get(ctx context.Context, userID uint) (Settings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	settings, ok := r.settings[userID]
+	if !ok {
+		return Settings{}, ErrNotFound
+	}
+	return settings, nil
+}
+
+func (r *memorySettingsRepository) Save(ctx context.Context, settings *Settings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	settings.UpdatedAt = time.Now()
+	r.settings[settings.UserID] = *settings
+	return nil
+}
+
+type memoryPinRepository struct {
+	mu     sync.RWMutex
+	pins   []Pin
+	nextID uint
+}
+
+func newMemoryPinRepository() *memoryPinRepository {
+	return &memoryPinRepository{nextID: 1}
+}
+
+func (r *memoryPinRepository) Add(ctx context.Context, pin *Pin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.pins {
+		if existing.PostID == pin.PostID {
+			return nil
+		}
+	}
+	pin.ID = r.nextID
+	pin.CreatedAt = time.Now()
+	r.pins = append(r.pins, *pin)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryPinRepository) Remove(ctx context.Context, postID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pins = slices.DeleteFunc(r.pins, func(pin Pin) bool { return pin.PostID == postID })
+	return nil
+}
+
+func (r *memoryPinRepository) List(ctx context.Context, userID uint) ([]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := []uint{}
+	for i := len(r.pins) - 1; i >= 0; i-- {
+		if r.pins[i].UserID == userID {
+			ids = append(ids, r.pins[i].PostID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryPinRepository) Pinned(ctx context.Context, postIDs []uint) (map[uint]bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pinned := make(map[uint]bool)
+	for _, pin := range r.pins {
+		if slices.Contains(postIDs, pin.PostID) {
+			pinned[pin.PostID] = true
+		}
+	}
+	return pinned, nil
+}
+
+type memoryAuditRepository struct {
+	mu     sync.Mutex
+	events []AuditEvent
+	nextID uint
+}
+
+func newMemoryAuditRepository() *memoryAuditRepository {
+	return &memoryAuditRepository{nextID: 1}
+}
+
+func (r *memoryAuditRepository) Append(ctx context.Context, event *AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = r.nextID
+	event.CreatedAt = time.Now()
+	r.events = append(r.events, *event)
+	r.nextID++
+	return nil
+}
+
+func (r *memoryAuditRepository) List(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []AuditEvent{}
+	for i := len(r.events) - 1; i >= 0; i-- {
+		event := r.events[i]
+		switch {
+		case !query.From.IsZero() && event.CreatedAt.Before(query.From),
+			!query.To.IsZero() && event.CreatedAt.After(query.To),
+			query.Type != "" && event.Type != query.Type,
+			query.UserID != nil && (event.UserID == nil || *event.UserID != *query.UserID),
+			query.Route != "" && event.Route != query.Route,
+			query.EntityID != "" && event.EntityID != query.EntityID:
+			continue
+		}
+		events = append(events, event)
+		if query.Limit > 0 && len(events) == query.Limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// visible reports whether a record with the given soft-delete state belongs
+// in a list with opts.
+func (opts ListOptions) visible(deletedAt gorm.DeletedAt) bool {
+	if opts.OnlyDeleted {
+		return deletedAt.Valid
+	}
+	return !deletedAt.Valid || opts.IncludeDeleted
+}
+
+// paginate applies opts.Offset and opts.Limit to items.
+func paginate[T any](items []T, opts ListOptions) []T {
+	if opts.Offset >= len(items) {
+		return items[:0]
+	}
+	items = items[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(items) {
+		items = items[:opts.Limit]
+	}
+	return items
+}
+
+func (f ModerationFilter) matches(decision ModerationDecision) bool {
+	return (f.PostID == 0 || decision.PostID == f.PostID) &&
+		(f.ModeratorID == 0 || decision.ModeratorID == f.ModeratorID) &&
+		(f.Action == "" || decision.Action == f.Action)
+}
+
+func (f UserFilter) matches(user User) bool {
+	switch {
+	case f.EmailDomain != "" && !strings.HasSuffix(strings.ToLower(user.Email), "@"+strings.ToLower(f.EmailDomain)),
+		f.UsernamePrefix != "" && !strings.HasPrefix(strings.ToLower(user.Username), strings.ToLower(f.UsernamePrefix)):
+		return false
+	}
+	return true
+}
+
+func (f PostFilter) matches(post Post) bool {
+	switch {
+	case f.AuthorID != 0 && post.AuthorID != f.AuthorID,
+		f.AuthorUUID != "" && post.AuthorUUID != f.AuthorUUID,
+		len(f.AuthorIDs) > 0 && !slices.Contains(f.AuthorIDs, post.AuthorID),
+		slices.Contains(f.ExcludeAuthorIDs, post.AuthorID),
+		len(f.IDs) > 0 && !slices.Contains(f.IDs, post.ID),
+		slices.Contains(f.ExcludeIDs, post.ID),
+		!f.CreatedAfter.IsZero() && !post.CreatedAt.After(f.CreatedAfter),
+		!f.CreatedBefore.IsZero() && !post.CreatedAt.Before(f.CreatedBefore),
+		f.TitleContains != "" && !strings.Contains(strings.ToLower(post.Title), strings.ToLower(f.TitleContains)),
+		f.TitlePrefix != "" && !strings.HasPrefix(strings.ToLower(post.Title), strings.ToLower(f.TitlePrefix)),
+		f.Status != "" && (post.Status == PostDraft) != (f.Status == PostDraft),
+		f.ListedOnly && !post.listed() && post.AuthorID != f.ListedFor:
+		return false
+	}
+	for _, term := range f.Terms {
+		term = strings.ToLower(term)
+		if !strings.Contains(strings.ToLower(post.Title), term) && !strings.Contains(strings.ToLower(post.Content), term) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortRecords sorts items by fields, falling back to less for ties. value
+// returns a record's value for a sort field.
+func sortRecords[T any](items []T, fields []SortField, value func(T, string) interface{}, less func(a, b T) bool) {
+	sort.Slice(items, func(i, j int) bool {
+		for _, field := range fields {
+			order := compareSortValues(value(items[i], field.Field), value(items[j], field.Field))
+			if field.Desc {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return less(items[i], items[j])
+	})
+}
+
+func compareSortValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case uint:
+		return cmp.Compare(a, b.(uint))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func userSortValue(user User, field string) interface{} {
+	switch field {
+	case "id":
+		return user.ID
+	case "username":
+		return user.Username
+	case "email":
+		return user.Email
+	case "created_at":
+		return user.CreatedAt
+	case "updated_at":
+		return user.UpdatedAt
+	}
+	return nil
+}
+
+func postSortValue(post Post, field string) interface{} {
+	switch field {
+	case "id":
+		return post.ID
+	case "title":
+		return post.Title
+	case "author_id":
+		return post.AuthorID
+	case "created_at":
+		return post.CreatedAt
+	case "updated_at":
+		return post.UpdatedAt
+	}
+	return nil
+}
@@ -0,0 +1,1985 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+// notDeleted matches documents that have not been soft-deleted.
+var notDeleted = bson.M{"deleted_at.valid": bson.M{"$ne": true}}
+
+// newMongoStorage connects to the MongoDB server at DATABASE_URL, ensures the
+// indexes exist and returns repositories backed by MONGO_DATABASE.
+func newMongoStorage() Storage {
+	uri := os.Getenv("DATABASE_URL")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+	name := os.Getenv("MONGO_DATABASE")
+	if name == "" {
+		name = "gin_golang_api"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Client().ApplyURI(uri)
+	traceMongo(opts)
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		log.Fatalf("failed to connect to mongodb: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		log.Fatalf("failed to connect to mongodb: %v", err)
+	}
+
+	database := client.Database(name)
+	if err := ensureMongoIndexes(ctx, database); err != nil {
+		log.Fatalf("failed to create mongodb indexes: %v", err)
+	}
+
+	return Storage{
+		Users:         newMongoUserRepository(database),
+		Posts:         newMongoPostRepository(database),
+		RefreshTokens: newMongoRefreshTokenRepository(database),
+		APIKeys:       newMongoAPIKeyRepository(database),
+		OneTimeTokens: newMongoOneTimeTokenRepository(database),
+		AuditLog:      newMongoAuditRepository(database),
+		Reactions:     newMongoReactionRepository(database),
+		Follows:       newMongoFollowRepository(database),
+		Bookmarks:     newMongoBookmarkRepository(database),
+		Messages:      newMongoMessageRepository(database),
+		Media:         newMongoMediaRepository(database),
+		Activity:      newMongoActivityRepository(database),
+		Reports:       newMongoReportRepository(database),
+		Moderation:    newMongoModerationRepository(database),
+		Views:         newMongoViewRepository(database),
+		Blocks:        newMongoBlockRepository(database),
+		Mentions:      newMongoMentionRepository(database),
+		Polls:         newMongoPollRepository(database),
+		Series:        newMongoSeriesRepository(database),
+		CoAuthors:     newMongoCoAuthorRepository(database),
+		Engagement:    newMongoEngagementRepository(database),
+		Settings:      newMongoSettingsRepository(database),
+		Pins:          newMongoPinRepository(database),
+		Close:         client.Disconnect,
+	}
+}
+
+// ensureMongoIndexes creates the indexes backing the unique username, email
+// and UUID constraints and the post author lookups. The UUID indexes are
+// sparse so documents written before UUIDs existed don't collide.
+func ensureMongoIndexes(ctx context.Context, database *mongo.Database) error {
+	_, err := database.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "uuid", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "external_id", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("posts").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "author_id", Value: 1}}},
+		{Keys: bson.D{{Key: "uuid", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("refresh_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("api_keys").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("one_time_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("audit_events").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("reactions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "type", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("follows").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "followee_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("bookmarks").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "post_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "post_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("messages").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "conversation", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "sender_id", Value: 1}}},
+		{Keys: bson.D{{Key: "recipient_id", Value: 1}, {Key: "read_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("media").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "position", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("activities").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("reports").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "reporter_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "reporter_id", Value: 1}}},
+		{Keys: bson.D{{Key: "resolved_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("moderation_decisions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}}},
+		{Keys: bson.D{{Key: "author_id", Value: 1}}},
+		{Keys: bson.D{{Key: "moderator_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("daily_views").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "day", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("blocks").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "blocker_id", Value: 1}, {Key: "blocked_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "blocked_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("mentions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("polls").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "post_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("poll_votes").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("series_entries").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "series_id", Value: 1}, {Key: "position", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("co_authors").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("daily_engagements").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("pins").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+type mongoUserRepository struct {
+	db    *mongo.Database
+	users *mongo.Collection
+}
+
+func newMongoUserRepository(db *mongo.Database) *mongoUserRepository {
+	return &mongoUserRepository{db: db, users: db.Collection("users")}
+}
+
+func (r *mongoUserRepository) List(ctx context.Context, opts ListOptions) ([]User, error) {
+	users := []User{}
+	err := mongoList(ctx, r.users, userFilter(opts), mongoSort(opts, bson.E{Key: "_id", Value: 1}), opts, &users)
+	return users, err
+}
+
+func (r *mongoUserRepository) Count(ctx context.Context, opts ListOptions) (int64, error) {
+	return r.users.CountDocuments(ctx, userFilter(opts))
+}
+
+func (r *mongoUserRepository) Get(ctx context.Context, id uint) (User, error) {
+	var user User
+	err := mongoGet(ctx, r.users, id, false, &user)
+	return user, err
+}
+
+func (r *mongoUserRepository) GetIncludingDeleted(ctx context.Context, id uint) (User, error) {
+	var user User
+	err := mongoGet(ctx, r.users, id, true, &user)
+	return user, err
+}
+
+func (r *mongoUserRepository) GetMany(ctx context.Context, ids []uint) ([]User, error) {
+	filter := listFilter(ListOptions{})
+	filter["_id"] = bson.M{"$in": ids}
+
+	users := []User{}
+	cursor, err := r.users.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &users)
+	return users, err
+}
+
+func (r *mongoUserRepository) GetByUsername(ctx context.Context, username string) (User, error) {
+	var user User
+	filter := bson.M{"username": username, "deleted_at.valid": bson.M{"$ne": true}}
+	err := r.users.FindOne(ctx, filter).Decode(&user)
+	return user, translateMongoError(err)
+}
+
+func (r *mongoUserRepository) GetByEmail(ctx context.Context, email string) (User, error) {
+	var user User
+	filter := bson.M{"email": email, "deleted_at.valid": bson.M{"$ne": true}}
+	err := r.users.FindOne(ctx, filter).Decode(&user)
+	return user, translateMongoError(err)
+}
+
+func (r *mongoUserRepository) GetByExternalID(ctx context.Context, externalID string) (User, error) {
+	var user User
+	filter := bson.M{"external_id": externalID, "deleted_at.valid": bson.M{"$ne": true}}
+	err := r.users.FindOne(ctx, filter).Decode(&user)
+	return user, translateMongoError(err)
+}
+
+func (r *mongoUserRepository) ResolveUUID(ctx context.Context, uuid string) (uint, error) {
+	return mongoResolveUUID(ctx, r.users, uuid)
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *User) error {
+	id, err := nextMongoID(ctx, r.db, "users")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.ID = id
+	user.UUID = newUUID()
+	user.Version = 1
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	_, err = r.users.InsertOne(ctx, user)
+	return translateMongoError(err)
+}
+
+func (r *mongoUserRepository) Update(ctx context.Context, user *User) error {
+	return mongoReplace(ctx, r.users, user.ID, &user.Version, &user.UpdatedAt, user)
+}
+
+func (r *mongoUserRepository) Delete(ctx context.Context, id uint) error {
+	return mongoSoftDelete(ctx, r.users, id)
+}
+
+func (r *mongoUserRepository) Restore(ctx context.Context, id uint) error {
+	return mongoRestore(ctx, r.users, id)
+}
+
+func (r *mongoUserRepository) ForceDelete(ctx context.Context, id uint) error {
+	return mongoForceDelete(ctx, r.users, id)
+}
+
+type mongoPostRepository struct {
+	db    *mongo.Database
+	posts *mongo.Collection
+}
+
+func newMongoPostRepository(db *mongo.Database) *mongoPostRepository {
+	return &mongoPostRepository{db: db, posts: db.Collection("posts")}
+}
+
+func (r *mongoPostRepository) List(ctx context.Context, opts ListOptions) ([]Post, error) {
+	filter := postFilter(opts)
+	direction, past := 1, "$gt"
+	if opts.Reverse {
+		direction, past = -1, "$lt"
+	}
+	if opts.After != nil {
+		filter["$or"] = bson.A{
+			bson.M{"created_at": bson.M{past: opts.After.CreatedAt}},
+			bson.M{"created_at": opts.After.CreatedAt, "_id": bson.M{past: opts.After.ID}},
+		}
+	}
+
+	posts := []Post{}
+	err := mongoList(ctx, r.posts, filter, mongoSort(opts, bson.E{Key: "created_at", Value: direction}, bson.E{Key: "_id", Value: direction}), opts, &posts)
+	return posts, err
+}
+
+func (r *mongoPostRepository) Count(ctx context.Context, opts ListOptions) (int64, error) {
+	return r.posts.CountDocuments(ctx, postFilter(opts))
+}
+
+func (r *mongoPostRepository) Get(ctx context.Context, id uint) (Post, error) {
+	var post Post
+	err := mongoGet(ctx, r.posts, id, false, &post)
+	return post, err
+}
+
+func (r *mongoPostRepository) GetIncludingDeleted(ctx context.Context, id uint) (Post, error) {
+	var post Post
+	err := mongoGet(ctx, r.posts, id, true, &post)
+	return post, err
+}
+
+func (r *mongoPostRepository) GetMany(ctx context.Context, ids []uint) ([]Post, error) {
+	filter := listFilter(ListOptions{})
+	filter["_id"] = bson.M{"$in": ids}
+
+	posts := []Post{}
+	cursor, err := r.posts.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &posts)
+	return posts, err
+}
+
+func (r *mongoPostRepository) ResolveUUID(ctx context.Context, uuid string) (uint, error) {
+	return mongoResolveUUID(ctx, r.posts, uuid)
+}
+
+func (r *mongoPostRepository) Create(ctx context.Context, post *Post) error {
+	id, err := nextMongoID(ctx, r.db, "posts")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	post.ID = id
+	post.UUID = newUUID()
+	post.Version = 1
+	post.CreatedAt = now
+	post.UpdatedAt = now
+
+	_, err = r.posts.InsertOne(ctx, post)
+	return translateMongoError(err)
+}
+
+func (r *mongoPostRepository) Update(ctx context.Context, post *Post) error {
+	return mongoReplace(ctx, r.posts, post.ID, &post.Version, &post.UpdatedAt, post)
+}
+
+func (r *mongoPostRepository) Delete(ctx context.Context, id uint) error {
+	return mongoSoftDelete(ctx, r.posts, id)
+}
+
+func (r *mongoPostRepository) DeleteMany(ctx context.Context, ids []uint) error {
+	filter := bson.M{"_id": bson.M{"$in": ids}, "deleted_at.valid": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"deleted_at": gorm.DeletedAt{Time: time.Now(), Valid: true}}}
+	_, err := r.posts.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *mongoPostRepository) Restore(ctx context.Context, id uint) error {
+	return mongoRestore(ctx, r.posts, id)
+}
+
+func (r *mongoPostRepository) ForceDelete(ctx context.Context, id uint) error {
+	return mongoForceDelete(ctx, r.posts, id)
+}
+
+type mongoRefreshTokenRepository struct {
+	db     *mongo.Database
+	tokens *mongo.Collection
+}
+
+func newMongoRefreshTokenRepository(db *mongo.Database) *mongoRefreshTokenRepository {
+	return &mongoRefreshTokenRepository{db: db, tokens: db.Collection("refresh_tokens")}
+}
+
+func (r *mongoRefreshTokenRepository) Create(ctx context.Context, token *RefreshToken) error {
+	id, err := nextMongoID(ctx, r.db, "refresh_tokens")
+	if err != nil {
+		return err
+	}
+	token.ID = id
+	token.CreatedAt = time.Now()
+
+	_, err = r.tokens.InsertOne(ctx, token)
+	return translateMongoError(err)
+}
+
+func (r *mongoRefreshTokenRepository) GetByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	var token RefreshToken
+	err := r.tokens.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&token)
+	return token, translateMongoError(err)
+}
+
+func (r *mongoRefreshTokenRepository) MarkUsed(ctx context.Context, id uint) error {
+	result, err := r.tokens.UpdateOne(ctx,
+		bson.M{"_id": id, "used_at": nil},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *mongoRefreshTokenRepository) RevokeUser(ctx context.Context, userID uint) error {
+	_, err := r.tokens.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *mongoRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := r.tokens.UpdateMany(ctx,
+		bson.M{"family_id": familyID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+type mongoAPIKeyRepository struct {
+	db   *mongo.Database
+	keys *mongo.Collection
+}
+
+func newMongoAPIKeyRepository(db *mongo.Database) *mongoAPIKeyRepository {
+	return &mongoAPIKeyRepository{db: db, keys: db.Collection("api_keys")}
+}
+
+func (r *mongoAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	id, err := nextMongoID(ctx, r.db, "api_keys")
+	if err != nil {
+		return err
+	}
+	key.ID = id
+	key.CreatedAt = time.Now()
+
+	_, err = r.keys.InsertOne(ctx, key)
+	return translateMongoError(err)
+}
+
+func (r *mongoAPIKeyRepository) ListByUser(ctx context.Context, userID uint) ([]APIKey, error) {
+	keys := []APIKey{}
+	cursor, err := r.keys.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &keys)
+	return keys, err
+}
+
+func (r *mongoAPIKeyRepository) GetByHash(ctx context.Context, hash string) (APIKey, error) {
+	var key APIKey
+	err := r.keys.FindOne(ctx, bson.M{"key_hash": hash}).Decode(&key)
+	return key, translateMongoError(err)
+}
+
+func (r *mongoAPIKeyRepository) Revoke(ctx context.Context, id, userID uint) error {
+	result, err := r.keys.UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoAPIKeyRepository) Touch(ctx context.Context, id uint) error {
+	_, err := r.keys.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": time.Now()}})
+	return err
+}
+
+type mongoOneTimeTokenRepository struct {
+	db     *mongo.Database
+	tokens *mongo.Collection
+}
+
+func newMongoOneTimeTokenRepository(db *mongo.Database) *mongoOneTimeTokenRepository {
+	return &mongoOneTimeTokenRepository{db: db, tokens: db.Collection("one_time_tokens")}
+}
+
+func (r *mongoOneTimeTokenRepository) Create(ctx context.Context, token *OneTimeToken) error {
+	id, err := nextMongoID(ctx, r.db, "one_time_tokens")
+	if err != nil {
+		return err
+	}
+	token.ID = id
+	token.CreatedAt = time.Now()
+
+	_, err = r.tokens.InsertOne(ctx, token)
+	return translateMongoError(err)
+}
+
+func (r *mongoOneTimeTokenRepository) Consume(ctx context.Context, purpose, hash string) (OneTimeToken, error) {
+	now := time.Now()
+	var token OneTimeToken
+	err := r.tokens.FindOneAndUpdate(ctx,
+		bson.M{"token_hash": hash, "purpose": purpose, "used_at": nil, "expires_at": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&token)
+	return token, translateMongoError(err)
+}
+
+type mongoReactionRepository struct {
+	db        *mongo.Database
+	reactions *mongo.Collection
+}
+
+func newMongoReactionRepository(db *mongo.Database) *mongoReactionRepository {
+	return &mongoReactionRepository{db: db, reactions: db.Collection("reactions")}
+}
+
+func (r *mongoReactionRepository) Add(ctx context.Context, reaction *Reaction) (bool, error) {
+	id, err := nextMongoID(ctx, r.db, "reactions")
+	if err != nil {
+		return false, err
+	}
+	reaction.ID = id
+	reaction.CreatedAt = time.Now()
+
+	_, err = r.reactions.InsertOne(ctx, reaction)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *mongoReactionRepository) Remove(ctx context.Context, postID, userID uint, reactionType string) (bool, error) {
+	result, err := r.reactions.DeleteOne(ctx, bson.M{"post_id": postID, "user_id": userID, "type": reactionType})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *mongoReactionRepository) List(ctx context.Context, postID uint, reactionType string, opts ListOptions) ([]Reaction, error) {
+	reactions := []Reaction{}
+	err := mongoList(ctx, r.reactions, reactionFilter(postID, reactionType), bson.D{{Key: "_id", Value: 1}}, opts, &reactions)
+	return reactions, err
+}
+
+func (r *mongoReactionRepository) Count(ctx context.Context, postID uint, reactionType string) (int64, error) {
+	return r.reactions.CountDocuments(ctx, reactionFilter(postID, reactionType))
+}
+
+func (r *mongoReactionRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]map[string]int64, error) {
+	cursor, err := r.reactions.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"post_id": bson.M{"$in": postIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"post_id": "$post_id", "type": "$type"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Key struct {
+			PostID uint   `bson:"post_id"`
+			Type   string `bson:"type"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]map[string]int64)
+	for _, row := range rows {
+		if counts[row.Key.PostID] == nil {
+			counts[row.Key.PostID] = make(map[string]int64)
+		}
+		counts[row.Key.PostID][row.Key.Type] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *mongoReactionRepository) Recent(ctx context.Context, since time.Time) ([]Reaction, error) {
+	reactions := []Reaction{}
+	err := mongoList(ctx, r.reactions, bson.M{"created_at": bson.M{"$gte": since}}, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &reactions)
+	return reactions, err
+}
+
+// reactionFilter matches a post's reactions of reactionType, or of any type
+// if it is empty.
+func reactionFilter(postID uint, reactionType string) bson.M {
+	filter := bson.M{"post_id": postID}
+	if reactionType != "" {
+		filter["type"] = reactionType
+	}
+	return filter
+}
+
+type mongoFollowRepository struct {
+	db      *mongo.Database
+	follows *mongo.Collection
+}
+
+func newMongoFollowRepository(db *mongo.Database) *mongoFollowRepository {
+	return &mongoFollowRepository{db: db, follows: db.Collection("follows")}
+}
+
+func (r *mongoFollowRepository) Add(ctx context.Context, follow *Follow) error {
+	id, err := nextMongoID(ctx, r.db, "follows")
+	if err != nil {
+		return err
+	}
+	follow.ID = id
+	follow.CreatedAt = time.Now()
+
+	_, err = r.follows.InsertOne(ctx, follow)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *mongoFollowRepository) Remove(ctx context.Context, followerID, followeeID uint) error {
+	_, err := r.follows.DeleteOne(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID})
+	return err
+}
+
+func (r *mongoFollowRepository) Followers(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error) {
+	follows := []Follow{}
+	err := mongoList(ctx, r.follows, bson.M{"followee_id": userID}, bson.D{{Key: "_id", Value: 1}}, opts, &follows)
+	return follows, err
+}
+
+func (r *mongoFollowRepository) Following(ctx context.Context, userID uint, opts ListOptions) ([]Follow, error) {
+	follows := []Follow{}
+	err := mongoList(ctx, r.follows, bson.M{"follower_id": userID}, bson.D{{Key: "_id", Value: 1}}, opts, &follows)
+	return follows, err
+}
+
+func (r *mongoFollowRepository) Counts(ctx context.Context, userIDs []uint) (followers, following map[uint]int64, err error) {
+	if followers, err = r.countBy(ctx, "followee_id", userIDs); err != nil {
+		return nil, nil, err
+	}
+	if following, err = r.countBy(ctx, "follower_id", userIDs); err != nil {
+		return nil, nil, err
+	}
+	return followers, following, nil
+}
+
+// countBy counts follows grouped by field, one of follower_id or
+// followee_id, for the users in ids.
+func (r *mongoFollowRepository) countBy(ctx context.Context, field string, ids []uint) (map[uint]int64, error) {
+	cursor, err := r.follows.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{field: bson.M{"$in": ids}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		UserID uint  `bson:"_id"`
+		Count  int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts, nil
+}
+
+type mongoBookmarkRepository struct {
+	db        *mongo.Database
+	bookmarks *mongo.Collection
+}
+
+func newMongoBookmarkRepository(db *mongo.Database) *mongoBookmarkRepository {
+	return &mongoBookmarkRepository{db: db, bookmarks: db.Collection("bookmarks")}
+}
+
+func (r *mongoBookmarkRepository) Add(ctx context.Context, bookmark *Bookmark) (bool, error) {
+	id, err := nextMongoID(ctx, r.db, "bookmarks")
+	if err != nil {
+		return false, err
+	}
+	bookmark.ID = id
+	bookmark.CreatedAt = time.Now()
+
+	_, err = r.bookmarks.InsertOne(ctx, bookmark)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *mongoBookmarkRepository) Remove(ctx context.Context, userID, postID uint) (bool, error) {
+	result, err := r.bookmarks.DeleteOne(ctx, bson.M{"user_id": userID, "post_id": postID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *mongoBookmarkRepository) List(ctx context.Context, userID uint, opts ListOptions) ([]Bookmark, error) {
+	bookmarks := []Bookmark{}
+	err := mongoList(ctx, r.bookmarks, bson.M{"user_id": userID}, bson.D{{Key: "_id", Value: -1}}, opts, &bookmarks)
+	return bookmarks, err
+}
+
+func (r *mongoBookmarkRepository) Count(ctx context.Context, userID uint) (int64, error) {
+	return r.bookmarks.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+func (r *mongoBookmarkRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error) {
+	cursor, err := r.bookmarks.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"post_id": bson.M{"$in": postIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$post_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		PostID uint  `bson:"_id"`
+		Count  int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, nil
+}
+
+type mongoMessageRepository struct {
+	db       *mongo.Database
+	messages *mongo.Collection
+}
+
+func newMongoMessageRepository(db *mongo.Database) *mongoMessageRepository {
+	return &mongoMessageRepository{db: db, messages: db.Collection("messages")}
+}
+
+func (r *mongoMessageRepository) Create(ctx context.Context, message *Message) error {
+	id, err := nextMongoID(ctx, r.db, "messages")
+	if err != nil {
+		return err
+	}
+	message.ID = id
+	message.CreatedAt = time.Now()
+
+	_, err = r.messages.InsertOne(ctx, message)
+	return err
+}
+
+func (r *mongoMessageRepository) Thread(ctx context.Context, conversation string, opts ListOptions) ([]Message, error) {
+	messages := []Message{}
+	err := mongoList(ctx, r.messages, bson.M{"conversation": conversation}, bson.D{{Key: "_id", Value: -1}}, opts, &messages)
+	return messages, err
+}
+
+func (r *mongoMessageRepository) CountThread(ctx context.Context, conversation string) (int64, error) {
+	return r.messages.CountDocuments(ctx, bson.M{"conversation": conversation})
+}
+
+// participant matches the messages userID sent or received.
+func participant(userID uint) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"sender_id": userID}, bson.M{"recipient_id": userID}}}
+}
+
+func (r *mongoMessageRepository) Conversations(ctx context.Context, userID uint, opts ListOptions) ([]Message, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: participant(userID)}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$conversation", "latest": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$latest"}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: -1}}}},
+	}
+	if opts.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: opts.Offset}})
+	}
+	if opts.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: opts.Limit}})
+	}
+
+	cursor, err := r.messages.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	messages := []Message{}
+	err = cursor.All(ctx, &messages)
+	return messages, err
+}
+
+func (r *mongoMessageRepository) CountConversations(ctx context.Context, userID uint) (int64, error) {
+	conversations, err := r.messages.Distinct(ctx, "conversation", participant(userID))
+	return int64(len(conversations)), err
+}
+
+func (r *mongoMessageRepository) MarkRead(ctx context.Context, recipientID, senderID uint) error {
+	_, err := r.messages.UpdateMany(ctx,
+		bson.M{"recipient_id": recipientID, "sender_id": senderID, "read_at": nil},
+		bson.M{"$set": bson.M{"read_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *mongoMessageRepository) Unread(ctx context.Context, userID uint) (map[uint]int64, error) {
+	cursor, err := r.messages.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"recipient_id": userID, "read_at": nil}}},
+		{{Key: "$group", Value: bson.M{"_id": "$sender_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		SenderID uint  `bson:"_id"`
+		Count    int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	unread := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		unread[row.SenderID] = row.Count
+	}
+	return unread, nil
+}
+
+type mongoMediaRepository struct {
+	db    *mongo.Database
+	media *mongo.Collection
+}
+
+func newMongoMediaRepository(db *mongo.Database) *mongoMediaRepository {
+	return &mongoMediaRepository{db: db, media: db.Collection("media")}
+}
+
+func (r *mongoMediaRepository) Create(ctx context.Context, media *Media) error {
+	id, err := nextMongoID(ctx, r.db, "media")
+	if err != nil {
+		return err
+	}
+	media.ID = id
+	media.CreatedAt = time.Now()
+
+	_, err = r.media.InsertOne(ctx, media)
+	return err
+}
+
+func (r *mongoMediaRepository) Get(ctx context.Context, id uint) (Media, error) {
+	var media Media
+	err := r.media.FindOne(ctx, bson.M{"_id": id}).Decode(&media)
+	return media, translateMongoError(err)
+}
+
+func (r *mongoMediaRepository) ListByPost(ctx context.Context, postID uint) ([]Media, error) {
+	media := []Media{}
+	sort := bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}}
+	err := mongoList(ctx, r.media, bson.M{"post_id": postID}, sort, ListOptions{}, &media)
+	return media, err
+}
+
+func (r *mongoMediaRepository) Update(ctx context.Context, media *Media) error {
+	result, err := r.media.UpdateOne(ctx,
+		bson.M{"_id": media.ID},
+		bson.M{"$set": bson.M{"alt_text": media.AltText, "position": media.Position}},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *mongoMediaRepository) Delete(ctx context.Context, id uint) error {
+	result, err := r.media.DeleteOne(ctx, bson.M{"_id": id})
+	if err == nil && result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+type mongoActivityRepository struct {
+	db       *mongo.Database
+	activity *mongo.Collection
+}
+
+func newMongoActivityRepository(db *mongo.Database) *mongoActivityRepository {
+	return &mongoActivityRepository{db: db, activity: db.Collection("activities")}
+}
+
+func (r *mongoActivityRepository) Record(ctx context.Context, activity *Activity) error {
+	id, err := nextMongoID(ctx, r.db, "activities")
+	if err != nil {
+		return err
+	}
+	activity.ID = id
+	activity.CreatedAt = time.Now()
+
+	_, err = r.activity.InsertOne(ctx, activity)
+	return err
+}
+
+func (r *mongoActivityRepository) Remove(ctx context.Context, activity Activity) error {
+	_, err := r.activity.DeleteMany(ctx, bson.M{
+		"user_id":        activity.UserID,
+		"type":           activity.Type,
+		"post_id":        activity.PostID,
+		"target_user_id": activity.TargetUserID,
+		"reaction":       activity.Reaction,
+	})
+	return err
+}
+
+func (r *mongoActivityRepository) List(ctx context.Context, userID uint, opts ListOptions) ([]Activity, error) {
+	activity := []Activity{}
+	err := mongoList(ctx, r.activity, bson.M{"user_id": userID}, bson.D{{Key: "_id", Value: -1}}, opts, &activity)
+	return activity, err
+}
+
+func (r *mongoActivityRepository) Count(ctx context.Context, userID uint) (int64, error) {
+	return r.activity.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+type mongoReportRepository struct {
+	db      *mongo.Database
+	reports *mongo.Collection
+}
+
+func newMongoReportRepository(db *mongo.Database) *mongoReportRepository {
+	return &mongoReportRepository{db: db, reports: db.Collection("reports")}
+}
+
+func (r *mongoReportRepository) Create(ctx context.Context, report *Report) error {
+	id, err := nextMongoID(ctx, r.db, "reports")
+	if err != nil {
+		return err
+	}
+	report.ID = id
+	report.CreatedAt = time.Now()
+
+	_, err = r.reports.InsertOne(ctx, report)
+	return translateMongoError(err)
+}
+
+func (r *mongoReportRepository) Summaries(ctx context.Context, open bool, opts ListOptions) ([]ReportSummary, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: reportFilter(open)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$post_id",
+			"count":   bson.M{"$sum": 1},
+			"latest":  bson.M{"$max": "$_id"},
+			"reasons": bson.M{"$push": "$reason"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "latest", Value: -1}}}},
+	}
+	if opts.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: opts.Offset}})
+	}
+	if opts.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: opts.Limit}})
+	}
+
+	cursor, err := r.reports.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		PostID  uint     `bson:"_id"`
+		Count   int64    `bson:"count"`
+		Reasons []string `bson:"reasons"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]ReportSummary, len(rows))
+	for i, row := range rows {
+		summaries[i] = ReportSummary{PostID: row.PostID, Count: row.Count, Reasons: map[string]int64{}}
+		for _, reason := range row.Reasons {
+			summaries[i].Reasons[reason]++
+		}
+	}
+	return summaries, nil
+}
+
+func (r *mongoReportRepository) CountSummaries(ctx context.Context, open bool) (int64, error) {
+	posts, err := r.reports.Distinct(ctx, "post_id", reportFilter(open))
+	return int64(len(posts)), err
+}
+
+func (r *mongoReportRepository) Resolve(ctx context.Context, postID uint) error {
+	_, err := r.reports.UpdateMany(ctx,
+		bson.M{"post_id": postID, "resolved_at": nil},
+		bson.M{"$set": bson.M{"resolved_at": time.Now()}},
+	)
+	return err
+}
+
+// reportFilter matches the open reports if open is set, or all of them.
+func reportFilter(open bool) bson.M {
+	if open {
+		return bson.M{"resolved_at": nil}
+	}
+	return bson.M{}
+}
+
+type mongoModerationRepository struct {
+	db        *mongo.Database
+	decisions *mongo.Collection
+}
+
+func newMongoModerationRepository(db *mongo.Database) *mongoModerationRepository {
+	return &mongoModerationRepository{db: db, decisions: db.Collection("moderation_decisions")}
+}
+
+func (r *mongoModerationRepository) Create(ctx context.Context, decision *ModerationDecision) error {
+	id, err := nextMongoID(ctx, r.db, "moderation_decisions")
+	if err != nil {
+		return err
+	}
+	decision.ID = id
+	decision.CreatedAt = time.Now()
+
+	_, err = r.decisions.InsertOne(ctx, decision)
+	return err
+}
+
+func (r *mongoModerationRepository) List(ctx context.Context, filter ModerationFilter, opts ListOptions) ([]ModerationDecision, error) {
+	decisions := []ModerationDecision{}
+	err := mongoList(ctx, r.decisions, moderationFilter(filter), bson.D{{Key: "_id", Value: -1}}, opts, &decisions)
+	return decisions, err
+}
+
+func (r *mongoModerationRepository) Count(ctx context.Context, filter ModerationFilter) (int64, error) {
+	return r.decisions.CountDocuments(ctx, moderationFilter(filter))
+}
+
+// moderationFilter matches the decisions matching filter.
+func moderationFilter(filter ModerationFilter) bson.M {
+	query := bson.M{}
+	if filter.PostID != 0 {
+		query["post_id"] = filter.PostID
+	}
+	if filter.ModeratorID != 0 {
+		query["moderator_id"] = filter.ModeratorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	return query
+}
+
+type mongoViewRepository struct {
+	views *mongo.Collection
+}
+
+func newMongoViewRepository(db *mongo.Database) *mongoViewRepository {
+	return &mongoViewRepository{views: db.Collection("daily_views")}
+}
+
+func (r *mongoViewRepository) Record(ctx context.Context, postID uint, day string) error {
+	_, err := r.views.UpdateOne(ctx,
+		bson.M{"post_id": postID, "day": day},
+		bson.M{"$inc": bson.M{"views": 1}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *mongoViewRepository) Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error) {
+	cursor, err := r.views.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"post_id": bson.M{"$in": postIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$post_id", "views": bson.M{"$sum": "$views"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		PostID uint  `bson:"_id"`
+		Views  int64 `bson:"views"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PostID] = row.Views
+	}
+	return counts, nil
+}
+
+func (r *mongoViewRepository) Daily(ctx context.Context, postID uint, from string) ([]DailyViews, error) {
+	daily := []DailyViews{}
+	err := mongoList(ctx, r.views, bson.M{"post_id": postID, "day": bson.M{"$gte": from}}, bson.D{{Key: "day", Value: 1}}, ListOptions{}, &daily)
+	return daily, err
+}
+
+func (r *mongoViewRepository) Recent(ctx context.Context, from string) ([]DailyViews, error) {
+	daily := []DailyViews{}
+	err := mongoList(ctx, r.views, bson.M{"day": bson.M{"$gte": from}}, bson.D{{Key: "day", Value: 1}}, ListOptions{}, &daily)
+	return daily, err
+}
+
+type mongoBlockRepository struct {
+	db     *mongo.Database
+	blocks *mongo.Collection
+}
+
+func newMongoBlockRepository(db *mongo.Database) *mongoBlockRepository {
+	return &mongoBlockRepository{db: db, blocks: db.Collection("blocks")}
+}
+
+func (r *mongoBlockRepository) Add(ctx context.Context, block *Block) error {
+	id, err := nextMongoID(ctx, r.db, "blocks")
+	if err != nil {
+		return err
+	}
+	block.ID = id
+	block.CreatedAt = time.Now()
+
+	_, err = r.blocks.InsertOne(ctx, block)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *mongoBlockRepository) Remove(ctx context.Context, blockerID, blockedID uint) error {
+	_, err := r.blocks.DeleteOne(ctx, bson.M{"blocker_id": blockerID, "blocked_id": blockedID})
+	return err
+}
+
+func (r *mongoBlockRepository) List(ctx context.Context, blockerID uint, opts ListOptions) ([]Block, error) {
+	blocks := []Block{}
+	err := mongoList(ctx, r.blocks, bson.M{"blocker_id": blockerID}, bson.D{{Key: "_id", Value: -1}}, opts, &blocks)
+	return blocks, err
+}
+
+func (r *mongoBlockRepository) Count(ctx context.Context, blockerID uint) (int64, error) {
+	return r.blocks.CountDocuments(ctx, bson.M{"blocker_id": blockerID})
+}
+
+func (r *mongoBlockRepository) Blocked(ctx context.Context, blockerID uint) ([]uint, error) {
+	blocks := []Block{}
+	if err := mongoList(ctx, r.blocks, bson.M{"blocker_id": blockerID}, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &blocks); err != nil {
+		return nil, err
+	}
+	blocked := make([]uint, len(blocks))
+	for i, block := range blocks {
+		blocked[i] = block.BlockedID
+	}
+	return blocked, nil
+}
+
+func (r *mongoBlockRepository) Between(ctx context.Context, a, b uint) (bool, error) {
+	count, err := r.blocks.CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"blocker_id": a, "blocked_id": b},
+		bson.M{"blocker_id": b, "blocked_id": a},
+	}})
+	return count > 0, err
+}
+
+type mongoMentionRepository struct {
+	db       *mongo.Database
+	mentions *mongo.Collection
+}
+
+func newMongoMentionRepository(db *mongo.Database) *mongoMentionRepository {
+	return &mongoMentionRepository{db: db, mentions: db.Collection("mentions")}
+}
+
+// Set records no events: the MongoDB driver has no outbox.
+func (r *mongoMentionRepository) Set(ctx context.Context, post Post, userIDs, notify []uint) error {
+	_, err := r.mentions.DeleteMany(ctx, bson.M{"post_id": post.ID, "user_id": bson.M{"$nin": userIDs}})
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		count, err := r.mentions.CountDocuments(ctx, bson.M{"post_id": post.ID, "user_id": userID})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		id, err := nextMongoID(ctx, r.db, "mentions")
+		if err != nil {
+			return err
+		}
+		_, err = r.mentions.InsertOne(ctx, Mention{ID: id, PostID: post.ID, UserID: userID, CreatedAt: time.Now()})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *mongoMentionRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error) {
+	mentions := []Mention{}
+	err := mongoList(ctx, r.mentions, bson.M{"post_id": bson.M{"$in": postIDs}}, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &mentions)
+	if err != nil {
+		return nil, err
+	}
+
+	mentioned := make(map[uint][]uint)
+	for _, mention := range mentions {
+		mentioned[mention.PostID] = append(mentioned[mention.PostID], mention.UserID)
+	}
+	return mentioned, nil
+}
+
+type mongoPollRepository struct {
+	db    *mongo.Database
+	polls *mongo.Collection
+	votes *mongo.Collection
+}
+
+func newMongoPollRepository(db *mongo.Database) *mongoPollRepository {
+	return &mongoPollRepository{db: db, polls: db.Collection("polls"), votes: db.Collection("poll_votes")}
+}
+
+func (r *mongoPollRepository) Get(ctx context.Context, postID uint) (Poll, error) {
+	var poll Poll
+	err := r.polls.FindOne(ctx, bson.M{"post_id": postID}).Decode(&poll)
+	return poll, translateMongoError(err)
+}
+
+func (r *mongoPollRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint]Poll, error) {
+	found := []Poll{}
+	if err := mongoList(ctx, r.polls, bson.M{"post_id": bson.M{"$in": postIDs}}, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &found); err != nil {
+		return nil, err
+	}
+
+	polls := make(map[uint]Poll, len(found))
+	for _, poll := range found {
+		polls[poll.PostID] = poll
+	}
+	return polls, nil
+}
+
+func (r *mongoPollRepository) Save(ctx context.Context, poll *Poll) error {
+	id, err := nextMongoID(ctx, r.db, "polls")
+	if err != nil {
+		return err
+	}
+	poll.ID = id
+	poll.CreatedAt = time.Now()
+
+	if _, err := r.polls.DeleteOne(ctx, bson.M{"post_id": poll.PostID}); err != nil {
+		return err
+	}
+	_, err = r.polls.InsertOne(ctx, poll)
+	return err
+}
+
+func (r *mongoPollRepository) Delete(ctx context.Context, postID uint) error {
+	result, err := r.polls.DeleteOne(ctx, bson.M{"post_id": postID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	_, err = r.votes.DeleteMany(ctx, bson.M{"post_id": postID})
+	return err
+}
+
+func (r *mongoPollRepository) Vote(ctx context.Context, vote *PollVote) error {
+	id, err := nextMongoID(ctx, r.db, "poll_votes")
+	if err != nil {
+		return err
+	}
+	vote.ID = id
+	vote.CreatedAt = time.Now()
+
+	_, err = r.votes.InsertOne(ctx, vote)
+	return translateMongoError(err)
+}
+
+func (r *mongoPollRepository) Tallies(ctx context.Context, postIDs []uint) (map[uint]map[int]int64, error) {
+	cursor, err := r.votes.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"post_id": bson.M{"$in": postIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"post_id": "$post_id", "choice": "$choice"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Key struct {
+			PostID uint `bson:"post_id"`
+			Choice int  `bson:"choice"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	tallies := make(map[uint]map[int]int64)
+	for _, row := range rows {
+		if tallies[row.Key.PostID] == nil {
+			tallies[row.Key.PostID] = make(map[int]int64)
+		}
+		tallies[row.Key.PostID][row.Key.Choice] = row.Count
+	}
+	return tallies, nil
+}
+
+func (r *mongoPollRepository) VotesBy(ctx context.Context, userID uint, postIDs []uint) (map[uint]int, error) {
+	found := []PollVote{}
+	filter := bson.M{"user_id": userID, "post_id": bson.M{"$in": postIDs}}
+	if err := mongoList(ctx, r.votes, filter, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &found); err != nil {
+		return nil, err
+	}
+
+	votes := make(map[uint]int, len(found))
+	for _, vote := range found {
+		votes[vote.PostID] = vote.Choice
+	}
+	return votes, nil
+}
+
+type mongoSeriesRepository struct {
+	db      *mongo.Database
+	series  *mongo.Collection
+	entries *mongo.Collection
+}
+
+func newMongoSeriesRepository(db *mongo.Database) *mongoSeriesRepository {
+	return &mongoSeriesRepository{db: db, series: db.Collection("series"), entries: db.Collection("series_entries")}
+}
+
+func (r *mongoSeriesRepository) Create(ctx context.Context, series *Series) error {
+	id, err := nextMongoID(ctx, r.db, "series")
+	if err != nil {
+		return err
+	}
+	series.ID = id
+	series.CreatedAt = time.Now()
+
+	_, err = r.series.InsertOne(ctx, series)
+	return err
+}
+
+func (r *mongoSeriesRepository) Get(ctx context.Context, id uint) (Series, error) {
+	var series Series
+	err := r.series.FindOne(ctx, bson.M{"_id": id}).Decode(&series)
+	return series, translateMongoError(err)
+}
+
+func (r *mongoSeriesRepository) GetMany(ctx context.Context, ids []uint) ([]Series, error) {
+	found := []Series{}
+	err := mongoList(ctx, r.series, bson.M{"_id": bson.M{"$in": ids}}, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &found)
+	return found, err
+}
+
+func (r *mongoSeriesRepository) Delete(ctx context.Context, id uint) error {
+	result, err := r.series.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	_, err = r.entries.DeleteMany(ctx, bson.M{"series_id": id})
+	return err
+}
+
+func (r *mongoSeriesRepository) SetPosts(ctx context.Context, seriesID uint, postIDs []uint) error {
+	taken, err := r.entries.CountDocuments(ctx, bson.M{
+		"post_id":   bson.M{"$in": postIDs},
+		"series_id": bson.M{"$ne": seriesID},
+	})
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrConflict
+	}
+	if _, err := r.entries.DeleteMany(ctx, bson.M{"series_id": seriesID}); err != nil {
+		return err
+	}
+	if len(postIDs) == 0 {
+		return nil
+	}
+	entries := make([]interface{}, len(postIDs))
+	for i, postID := range postIDs {
+		entries[i] = SeriesEntry{SeriesID: seriesID, PostID: postID, Position: i}
+	}
+	_, err = r.entries.InsertMany(ctx, entries)
+	return translateMongoError(err)
+}
+
+func (r *mongoSeriesRepository) Posts(ctx context.Context, seriesID uint) ([]uint, error) {
+	entries := []SeriesEntry{}
+	err := mongoList(ctx, r.entries, bson.M{"series_id": seriesID}, bson.D{{Key: "position", Value: 1}}, ListOptions{}, &entries)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.PostID
+	}
+	return ids, nil
+}
+
+func (r *mongoSeriesRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint]uint, error) {
+	entries := []SeriesEntry{}
+	err := mongoList(ctx, r.entries, bson.M{"post_id": bson.M{"$in": postIDs}}, bson.D{{Key: "post_id", Value: 1}}, ListOptions{}, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships := make(map[uint]uint, len(entries))
+	for _, entry := range entries {
+		memberships[entry.PostID] = entry.SeriesID
+	}
+	return memberships, nil
+}
+
+type mongoCoAuthorRepository struct {
+	db        *mongo.Database
+	coAuthors *mongo.Collection
+}
+
+func newMongoCoAuthorRepository(db *mongo.Database) *mongoCoAuthorRepository {
+	return &mongoCoAuthorRepository{db: db, coAuthors: db.Collection("co_authors")}
+}
+
+func (r *mongoCoAuthorRepository) Add(ctx context.Context, coAuthor *CoAuthor) error {
+	id, err := nextMongoID(ctx, r.db, "co_authors")
+	if err != nil {
+		return err
+	}
+	coAuthor.ID = id
+	coAuthor.CreatedAt = time.Now()
+
+	_, err = r.coAuthors.InsertOne(ctx, coAuthor)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *mongoCoAuthorRepository) Remove(ctx context.Context, postID, userID uint) error {
+	_, err := r.coAuthors.DeleteOne(ctx, bson.M{"post_id": postID, "user_id": userID})
+	return err
+}
+
+func (r *mongoCoAuthorRepository) ForPosts(ctx context.Context, postIDs []uint) (map[uint][]uint, error) {
+	found := []CoAuthor{}
+	err := mongoList(ctx, r.coAuthors, bson.M{"post_id": bson.M{"$in": postIDs}}, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &found)
+	if err != nil {
+		return nil, err
+	}
+
+	coAuthors := make(map[uint][]uint)
+	for _, coAuthor := range found {
+		coAuthors[coAuthor.PostID] = append(coAuthors[coAuthor.PostID], coAuthor.UserID)
+	}
+	return coAuthors, nil
+}
+
+func (r *mongoCoAuthorRepository) Is(ctx context.Context, postID, userID uint) (bool, error) {
+	count, err := r.coAuthors.CountDocuments(ctx, bson.M{"post_id": postID, "user_id": userID})
+	return count > 0, err
+}
+
+type mongoEngagementRepository struct {
+	engagement *mongo.Collection
+}
+
+func newMongoEngagementRepository(db *mongo.Database) *mongoEngagementRepository {
+	return &mongoEngagementRepository{engagement: db.Collection("daily_engagements")}
+}
+
+func (r *mongoEngagementRepository) Record(ctx context.Context, postID uint, day, counter string, delta int64) error {
+	_, err := r.engagement.UpdateOne(ctx,
+		bson.M{"post_id": postID, "day": day},
+		bson.M{"$inc": bson.M{counter: delta}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *mongoEngagementRepository) Daily(ctx context.Context, postID uint, from string) ([]DailyEngagement, error) {
+	daily := []DailyEngagement{}
+	err := mongoList(ctx, r.engagement, bson.M{"post_id": postID, "day": bson.M{"$gte": from}}, bson.D{{Key: "day", Value: 1}}, ListOptions{}, &daily)
+	return daily, err
+}
+
+type mongoSettingsRepository struct {
+	settings *mongo.Collection
+}
+
+func newMongoSettingsRepository(db *mongo.Database) *mongoSettingsRepository {
+	return &mongoSettingsRepository{settings: db.Collection("settings")}
+}
+
+func (r *mongoSettingsRepository) Get(ctx context.Context, userID uint) (Settings, error) {
+	var settings Settings
+	err := r.settings.FindOne(ctx, bson.M{"_id": userID}).Decode(&settings)
+	return settings, translateMongoError(err)
+}
+
+func (r *mongoSettingsRepository) Save(ctx context.Context, settings *Settings) error {
+	settings.UpdatedAt = time.Now()
+	_, err := r.settings.ReplaceOne(ctx, bson.M{"_id": settings.UserID}, settings, options.Replace().SetUpsert(true))
+	return err
+}
+
+type mongoPinRepository struct {
+	db   *mongo.Database
+	pins *mongo.Collection
+}
+
+func newMongoPinRepository(db *mongo.Database) *mongoPinRepository {
+	return &mongoPinRepository{db: db, pins: db.Collection("pins")}
+}
+
+func (r *mongoPinRepository) Add(ctx context.Context, pin *Pin) error {
+	id, err := nextMongoID(ctx, r.db, "pins")
+	if err != nil {
+		return err
+	}
+	pin.ID = id
+	pin.CreatedAt = time.Now()
+
+	_, err = r.pins.InsertOne(ctx, pin)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *mongoPinRepository) Remove(ctx context.Context, postID uint) error {
+	_, err := r.pins.DeleteOne(ctx, bson.M{"post_id": postID})
+	return err
+}
+
+func (r *mongoPinRepository) List(ctx context.Context, userID uint) ([]uint, error) {
+	pins := []Pin{}
+	if err := mongoList(ctx, r.pins, bson.M{"user_id": userID}, bson.D{{Key: "_id", Value: -1}}, ListOptions{}, &pins); err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(pins))
+	for i, pin := range pins {
+		ids[i] = pin.PostID
+	}
+	return ids, nil
+}
+
+func (r *mongoPinRepository) Pinned(ctx context.Context, postIDs []uint) (map[uint]bool, error) {
+	pins := []Pin{}
+	if err := mongoList(ctx, r.pins, bson.M{"post_id": bson.M{"$in": postIDs}}, bson.D{{Key: "_id", Value: 1}}, ListOptions{}, &pins); err != nil {
+		return nil, err
+	}
+	pinned := make(map[uint]bool, len(pins))
+	for _, pin := range pins {
+		pinned[pin.PostID] = true
+	}
+	return pinned, nil
+}
+
+type mongoAuditRepository struct {
+	db     *mongo.Database
+	events *mongo.Collection
+}
+
+func newMongoAuditRepository(db *mongo.Database) *mongoAuditRepository {
+	return &mongoAuditRepository{db: db, events: db.Collection("audit_events")}
+}
+
+func (r *mongoAuditRepository) Append(ctx context.Context, event *AuditEvent) error {
+	id, err := nextMongoID(ctx, r.db, "audit_events")
+	if err != nil {
+		return err
+	}
+	event.ID = id
+	event.CreatedAt = time.Now()
+
+	_, err = r.events.InsertOne(ctx, event)
+	return err
+}
+
+func (r *mongoAuditRepository) List(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	filter := bson.M{}
+	created := bson.M{}
+	if !query.From.IsZero() {
+		created["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		created["$lte"] = query.To
+	}
+	if len(created) > 0 {
+		filter["created_at"] = created
+	}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+	if query.UserID != nil {
+		filter["user_id"] = *query.UserID
+	}
+	if query.Route != "" {
+		filter["route"] = query.Route
+	}
+	if query.EntityID != "" {
+		filter["entity_id"] = query.EntityID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+	cursor, err := r.events.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := []AuditEvent{}
+	err = cursor.All(ctx, &events)
+	return events, err
+}
+
+// nextMongoID allocates the next sequential ID for the named collection from
+// the counters collection, so documents keep the numeric IDs the API exposes.
+func nextMongoID(ctx context.Context, db *mongo.Database, name string) (uint, error) {
+	var counter struct {
+		Seq uint `bson:"seq"`
+	}
+	err := db.Collection("counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": name},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter.Seq, err
+}
+
+func mongoResolveUUID(ctx context.Context, coll *mongo.Collection, uuid string) (uint, error) {
+	var doc struct {
+		ID uint `bson:"_id"`
+	}
+	err := coll.FindOne(ctx, bson.M{"uuid": uuid}, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&doc)
+	return doc.ID, translateMongoError(err)
+}
+
+// userFilter matches the users selected by opts, ignoring After.
+func userFilter(opts ListOptions) bson.M {
+	filter := listFilter(opts)
+	if domain := opts.Users.EmailDomain; domain != "" {
+		filter["email"] = primitive.Regex{Pattern: "@" + regexp.QuoteMeta(domain) + "$", Options: "i"}
+	}
+	if prefix := opts.Users.UsernamePrefix; prefix != "" {
+		filter["username"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix), Options: "i"}
+	}
+	return filter
+}
+
+// postFilter matches the posts selected by opts, ignoring After.
+func postFilter(opts ListOptions) bson.M {
+	filter := listFilter(opts)
+	f := opts.Posts
+	if f.AuthorID != 0 {
+		filter["author_id"] = f.AuthorID
+	}
+	if f.AuthorUUID != "" {
+		filter["author_uuid"] = f.AuthorUUID
+	}
+	if len(f.AuthorIDs) > 0 {
+		authors := bson.M{"$in": f.AuthorIDs}
+		if f.AuthorID != 0 {
+			authors["$eq"] = f.AuthorID
+		}
+		filter["author_id"] = authors
+	}
+	created := bson.M{}
+	if !f.CreatedAfter.IsZero() {
+		created["$gt"] = f.CreatedAfter
+	}
+	if !f.CreatedBefore.IsZero() {
+		created["$lt"] = f.CreatedBefore
+	}
+	if len(created) > 0 {
+		filter["created_at"] = created
+	}
+	if f.TitleContains != "" {
+		filter["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(f.TitleContains), Options: "i"}
+	}
+	// Conditions that may share a field with another go under $and.
+	and := bson.A{}
+	if f.TitlePrefix != "" {
+		and = append(and, bson.M{"title": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.TitlePrefix), Options: "i"}})
+	}
+	if len(f.ExcludeAuthorIDs) > 0 {
+		and = append(and, bson.M{"author_id": bson.M{"$nin": f.ExcludeAuthorIDs}})
+	}
+	if len(f.IDs) > 0 {
+		and = append(and, bson.M{"_id": bson.M{"$in": f.IDs}})
+	}
+	if len(f.ExcludeIDs) > 0 {
+		and = append(and, bson.M{"_id": bson.M{"$nin": f.ExcludeIDs}})
+	}
+	for _, term := range f.Terms {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(term), Options: "i"}
+		and = append(and, bson.M{"$or": bson.A{bson.M{"title": pattern}, bson.M{"content": pattern}}})
+	}
+	// Posts stored before statuses existed have none, and count as published.
+	switch f.Status {
+	case PostDraft:
+		filter["status"] = PostDraft
+	case PostPublished:
+		filter["status"] = bson.M{"$ne": PostDraft}
+	}
+	// Posts stored before visibility existed have none, and count as public.
+	if f.ListedOnly {
+		listed := bson.M{
+			"status":      bson.M{"$ne": PostDraft},
+			"visibility":  bson.M{"$in": bson.A{VisibilityPublic, nil}},
+			"archived_at": nil,
+		}
+		and = append(and, bson.M{"$or": bson.A{listed, bson.M{"author_id": f.ListedFor}}})
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
+
+func mongoList(ctx context.Context, coll *mongo.Collection, filter bson.M, sort bson.D, opts ListOptions, out interface{}) error {
+	find := options.Find().SetSort(sort)
+	if opts.Offset > 0 {
+		find.SetSkip(int64(opts.Offset))
+	}
+	if opts.Limit > 0 {
+		find.SetLimit(int64(opts.Limit))
+	}
+
+	cursor, err := coll.Find(ctx, filter, find)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, out)
+}
+
+// mongoSort orders by the sort fields in opts, then by defaults. Fields
+// already sorted on are left out of the defaults, as MongoDB rejects
+// repeated sort keys.
+func mongoSort(opts ListOptions, defaults ...bson.E) bson.D {
+	var sort bson.D
+	seen := make(map[string]bool)
+	for _, field := range opts.Sort {
+		key := field.Field
+		if key == "id" {
+			key = "_id"
+		}
+		direction := 1
+		if field.Desc {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: key, Value: direction})
+		seen[key] = true
+	}
+	for _, field := range defaults {
+		if !seen[field.Key] {
+			sort = append(sort, field)
+		}
+	}
+	return sort
+}
+
+// listFilter returns a new filter applying the soft-delete visibility from
+// opts, which callers may add to.
+func listFilter(opts ListOptions) bson.M {
+	if opts.OnlyDeleted {
+		return bson.M{"deleted_at.valid": true}
+	}
+	if opts.IncludeDeleted {
+		return bson.M{}
+	}
+	return bson.M{"deleted_at.valid": bson.M{"$ne": true}}
+}
+
+func mongoGet(ctx context.Context, coll *mongo.Collection, id uint, includeDeleted bool, out interface{}) error {
+	filter := bson.M{"_id": id}
+	if !includeDeleted {
+		filter["deleted_at.valid"] = bson.M{"$ne": true}
+	}
+	return translateMongoError(coll.FindOne(ctx, filter).Decode(out))
+}
+
+// mongoReplace replaces the live document with the given ID by doc, provided
+// it is still at *version. On success *version is incremented and *updatedAt
+// set, mirroring updateRow.
+func mongoReplace(ctx context.Context, coll *mongo.Collection, id uint, version *uint, updatedAt *time.Time, doc interface{}) error {
+	expected, previousUpdate := *version, *updatedAt
+	*version = expected + 1
+	*updatedAt = time.Now()
+
+	filter := bson.M{"_id": id, "version": expected, "deleted_at.valid": bson.M{"$ne": true}}
+	result, err := coll.ReplaceOne(ctx, filter, doc)
+	if err == nil && result.MatchedCount == 1 {
+		return nil
+	}
+
+	*version, *updatedAt = expected, previousUpdate
+	if err != nil {
+		return translateMongoError(err)
+	}
+
+	count, err := coll.CountDocuments(ctx, bson.M{"_id": id, "deleted_at.valid": bson.M{"$ne": true}})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+func mongoSoftDelete(ctx context.Context, coll *mongo.Collection, id uint) error {
+	filter := bson.M{"_id": id, "deleted_at.valid": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"deleted_at": gorm.DeletedAt{Time: time.Now(), Valid: true}}}
+
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func mongoForceDelete(ctx context.Context, coll *mongo.Collection, id uint) error {
+	result, err := coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func mongoRestore(ctx context.Context, coll *mongo.Collection, id uint) error {
+	filter := bson.M{"_id": id, "deleted_at.valid": true}
+	update := bson.M{"$set": bson.M{"deleted_at": gorm.DeletedAt{}}}
+
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// translateMongoError maps MongoDB driver errors onto the repository errors.
+func translateMongoError(err error) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return ErrConflict
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextRequestIDKey is the gin context key assignRequestID stores the
+// request's ID under.
+const contextRequestIDKey = "request_id"
+
+// requestIDKey is the request context key assignRequestID stores the
+// request's ID under, for code that only has the context.Context.
+type requestIDKey struct{}
+
+// requestIDPattern matches the inbound X-Request-ID values kept: up to 128
+// letters, digits and -_.:, so they are safe to echo and log.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// assignRequestID gives each request an ID, the client's X-Request-ID if it
+// sent a usable one or else a new UUID, and echoes it in the X-Request-ID
+// response header. Error responses and log lines carry it so a report can
+// be matched to the logs.
+func assignRequestID(c *gin.Context) {
+	id := c.GetHeader("X-Request-ID")
+	if !requestIDPattern.MatchString(id) {
+		id = newUUID()
+	}
+	c.Set(contextRequestIDKey, id)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+	c.Header("X-Request-ID", id)
+	c.Next()
+}
+
+// requestID returns the current request's ID.
+func requestID(c *gin.Context) string {
+	return c.GetString(contextRequestIDKey)
+}
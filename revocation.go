@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevocationList records access tokens, by their jti claim, that must be
+// rejected before they expire. Entries only need to be kept until then.
+type RevocationList interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser revokes every access token issued to the user before
+	// before. It need only be remembered until until, when the last of
+	// them has expired.
+	RevokeUser(ctx context.Context, userID uint, before, until time.Time) error
+	// IsRevoked reports whether token has been revoked, by its jti or
+	// along with the rest of its user's tokens.
+	IsRevoked(ctx context.Context, token accessClaims) (bool, error)
+}
+
+// newRevocationList reads REVOCATION_STORE ("memory", the default, or
+// "redis").
+func newRevocationList() RevocationList {
+	switch store := os.Getenv("REVOCATION_STORE"); store {
+	case "", "memory":
+		return newMemoryRevocationList()
+	case "redis":
+		return newRedisRevocationList()
+	default:
+		log.Fatalf("unsupported REVOCATION_STORE %q", store)
+		return nil
+	}
+}
+
+type LogoutRequest struct {
+	// RefreshToken, if given, is revoked along with every other refresh
+	// token from the same login.
+	RefreshToken string `json:"refresh_token"`
+}
+
+// logout revokes the access token the request was authenticated with and,
+// optionally, the refresh token family that goes with it.
+func (a *API) logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	user, _ := currentUser(c)
+
+	// requireAuth has accepted the token, so one we can't parse is from the
+	// OIDC provider; it isn't ours to revoke, and expires on its own.
+	if token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+		if claims, err := a.tokens.Parse(token); err == nil {
+			if err := a.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt); err != nil {
+				respondError(c, http.StatusInternalServerError, "Failed to log out")
+				return
+			}
+		}
+	}
+
+	if req.RefreshToken != "" {
+		token, err := a.refreshTokens.GetByHash(ctx, hashToken(req.RefreshToken))
+		switch {
+		case err == nil && token.UserID == user.ID:
+			err = a.refreshTokens.RevokeFamily(ctx, token.FamilyID)
+		case err == nil, errors.Is(err, ErrNotFound):
+			// Someone else's or an unknown token: nothing of ours to revoke.
+			err = nil
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+	}
+
+	a.audit(c, AuditLogout, user, "")
+	respond(c, http.StatusOK, "", gin.H{"message": "Logged out"}, nil)
+}
+
+// logOutEverywhere ends every login of the user: their refresh tokens, the
+// access tokens issued to them so far and their cookie sessions. Tokens are
+// issued with whole-second times, so one issued earlier in the same second
+// survives.
+func (a *API) logOutEverywhere(ctx context.Context, userID uint) error {
+	now := time.Now()
+	errs := []error{
+		a.refreshTokens.RevokeUser(ctx, userID),
+		a.revocations.RevokeUser(ctx, userID, now.Truncate(time.Second), now.Add(a.tokens.ttl)),
+	}
+	if a.sessions.store != nil {
+		errs = append(errs, a.sessions.store.DeleteUser(ctx, userID))
+	}
+	return errors.Join(errs...)
+}
+
+// memoryRevocationList keeps revoked token IDs in process memory. They are
+// lost on restart and not shared between instances.
+type memoryRevocationList struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	users   map[uint]memoryUserRevocation
+}
+
+// memoryUserRevocation is a RevokeUser call, kept until until.
+type memoryUserRevocation struct {
+	before, until time.Time
+}
+
+func newMemoryRevocationList() *memoryRevocationList {
+	return &memoryRevocationList{revoked: map[string]time.Time{}, users: map[uint]memoryUserRevocation{}}
+}
+
+func (l *memoryRevocationList) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Tokens that have expired are rejected anyway; forget them.
+	now := time.Now()
+	for id, exp := range l.revoked {
+		if !now.Before(exp) {
+			delete(l.revoked, id)
+		}
+	}
+	l.revoked[jti] = expiresAt
+	return nil
+}
+
+func (l *memoryRevocationList) RevokeUser(ctx context.Context, userID uint, before, until time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for id, revocation := range l.users {
+		if !now.Before(revocation.until) {
+			delete(l.users, id)
+		}
+	}
+	l.users[userID] = memoryUserRevocation{before: before, until: until}
+	return nil
+}
+
+func (l *memoryRevocationList) IsRevoked(ctx context.Context, token accessClaims) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.revoked[token.ID]; ok {
+		return true, nil
+	}
+	revocation, ok := l.users[token.UserID]
+	return ok && token.IssuedAt.Before(revocation.before), nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRouteSuggestions caps how many routes a 404 suggests, and
+// maxSuggestionDistance how far from the requested path they may be.
+const (
+	maxRouteSuggestions   = 3
+	maxSuggestionDistance = 1.0
+)
+
+// handleUnmatched makes requests that match no route get JSON errors: 405
+// with the allowed methods when the path exists under other methods, and
+// otherwise 404 with the routes closest to the requested path.
+func handleUnmatched(r *gin.Engine) {
+	r.NoRoute(func(c *gin.Context) {
+		respondErrorDetails(c, http.StatusNotFound,
+			"No route matches "+c.Request.Method+" "+c.Request.URL.Path,
+			gin.H{"suggestions": suggestRoutes(r.Routes(), c.Request.URL.Path)})
+	})
+	r.HandleMethodNotAllowed = true
+	r.NoMethod(func(c *gin.Context) {
+		allowed := allowedMethods(r.Routes(), c.Request.URL.Path)
+		c.Header("Allow", strings.Join(allowed, ", "))
+		respondErrorDetails(c, http.StatusMethodNotAllowed,
+			c.Request.Method+" is not allowed on "+c.Request.URL.Path,
+			gin.H{"allowed_methods": allowed})
+	})
+}
+
+// allowedMethods returns the methods of the routes matching path, sorted.
+func allowedMethods(routes gin.RoutesInfo, path string) []string {
+	seen := make(map[string]bool)
+	methods := []string{}
+	for _, route := range routes {
+		if matchRoute(route.Path, path) && !seen[route.Method] {
+			seen[route.Method] = true
+			methods = append(methods, route.Method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// matchRoute reports whether path matches the gin route pattern, where
+// :name matches one segment and *name the rest of the path.
+func matchRoute(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
+
+// suggestRoutes returns the route patterns closest to path, nearest first.
+func suggestRoutes(routes gin.RoutesInfo, path string) []string {
+	type candidate struct {
+		path     string
+		distance float64
+	}
+	var candidates []candidate
+	seen := make(map[string]bool)
+	for _, route := range routes {
+		if seen[route.Path] {
+			continue
+		}
+		seen[route.Path] = true
+		if d := routeDistance(route.Path, path); d <= maxSuggestionDistance {
+			candidates = append(candidates, candidate{route.Path, d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].path < candidates[j].path
+	})
+
+	suggestions := []string{}
+	for i := 0; i < len(candidates) && i < maxRouteSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].path)
+	}
+	return suggestions
+}
+
+// routeDistance is an edit distance between a route pattern and a path,
+// counted in segments. Adding or dropping a segment costs 1; replacing one
+// costs the fraction of its characters that differ, and nothing for a
+// :param segment.
+func routeDistance(pattern, path string) float64 {
+	a := strings.Split(strings.Trim(pattern, "/"), "/")
+	b := strings.Split(strings.Trim(path, "/"), "/")
+
+	prev := make([]float64, len(b)+1)
+	curr := make([]float64, len(b)+1)
+	for j := range prev {
+		prev[j] = float64(j)
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = float64(i)
+		for j := 1; j <= len(b); j++ {
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+segmentDistance(a[i-1], b[j-1]))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// segmentDistance compares one segment of a route pattern with one of a
+// path, from 0 (a match) to 1 (nothing in common).
+func segmentDistance(pattern, segment string) float64 {
+	if strings.HasPrefix(pattern, ":") || strings.HasPrefix(pattern, "*") {
+		return 0
+	}
+	p, s := []rune(pattern), []rune(strings.ToLower(segment))
+	if len(p) == 0 && len(s) == 0 {
+		return 0
+	}
+
+	prev := make([]int, len(s)+1)
+	curr := make([]int, len(s)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(p); i++ {
+		curr[0] = i
+		for j := 1; j <= len(s); j++ {
+			cost := 1
+			if p[i-1] == s[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return float64(prev[len(s)]) / float64(max(len(p), len(s)))
+}
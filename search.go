@@ -0,0 +1,213 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxSearchTerms caps how many terms of a query are used.
+	maxSearchTerms = 10
+	// maxSearchCandidates caps how many matching posts, the most recent
+	// first, are ranked per search.
+	maxSearchCandidates = 1000
+	// snippetLength is the approximate length, in runes, of result snippets.
+	snippetLength = 160
+)
+
+// searchResult is a post matching a search, with its relevance score and
+// the highlighted title and content snippet. Highlights are HTML: the text
+// is escaped and matches wrapped in <mark> tags.
+type searchResult struct {
+	Post    Post    `json:"post"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title_highlight"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchPosts serves GET /posts/search?q=. Posts containing every term of
+// the query in their title or content match, and are ranked by how often
+// the terms appear, with title matches weighing more.
+func (a *API) searchPosts(c *gin.Context) {
+	query := c.Query("q")
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		respondError(c, http.StatusBadRequest, "q must contain at least one word")
+		return
+	}
+
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	filter := PostFilter{Terms: terms}
+	hideUnlisted(c, &filter)
+	if !a.hideBlocked(c, &filter) {
+		return
+	}
+	posts, err := a.posts.List(c.Request.Context(), ListOptions{
+		Posts: filter,
+		Sort:  []SortField{{Field: "created_at", Desc: true}},
+		Limit: maxSearchCandidates,
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to search posts")
+		return
+	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+
+	results := make([]searchResult, 0, len(posts))
+	for _, post := range posts {
+		results = append(results, searchResult{
+			Post:    post,
+			Score:   searchScore(post, query, terms),
+			Title:   highlight(post.Title, terms),
+			Snippet: highlight(snippet(post.Content, terms), terms),
+		})
+	}
+	// Best matches first, newest first among equals.
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Post.CreatedAt.After(results[j].Post.CreatedAt)
+	})
+
+	total := int64(len(results))
+	results = paginate(results, page.apply(ListOptions{}))
+
+	respondCacheable(c, render(c, "results", results, gin.H{
+		"query":      query,
+		"count":      len(results),
+		"pagination": page.meta(total),
+	}))
+}
+
+// searchTerms splits query into distinct lowercase words.
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// searchScore rates how well post matches. Each occurrence of a term counts
+// three times in the title and once in the content, and the whole query
+// appearing verbatim in the title doubles the score.
+func searchScore(post Post, query string, terms []string) float64 {
+	title, content := strings.ToLower(post.Title), strings.ToLower(post.Content)
+
+	var score float64
+	for _, term := range terms {
+		score += 3*float64(strings.Count(title, term)) + float64(strings.Count(content, term))
+	}
+	if phrase := strings.ToLower(strings.TrimSpace(query)); len(terms) > 1 && strings.Contains(title, phrase) {
+		score *= 2
+	}
+	return score
+}
+
+// snippet returns about snippetLength runes of content around the first
+// term it contains, with ellipses where it was cut.
+func snippet(content string, terms []string) string {
+	runes := []rune(content)
+	if len(runes) <= snippetLength {
+		return content
+	}
+
+	lower := []rune(strings.ToLower(content))
+	first := len(lower)
+	for _, term := range terms {
+		if i := runeIndex(lower, []rune(term)); i >= 0 && i < first {
+			first = i
+		}
+	}
+	if first == len(lower) {
+		first = 0
+	}
+
+	start := first - snippetLength/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end, start = len(runes), len(runes)-snippetLength
+	}
+
+	text := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		text = "…" + text
+	}
+	if end < len(runes) {
+		text += "…"
+	}
+	return text
+}
+
+// highlight HTML-escapes text and wraps each occurrence of a term in
+// <mark> tags.
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	// strings.ToLower can change the length of some runes; give up on
+	// highlighting rather than mark the wrong text.
+	if len(lower) != len(runes) {
+		return html.EscapeString(text)
+	}
+
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString("<mark>")
+		}
+		b.WriteString(html.EscapeString(string(r)))
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString("</mark>")
+		}
+	}
+	return b.String()
+}
+
+// runeIndex returns the index of the first occurrence of sub in s, or -1.
+func runeIndex(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
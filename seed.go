@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// seedRandom fixes the faker seed so repeated runs generate the same data.
+const seedRandom = 42
+
+// runSeed implements the `seed` subcommand:
+//
+//	seed [-users N] [-posts M]
+func runSeed(args []string) {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	users := fs.Int("users", envInt("SEED_USERS", 20), "number of users to have after seeding")
+	posts := fs.Int("posts", envInt("SEED_POSTS", 100), "number of posts to have after seeding")
+	fs.Parse(args)
+
+	if err := seed(context.Background(), newStorage(), *users, *posts); err != nil {
+		log.Fatalf("seed failed: %v", err)
+	}
+}
+
+// seed tops storage up with fake users and posts until there are at least
+// userCount users and postCount posts. It is idempotent: records that
+// already exist count towards the totals, and running it again against a
+// seeded store creates nothing.
+func seed(ctx context.Context, storage Storage, userCount, postCount int) error {
+	faker := gofakeit.New(seedRandom)
+
+	users, err := storage.Users.List(ctx, ListOptions{})
+	if err != nil {
+		return err
+	}
+	createdUsers := 0
+	for attempts := 0; len(users) < userCount && attempts < userCount*10; attempts++ {
+		user := User{Username: faker.Username(), Email: faker.Email(), Role: RoleEditor}
+		if err := storage.Users.Create(ctx, &user); err != nil {
+			if errors.Is(err, ErrConflict) {
+				continue
+			}
+			return err
+		}
+		users = append(users, user)
+		createdUsers++
+	}
+
+	posts, err := storage.Posts.List(ctx, ListOptions{})
+	if err != nil {
+		return err
+	}
+	missing := postCount - len(posts)
+	if missing > 0 && len(users) == 0 {
+		return errors.New("no users to author posts")
+	}
+	for i := 0; i < missing; i++ {
+		author := users[faker.Number(0, len(users)-1)]
+		post := Post{
+			Title:      faker.Sentence(6),
+			Content:    faker.Paragraph(3, 5, 12, "\n\n"),
+			AuthorID:   author.ID,
+			AuthorUUID: author.UUID,
+		}
+		post.countWords()
+		post.setStatus(PostPublished)
+		if err := storage.Posts.Create(ctx, &post); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("seeded %d users and %d posts", createdUsers, max(missing, 0))
+	return nil
+}
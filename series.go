@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Series groups up to 100 of an author's posts in a reading order. A post
+// is in at most one series.
+type Series struct {
+	ID          uint      `json:"id" gorm:"primary_key" bson:"_id"`
+	AuthorID    uint      `json:"-" gorm:"not null;index" bson:"author_id"`
+	Title       string    `json:"title" gorm:"size:200;not null" bson:"title"`
+	Description string    `json:"description" gorm:"size:1000;not null;default:''" bson:"description"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime" bson:"created_at"`
+	// Author is loaded for responses, and Posts for GET /series/:id.
+	Author *User  `json:"author,omitempty" gorm:"-" bson:"-"`
+	Posts  []Post `json:"posts,omitempty" gorm:"-" bson:"-"`
+}
+
+// SeriesEntry places a post in a series, at Position from 0.
+type SeriesEntry struct {
+	ID       uint `gorm:"primary_key" bson:"-"`
+	SeriesID uint `gorm:"not null;index" bson:"series_id"`
+	PostID   uint `gorm:"not null;uniqueIndex" bson:"post_id"`
+	Position int  `gorm:"not null" bson:"position"`
+}
+
+// SeriesNav places a post in its series for readers: its 1-based position
+// among the posts they can see, and the posts either side of it.
+type SeriesNav struct {
+	ID       uint      `json:"id"`
+	Title    string    `json:"title"`
+	Position int       `json:"position"`
+	Count    int       `json:"count"`
+	Previous *PostLink `json:"previous"`
+	Next     *PostLink `json:"next"`
+}
+
+// PostLink refers to a post by ID and title.
+type PostLink struct {
+	ID    uint   `json:"id"`
+	UUID  string `json:"-"`
+	Title string `json:"title"`
+}
+
+// SeriesRepository stores series and the order of their posts.
+type SeriesRepository interface {
+	Create(ctx context.Context, series *Series) error
+	// Get returns a series, or ErrNotFound.
+	Get(ctx context.Context, id uint) (Series, error)
+	GetMany(ctx context.Context, ids []uint) ([]Series, error)
+	// Delete removes a series, leaving its posts be, or returns
+	// ErrNotFound.
+	Delete(ctx context.Context, id uint) error
+	// SetPosts replaces the posts in a series with postIDs, in order. It
+	// returns ErrConflict if one of them is in another series.
+	SetPosts(ctx context.Context, seriesID uint, postIDs []uint) error
+	// Posts returns the IDs of the posts in a series, in order.
+	Posts(ctx context.Context, seriesID uint) ([]uint, error)
+	// ForPosts returns the series each of postIDs is in. Posts in none are
+	// missing from the result.
+	ForPosts(ctx context.Context, postIDs []uint) (map[uint]uint, error)
+}
+
+type SeriesRequest struct {
+	Title       string `json:"title" binding:"required,max=200"`
+	Description string `json:"description" binding:"max=1000"`
+}
+
+type SeriesPostsRequest struct {
+	PostIDs []publicID `json:"post_ids" binding:"max=100"`
+}
+
+// createSeries serves POST /series, creating an empty series by the caller.
+func (a *API) createSeries(c *gin.Context) {
+	var req SeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	author, _ := currentUser(c)
+	series := Series{AuthorID: author.ID, Title: req.Title, Description: req.Description}
+	if err := a.series.Create(c.Request.Context(), &series); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create series")
+		return
+	}
+	series.Author = &author
+	series.Posts = []Post{}
+
+	respond(c, http.StatusCreated, "", series, nil)
+}
+
+// getSeries serves GET /series/:id, the series with its author and its
+// posts in order. Drafts are left out for anyone but their author and
+// admins.
+func (a *API) getSeries(c *gin.Context) {
+	series, ok := a.pathSeries(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	author, err := a.users.GetIncludingDeleted(ctx, series.AuthorID)
+	if err != nil {
+		respondStoreError(c, err, "author", "fetch")
+		return
+	}
+	series.Author = &author
+	posts, err := a.seriesPosts(c, series.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+	series.Posts = posts
+
+	respond(c, http.StatusOK, "", series, nil)
+}
+
+// setSeriesPosts serves PUT /series/:id/posts, replacing the posts in the
+// series with the post_ids listed, in that order, for its author and
+// admins. The posts must be by the series' author and in no other series.
+func (a *API) setSeriesPosts(c *gin.Context) {
+	series, ok := a.modifiableSeries(c)
+	if !ok {
+		return
+	}
+	var req SeriesPostsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	ids := make([]uint, 0, len(req.PostIDs))
+	seen := make(map[uint]bool)
+	for _, param := range req.PostIDs {
+		id, err := a.parsePostID(ctx, string(param))
+		if err == nil {
+			var post Post
+			post, err = a.posts.Get(ctx, id)
+			if err == nil && post.AuthorID != series.AuthorID {
+				respondError(c, http.StatusBadRequest, "Post "+strconv.Quote(string(param))+" is not by the series author")
+				return
+			}
+		}
+		switch {
+		case errors.Is(err, errInvalidID):
+			respondError(c, http.StatusBadRequest, "Invalid post ID "+strconv.Quote(string(param)))
+			return
+		case errors.Is(err, ErrNotFound):
+			respondError(c, http.StatusBadRequest, "Post "+strconv.Quote(string(param))+" not found")
+			return
+		case err != nil:
+			respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+			return
+		}
+		if seen[id] {
+			respondError(c, http.StatusBadRequest, "Post "+strconv.Quote(string(param))+" is listed twice")
+			return
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	if err := a.series.SetPosts(ctx, series.ID, ids); err != nil {
+		if errors.Is(err, ErrConflict) {
+			respondError(c, http.StatusConflict, "A post is already in another series")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to update series")
+		return
+	}
+	a.getSeries(c)
+}
+
+// deleteSeries serves DELETE /series/:id for the series' author and admins.
+// Its posts are kept.
+func (a *API) deleteSeries(c *gin.Context) {
+	series, ok := a.modifiableSeries(c)
+	if !ok {
+		return
+	}
+
+	if err := a.series.Delete(c.Request.Context(), series.ID); err != nil {
+		respondStoreError(c, err, "series", "delete")
+		return
+	}
+
+	respond(c, http.StatusOK, "", gin.H{"message": "Series deleted successfully"}, nil)
+}
+
+// pathSeries loads the :id series, writing the error response and
+// returning ok == false if it can't.
+func (a *API) pathSeries(c *gin.Context) (Series, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid series ID")
+		return Series{}, false
+	}
+	series, err := a.series.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		respondStoreError(c, err, "series", "fetch")
+		return Series{}, false
+	}
+	return series, true
+}
+
+// modifiableSeries loads the :id series for a change by the caller, writing
+// the error response and returning ok == false if it doesn't exist or they
+// aren't its author or an admin.
+func (a *API) modifiableSeries(c *gin.Context) (Series, bool) {
+	series, ok := a.pathSeries(c)
+	if !ok {
+		return Series{}, false
+	}
+	user, _ := currentUser(c)
+	if series.AuthorID != user.ID && !hasRole(user, RoleAdmin) {
+		a.deny(c, "Only the author or an admin can modify this series")
+		return Series{}, false
+	}
+	auditBefore(c, series)
+	return series, true
+}
+
+// seriesPosts returns the posts in a series the caller can see, in order.
+// Deleted posts are left out.
+func (a *API) seriesPosts(c *gin.Context, seriesID uint) ([]Post, error) {
+	ctx := c.Request.Context()
+	ids, err := a.series.Posts(ctx, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	found, err := a.posts.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+	posts := []Post{}
+	for _, id := range ids {
+		if post, ok := byID[id]; ok && a.canViewPost(c, post) {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// fillSeries fills in where posts stand in their series, among the posts
+// the caller can see.
+func (a *API) fillSeries(c *gin.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ctx := c.Request.Context()
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	memberships, err := a.series.ForPosts(ctx, ids)
+	if err != nil || len(memberships) == 0 {
+		return err
+	}
+
+	var seriesIDs []uint
+	ordered := make(map[uint][]Post)
+	for _, seriesID := range memberships {
+		if _, ok := ordered[seriesID]; ok {
+			continue
+		}
+		if ordered[seriesID], err = a.seriesPosts(c, seriesID); err != nil {
+			return err
+		}
+		seriesIDs = append(seriesIDs, seriesID)
+	}
+	found, err := a.series.GetMany(ctx, seriesIDs)
+	if err != nil {
+		return err
+	}
+	titles := make(map[uint]string, len(found))
+	for _, series := range found {
+		titles[series.ID] = series.Title
+	}
+
+	for i := range posts {
+		seriesID, ok := memberships[posts[i].ID]
+		if !ok {
+			continue
+		}
+		order := ordered[seriesID]
+		for j, post := range order {
+			if post.ID != posts[i].ID {
+				continue
+			}
+			nav := SeriesNav{ID: seriesID, Title: titles[seriesID], Position: j + 1, Count: len(order)}
+			if j > 0 {
+				nav.Previous = linkTo(order[j-1])
+			}
+			if j < len(order)-1 {
+				nav.Next = linkTo(order[j+1])
+			}
+			posts[i].Series = &nav
+		}
+	}
+	return nil
+}
+
+// linkTo returns a link to post.
+func linkTo(post Post) *PostLink {
+	return &PostLink{ID: post.ID, UUID: post.UUID, Title: post.Title}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionStore maps session IDs, by their SHA-256 hash, to the users they
+// were issued to. Sessions expire after the TTL they were saved with.
+type SessionStore interface {
+	Save(ctx context.Context, hash string, userID uint, ttl time.Duration) error
+	// Get returns the user the session belongs to, or ErrNotFound if there
+	// is no such live session.
+	Get(ctx context.Context, hash string) (uint, error)
+	Delete(ctx context.Context, hash string) error
+	// DeleteUser deletes all of the user's sessions.
+	DeleteUser(ctx context.Context, userID uint) error
+}
+
+// sessionConfig controls cookie sessions. Sessions are disabled, and the
+// session endpoints respond 404, when store is nil.
+type sessionConfig struct {
+	store  SessionStore
+	ttl    time.Duration
+	cookie string
+	secure bool
+}
+
+// newSessionConfig reads SESSION_STORE ("memory" or "redis"; unset disables
+// sessions), SESSION_TTL, SESSION_COOKIE_NAME and SESSION_COOKIE_SECURE.
+func newSessionConfig() sessionConfig {
+	config := sessionConfig{
+		ttl:    envDuration("SESSION_TTL", 24*time.Hour),
+		cookie: os.Getenv("SESSION_COOKIE_NAME"),
+		secure: os.Getenv("SESSION_COOKIE_SECURE") != "false",
+	}
+	if config.cookie == "" {
+		config.cookie = "session"
+	}
+
+	switch store := os.Getenv("SESSION_STORE"); store {
+	case "":
+	case "memory":
+		config.store = newMemorySessionStore()
+	case "redis":
+		config.store = newRedisSessionStore()
+	default:
+		log.Fatalf("unsupported SESSION_STORE %q", store)
+	}
+	return config
+}
+
+// createSession logs in with a username and password like login, but
+// instead of returning tokens sets an HTTP-only session cookie.
+func (a *API) createSession(c *gin.Context) {
+	if a.sessions.store == nil {
+		respondError(c, http.StatusNotFound, "Sessions are not enabled")
+		return
+	}
+
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, ok := a.checkCredentials(c, req)
+	if !ok {
+		return
+	}
+
+	id, err := newRandomToken()
+	if err == nil {
+		err = a.sessions.store.Save(c.Request.Context(), hashToken(id), user.ID, a.sessions.ttl)
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+
+	a.setSessionCookie(c, id, int(a.sessions.ttl.Seconds()))
+	respond(c, http.StatusOK, "user", user, nil)
+}
+
+// deleteSession logs out of the current session and clears the cookie.
+func (a *API) deleteSession(c *gin.Context) {
+	if a.sessions.store == nil {
+		respondError(c, http.StatusNotFound, "Sessions are not enabled")
+		return
+	}
+
+	if id, err := c.Cookie(a.sessions.cookie); err == nil && id != "" {
+		if err := a.sessions.store.Delete(c.Request.Context(), hashToken(id)); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+	}
+
+	a.setSessionCookie(c, "", -1)
+	respond(c, http.StatusOK, "", gin.H{"message": "Logged out"}, nil)
+}
+
+// setSessionCookie writes the session cookie. SameSite=Strict keeps other
+// sites from riding on the session.
+func (a *API) setSessionCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(a.sessions.cookie, value, maxAge, "/", "", a.sessions.secure, true)
+}
+
+// authenticateSession resolves the session cookie, if sessions are enabled
+// and the request has one, to its user. found reports whether there was a
+// cookie to check.
+func (a *API) authenticateSession(c *gin.Context) (user User, found bool, err error) {
+	if a.sessions.store == nil {
+		return User{}, false, nil
+	}
+	id, err := c.Cookie(a.sessions.cookie)
+	if err != nil || id == "" {
+		return User{}, false, nil
+	}
+
+	ctx := c.Request.Context()
+	userID, err := a.sessions.store.Get(ctx, hashToken(id))
+	if err != nil {
+		return User{}, true, err
+	}
+	user, err = a.users.Get(ctx, userID)
+	return user, true, err
+}
+
+type memorySession struct {
+	userID    uint
+	expiresAt time.Time
+}
+
+// memorySessionStore keeps sessions in process memory. They are lost on
+// restart and not shared between instances.
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: map[string]memorySession{}}
+}
+
+func (s *memorySessionStore) Save(ctx context.Context, hash string, userID uint, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired sessions as we go so the map doesn't grow forever.
+	now := time.Now()
+	for h, session := range s.sessions {
+		if !now.Before(session.expiresAt) {
+			delete(s.sessions, h)
+		}
+	}
+	s.sessions[hash] = memorySession{userID: userID, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *memorySessionStore) Get(ctx context.Context, hash string) (uint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[hash]
+	if !ok || !time.Now().Before(session.expiresAt) {
+		return 0, ErrNotFound
+	}
+	return session.userID, nil
+}
+
+func (s *memorySessionStore) Delete(ctx context.Context, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, hash)
+	return nil
+}
+
+func (s *memorySessionStore) DeleteUser(ctx context.Context, userID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range s.sessions {
+		if session.userID == userID {
+			delete(s.sessions, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+	// Embeds the time zone database, which the runtime image lacks, so
+	// timezone settings validate the same everywhere.
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localePattern matches a BCP 47 language tag such as "en" or "pt-BR".
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// Settings are a user's preferences. Users who never changed theirs get
+// defaultSettings.
+type Settings struct {
+	UserID            uint                 `json:"-" gorm:"primaryKey;autoIncrement:false" bson:"_id"`
+	Notifications     NotificationSettings `json:"notifications" gorm:"serializer:json;type:text;not null" bson:"notifications"`
+	DefaultVisibility string               `json:"default_visibility" gorm:"size:16;not null;default:public" bson:"default_visibility"`
+	Locale            string               `json:"locale" gorm:"size:35;not null;default:en" bson:"locale"`
+	Timezone          string               `json:"timezone" gorm:"size:64;not null;default:UTC" bson:"timezone"`
+	UpdatedAt         time.Time            `json:"updated_at" gorm:"autoUpdateTime" bson:"updated_at"`
+}
+
+// NotificationSettings say which events a user wants to be notified of, and
+// whether by email as well as in the app.
+type NotificationSettings struct {
+	Mentions  bool `json:"mentions" bson:"mentions"`
+	Follows   bool `json:"follows" bson:"follows"`
+	Messages  bool `json:"messages" bson:"messages"`
+	Reactions bool `json:"reactions" bson:"reactions"`
+	Email     bool `json:"email" bson:"email"`
+}
+
+// defaultSettings returns the settings of a user who never changed them.
+func defaultSettings(userID uint) Settings {
+	return Settings{
+		UserID: userID,
+		Notifications: NotificationSettings{
+			Mentions:  true,
+			Follows:   true,
+			Messages:  true,
+			Reactions: true,
+			Email:     true,
+		},
+		DefaultVisibility: VisibilityPublic,
+		Locale:            "en",
+		Timezone:          "UTC",
+	}
+}
+
+// SettingsRepository stores user settings.
+type SettingsRepository interface {
+	// Get returns a user's settings, or ErrNotFound if they never saved
+	// any.
+	Get(ctx context.Context, userID uint) (Settings, error)
+	// Save creates or replaces the settings of settings.UserID.
+	Save(ctx context.Context, settings *Settings) error
+}
+
+// UpdateSettingsRequest is the body of PATCH /users/me/settings. Fields left
+// out are unchanged.
+type UpdateSettingsRequest struct {
+	Notifications *struct {
+		Mentions  *bool `json:"mentions"`
+		Follows   *bool `json:"follows"`
+		Messages  *bool `json:"messages"`
+		Reactions *bool `json:"reactions"`
+		Email     *bool `json:"email"`
+	} `json:"notifications"`
+	DefaultVisibility *string `json:"default_visibility" binding:"omitempty,oneof=public unlisted private"`
+	Locale            *string `json:"locale" binding:"omitempty,max=35"`
+	Timezone          *string `json:"timezone" binding:"omitempty,max=64"`
+}
+
+// getSettings serves GET /users/me/settings, the caller's settings.
+func (a *API) getSettings(c *gin.Context) {
+	settings, ok := a.mySettings(c)
+	if !ok {
+		return
+	}
+
+	respond(c, http.StatusOK, "", settings, nil)
+}
+
+// updateSettings serves PATCH /users/me/settings, changing the caller's
+// settings. The locale must be a language tag such as "en" or "pt-BR", and
+// the timezone an IANA name such as "Europe/Paris".
+func (a *API) updateSettings(c *gin.Context) {
+	var req UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.Locale != nil && !localePattern.MatchString(*req.Locale) {
+		respondError(c, http.StatusBadRequest, "locale must be a language tag such as en or pt-BR")
+		return
+	}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			respondError(c, http.StatusBadRequest, "timezone must be an IANA time zone such as Europe/Paris")
+			return
+		}
+	}
+
+	settings, ok := a.mySettings(c)
+	if !ok {
+		return
+	}
+	auditBefore(c, settings)
+	if n := req.Notifications; n != nil {
+		for _, field := range []struct {
+			value *bool
+			into  *bool
+		}{
+			{n.Mentions, &settings.Notifications.Mentions},
+			{n.Follows, &settings.Notifications.Follows},
+			{n.Messages, &settings.Notifications.Messages},
+			{n.Reactions, &settings.Notifications.Reactions},
+			{n.Email, &settings.Notifications.Email},
+		} {
+			if field.value != nil {
+				*field.into = *field.value
+			}
+		}
+	}
+	if req.DefaultVisibility != nil {
+		settings.DefaultVisibility = *req.DefaultVisibility
+	}
+	if req.Locale != nil {
+		settings.Locale = *req.Locale
+	}
+	if req.Timezone != nil {
+		settings.Timezone = *req.Timezone
+	}
+
+	if err := a.settings.Save(c.Request.Context(), &settings); err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to update settings")
+		return
+	}
+
+	respond(c, http.StatusOK, "", settings, nil)
+}
+
+// mySettings loads the caller's settings, writing the error response and
+// returning ok == false if it can't.
+func (a *API) mySettings(c *gin.Context) (Settings, bool) {
+	user, _ := currentUser(c)
+	settings, err := a.userSettings(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch settings")
+		return Settings{}, false
+	}
+	return settings, true
+}
+
+// userSettings returns a user's settings, or the defaults if they never
+// saved any.
+func (a *API) userSettings(ctx context.Context, userID uint) (Settings, error) {
+	settings, err := a.settings.Get(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return defaultSettings(userID), nil
+	}
+	return settings, err
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// memorySnapshot is the on-disk form of the in-memory store.
+type memorySnapshot struct {
+	Users                    []User
+	NextUserID               uint
+	Posts                    []Post
+	NextPostID               uint
+	Events                   []OutboxEvent
+	NextEventID              uint
+	Reactions                []Reaction
+	NextReactionID           uint
+	Follows                  []Follow
+	NextFollowID             uint
+	Bookmarks                []Bookmark
+	NextBookmarkID           uint
+	Messages                 []Message
+	NextMessageID            uint
+	Media                    []Media
+	NextMediaID              uint
+	Activity                 []Activity
+	NextActivityID           uint
+	Reports                  []Report
+	NextReportID             uint
+	ModerationDecisions      []ModerationDecision
+	NextModerationDecisionID uint
+	DailyViews               []DailyViews
+	Blocks                   []Block
+	NextBlockID              uint
+	Mentions                 []Mention
+	NextMentionID            uint
+	Polls                    []Poll
+	PollVotes                []PollVote
+	NextPollID               uint
+	Series                   []Series
+	SeriesEntries            []SeriesEntry
+	NextSeriesID             uint
+	CoAuthors                []CoAuthor
+	NextCoAuthorID           uint
+	DailyEngagement          []DailyEngagement
+	Settings                 []Settings
+	Pins                     []Pin
+	NextPinID                uint
+}
+
+// snapshotter periodically saves the in-memory repositories to a gob file so
+// they survive restarts.
+type snapshotter struct {
+	path       string
+	users      *memoryUserRepository
+	posts      *memoryPostRepository
+	outbox     *memoryOutbox
+	reactions  *memoryReactionRepository
+	follows    *memoryFollowRepository
+	bookmarks  *memoryBookmarkRepository
+	messages   *memoryMessageRepository
+	media      *memoryMediaRepository
+	activity   *memoryActivityRepository
+	reports    *memoryReportRepository
+	moderation *memoryModerationRepository
+	views      *memoryViewRepository
+	blocks     *memoryBlockRepository
+	mentions   *memoryMentionRepository
+	polls      *memoryPollRepository
+	series     *memorySeriesRepository
+	coAuthors  *memoryCoAuthorRepository
+	engagement *memoryEngagementRepository
+	settings   *memorySettingsRepository
+	pins       *memoryPinRepository
+}
+
+// newMemoryStorage builds the in-memory repositories. If SNAPSHOT_PATH is set
+// they are restored from that file and saved back to it every
+// SNAPSHOT_INTERVAL.
+func newMemoryStorage() Storage {
+	outbox := newMemoryOutbox()
+	users := newMemoryUserRepository(outbox)
+	posts := newMemoryPostRepository(outbox)
+	reactions := newMemoryReactionRepository()
+	follows := newMemoryFollowRepository()
+	bookmarks := newMemoryBookmarkRepository()
+	messages := newMemoryMessageRepository(outbox)
+	media := newMemoryMediaRepository()
+	activity := newMemoryActivityRepository()
+	reports := newMemoryReportRepository()
+	moderation := newMemoryModerationRepository()
+	views := newMemoryViewRepository()
+	blocks := newMemoryBlockRepository()
+	mentions := newMemoryMentionRepository(outbox)
+	polls := newMemoryPollRepository()
+	series := newMemorySeriesRepository()
+	coAuthors := newMemoryCoAuthorRepository()
+	engagement := newMemoryEngagementRepository()
+	settings := newMemorySettingsRepository()
+	pins := newMemoryPinRepository()
+	storage := Storage{
+		Users:         users,
+		Posts:         posts,
+		RefreshTokens: newMemoryRefreshTokenRepository(),
+		APIKeys:       newMemoryAPIKeyRepository(),
+		OneTimeTokens: newMemoryOneTimeTokenRepository(),
+		AuditLog:      newMemoryAuditRepository(),
+		Reactions:     reactions,
+		Follows:       follows,
+		Bookmarks:     bookmarks,
+		Messages:      messages,
+		Media:         media,
+		Activity:      activity,
+		Reports:       reports,
+		Moderation:    moderation,
+		Views:         views,
+		Blocks:        blocks,
+		Mentions:      mentions,
+		Polls:         polls,
+		Series:        series,
+		CoAuthors:     coAuthors,
+		Engagement:    engagement,
+		Settings:      settings,
+		Pins:          pins,
+		Outbox:        outbox,
+		Close:         func(context.Context) error { return nil },
+	}
+
+	path := os.Getenv("SNAPSHOT_PATH")
+	if path == "" {
+		log.Println("using in-memory storage; data will not survive a restart")
+		return storage
+	}
+
+	s := &snapshotter{path: path, users: users, posts: posts, outbox: outbox, reactions: reactions, follows: follows, bookmarks: bookmarks, messages: messages, media: media, activity: activity, reports: reports, moderation: moderation, views: views, blocks: blocks, mentions: mentions, polls: polls, series: series, coAuthors: coAuthors, engagement: engagement, settings: settings, pins: pins}
+	if err := s.load(); err != nil {
+		log.Fatalf("failed to load snapshot %s: %v", path, err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.run(ctx, envDuration("SNAPSHOT_INTERVAL", 30*time.Second))
+		close(stopped)
+	}()
+	storage.Close = func(ctx context.Context) error {
+		stop()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	log.Printf("using in-memory storage with snapshots in %s", path)
+	return storage
+}
+
+// load restores the repositories from the snapshot file, if there is one.
+func (s *snapshotter) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snap memorySnapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return err
+	}
+
+	s.users.mu.Lock()
+	for _, user := range snap.Users {
+		s.users.users[user.ID] = user
+	}
+	s.users.nextID = snap.NextUserID
+	s.users.mu.Unlock()
+
+	s.posts.mu.Lock()
+	for _, post := range snap.Posts {
+		s.posts.posts[post.ID] = post
+	}
+	s.posts.nextID = snap.NextPostID
+	s.posts.mu.Unlock()
+
+	s.outbox.mu.Lock()
+	s.outbox.events = snap.Events
+	s.outbox.nextID = snap.NextEventID
+	s.outbox.mu.Unlock()
+
+	s.reactions.mu.Lock()
+	s.reactions.reactions = snap.Reactions
+	if snap.NextReactionID > 0 {
+		s.reactions.nextID = snap.NextReactionID
+	}
+	s.reactions.mu.Unlock()
+
+	s.follows.mu.Lock()
+	s.follows.follows = snap.Follows
+	if snap.NextFollowID > 0 {
+		s.follows.nextID = snap.NextFollowID
+	}
+	s.follows.mu.Unlock()
+
+	s.bookmarks.mu.Lock()
+	s.bookmarks.bookmarks = snap.Bookmarks
+	if snap.NextBookmarkID > 0 {
+		s.bookmarks.nextID = snap.NextBookmarkID
+	}
+	s.bookmarks.mu.Unlock()
+
+	s.messages.mu.Lock()
+	s.messages.messages = snap.Messages
+	if snap.NextMessageID > 0 {
+		s.messages.nextID = snap.NextMessageID
+	}
+	s.messages.mu.Unlock()
+
+	s.media.mu.Lock()
+	for _, media := range snap.Media {
+		s.media.media[media.ID] = media
+	}
+	if snap.NextMediaID > 0 {
+		s.media.nextID = snap.NextMediaID
+	}
+	s.media.mu.Unlock()
+
+	s.activity.mu.Lock()
+	s.activity.activity = snap.Activity
+	if snap.NextActivityID > 0 {
+		s.activity.nextID = snap.NextActivityID
+	}
+	s.activity.mu.Unlock()
+
+	s.reports.mu.Lock()
+	s.reports.reports = snap.Reports
+	if snap.NextReportID > 0 {
+		s.reports.nextID = snap.NextReportID
+	}
+	s.reports.mu.Unlock()
+
+	s.moderation.mu.Lock()
+	s.moderation.decisions = snap.ModerationDecisions
+	if snap.NextModerationDecisionID > 0 {
+		s.moderation.nextID = snap.NextModerationDecisionID
+	}
+	s.moderation.mu.Unlock()
+
+	s.views.mu.Lock()
+	for _, daily := range snap.DailyViews {
+		if s.views.views[daily.PostID] == nil {
+			s.views.views[daily.PostID] = make(map[string]int64)
+		}
+		s.views.views[daily.PostID][daily.Day] = daily.Views
+	}
+	s.views.mu.Unlock()
+
+	s.blocks.mu.Lock()
+	s.blocks.blocks = snap.Blocks
+	if snap.NextBlockID > 0 {
+		s.blocks.nextID = snap.NextBlockID
+	}
+	s.blocks.mu.Unlock()
+
+	s.mentions.mu.Lock()
+	s.mentions.mentions = snap.Mentions
+	if snap.NextMentionID > 0 {
+		s.mentions.nextID = snap.NextMentionID
+	}
+	s.mentions.mu.Unlock()
+
+	s.polls.mu.Lock()
+	for _, poll := range snap.Polls {
+		s.polls.polls[poll.PostID] = poll
+	}
+	s.polls.votes = snap.PollVotes
+	if snap.NextPollID > 0 {
+		s.polls.nextID = snap.NextPollID
+	}
+	s.polls.mu.Unlock()
+
+	s.series.mu.Lock()
+	for _, series := range snap.Series {
+		s.series.series[series.ID] = series
+	}
+	s.series.entries = snap.SeriesEntries
+	if snap.NextSeriesID > 0 {
+		s.series.nextID = snap.NextSeriesID
+	}
+	s.series.mu.Unlock()
+
+	s.coAuthors.mu.Lock()
+	s.coAuthors.coAuthors = snap.CoAuthors
+	if snap.NextCoAuthorID > 0 {
+		s.coAuthors.nextID = snap.NextCoAuthorID
+	}
+	s.coAuthors.mu.Unlock()
+
+	s.engagement.mu.Lock()
+	for _, daily := range snap.DailyEngagement {
+		if s.engagement.counts[daily.PostID] == nil {
+			s.engagement.counts[daily.PostID] = make(map[string]DailyEngagement)
+		}
+		s.engagement.counts[daily.PostID][daily.Day] = daily
+	}
+	s.engagement.mu.Unlock()
+
+	s.settings.mu.Lock()
+	for _, settings := range snap.Settings {
+		s.settings.settings[settings.UserID] = settings
+	}
+	s.settings.mu.Unlock()
+
+	s.pins.mu.Lock()
+	s.pins.pins = snap.Pins
+	if snap.NextPinID > 0 {
+		s.pins.nextID = snap.NextPinID
+	}
+	s.pins.mu.Unlock()
+
+	return nil
+}
+
+// save writes the current state to the snapshot file. It writes to a
+// temporary file first so a crash mid-write never leaves a torn snapshot.
+func (s *snapshotter) save() error {
+	var snap memorySnapshot
+
+	s.users.mu.RLock()
+	for _, user := range s.users.users {
+		snap.Users = append(snap.Users, user)
+	}
+	snap.NextUserID = s.users.nextID
+	s.users.mu.RUnlock()
+
+	s.posts.mu.RLock()
+	for _, post := range s.posts.posts {
+		snap.Posts = append(snap.Posts, post)
+	}
+	snap.NextPostID = s.posts.nextID
+	s.posts.mu.RUnlock()
+
+	s.outbox.mu.Lock()
+	snap.Events = append(snap.Events, s.outbox.events...)
+	snap.NextEventID = s.outbox.nextID
+	s.outbox.mu.Unlock()
+
+	s.reactions.mu.RLock()
+	snap.Reactions = append(snap.Reactions, s.reactions.reactions...)
+	snap.NextReactionID = s.reactions.nextID
+	s.reactions.mu.RUnlock()
+
+	s.follows.mu.RLock()
+	snap.Follows = append(snap.Follows, s.follows.follows...)
+	snap.NextFollowID = s.follows.nextID
+	s.follows.mu.RUnlock()
+
+	s.bookmarks.mu.RLock()
+	snap.Bookmarks = append(snap.Bookmarks, s.bookmarks.bookmarks...)
+	snap.NextBookmarkID = s.bookmarks.nextID
+	s.bookmarks.mu.RUnlock()
+
+	s.messages.mu.RLock()
+	snap.Messages = append(snap.Messages, s.messages.messages...)
+	snap.NextMessageID = s.messages.nextID
+	s.messages.mu.RUnlock()
+
+	s.media.mu.RLock()
+	for _, media := range s.media.media {
+		snap.Media = append(snap.Media, media)
+	}
+	snap.NextMediaID = s.media.nextID
+	s.media.mu.RUnlock()
+
+	s.activity.mu.RLock()
+	snap.Activity = append(snap.Activity, s.activity.activity...)
+	snap.NextActivityID = s.activity.nextID
+	s.activity.mu.RUnlock()
+
+	s.reports.mu.RLock()
+	snap.Reports = append(snap.Reports, s.reports.reports...)
+	snap.NextReportID = s.reports.nextID
+	s.reports.mu.RUnlock()
+
+	s.moderation.mu.RLock()
+	snap.ModerationDecisions = append(snap.ModerationDecisions, s.moderation.decisions...)
+	snap.NextModerationDecisionID = s.moderation.nextID
+	s.moderation.mu.RUnlock()
+
+	s.views.mu.RLock()
+	for postID, days := range s.views.views {
+		for day, views := range days {
+			snap.DailyViews = append(snap.DailyViews, DailyViews{PostID: postID, Day: day, Views: views})
+		}
+	}
+	s.views.mu.RUnlock()
+
+	s.blocks.mu.RLock()
+	snap.Blocks = append(snap.Blocks, s.blocks.blocks...)
+	snap.NextBlockID = s.blocks.nextID
+	s.blocks.mu.RUnlock()
+
+	s.mentions.mu.RLock()
+	snap.Mentions = append(snap.Mentions, s.mentions.mentions...)
+	snap.NextMentionID = s.mentions.nextID
+	s.mentions.mu.RUnlock()
+
+	s.polls.mu.RLock()
+	for _, poll := range s.polls.polls {
+		snap.Polls = append(snap.Polls, poll)
+	}
+	snap.PollVotes = append(snap.PollVotes, s.polls.votes...)
+	snap.NextPollID = s.polls.nextID
+	s.polls.mu.RUnlock()
+
+	s.series.mu.RLock()
+	for _, series := range s.series.series {
+		snap.Series = append(snap.Series, series)
+	}
+	snap.SeriesEntries = append(snap.SeriesEntries, s.series.entries...)
+	snap.NextSeriesID = s.series.nextID
+	s.series.mu.RUnlock()
+
+	s.coAuthors.mu.RLock()
+	snap.CoAuthors = append(snap.CoAuthors, s.coAuthors.coAuthors...)
+	snap.NextCoAuthorID = s.coAuthors.nextID
+	s.coAuthors.mu.RUnlock()
+
+	s.engagement.mu.RLock()
+	for _, days := range s.engagement.counts {
+		for _, daily := range days {
+			snap.DailyEngagement = append(snap.DailyEngagement, daily)
+		}
+	}
+	s.engagement.mu.RUnlock()
+
+	s.settings.mu.RLock()
+	for _, settings := range s.settings.settings {
+		snap.Settings = append(snap.Settings, settings)
+	}
+	s.settings.mu.RUnlock()
+
+	s.pins.mu.RLock()
+	snap.Pins = append(snap.Pins, s.pins.pins...)
+	snap.NextPinID = s.pins.nextID
+	s.pins.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(&snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// run saves a snapshot every interval until ctx is done, then saves once more.
+func (s *snapshotter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.save(); err != nil {
+				log.Printf("failed to save snapshot: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.save(); err != nil {
+				log.Printf("failed to save snapshot: %v", err)
+			}
+		}
+	}
+}
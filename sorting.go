@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fields that ?sort= accepts on each list endpoint.
+var (
+	userSortFields = []string{"id", "username", "email", "created_at", "updated_at"}
+	postSortFields = []string{"id", "title", "author_id", "created_at", "updated_at"}
+)
+
+// parseSort reads ?sort=, a comma-separated list of fields each optionally
+// prefixed with "-" for descending order, e.g. "created_at,-title". Fields
+// must be in allowed. On invalid input it writes the error response and
+// returns ok == false.
+func parseSort(c *gin.Context, allowed []string) (fields []SortField, ok bool) {
+	var query struct {
+		Sort string `form:"sort"`
+	}
+	if !bindQuery(c, &query) {
+		return nil, false
+	}
+	if query.Sort == "" {
+		return nil, true
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(query.Sort, ",") {
+		field := SortField{Field: strings.TrimSpace(part)}
+		if strings.HasPrefix(field.Field, "-") {
+			field.Field, field.Desc = field.Field[1:], true
+		}
+		if !slices.Contains(allowed, field.Field) {
+			respondErrorDetails(c, http.StatusBadRequest, "Invalid query parameters", gin.H{
+				"errors":   []fieldError{{Field: "sort", Rule: "oneof", Message: "Cannot sort by " + strconv.Quote(field.Field)}},
+				"sortable": allowed,
+			})
+			return nil, false
+		}
+		if seen[field.Field] {
+			respondQueryErrors(c, fieldError{Field: "sort", Rule: "unique", Message: "Cannot sort by " + strconv.Quote(field.Field) + " twice"})
+			return nil, false
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+	return fields, true
+}
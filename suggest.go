@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSuggestions is how many post titles and usernames GET
+// /search/suggest returns by default; ?limit= can ask for up to 10 of each.
+const defaultSuggestions = 5
+
+// suggestQuery is the query string of GET /search/suggest.
+type suggestQuery struct {
+	Q     string `form:"q" binding:"required,max=100"`
+	Limit *int   `form:"limit" binding:"omitempty,min=1,max=10"`
+}
+
+// suggest serves GET /search/suggest?q=, for typeahead: the newest posts
+// whose title starts with q and the users whose username does, in any case.
+// Each is a single prefix query capped at a handful of rows, and
+// results carry only what a dropdown needs.
+func (a *API) suggest(c *gin.Context) {
+	var query suggestQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	prefix := strings.TrimSpace(query.Q)
+	if prefix == "" {
+		respondQueryErrors(c, fieldError{Field: "q", Rule: "required", Message: "q is required"})
+		return
+	}
+	limit := defaultSuggestions
+	if query.Limit != nil {
+		limit = *query.Limit
+	}
+
+	ctx := c.Request.Context()
+	filter := PostFilter{TitlePrefix: prefix}
+	hideUnlisted(c, &filter)
+	if !a.hideBlocked(c, &filter) {
+		return
+	}
+	posts, err := a.posts.List(ctx, ListOptions{
+		Posts: filter,
+		Sort:  []SortField{{Field: "created_at", Desc: true}},
+		Limit: limit,
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch suggestions")
+		return
+	}
+	users, err := a.users.List(ctx, ListOptions{
+		Users: UserFilter{UsernamePrefix: prefix},
+		Sort:  []SortField{{Field: "username"}},
+		Limit: limit,
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch suggestions")
+		return
+	}
+
+	respondCacheable(c, render(c, "", gin.H{
+		"query": prefix,
+		"posts": projectAll(fieldSet{"id", "title"}, posts),
+		"users": projectAll(fieldSet{"id", "username"}, users),
+	}, nil))
+}
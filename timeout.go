@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimeoutMessage is the message of 504 responses to requests that
+// ran out of time.
+const requestTimeoutMessage = "Request timed out"
+
+// limitRequestTime gives each request a deadline of timeout, or for the
+// routes in perRoute, keyed like "POST /posts/:id/media", their own, after
+// which its context is cancelled so that store calls and other downstream
+// work give up. A request that fails or writes nothing because of that gets
+// a 504 (see timedOut). A timeout of 0 or less disables the deadline.
+func limitRequestTime(timeout time.Duration, perRoute map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d, ok := perRoute[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			d = timeout
+		}
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if !c.Writer.Written() && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			abortWithError(c, http.StatusGatewayTimeout, requestTimeoutMessage)
+		}
+	}
+}
+
+// timedOut turns a server error response to a request whose deadline has
+// passed into a 504, since the failure was most likely the store call cut
+// short by it.
+func timedOut(c *gin.Context, status int, message string) (int, string) {
+	if status >= http.StatusInternalServerError && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, requestTimeoutMessage
+	}
+	return status, message
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gorm.io/gorm"
+)
+
+// serviceName names this service in traces unless OTEL_SERVICE_NAME is set.
+const serviceName = "gin-golang-api"
+
+// tracingEnabled is whether configureTracing set up an exporter.
+var tracingEnabled bool
+
+// configureTracing exports OpenTelemetry traces over OTLP/HTTP if
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set.
+// The exporter, sampler and resource are configured by the standard OTEL_*
+// variables. W3C traceparent and baggage headers are propagated either way.
+// The returned function flushes and stops the exporter.
+func configureTracing() func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		log.Fatalf("failed to configure trace exporter: %v", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		log.Fatalf("failed to configure trace resource: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	tracingEnabled = true
+	return provider.Shutdown
+}
+
+// traceRequests starts a span for each request, continuing the trace of an
+// inbound traceparent header.
+func traceRequests() gin.HandlerFunc {
+	if !tracingEnabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return otelgin.Middleware(serviceName)
+}
+
+// traceGorm adds a span for each query db runs.
+func traceGorm(db *gorm.DB) {
+	if !tracingEnabled {
+		return
+	}
+	if err := db.Use(otelgorm.NewPlugin()); err != nil {
+		log.Fatalf("failed to trace database queries: %v", err)
+	}
+}
+
+// traceMongo adds a span for each command the client runs.
+func traceMongo(opts *options.ClientOptions) {
+	if tracingEnabled {
+		opts.SetMonitor(otelmongo.NewMonitor())
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getUserTrash serves GET /users/trash, the soft-deleted users a page at a
+// time. It sorts and filters like GET /users.
+func (a *API) getUserTrash(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	sort, ok := parseSort(c, userSortFields)
+	if !ok {
+		return
+	}
+	filter, ok := parseUserFilter(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	opts := ListOptions{OnlyDeleted: true, Sort: sort, Users: filter}
+	total, err := a.users.Count(ctx, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	users, err := a.users.List(ctx, page.apply(opts))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "users", users, gin.H{
+		"count":      len(users),
+		"pagination": page.meta(total),
+	})
+}
+
+// restoreUser serves POST /users/:id/restore, undoing a soft delete.
+func (a *API) restoreUser(c *gin.Context) {
+	id, ok := a.userID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := a.users.GetIncludingDeleted(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	if !user.DeletedAt.Valid {
+		respondError(c, http.StatusConflict, "User is not in the trash")
+		return
+	}
+	auditBefore(c, user)
+
+	if err := a.users.Restore(ctx, id); err != nil {
+		respondStoreError(c, err, "user", "restore")
+		return
+	}
+	user, err = a.users.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	if !a.countUserFollows(c, &user) {
+		return
+	}
+
+	respond(c, http.StatusOK, "", user, nil)
+}
+
+// getPostTrash serves GET /posts/trash, the soft-deleted posts a page at a
+// time. Editors only see their own; admins see everyone's. It sorts and
+// filters like GET /posts.
+func (a *API) getPostTrash(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	sort, ok := parseSort(c, postSortFields)
+	if !ok {
+		return
+	}
+	filter, ok := parsePostFilter(c)
+	if !ok {
+		return
+	}
+	if user, _ := currentUser(c); !hasRole(user, RoleAdmin) {
+		filter.AuthorID, filter.AuthorUUID = user.ID, ""
+	}
+
+	ctx := c.Request.Context()
+	opts := ListOptions{OnlyDeleted: true, Sort: sort, Posts: filter}
+	total, err := a.posts.Count(ctx, opts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	posts, err := a.posts.List(ctx, page.apply(opts))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	respond(c, http.StatusOK, "posts", posts, gin.H{
+		"count":      len(posts),
+		"pagination": page.meta(total),
+	})
+}
+
+// restorePost serves POST /posts/:id/restore, undoing a soft delete. Like
+// deleting, it is open to the post's author and to admins.
+func (a *API) restorePost(c *gin.Context) {
+	id, ok := a.postID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	post, err := a.posts.GetIncludingDeleted(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+	if !a.canModifyPost(c, post) {
+		return
+	}
+	if !post.DeletedAt.Valid {
+		respondError(c, http.StatusConflict, "Post is not in the trash")
+		return
+	}
+
+	if err := a.posts.Restore(ctx, id); err != nil {
+		respondStoreError(c, err, "post", "restore")
+		return
+	}
+	post, err = a.posts.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "post", "fetch")
+		return
+	}
+
+	respond(c, http.StatusOK, "", post, nil)
+}
+
+// adminPurgeUser permanently deletes a user, whether or not they have been
+// soft-deleted. Users with posts, deleted or not, are refused so no post is
+// left without an author; purge the posts first.
+func (a *API) adminPurgeUser(c *gin.Context) {
+	id, ok := a.userID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := a.users.GetIncludingDeleted(ctx, id)
+	if err != nil {
+		respondStoreError(c, err, "user", "fetch")
+		return
+	}
+	auditBefore(c, user)
+	posts, err := a.posts.Count(ctx, ListOptions{IncludeDeleted: true, Posts: PostFilter{AuthorID: id}})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to count posts")
+		return
+	}
+	if posts > 0 {
+		respondErrorDetails(c, http.StatusConflict, "User still has posts", gin.H{"posts": posts})
+		return
+	}
+
+	if err := a.users.ForceDelete(ctx, id); err != nil {
+		respondStoreError(c, err, "user", "delete")
+		return
+	}
+
+	respond(c, http.StatusOK, "", gin.H{"message": "User permanently deleted"}, nil)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTrendingPosts caps how many posts the trending ranking keeps.
+const maxTrendingPosts = 100
+
+// trendingViewWeight is what a view adds to a post's trending score, relative
+// to a reaction.
+const trendingViewWeight = 0.1
+
+// trending ranks posts by recent engagement. The ranking is recomputed in
+// the background by run, so GET /posts/trending only reads it.
+//
+// Each reaction left and each view made within the window adds to its
+// post's score, weighted down by age so that it counts half as much every
+// half-life. Views are only counted by day, so they are aged from midday.
+type trending struct {
+	reactions ReactionRepository
+	views     ViewRepository
+	posts     PostRepository
+	window    time.Duration
+	halfLife  time.Duration
+
+	mu         sync.RWMutex
+	ranked     []uint
+	computedAt time.Time
+}
+
+// newTrending reads TRENDING_WINDOW and TRENDING_HALF_LIFE.
+func newTrending(reactions ReactionRepository, views ViewRepository, posts PostRepository) *trending {
+	return &trending{
+		reactions: reactions,
+		views:     views,
+		posts:     posts,
+		window:    envDuration("TRENDING_WINDOW", 72*time.Hour),
+		halfLife:  envDuration("TRENDING_HALF_LIFE", 12*time.Hour),
+	}
+}
+
+// run ranks posts straight away and then every interval until ctx is done.
+func (t *trending) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.refresh(ctx); err != nil {
+			log.Printf("trending: failed to rank posts: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh recomputes the ranking from the reactions and views within the
+// window. Only listed posts are ranked.
+func (t *trending) refresh(ctx context.Context) error {
+	now := time.Now()
+	since := now.Add(-t.window)
+	decay := func(at time.Time) float64 {
+		return math.Exp2(-max(now.Sub(at), 0).Hours() / t.halfLife.Hours())
+	}
+
+	reactions, err := t.reactions.Recent(ctx, since)
+	if err != nil {
+		return err
+	}
+	scores := make(map[uint]float64)
+	for _, reaction := range reactions {
+		scores[reaction.PostID] += decay(reaction.CreatedAt)
+	}
+	daily, err := t.views.Recent(ctx, since.UTC().Format(viewDay))
+	if err != nil {
+		return err
+	}
+	for _, views := range daily {
+		day, err := time.Parse(viewDay, views.Day)
+		if err != nil {
+			continue
+		}
+		scores[views.PostID] += trendingViewWeight * float64(views.Views) * decay(day.Add(12*time.Hour))
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	posts, err := t.posts.GetMany(ctx, ids)
+	if err != nil {
+		return err
+	}
+	ranked := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		if post.listed() {
+			ranked = append(ranked, post.ID)
+		}
+	}
+	// Ties go to the newer post.
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i]] != scores[ranked[j]] {
+			return scores[ranked[i]] > scores[ranked[j]]
+		}
+		return ranked[i] > ranked[j]
+	})
+	if len(ranked) > maxTrendingPosts {
+		ranked = ranked[:maxTrendingPosts]
+	}
+
+	t.mu.Lock()
+	t.ranked, t.computedAt = ranked, now
+	t.mu.Unlock()
+	return nil
+}
+
+// ranking returns the ranked post IDs and when they were ranked, which is
+// zero until the first run.
+func (t *trending) ranking() ([]uint, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.ranked, t.computedAt
+}
+
+// getTrendingPosts serves GET /posts/trending, the most engaged-with
+// published posts of late, a page at a time, highest score first. It takes
+// ?fields= and ?expand= like GET /posts.
+func (a *API) getTrendingPosts(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(c, postFields)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c, postExpansions)
+	if !ok {
+		return
+	}
+	var blocked PostFilter
+	if !a.hideBlocked(c, &blocked) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	ranked, computedAt := a.trending.ranking()
+	total := int64(len(ranked))
+	ids := paginate(ranked, page.apply(ListOptions{}))
+	found, err := a.posts.GetMany(ctx, ids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+
+	// Posts deleted, unpublished or hidden since the ranking are left out, as
+	// are those by users the caller blocked.
+	byID := make(map[uint]Post, len(found))
+	for _, post := range found {
+		byID[post.ID] = post
+	}
+	posts := []Post{}
+	for _, id := range ids {
+		if post, ok := byID[id]; ok && post.listed() && !slices.Contains(blocked.ExcludeAuthorIDs, post.AuthorID) {
+			posts = append(posts, post)
+		}
+	}
+	if expand["author"] {
+		if err := a.expandAuthors(ctx, posts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+			return
+		}
+	}
+	if !a.fillPostCounts(c, posts) {
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	var ranking interface{}
+	if !computedAt.IsZero() {
+		ranking = computedAt
+	}
+	respondCacheable(c, render(c, "posts", projectAll(fields, posts), gin.H{
+		"count":       len(posts),
+		"pagination":  page.meta(total),
+		"computed_at": ranking,
+	}))
+}
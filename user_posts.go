@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getUserPosts serves GET /users/:id/posts, the user's posts with the same
+// pagination, sorting and filters as GET /posts.
+func (a *API) getUserPosts(c *gin.Context) {
+	author, ok := a.pathUser(c)
+	if !ok {
+		return
+	}
+	a.listPosts(c, &author)
+}
+
+// createUserPost serves POST /users/:id/posts, which lets admins create a
+// post on behalf of the user.
+func (a *API) createUserPost(c *gin.Context) {
+	author, ok := a.pathUser(c)
+	if !ok {
+		return
+	}
+	a.createPostBy(c, author)
+}
+
+// pathUser loads the live user named by the :id path parameter, writing the
+// error response and returning ok == false if it can't.
+func (a *API) pathUser(c *gin.Context) (user User, ok bool) {
+	id, ok := a.userID(c)
+	if !ok {
+		return User{}, false
+	}
+
+	user, err := a.users.Get(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, http.StatusNotFound, "User not found")
+			return User{}, false
+		}
+		respondError(c, http.StatusInternalServerError, "Failed to fetch user")
+		return User{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation errors name fields as they appear in JSON, or for query
+// parameters, in the query string.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" {
+				name, _, _ = strings.Cut(field.Tag.Get("form"), ",")
+			}
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		v.RegisterValidation("public_id", func(fl validator.FieldLevel) bool {
+			return validPublicID(fl.Field().String())
+		})
+		v.RegisterValidation("filter_time", func(fl validator.FieldLevel) bool {
+			_, err := parseFilterTime(fl.Field().String())
+			return err == nil
+		})
+		v.RegisterValidation("web_url", func(fl validator.FieldLevel) bool {
+			u, err := url.Parse(fl.Field().String())
+			return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+		})
+	}
+}
+
+// fieldError describes why one field of a request body is invalid.
+type fieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// respondBindError writes the 400 response for an error from binding a
+// request body, listing each invalid field under details.errors, or 413 if
+// the body was too large.
+func respondBindError(c *gin.Context, err error) {
+	if bodyTooLarge(c, err) {
+		return
+	}
+	message, fields := describeBindError(err)
+	if fields == nil {
+		respondError(c, http.StatusBadRequest, message)
+		return
+	}
+	respondErrorDetails(c, http.StatusBadRequest, message, gin.H{"errors": fields})
+}
+
+// bindQuery binds the query string into obj, a pointer to a struct with
+// form tags. If a parameter doesn't parse or breaks a binding rule it
+// writes a 400 listing the invalid parameters under details.errors and
+// returns false.
+func bindQuery(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindQuery(obj)
+	if err == nil {
+		return true
+	}
+
+	var invalid validator.ValidationErrors
+	var fields []fieldError
+	if errors.As(err, &invalid) {
+		fields = describeValidationErrors(invalid)
+	} else {
+		fields = queryTypeErrors(c.Request.URL.Query(), obj)
+	}
+	if fields == nil {
+		respondError(c, http.StatusBadRequest, "Invalid query parameters")
+		return false
+	}
+	respondQueryErrors(c, fields...)
+	return false
+}
+
+// respondQueryErrors writes the 400 response for invalid query parameters.
+func respondQueryErrors(c *gin.Context, fields ...fieldError) {
+	respondErrorDetails(c, http.StatusBadRequest, "Invalid query parameters", gin.H{"errors": fields})
+}
+
+// queryTypeErrors finds the parameters in query that don't parse as the
+// type of obj's matching field, binding each on its own since the binding
+// error doesn't say which it was.
+func queryTypeErrors(query url.Values, obj interface{}) (fields []fieldError) {
+	t := reflect.TypeOf(obj).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		values, ok := query[name]
+		if !ok {
+			continue
+		}
+		probe := reflect.New(t).Interface()
+		if err := binding.MapFormWithTag(probe, map[string][]string{name: values}, "form"); err != nil {
+			fields = append(fields, fieldError{Field: name, Rule: "type", Message: name + " must be " + queryTypeName(field.Type)})
+		}
+	}
+	return fields
+}
+
+// queryTypeName describes the values a query parameter of type t accepts.
+func queryTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Bool:
+		return "true or false"
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "an RFC 3339 time"
+	}
+	return "a " + jsonTypeName(t)
+}
+
+// describeBindError explains a binding error, with the invalid fields if it
+// can tell which they are.
+func describeBindError(err error) (message string, fields []fieldError) {
+	var invalid validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &invalid):
+		return "Validation failed", describeValidationErrors(invalid)
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		return "Validation failed", []fieldError{{Field: field, Rule: "type", Message: field + " must be a " + jsonTypeName(typeErr.Type)}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "Request body must be valid JSON", nil
+	}
+	return err.Error(), nil
+}
+
+// describeValidationErrors lists the fields that broke a validation rule.
+func describeValidationErrors(invalid validator.ValidationErrors) (fields []fieldError) {
+	for _, fe := range invalid {
+		fields = append(fields, fieldError{Field: fe.Field(), Rule: fe.Tag(), Message: validationMessage(fe)})
+	}
+	return fields
+}
+
+// validationMessage renders a failed validation rule as a sentence about
+// the field.
+func validationMessage(fe validator.FieldError) string {
+	field, param := fe.Field(), fe.Param()
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " characters"
+	} else if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map {
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "url", "http_url", "web_url":
+		return field + " must be a valid URL"
+	case "oneof":
+		return field + " must be one of " + strings.ReplaceAll(param, " ", ", ")
+	case "min":
+		return field + " must be at least " + param + unit
+	case "max":
+		return field + " must be at most " + param + unit
+	case "len":
+		return field + " must be exactly " + param + unit
+	case "hostname_rfc1123":
+		return field + " must be a domain name, such as example.com"
+	case "public_id":
+		return field + " must be a valid ID"
+	case "filter_time":
+		return field + " must be a date (2006-01-02) or an RFC 3339 time"
+	}
+	return field + " failed the " + fe.Tag() + " rule"
+}
+
+// jsonTypeName names a Go type as the JSON type clients should send.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// expectedVersion returns the version the client believes it is updating,
+// given the version of the stored record. An If-Match header holding a
+// version number takes precedence over the version in the request body. An
+// If-Match entity tag or If-Unmodified-Since header, which
+// checkPreconditions has already checked, stands for the stored version. If
+// there is none of these it writes the error response and returns
+// ok == false.
+func expectedVersion(c *gin.Context, body *uint, stored uint) (version uint, ok bool) {
+	if header := c.GetHeader("If-Match"); header != "" {
+		if v, ok := versionTag(header); ok {
+			return v, true
+		}
+		return stored, true
+	}
+	if _, err := http.ParseTime(c.GetHeader("If-Unmodified-Since")); err == nil {
+		return stored, true
+	}
+
+	if body == nil {
+		respondError(c, http.StatusPreconditionRequired, "Version is required (send it in the body or an If-Match header)")
+		return 0, false
+	}
+	return *body, true
+}
+
+// versionTag parses an If-Match header holding a single version number,
+// such as "3".
+func versionTag(header string) (uint, bool) {
+	tag := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	v, err := strconv.ParseUint(strings.Trim(tag, `"`), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(v), true
+}
+
+// checkPostPreconditions runs checkPreconditions for a write to post, whose
+// ETag is that of GET /posts/:id without ?fields= or ?expand=.
+func (a *API) checkPostPreconditions(c *gin.Context, post Post) bool {
+	return checkPreconditions(c, func() (interface{}, bool) {
+		return a.renderPost(c, post, nil, nil)
+	}, post.UpdatedAt)
+}
+
+// checkUserPreconditions runs checkPreconditions for a write to user, whose
+// ETag is that of GET /users/:id without ?fields= or ?expand=.
+func (a *API) checkUserPreconditions(c *gin.Context, user User) bool {
+	return checkPreconditions(c, func() (interface{}, bool) {
+		return a.renderUser(c, user, nil, nil)
+	}, user.UpdatedAt)
+}
+
+// checkPreconditions evaluates the If-Match (other than a version number)
+// and If-Unmodified-Since headers of a write. representation renders the
+// record as GET would, for comparing with If-Match; it is only called if
+// that holds an entity tag, and writes the error response itself if it
+// fails. If either header fails it responds 412 Precondition Failed and
+// returns false.
+func checkPreconditions(c *gin.Context, representation func() (interface{}, bool), updatedAt time.Time) bool {
+	if header := c.GetHeader("If-Match"); header != "" {
+		if _, ok := versionTag(header); ok {
+			return true
+		}
+		body, ok := representation()
+		if !ok {
+			return false
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to render record")
+			return false
+		}
+		if !etagMatches(header, etag(data)) {
+			respondError(c, http.StatusPreconditionFailed, "The record has changed since it was fetched")
+			return false
+		}
+		// If-Unmodified-Since is ignored alongside If-Match (RFC 9110).
+		return true
+	}
+
+	since, err := http.ParseTime(c.GetHeader("If-Unmodified-Since"))
+	if err == nil && updatedAt.Truncate(time.Second).After(since) {
+		respondError(c, http.StatusPreconditionFailed, "The record has been modified since "+since.UTC().Format(http.TimeFormat))
+		return false
+	}
+	return true
+}
+
+// renderPost returns post as GET /posts/:id responds with it, given the
+// request's fields and expansions, so the ETag of the one can be checked
+// against the other. It writes the error response and returns ok == false
+// if filling in the post fails.
+func (a *API) renderPost(c *gin.Context, post Post, fields fieldSet, expand map[string]bool) (body interface{}, ok bool) {
+	if expand["author"] {
+		posts := []Post{post}
+		if err := a.expandAuthors(c.Request.Context(), posts); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch authors")
+			return nil, false
+		}
+		post = posts[0]
+	}
+	if !a.fillPostCount(c, &post) {
+		return nil, false
+	}
+	return render(c, "", fields.project(post), nil), true
+}
+
+// renderUser is renderPost for GET /users/:id.
+func (a *API) renderUser(c *gin.Context, user User, fields fieldSet, expand map[string]bool) (body interface{}, ok bool) {
+	if expand["posts"] {
+		users := []User{user}
+		if err := a.expandPosts(c.Request.Context(), users); err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to fetch posts")
+			return nil, false
+		}
+		user = users[0]
+	}
+	if !a.countUserFollows(c, &user) {
+		return nil, false
+	}
+	return render(c, "", fields.project(user), nil), true
+}
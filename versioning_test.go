@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestAPI returns an API over fresh in-memory storage, and an admin
+// user to make requests as.
+func newTestAPI(t *testing.T) (*API, User) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	a := NewAPI(newMemoryStorage(), newTokenIssuer(), logMailer{})
+
+	admin := User{Username: "admin", Email: "admin@example.com", Role: RoleAdmin}
+	if err := a.users.Create(context.Background(), &admin); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	return a, admin
+}
+
+// serve calls handler for a request to the :id route as user, with the
+// given JSON body and headers, and returns the response.
+func serve(handler gin.HandlerFunc, user User, method string, id uint, body string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/"+strconv.FormatUint(uint64(id), 10), strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		c.Request.Header[name] = values
+	}
+	c.Params = gin.Params{{Key: "id", Value: strconv.FormatUint(uint64(id), 10)}}
+	c.Set(contextUserKey, user)
+	handler(c)
+	return w
+}
+
+func createTestPost(t *testing.T, a *API, author User) Post {
+	t.Helper()
+	post := Post{Title: "Title", Content: "Content", AuthorID: author.ID, Status: PostPublished}
+	if err := a.posts.Create(context.Background(), &post); err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	return post
+}
+
+func TestVersionTag(t *testing.T) {
+	for header, want := range map[string]uint{`"3"`: 3, `W/"7"`: 7, ` 12 `: 12} {
+		if got, ok := versionTag(header); !ok || got != want {
+			t.Errorf("versionTag(%q) = %d, %v; want %d, true", header, got, ok, want)
+		}
+	}
+	for _, header := range []string{`"abc123"`, `"1", "2"`, `*`} {
+		if _, ok := versionTag(header); ok {
+			t.Errorf("versionTag(%q) ok; want not a version", header)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tag := etag([]byte(`{"id":1}`))
+	for _, header := range []string{tag, "W/" + tag, `"other", ` + tag, "*"} {
+		if !etagMatches(header, tag) {
+			t.Errorf("etagMatches(%q) = false; want true", header)
+		}
+	}
+	if etagMatches(`"other"`, tag) {
+		t.Error(`etagMatches("other") = true; want false`)
+	}
+}
+
+func TestUpdatePostVersion(t *testing.T) {
+	a, admin := newTestAPI(t)
+	post := createTestPost(t, a, admin)
+	stale := strconv.FormatUint(uint64(post.Version), 10)
+
+	w := serve(a.updatePost, admin, http.MethodPut, post.ID, `{"title":"New","content":"Content"}`, nil)
+	if w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("PUT without a version responded %d; want 428", w.Code)
+	}
+
+	w = serve(a.updatePost, admin, http.MethodPut, post.ID, `{"title":"New","content":"Content","version":`+stale+`}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT with the current version responded %d: %s", w.Code, w.Body)
+	}
+
+	w = serve(a.updatePost, admin, http.MethodPut, post.ID, `{"title":"Newer","content":"Content","version":`+stale+`}`, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("PUT with a stale version responded %d; want 409", w.Code)
+	}
+
+	// A version in If-Match takes precedence over the body.
+	w = serve(a.updatePost, admin, http.MethodPut, post.ID, `{"title":"Newer","content":"Content","version":`+stale+`}`,
+		http.Header{"If-Match": {`"` + strconv.FormatUint(uint64(post.Version+1), 10) + `"`}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT with the current version in If-Match responded %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestIfMatchPost(t *testing.T) {
+	a, admin := newTestAPI(t)
+	post := createTestPost(t, a, admin)
+
+	w := serve(a.getPost, admin, http.MethodGet, post.ID, "", nil)
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || tag == "" {
+		t.Fatalf("GET responded %d with ETag %q", w.Code, tag)
+	}
+
+	w = serve(a.updatePost, admin, http.MethodPut, post.ID, `{"title":"New","content":"Content"}`, http.Header{"If-Match": {tag}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT with the ETag from GET responded %d: %s", w.Code, w.Body)
+	}
+
+	w = serve(a.deletePost, admin, http.MethodDelete, post.ID, "", http.Header{"If-Match": {tag}})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE with an outdated ETag responded %d; want 412", w.Code)
+	}
+	if _, err := a.posts.Get(context.Background(), post.ID); err != nil {
+		t.Fatalf("post was deleted despite the failed precondition: %v", err)
+	}
+}
+
+func TestIfMatchUser(t *testing.T) {
+	a, admin := newTestAPI(t)
+	user := User{Username: "ada", Email: "ada@example.com", Role: RoleReader}
+	if err := a.users.Create(context.Background(), &user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	w := serve(a.getUser, admin, http.MethodGet, user.ID, "", nil)
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || tag == "" {
+		t.Fatalf("GET responded %d with ETag %q", w.Code, tag)
+	}
+
+	w = serve(a.deleteUser, admin, http.MethodDelete, user.ID, "", http.Header{"If-Match": {tag}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE with the ETag from GET responded %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestIfUnmodifiedSince(t *testing.T) {
+	a, admin := newTestAPI(t)
+	post := createTestPost(t, a, admin)
+
+	before := post.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	w := serve(a.updatePost, admin, http.MethodPut, post.ID, `{"title":"New","content":"Content"}`, http.Header{"If-Unmodified-Since": {before}})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PUT If-Unmodified-Since an earlier time responded %d; want 412", w.Code)
+	}
+
+	after := post.UpdatedAt.Add(time.Hour).UTC().Format(http.TimeFormat)
+	w = serve(a.updatePost, admin, http.MethodPut, post.ID, `{"title":"New","content":"Content"}`, http.Header{"If-Unmodified-Since": {after}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT If-Unmodified-Since a later time responded %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// viewDay is the layout of DailyViews.Day. Days are in UTC.
+const viewDay = "2006-01-02"
+
+// DailyViews counts the views of a post on one day.
+type DailyViews struct {
+	ID     uint   `json:"-" gorm:"primary_key" bson:"-"`
+	PostID uint   `json:"-" gorm:"not null;uniqueIndex:idx_daily_views_post_day,priority:1" bson:"post_id"`
+	Day    string `json:"day" gorm:"size:10;not null;uniqueIndex:idx_daily_views_post_day,priority:2;index" bson:"day"`
+	Views  int64  `json:"views" gorm:"not null;default:0" bson:"views"`
+}
+
+// ViewRepository stores post views as a count per post per day.
+type ViewRepository interface {
+	// Record counts a view of a post on day.
+	Record(ctx context.Context, postID uint, day string) error
+	// Counts totals the views of each of postIDs. Posts never viewed are
+	// missing from the result.
+	Counts(ctx context.Context, postIDs []uint) (map[uint]int64, error)
+	// Daily returns a post's counts from day from on, oldest first. Days
+	// without views are missing.
+	Daily(ctx context.Context, postID uint, from string) ([]DailyViews, error)
+	// Recent returns the counts of every post from day from on.
+	Recent(ctx context.Context, from string) ([]DailyViews, error)
+}
+
+// countView records that the caller viewed post, unless it is their own or
+// they already viewed it within VIEW_DEDUP_WINDOW. Viewers are told apart by
+// user, or by IP when anonymous, and counted in the rate limit store.
+// Failing to record is logged but doesn't fail the request.
+func (a *API) countView(c *gin.Context, post Post) {
+	viewer := "ip:" + c.ClientIP()
+	if user, ok := currentUser(c); ok {
+		if user.ID == post.AuthorID {
+			return
+		}
+		viewer = "user:" + strconv.FormatUint(uint64(user.ID), 10)
+	}
+
+	ctx := c.Request.Context()
+	key := "view:" + strconv.FormatUint(uint64(post.ID), 10) + ":" + viewer
+	count, _, err := a.rateLimits.store.Incr(ctx, key, a.viewDedup)
+	if err == nil && count > 1 {
+		return
+	}
+	if err == nil {
+		err = a.views.Record(ctx, post.ID, time.Now().UTC().Format(viewDay))
+	}
+	if err != nil {
+		logf(c.Request.Context(), "views: failed to count view of post %d: %v", post.ID, err)
+	}
+}
+
+// countViews fills in the view counts of posts.
+func (a *API) countViews(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	counts, err := a.views.Counts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].ViewsCount = counts[posts[i].ID]
+	}
+	return nil
+}
+
+// statsQuery is the query string of GET /posts/:id/stats.
+type statsQuery struct {
+	Days int `form:"days" binding:"omitempty,min=1,max=365"`
+}
+
+// getPostStats serves GET /posts/:id/stats to the post's author and admins:
+// its total views and its views on each of the last ?days= days (30 by
+// default), oldest first, today included.
+func (a *API) getPostStats(c *gin.Context) {
+	post, ok := a.modifiablePost(c)
+	if !ok {
+		return
+	}
+	query := statsQuery{Days: 30}
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, 1-query.Days)
+	daily, err := a.views.Daily(ctx, post.ID, from.Format(viewDay))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch views")
+		return
+	}
+	counts, err := a.views.Counts(ctx, []uint{post.ID})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to fetch views")
+		return
+	}
+
+	byDay := make(map[string]int64, len(daily))
+	for _, day := range daily {
+		byDay[day.Day] = day.Views
+	}
+	series := make([]DailyViews, 0, query.Days)
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		key := day.Format(viewDay)
+		series = append(series, DailyViews{Day: key, Views: byDay[key]})
+	}
+
+	respond(c, http.StatusOK, "", gin.H{
+		"views_count": counts[post.ID],
+		"daily_views": series,
+	}, nil)
+}
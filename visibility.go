@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Post visibility levels. Public posts are listed for everyone. Unlisted
+// posts can be fetched by anyone with their ID but are only listed for
+// their authors, and private posts are only shown to their authors, like
+// drafts. Admins see everything.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// visibility returns the post's visibility. Posts stored before visibility
+// existed, with none, count as public.
+func (p Post) visibility() string {
+	if p.Visibility == "" {
+		return VisibilityPublic
+	}
+	return p.Visibility
+}
+
+// listed reports whether the post shows in listings, search and feeds for
+// users other than its authors: it is published, public and not archived.
+func (p Post) listed() bool {
+	return p.Status != PostDraft && p.visibility() == VisibilityPublic && p.ArchivedAt == nil
+}
+
+// hideUnlisted narrows filter to listed posts and the caller's own, unless
+// the caller is an admin.
+func hideUnlisted(c *gin.Context, filter *PostFilter) {
+	user, ok := currentUser(c)
+	if ok && hasRole(user, RoleAdmin) {
+		return
+	}
+	filter.ListedOnly, filter.ListedFor = true, user.ID
+}